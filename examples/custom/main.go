@@ -34,20 +34,15 @@ func NewCustomLogHandler(filename string) (*CustomLogHandler, error) {
 	}, nil
 }
 
-// OnLog writes the log message to a file
+// OnLog writes the formatted log message to a file
 func (h *CustomLogHandler) OnLog(message klogstream.LogMessage) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	h.counter++
 
-	// Write a custom formatted line to the file
-	fmt.Fprintf(h.file, "[%d] %s - %s/%s: %s\n",
-		h.counter,
-		message.Timestamp.Format(time.RFC3339),
-		message.Namespace,
-		message.PodName,
-		message.Message)
+	// Write the line as rendered by the streamer's formatter
+	fmt.Fprintf(h.file, "[%d] %s\n", h.counter, message.Formatted)
 }
 
 // OnError handles errors
@@ -188,7 +183,7 @@ func main() {
 		WithPodRegex("kube-.*").               // Only pods starting with "kube-"
 		WithContainerRegex(".*").              // All containers
 		WithHandler(multiHandler).             // Use our multi-output handler
-		WithFormatter(&CustomJSONFormatter{}). // Use custom JSON formatter
+		WithFormatter(&CustomJSONFormatter{}). // JSON for the file, the template sees the original message
 		Build()
 
 	if err != nil {
@@ -61,6 +61,36 @@ func (b *LogFilterBuilder) Include(pattern string) *LogFilterBuilder {
 	return b
 }
 
+// Exclude sets the regex for log lines to drop, even if they match the include regex
+func (b *LogFilterBuilder) Exclude(pattern string) *LogFilterBuilder {
+	if pattern != "" {
+		regex, err := regexp.Compile(pattern)
+		if err == nil {
+			b.filter.ExcludeRegex = regex
+		}
+	}
+	return b
+}
+
+// ImageRegex sets the container image regex pattern
+func (b *LogFilterBuilder) ImageRegex(pattern string) *LogFilterBuilder {
+	if pattern != "" {
+		regex, err := regexp.Compile(pattern)
+		if err == nil {
+			b.filter.ImageRegex = regex
+		}
+	}
+	return b
+}
+
+// MinRestartCount sets the minimum container restart count
+func (b *LogFilterBuilder) MinRestartCount(count int32) *LogFilterBuilder {
+	if count >= 0 {
+		b.filter.MinRestartCount = count
+	}
+	return b
+}
+
 // Since sets the time to stream logs from
 func (b *LogFilterBuilder) Since(duration time.Duration) *LogFilterBuilder {
 	if duration >= 0 {
@@ -89,6 +89,7 @@ func TestLogFilterBuilder_Chaining(t *testing.T) {
 		ContainerRegex("web").
 		Label("app", "web").
 		Include("ERROR").
+		Exclude("healthz").
 		Since(30 * time.Minute).
 		ContainerState("running").
 		Namespace("default").
@@ -116,6 +117,10 @@ func TestLogFilterBuilder_Chaining(t *testing.T) {
 		t.Errorf("IncludeRegex not set correctly, got %v", filter.IncludeRegex)
 	}
 
+	if filter.ExcludeRegex == nil || filter.ExcludeRegex.String() != "healthz" {
+		t.Errorf("ExcludeRegex not set correctly, got %v", filter.ExcludeRegex)
+	}
+
 	if filter.Since == nil {
 		t.Errorf("Since not set correctly, got nil")
 	}
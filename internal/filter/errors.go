@@ -8,12 +8,20 @@ var (
 	ErrInvalidRegex = errors.New("invalid regular expression pattern")
 	// ErrInvalidSinceTime is returned when the since time is invalid
 	ErrInvalidSinceTime = errors.New("since time cannot be in the future")
+	// ErrInvalidTimeWindow is returned when the until time is in the future or not after the since time
+	ErrInvalidTimeWindow = errors.New("time window must end after it starts and cannot end in the future")
 	// ErrInvalidSinceDuration is returned when the since duration is invalid
 	ErrInvalidSinceDuration = errors.New("since duration cannot be negative")
+	// ErrInvalidTailLines is returned when the number of tail lines is negative
+	ErrInvalidTailLines = errors.New("tail lines cannot be negative")
 	// ErrInvalidContainerState is returned when the container state is invalid
 	ErrInvalidContainerState = errors.New("invalid container state, must be 'all', 'running', or 'terminated'")
 	// ErrEmptyFilter is returned when no filter criteria are provided
 	ErrEmptyFilter = errors.New("at least one filter criteria must be specified")
 	// ErrNoNamespaceSpecified is returned when no namespace is specified
 	ErrNoNamespaceSpecified = errors.New("no namespace specified")
+	// ErrInvalidLabelSelector is returned when a label selector string can't be parsed
+	ErrInvalidLabelSelector = errors.New("invalid label selector")
+	// ErrInvalidFieldSelector is returned when a field selector string can't be parsed
+	ErrInvalidFieldSelector = errors.New("invalid field selector")
 )
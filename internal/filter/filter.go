@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"time"
 
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
@@ -15,14 +16,39 @@ type LogFilter struct {
 	ContainerRegex *regexp.Regexp
 	// LabelSelector filters pods by their labels
 	LabelSelector labels.Selector
+	// AdditionalSelectors also match pods, a pod matching LabelSelector or any of them is
+	// streamed once. Each selector is listed and watched on its own.
+	AdditionalSelectors []labels.Selector
+	// FieldSelector filters pods by their fields, such as spec.nodeName or status.phase
+	FieldSelector fields.Selector
 	// IncludeRegex only includes log lines matching this regex
 	IncludeRegex *regexp.Regexp
+	// ExcludeRegex drops log lines matching this regex, even if they match IncludeRegex
+	ExcludeRegex *regexp.Regexp
+	// ImageRegex filters containers by image name
+	ImageRegex *regexp.Regexp
+	// MinRestartCount only includes containers that restarted at least this many times
+	MinRestartCount int32
 	// Since only includes logs newer than this time
 	Since *time.Time
+	// Until only includes logs up to this time, each container's log is read once and its
+	// stream ends at the first line logged after it
+	Until *time.Time
+	// TailLines, if set, starts each container's log with only this many of its most recent
+	// lines
+	TailLines *int64
 	// ContainerState filters by container state ("all", "running", "terminated", ...)
 	ContainerState string
+	// Previous streams the logs of the previous instance of each container, the one that
+	// ran before its last restart. Those logs don't grow, so they are read once instead of
+	// followed.
+	Previous bool
 	// Namespaces is a list of namespaces to filter logs from
 	Namespaces []string
+	// AllNamespaces streams the pods of every namespace, Namespaces is then ignored
+	AllNamespaces bool
+	// ExcludeNamespaces lists namespaces whose pods are never streamed, such as kube-system
+	ExcludeNamespaces []string
 }
 
 // DefaultContainerState is the default container state to filter by
@@ -40,10 +66,26 @@ func (f *LogFilter) IsEmpty() bool {
 	return f.PodNameRegex == nil &&
 		f.ContainerRegex == nil &&
 		f.LabelSelector == nil &&
+		len(f.AdditionalSelectors) == 0 &&
+		f.FieldSelector == nil &&
 		f.IncludeRegex == nil &&
+		f.ExcludeRegex == nil &&
+		f.ImageRegex == nil &&
+		f.MinRestartCount == 0 &&
 		f.Since == nil &&
+		f.Until == nil &&
 		(f.ContainerState == DefaultContainerState || f.ContainerState == "") &&
-		len(f.Namespaces) == 0
+		len(f.Namespaces) == 0 &&
+		!f.AllNamespaces
+}
+
+// MatchesLine reports whether a log line passes the include and exclude regexes. The
+// exclude regex wins over the include regex.
+func (f *LogFilter) MatchesLine(line string) bool {
+	if f.IncludeRegex != nil && !f.IncludeRegex.MatchString(line) {
+		return false
+	}
+	return f.ExcludeRegex == nil || !f.ExcludeRegex.MatchString(line)
 }
 
 // Validate checks if the filter is valid
@@ -52,7 +94,7 @@ func (f *LogFilter) Validate() error {
 		return ErrEmptyFilter
 	}
 
-	if len(f.Namespaces) == 0 {
+	if len(f.Namespaces) == 0 && !f.AllNamespaces {
 		return ErrNoNamespaceSpecified
 	}
 
@@ -67,5 +109,13 @@ func (f *LogFilter) Validate() error {
 		return ErrInvalidSinceTime
 	}
 
+	if f.Until != nil && (f.Until.After(time.Now()) || (f.Since != nil && !f.Since.Before(*f.Until))) {
+		return ErrInvalidTimeWindow
+	}
+
+	if f.TailLines != nil && *f.TailLines < 0 {
+		return ErrInvalidTailLines
+	}
+
 	return nil
 }
@@ -48,6 +48,9 @@ func TestLogFilter_IsEmpty(t *testing.T) {
 
 func TestLogFilter_Validate(t *testing.T) {
 	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	negative := int64(-1)
 	selector := labels.SelectorFromSet(labels.Set{"app": "test"})
 
 	tests := []struct {
@@ -86,6 +89,54 @@ func TestLogFilter_Validate(t *testing.T) {
 			},
 			wantErr: ErrInvalidSinceTime,
 		},
+		{
+			name: "future until time",
+			filter: &LogFilter{
+				Namespaces:     []string{"default"},
+				ContainerState: "all",
+				Since:          &past,
+				Until:          &future,
+			},
+			wantErr: ErrInvalidTimeWindow,
+		},
+		{
+			name: "until time before since time",
+			filter: &LogFilter{
+				Namespaces:     []string{"default"},
+				ContainerState: "all",
+				Since:          &recent,
+				Until:          &past,
+			},
+			wantErr: ErrInvalidTimeWindow,
+		},
+		{
+			name: "time window",
+			filter: &LogFilter{
+				Namespaces:     []string{"default"},
+				ContainerState: "all",
+				Since:          &past,
+				Until:          &recent,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "all namespaces",
+			filter: &LogFilter{
+				PodNameRegex:   regexp.MustCompile("test"),
+				ContainerState: "all",
+				AllNamespaces:  true,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "negative tail lines",
+			filter: &LogFilter{
+				Namespaces:     []string{"default"},
+				ContainerState: "all",
+				TailLines:      &negative,
+			},
+			wantErr: ErrInvalidTailLines,
+		},
 		{
 			name: "valid filter",
 			filter: &LogFilter{
@@ -109,3 +160,36 @@ func TestLogFilter_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestLogFilter_MatchesLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		line    string
+		want    bool
+	}{
+		{name: "no regex", line: "GET /healthz", want: true},
+		{name: "included", include: "ERROR", line: "ERROR failed", want: true},
+		{name: "not included", include: "ERROR", line: "INFO ready", want: false},
+		{name: "excluded", exclude: "healthz", line: "GET /healthz", want: false},
+		{name: "not excluded", exclude: "healthz", line: "GET /orders", want: true},
+		{name: "exclude wins over include", include: "ERROR", exclude: "healthz", line: "ERROR /healthz timed out", want: false},
+		{name: "included and not excluded", include: "ERROR", exclude: "healthz", line: "ERROR /orders timed out", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewLogFilter()
+			if tt.include != "" {
+				f.IncludeRegex = regexp.MustCompile(tt.include)
+			}
+			if tt.exclude != "" {
+				f.ExcludeRegex = regexp.MustCompile(tt.exclude)
+			}
+			if got := f.MatchesLine(tt.line); got != tt.want {
+				t.Errorf("MatchesLine(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package formatter
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
 	"time"
 )
 
@@ -12,6 +14,15 @@ type JSONFormatter struct {
 	IncludeNamespace     bool
 	IncludePodName       bool
 	IncludeContainerName bool
+	IncludeNodeName      bool
+	// TruncateTo truncates the timestamp to this precision before formatting, zero keeps full precision
+	TruncateTo time.Duration
+	// Pretty indents the JSON over several lines for human reading. The output is no longer
+	// one object per line, so it must not be used for NDJSON consumers.
+	Pretty bool
+	// StaticFields are added to every JSON object after the log fields, sorted by key.
+	// Keys of the log fields, such as "message" or "pod_name", are reserved and skipped.
+	StaticFields map[string]string
 }
 
 // JSONLogEntry represents a log entry in JSON format
@@ -20,6 +31,11 @@ type JSONLogEntry struct {
 	Namespace     string `json:"namespace,omitempty"`
 	PodName       string `json:"pod_name,omitempty"`
 	ContainerName string `json:"container_name,omitempty"`
+	NodeName      string `json:"node_name,omitempty"`
+	StreamLabel   string `json:"stream_label,omitempty"`
+	LineNumber    int64  `json:"line_number,omitempty"`
+	Stream        string `json:"stream,omitempty"`
+	IsEvent       bool   `json:"is_event,omitempty"`
 	Message       string `json:"message"`
 }
 
@@ -36,11 +52,15 @@ func NewJSONFormatter() *JSONFormatter {
 // Format converts a LogMessage to a JSON string
 func (f *JSONFormatter) Format(msg LogMessage) string {
 	entry := JSONLogEntry{
-		Message: msg.Message,
+		StreamLabel: msg.StreamLabel,
+		LineNumber:  msg.LineNumber,
+		Stream:      msg.Stream,
+		IsEvent:     msg.IsEvent,
+		Message:     msg.Message,
 	}
 
 	if f.IncludeTimestamp {
-		entry.Timestamp = msg.Timestamp.Format(time.RFC3339)
+		entry.Timestamp = truncateTimestamp(msg.Timestamp, f.TruncateTo).Format(time.RFC3339)
 	}
 
 	if f.IncludeNamespace {
@@ -55,11 +75,66 @@ func (f *JSONFormatter) Format(msg LogMessage) string {
 		entry.ContainerName = msg.ContainerName
 	}
 
+	if f.IncludeNodeName {
+		entry.NodeName = msg.NodeName
+	}
+
 	data, err := json.Marshal(entry)
 	if err != nil {
 		// Fallback in case of marshaling error
 		return msg.Message
 	}
 
+	data = appendStaticFields(data, f.StaticFields)
+
+	if f.Pretty {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", "  "); err == nil {
+			data = indented.Bytes()
+		}
+	}
+
 	return string(data)
 }
+
+// reservedJSONKeys are the keys of JSONLogEntry, static fields can't replace them
+var reservedJSONKeys = map[string]bool{
+	"timestamp":      true,
+	"namespace":      true,
+	"pod_name":       true,
+	"container_name": true,
+	"node_name":      true,
+	"stream_label":   true,
+	"line_number":    true,
+	"stream":         true,
+	"is_event":       true,
+	"message":        true,
+}
+
+// appendStaticFields adds the fields to the encoded JSON object in key order, so the output
+// is the same for every message
+func appendStaticFields(data []byte, fields map[string]string) []byte {
+	if len(fields) == 0 {
+		return data
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !reservedJSONKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// Reopen the object by dropping its closing brace
+	out := append([]byte(nil), data[:len(data)-1]...)
+	for _, key := range keys {
+		name, _ := json.Marshal(key)
+		value, _ := json.Marshal(fields[key])
+		out = append(out, ',')
+		out = append(out, name...)
+		out = append(out, ':')
+		out = append(out, value...)
+	}
+	return append(out, '}')
+}
@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -98,3 +99,129 @@ func TestJSONFormatter_Format(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONFormatter_TruncateTo(t *testing.T) {
+	msg := LogMessage{
+		Timestamp: time.Date(2023, 1, 1, 12, 0, 59, 999999999, time.UTC),
+		Message:   "Test message",
+	}
+
+	formatter := &JSONFormatter{
+		IncludeTimestamp: true,
+		TruncateTo:       time.Minute,
+	}
+
+	var entry JSONLogEntry
+	if err := json.Unmarshal([]byte(formatter.Format(msg)), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	if want := "2023-01-01T12:00:00Z"; entry.Timestamp != want {
+		t.Errorf("timestamp = %q, want %q", entry.Timestamp, want)
+	}
+}
+
+func TestJSONFormatter_LineNumber(t *testing.T) {
+	formatter := NewJSONFormatter()
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(formatter.Format(LogMessage{LineNumber: 42, Message: "Test message"})), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if entry["line_number"] != float64(42) {
+		t.Errorf("line_number = %v, want 42", entry["line_number"])
+	}
+
+	entry = nil
+	if err := json.Unmarshal([]byte(formatter.Format(LogMessage{Message: "Test message"})), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if _, ok := entry["line_number"]; ok {
+		t.Error("line_number included for an unnumbered message")
+	}
+}
+
+func TestJSONFormatter_NodeName(t *testing.T) {
+	formatter := NewJSONFormatter()
+	msg := LogMessage{NodeName: "worker-1", Message: "Test message"}
+
+	if got := formatter.Format(msg); strings.Contains(got, `"node_name"`) {
+		t.Errorf("node_name included by default: %s", got)
+	}
+
+	formatter.IncludeNodeName = true
+	var entry JSONLogEntry
+	if err := json.Unmarshal([]byte(formatter.Format(msg)), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if entry.NodeName != "worker-1" {
+		t.Errorf("node_name = %q, want %q", entry.NodeName, "worker-1")
+	}
+}
+
+func TestJSONFormatter_Stream(t *testing.T) {
+	formatter := NewJSONFormatter()
+
+	var entry JSONLogEntry
+	if err := json.Unmarshal([]byte(formatter.Format(LogMessage{Stream: "stderr", Message: "Test message"})), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if entry.Stream != "stderr" {
+		t.Errorf("stream = %q, want %q", entry.Stream, "stderr")
+	}
+
+	if got := formatter.Format(LogMessage{Message: "Test message"}); strings.Contains(got, `"stream"`) {
+		t.Errorf("stream included for a message of unknown stream: %s", got)
+	}
+}
+
+func TestJSONFormatter_Pretty(t *testing.T) {
+	formatter := NewJSONFormatter()
+	msg := LogMessage{PodName: "web-1", Message: "Test message"}
+
+	// Compact output is a single line, as NDJSON consumers expect
+	if got := formatter.Format(msg); strings.Contains(got, "\n") {
+		t.Errorf("compact output spans several lines: %q", got)
+	}
+
+	formatter.Pretty = true
+	got := formatter.Format(msg)
+	if !strings.Contains(got, "\n  \"pod_name\": \"web-1\"") {
+		t.Errorf("pretty output is not indented: %q", got)
+	}
+
+	var entry JSONLogEntry
+	if err := json.Unmarshal([]byte(got), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if entry.Message != "Test message" {
+		t.Errorf("message = %q, want %q", entry.Message, "Test message")
+	}
+}
+
+func TestJSONFormatter_StaticFields(t *testing.T) {
+	formatter := NewJSONFormatter()
+	formatter.IncludeTimestamp = false
+	formatter.StaticFields = map[string]string{
+		"service":  "checkout",
+		"env":      "prod",
+		"message":  "overridden",
+		"pod_name": "overridden",
+	}
+
+	got := formatter.Format(LogMessage{PodName: "web-1", Message: "Test message"})
+	want := `{"pod_name":"web-1","message":"Test message","env":"prod","service":"checkout"}`
+	if got != want {
+		t.Errorf("Format() = %s, want %s", got, want)
+	}
+
+	// Static fields are kept in indented output
+	formatter.Pretty = true
+	var entry map[string]string
+	if err := json.Unmarshal([]byte(formatter.Format(LogMessage{Message: "Test message"})), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if entry["env"] != "prod" || entry["message"] != "Test message" {
+		t.Errorf("pretty output = %v, want env prod and the original message", entry)
+	}
+}
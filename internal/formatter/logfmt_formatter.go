@@ -0,0 +1,85 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtFormatter formats log messages as logfmt key=value pairs
+type LogfmtFormatter struct {
+	// Fields to include in the logfmt output
+	IncludeTimestamp     bool
+	IncludeNamespace     bool
+	IncludePodName       bool
+	IncludeContainerName bool
+	// TruncateTo truncates the timestamp to this precision before formatting, zero keeps full precision
+	TruncateTo time.Duration
+}
+
+// NewLogfmtFormatter creates a new LogfmtFormatter with default settings
+func NewLogfmtFormatter() *LogfmtFormatter {
+	return &LogfmtFormatter{
+		IncludeTimestamp:     true,
+		IncludeNamespace:     true,
+		IncludePodName:       true,
+		IncludeContainerName: true,
+	}
+}
+
+// Format converts a LogMessage to a logfmt line. The keys are those of the JSON format,
+// and empty optional fields are left out like there.
+func (f *LogfmtFormatter) Format(msg LogMessage) string {
+	var b strings.Builder
+
+	add := func(key, value string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(value))
+	}
+
+	if f.IncludeTimestamp {
+		add("timestamp", truncateTimestamp(msg.Timestamp, f.TruncateTo).Format(time.RFC3339))
+	}
+	if f.IncludeNamespace && msg.Namespace != "" {
+		add("namespace", msg.Namespace)
+	}
+	if f.IncludePodName && msg.PodName != "" {
+		add("pod_name", msg.PodName)
+	}
+	if f.IncludeContainerName && msg.ContainerName != "" {
+		add("container_name", msg.ContainerName)
+	}
+	if msg.StreamLabel != "" {
+		add("stream_label", msg.StreamLabel)
+	}
+	if msg.LineNumber > 0 {
+		add("line_number", strconv.FormatInt(msg.LineNumber, 10))
+	}
+	if msg.Stream != "" {
+		add("stream", msg.Stream)
+	}
+	if msg.IsEvent {
+		add("is_event", "true")
+	}
+	add("message", msg.Message)
+
+	return b.String()
+}
+
+// logfmtValue quotes a value that is empty or contains spaces, quotes, equal signs or
+// control characters, so the line splits back into the same pairs
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
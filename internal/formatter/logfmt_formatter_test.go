@@ -0,0 +1,58 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogfmtFormatter_Format(t *testing.T) {
+	fixedTime := time.Date(2023, 4, 15, 12, 34, 56, 0, time.UTC)
+
+	msg := LogMessage{
+		Namespace:     "default",
+		PodName:       "test-pod",
+		ContainerName: "test-container",
+		Timestamp:     fixedTime,
+		Message:       "Test message",
+	}
+
+	tests := []struct {
+		name      string
+		formatter *LogfmtFormatter
+		msg       LogMessage
+		expected  string
+	}{
+		{
+			name:      "all fields",
+			formatter: NewLogfmtFormatter(),
+			msg:       msg,
+			expected:  `timestamp=2023-04-15T12:34:56Z namespace=default pod_name=test-pod container_name=test-container message="Test message"`,
+		},
+		{
+			name:      "message only",
+			formatter: &LogfmtFormatter{},
+			msg:       LogMessage{Message: "ready"},
+			expected:  `message=ready`,
+		},
+		{
+			name:      "quoted values",
+			formatter: &LogfmtFormatter{},
+			msg:       LogMessage{StreamLabel: "web", Stream: "stderr", Message: `key="value"` + "\tnext"},
+			expected:  `stream_label=web stream=stderr message="key=\"value\"\tnext"`,
+		},
+		{
+			name:      "empty message",
+			formatter: &LogfmtFormatter{},
+			msg:       LogMessage{LineNumber: 3, IsEvent: true},
+			expected:  `line_number=3 is_event=true message=""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.formatter.Format(tt.msg); got != tt.expected {
+				t.Errorf("Format() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"fmt"
+	"hash/fnv"
 	"time"
 )
 
@@ -13,6 +14,16 @@ type LogMessage struct {
 	PodName string
 	// ContainerName is the name of the container within the pod
 	ContainerName string
+	// NodeName is the name of the node the pod is scheduled on
+	NodeName string
+	// StreamLabel is the user-defined label of the streamer that produced the message
+	StreamLabel string
+	// LineNumber is the message's sequence number within its container, when line numbering is enabled
+	LineNumber int64
+	// Stream is the container output the message was written to, "stdout" or "stderr", or empty if unknown
+	Stream string
+	// IsEvent marks a message generated by the streamer rather than read from the container's log
+	IsEvent bool
 	// Timestamp is the time when the log message was created
 	Timestamp time.Time
 	// Message is the log content
@@ -31,10 +42,23 @@ type TextFormatter struct {
 	ShowPodName bool
 	// ShowContainerName controls whether to display the container name
 	ShowContainerName bool
+	// ShowNodeName controls whether to display the node name after the container name
+	ShowNodeName bool
+	// ShowStreamLabel controls whether to display the stream label when one is set
+	ShowStreamLabel bool
+	// ShowLineNumber controls whether to display the line number when one is set
+	ShowLineNumber bool
 	// TimestampFormat defines the format for timestamps
 	TimestampFormat string
+	// TruncateTo truncates the timestamp to this precision before formatting, zero keeps full precision
+	TruncateTo time.Duration
 	// ColorOutput enables colorized output
 	ColorOutput bool
+	// ColorByPod colors the prefix of each pod with its own color from Palette, so the
+	// lines of different pods are told apart. Lines written to stderr stay red.
+	ColorByPod bool
+	// Palette holds the ANSI color codes pods are colored with, DefaultColorPalette if empty
+	Palette []string
 }
 
 // ColorMap defines ANSI color codes for colorized output
@@ -58,6 +82,21 @@ var ColorMap = map[string]string{
 	"boldWhite":   "\033[1;37m",
 }
 
+// DefaultColorPalette holds the colors pods are colored with by default, leaving out red
+// for stderr and the colors that are hard to read on common terminal backgrounds
+var DefaultColorPalette = []string{
+	ColorMap["cyan"],
+	ColorMap["green"],
+	ColorMap["yellow"],
+	ColorMap["blue"],
+	ColorMap["magenta"],
+	ColorMap["boldCyan"],
+	ColorMap["boldGreen"],
+	ColorMap["boldYellow"],
+	ColorMap["boldBlue"],
+	ColorMap["boldMagenta"],
+}
+
 // DefaultTimestampFormat is the default format for timestamps
 const DefaultTimestampFormat = time.RFC3339
 
@@ -68,6 +107,8 @@ func NewTextFormatter() *TextFormatter {
 		ShowNamespace:     true,
 		ShowPodName:       true,
 		ShowContainerName: true,
+		ShowStreamLabel:   true,
+		ShowLineNumber:    true,
 		TimestampFormat:   DefaultTimestampFormat,
 		ColorOutput:       true,
 	}
@@ -78,7 +119,15 @@ func (f *TextFormatter) Format(msg LogMessage) string {
 	var prefix string
 
 	if f.ShowTimestamp {
-		prefix += fmt.Sprintf("%s ", msg.Timestamp.Format(f.TimestampFormat))
+		prefix += fmt.Sprintf("%s ", truncateTimestamp(msg.Timestamp, f.TruncateTo).Format(f.TimestampFormat))
+	}
+
+	if f.ShowStreamLabel && msg.StreamLabel != "" {
+		prefix += fmt.Sprintf("(%s) ", msg.StreamLabel)
+	}
+
+	if f.ShowLineNumber && msg.LineNumber > 0 {
+		prefix += fmt.Sprintf("#%d ", msg.LineNumber)
 	}
 
 	if f.ShowNamespace {
@@ -93,13 +142,46 @@ func (f *TextFormatter) Format(msg LogMessage) string {
 		prefix += fmt.Sprintf("/%s", msg.ContainerName)
 	}
 
+	if f.ShowNodeName && msg.NodeName != "" {
+		prefix += fmt.Sprintf("@%s", msg.NodeName)
+	}
+
 	if prefix != "" {
 		if f.ColorOutput {
-			// Color the prefix with cyan
-			prefix = ColorMap["cyan"] + prefix + ColorMap["reset"]
+			// Color the prefix with cyan or the pod's color, or red for stderr so errors stand out
+			color := ColorMap["cyan"]
+			if f.ColorByPod {
+				color = f.podColor(msg)
+			}
+			if msg.Stream == "stderr" {
+				color = ColorMap["red"]
+			}
+			prefix = color + prefix + ColorMap["reset"]
 		}
 		prefix += ": "
 	}
 
 	return prefix + msg.Message
 }
+
+// podColor picks the pod's color from the palette by a hash of its namespace and name, so
+// a pod keeps its color for the whole capture and across runs
+func (f *TextFormatter) podColor(msg LogMessage) string {
+	palette := f.Palette
+	if len(palette) == 0 {
+		palette = DefaultColorPalette
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(msg.Namespace + "/" + msg.PodName))
+	return palette[hash.Sum32()%uint32(len(palette))]
+}
+
+// truncateTimestamp rounds the timestamp down to the given precision, e.g. time.Second or
+// time.Millisecond, so layouts with fractional seconds don't print noisy nanoseconds
+func truncateTimestamp(t time.Time, precision time.Duration) time.Time {
+	if precision <= 0 {
+		return t
+	}
+	return t.Truncate(precision)
+}
@@ -1,6 +1,7 @@
 package formatter
 
 import (
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -131,3 +132,184 @@ func TestTextFormatter_Format(t *testing.T) {
 		})
 	}
 }
+
+func TestTextFormatter_StreamLabel(t *testing.T) {
+	msg := LogMessage{
+		PodName:       "test-pod",
+		ContainerName: "test-container",
+		StreamLabel:   "errors-only",
+		Message:       "Test message",
+	}
+
+	formatter := &TextFormatter{
+		ShowPodName:       true,
+		ShowContainerName: true,
+		ShowStreamLabel:   true,
+	}
+
+	want := "(errors-only) test-pod/test-container: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() = %q, want %q", got, want)
+	}
+
+	formatter.ShowStreamLabel = false
+	want = "test-pod/test-container: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() without label = %q, want %q", got, want)
+	}
+}
+
+func TestTextFormatter_NodeName(t *testing.T) {
+	msg := LogMessage{
+		PodName:       "test-pod",
+		ContainerName: "test-container",
+		NodeName:      "worker-1",
+		Message:       "Test message",
+	}
+
+	formatter := &TextFormatter{ShowPodName: true, ShowContainerName: true}
+	want := "test-pod/test-container: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() by default = %q, want %q", got, want)
+	}
+
+	formatter.ShowNodeName = true
+	want = "test-pod/test-container@worker-1: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() = %q, want %q", got, want)
+	}
+
+	// A message without a node, such as one of an unscheduled pod, shows nothing
+	msg.NodeName = ""
+	want = "test-pod/test-container: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() without node = %q, want %q", got, want)
+	}
+}
+
+func TestTextFormatter_LineNumber(t *testing.T) {
+	formatter := &TextFormatter{
+		ShowPodName:     true,
+		ShowStreamLabel: true,
+		ShowLineNumber:  true,
+	}
+
+	msg := LogMessage{PodName: "test-pod", StreamLabel: "api", LineNumber: 4521, Message: "Test message"}
+	want := "(api) #4521 test-pod: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() = %q, want %q", got, want)
+	}
+
+	// Messages from a streamer without numbering have no line number to show
+	msg.LineNumber = 0
+	want = "(api) test-pod: Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() without number = %q, want %q", got, want)
+	}
+}
+
+func TestTextFormatter_StderrColor(t *testing.T) {
+	formatter := &TextFormatter{ShowPodName: true, ColorOutput: true}
+
+	msg := LogMessage{PodName: "test-pod", Stream: "stderr", Message: "Test message"}
+	want := ColorMap["red"] + "test-pod" + ColorMap["reset"] + ": Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() = %q, want %q", got, want)
+	}
+
+	msg.Stream = "stdout"
+	want = ColorMap["cyan"] + "test-pod" + ColorMap["reset"] + ": Test message"
+	if got := formatter.Format(msg); got != want {
+		t.Errorf("TextFormatter.Format() for stdout = %q, want %q", got, want)
+	}
+}
+
+func TestTextFormatter_ColorByPod(t *testing.T) {
+	palette := []string{"\033[38;5;208m", "\033[38;5;141m", "\033[38;5;39m"}
+	formatter := &TextFormatter{ShowPodName: true, ColorOutput: true, ColorByPod: true, Palette: palette}
+
+	colorOf := func(f *TextFormatter, pod string) string {
+		got := f.Format(LogMessage{Namespace: "default", PodName: pod, Message: "hello"})
+		return got[:strings.Index(got, pod)]
+	}
+
+	// Every pod keeps the same color from the palette
+	used := make(map[string]bool)
+	for _, pod := range []string{"web-1", "web-2", "api-1", "api-2", "worker-1"} {
+		color := colorOf(formatter, pod)
+		if !slices.Contains(palette, color) {
+			t.Errorf("pod %s colored %q, want a color of the palette", pod, color)
+		}
+		if again := colorOf(formatter, pod); again != color {
+			t.Errorf("pod %s colored %q then %q", pod, color, again)
+		}
+		used[color] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("all pods got the same color %v", used)
+	}
+
+	// An empty palette falls back to the default one
+	formatter.Palette = nil
+	if color := colorOf(formatter, "web-1"); !slices.Contains(DefaultColorPalette, color) {
+		t.Errorf("pod colored %q without a palette, want a color of the default palette", color)
+	}
+
+	// Stderr stays red
+	got := formatter.Format(LogMessage{PodName: "web-1", Stream: "stderr", Message: "hello"})
+	if !strings.HasPrefix(got, ColorMap["red"]) {
+		t.Errorf("stderr line = %q, want it red", got)
+	}
+}
+
+func TestTextFormatter_TruncateTo(t *testing.T) {
+	msg := LogMessage{
+		Timestamp: time.Date(2023, 1, 1, 12, 0, 0, 123456789, time.UTC),
+		Message:   "Test message",
+	}
+
+	tests := []struct {
+		name       string
+		layout     string
+		truncateTo time.Duration
+		want       string
+	}{
+		{
+			name:   "full precision",
+			layout: time.RFC3339Nano,
+			want:   "2023-01-01T12:00:00.123456789Z : Test message",
+		},
+		{
+			name:       "second with nano layout",
+			layout:     time.RFC3339Nano,
+			truncateTo: time.Second,
+			want:       "2023-01-01T12:00:00Z : Test message",
+		},
+		{
+			name:       "second with fixed fraction layout",
+			layout:     "15:04:05.000000000",
+			truncateTo: time.Second,
+			want:       "12:00:00.000000000 : Test message",
+		},
+		{
+			name:       "millisecond",
+			layout:     time.RFC3339Nano,
+			truncateTo: time.Millisecond,
+			want:       "2023-01-01T12:00:00.123Z : Test message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := &TextFormatter{
+				ShowTimestamp:   true,
+				TimestampFormat: tt.layout,
+				TruncateTo:      tt.truncateTo,
+			}
+
+			if got := formatter.Format(msg); got != tt.want {
+				t.Errorf("TextFormatter.Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultArchivePrefix is the default file name prefix of archives
+const DefaultArchivePrefix = "klogstream"
+
+// ArchiveConfig configures where an ArchiveHandler writes archives and when it rotates them
+type ArchiveConfig struct {
+	// Dir is the directory archives and the manifest are written to, it is created if missing
+	Dir string
+	// Prefix starts the name of every archive and the manifest, defaults to DefaultArchivePrefix
+	Prefix string
+	// MaxBytes rotates to a new archive once this many uncompressed bytes were written, zero disables it
+	MaxBytes int64
+	// MaxAge rotates to a new archive once the current one has been open this long, zero disables it
+	MaxAge time.Duration
+	// ErrOut receives errors, defaults to stderr
+	ErrOut io.Writer
+}
+
+// ArchiveHandler writes formatted log lines into gzip compressed archives, rotating to a new
+// timestamped .gz file by size or age. Every completed archive is appended to a manifest
+// file named <prefix>.manifest in the archive directory.
+type ArchiveHandler struct {
+	config ArchiveConfig
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	file     *os.File
+	gz       *gzip.Writer
+	opened   time.Time
+	written  int64
+	sequence int
+	archived []string
+}
+
+// NewArchiveHandler creates an ArchiveHandler, creating the archive directory if needed
+func NewArchiveHandler(config ArchiveConfig) (*ArchiveHandler, error) {
+	if config.Dir == "" {
+		return nil, errors.New("archive directory is required")
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultArchivePrefix
+	}
+	if config.ErrOut == nil {
+		config.ErrOut = os.Stderr
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	return &ArchiveHandler{
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// OnLog compresses the formatted message into the current archive, writing failures to
+// the error output
+func (h *ArchiveHandler) OnLog(msg LogMessage) {
+	if err := h.WriteLog(msg); err != nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		fmt.Fprintf(h.config.ErrOut, "Error: %v\n", err)
+	}
+}
+
+// WriteLog compresses the formatted message into the current archive, returning any failure
+func (h *ArchiveHandler) WriteLog(msg LogMessage) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.write(msg.text())
+}
+
+// OnError writes error messages to the error output writer
+func (h *ArchiveHandler) OnError(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(h.config.ErrOut, "Error: %v\n", err)
+}
+
+// OnEnd finalizes the current archive so it is a complete gzip file
+func (h *ArchiveHandler) OnEnd() {
+	if err := h.Close(); err != nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		fmt.Fprintf(h.config.ErrOut, "Error: %v\n", err)
+	}
+}
+
+// Close finalizes the current archive and records it in the manifest.
+// Later messages start a new archive.
+func (h *ArchiveHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.finalize()
+}
+
+// Archives returns the paths of the completed archives, oldest first
+func (h *ArchiveHandler) Archives() []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]string(nil), h.archived...)
+}
+
+// ManifestPath returns the path of the manifest listing the completed archives
+func (h *ArchiveHandler) ManifestPath() string {
+	return filepath.Join(h.config.Dir, h.config.Prefix+".manifest")
+}
+
+// write appends a line to the current archive, rotating first if it is too old
+func (h *ArchiveHandler) write(line string) error {
+	if h.gz != nil && h.config.MaxAge > 0 && h.now().Sub(h.opened) >= h.config.MaxAge {
+		if err := h.finalize(); err != nil {
+			return err
+		}
+	}
+
+	if h.gz == nil {
+		if err := h.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := io.WriteString(h.gz, line+"\n")
+	h.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if h.config.MaxBytes > 0 && h.written >= h.config.MaxBytes {
+		return h.finalize()
+	}
+	return nil
+}
+
+// open starts a new archive named after the current time
+func (h *ArchiveHandler) open() error {
+	h.opened = h.now()
+	h.sequence++
+	name := fmt.Sprintf("%s-%s-%04d.gz", h.config.Prefix, h.opened.UTC().Format("20060102T150405Z"), h.sequence)
+
+	file, err := os.Create(filepath.Join(h.config.Dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	h.file = file
+	h.gz = gzip.NewWriter(file)
+	h.written = 0
+	return nil
+}
+
+// finalize closes the current archive, if any, and appends it to the manifest
+func (h *ArchiveHandler) finalize() error {
+	if h.gz == nil {
+		return nil
+	}
+
+	path := h.file.Name()
+	gzErr := h.gz.Close()
+	fileErr := h.file.Close()
+	h.gz = nil
+	h.file = nil
+
+	if err := errors.Join(gzErr, fileErr); err != nil {
+		return fmt.Errorf("failed to finalize archive %s: %w", path, err)
+	}
+
+	h.archived = append(h.archived, path)
+	return h.appendManifest(filepath.Base(path))
+}
+
+// appendManifest records a completed archive in the manifest
+func (h *ArchiveHandler) appendManifest(name string) error {
+	manifest, err := os.OpenFile(h.ManifestPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open archive manifest: %w", err)
+	}
+
+	_, err = fmt.Fprintln(manifest, name)
+	if closeErr := manifest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update archive manifest: %w", err)
+	}
+	return nil
+}
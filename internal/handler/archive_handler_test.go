@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readArchive decompresses an archive and returns its lines
+func readArchive(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("%s is not a gzip file: %v", path, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("%s is not a valid gzip archive: %v", path, err)
+	}
+	return lines
+}
+
+func TestArchiveHandler_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewArchiveHandler(ArchiveConfig{Dir: dir, Prefix: "web", MaxBytes: 20})
+	if err != nil {
+		t.Fatalf("NewArchiveHandler() error = %v", err)
+	}
+
+	// Each line is 8 bytes with its newline, so archives rotate after 3 lines
+	for i := 1; i <= 7; i++ {
+		h.OnLog(LogMessage{Message: fmt.Sprintf("line %02d", i)})
+	}
+	h.OnEnd()
+
+	archives := h.Archives()
+	want := [][]string{
+		{"line 01", "line 02", "line 03"},
+		{"line 04", "line 05", "line 06"},
+		{"line 07"},
+	}
+	if len(archives) != len(want) {
+		t.Fatalf("Archives() = %v, want %d archives", archives, len(want))
+	}
+	for i, path := range archives {
+		if !strings.HasPrefix(filepath.Base(path), "web-") || filepath.Ext(path) != ".gz" {
+			t.Errorf("archive %d is named %s, want web-<time>.gz", i, filepath.Base(path))
+		}
+		if got := readArchive(t, path); fmt.Sprint(got) != fmt.Sprint(want[i]) {
+			t.Errorf("archive %d contains %q, want %q", i, got, want[i])
+		}
+	}
+
+	manifest, err := os.ReadFile(h.ManifestPath())
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var names []string
+	for _, path := range archives {
+		names = append(names, filepath.Base(path))
+	}
+	if got, want := string(manifest), strings.Join(names, "\n")+"\n"; got != want {
+		t.Errorf("manifest = %q, want %q", got, want)
+	}
+}
+
+func TestArchiveHandler_RotatesByAge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h, err := NewArchiveHandler(ArchiveConfig{Dir: t.TempDir(), MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("NewArchiveHandler() error = %v", err)
+	}
+	h.now = func() time.Time { return now }
+
+	h.OnLog(LogMessage{Message: "first"})
+	now = now.Add(30 * time.Minute)
+	h.OnLog(LogMessage{Message: "second"})
+	now = now.Add(30 * time.Minute)
+	h.OnLog(LogMessage{Message: "third"})
+	h.OnEnd()
+
+	archives := h.Archives()
+	if len(archives) != 2 {
+		t.Fatalf("Archives() = %v, want 2 archives", archives)
+	}
+	if got := readArchive(t, archives[0]); fmt.Sprint(got) != "[first second]" {
+		t.Errorf("first archive contains %q", got)
+	}
+	if got := readArchive(t, archives[1]); fmt.Sprint(got) != "[third]" {
+		t.Errorf("second archive contains %q", got)
+	}
+	if base := filepath.Base(archives[1]); !strings.HasPrefix(base, DefaultArchivePrefix+"-20240101T010000Z") {
+		t.Errorf("second archive is named %s, want it stamped with its start time", base)
+	}
+}
+
+func TestArchiveHandler_NeedsDir(t *testing.T) {
+	if _, err := NewArchiveHandler(ArchiveConfig{}); err == nil {
+		t.Error("NewArchiveHandler() without a directory succeeded")
+	}
+}
@@ -16,19 +16,37 @@ type LogMessage struct {
 	PodName string
 	// ContainerName is the name of the container within the pod
 	ContainerName string
+	// NodeName is the name of the node the pod is scheduled on
+	NodeName string
+	// StreamLabel is the user-defined label of the streamer that produced the message
+	StreamLabel string
+	// LineNumber is the message's sequence number within its container, when line numbering is enabled
+	LineNumber int64
 	// Timestamp is the time when the log message was created
 	Timestamp time.Time
 	// Message is the log content
 	Message string
+	// Formatted is the formatter's output for the message, empty when it was not formatted
+	Formatted string
 	// Raw contains the original bytes of the log message
 	Raw []byte
 }
 
+// text returns the formatted message, or the message itself when it was not formatted
+func (m LogMessage) text() string {
+	if m.Formatted != "" {
+		return m.Formatted
+	}
+	return m.Message
+}
+
 // ConsoleHandler outputs logs to the console
 type ConsoleHandler struct {
 	out    io.Writer
 	errOut io.Writer
 	mutex  sync.Mutex
+	// encodeError, if set, renders errors in place of the plain text format
+	encodeError func(error) string
 }
 
 // NewConsoleHandler creates a new ConsoleHandler with stdout and stderr as default outputs
@@ -47,12 +65,18 @@ func NewConsoleHandlerWithWriters(out, errOut io.Writer) *ConsoleHandler {
 	}
 }
 
+// WithErrorEncoder renders each error with encode, e.g. as JSON, instead of as plain text
+func (h *ConsoleHandler) WithErrorEncoder(encode func(error) string) *ConsoleHandler {
+	h.encodeError = encode
+	return h
+}
+
 // OnLog writes formatted log messages to the configured output writer
 func (h *ConsoleHandler) OnLog(msg LogMessage) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	fmt.Fprintln(h.out, msg.Message)
+	fmt.Fprintln(h.out, msg.text())
 }
 
 // OnError writes error messages to the error output writer
@@ -60,6 +84,10 @@ func (h *ConsoleHandler) OnError(err error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	if h.encodeError != nil {
+		fmt.Fprintln(h.errOut, h.encodeError(err))
+		return
+	}
 	fmt.Fprintf(h.errOut, "Error: %v\n", err)
 }
 
@@ -38,6 +38,18 @@ func TestConsoleHandler_OnLog(t *testing.T) {
 	}
 }
 
+func TestConsoleHandler_OnLogFormatted(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	handler := NewConsoleHandlerWithWriters(outBuf, new(bytes.Buffer))
+
+	// The formatted text is printed in place of the original message
+	handler.OnLog(LogMessage{Message: "Test message", Formatted: "[app] Test message"})
+
+	if outBuf.String() != "[app] Test message\n" {
+		t.Errorf("Expected '[app] Test message\\n', got %q", outBuf.String())
+	}
+}
+
 func TestConsoleHandler_OnError(t *testing.T) {
 	// Create a buffer to capture output
 	outBuf := new(bytes.Buffer)
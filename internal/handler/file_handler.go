@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileHandlerOptions configures when a FileHandler rotates its file and how many old
+// files it keeps
+type FileHandlerOptions struct {
+	// MaxBytes rotates the file before a line would grow it past this size, zero disables rotation
+	MaxBytes int64
+	// MaxBackups is the number of rotated files kept as <path>.1 to <path>.N, the oldest
+	// being removed; zero keeps none and truncates the file on rotation
+	MaxBackups int
+	// ErrOut receives errors, defaults to stderr
+	ErrOut io.Writer
+}
+
+// FileHandler appends formatted log lines to a file, rotating it by renaming once it
+// reaches the configured size: app.log becomes app.log.1, app.log.1 becomes app.log.2
+// and so on. Writes are buffered and flushed on OnEnd, Close and rotation.
+type FileHandler struct {
+	path    string
+	options FileHandlerOptions
+
+	mutex   sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	written int64
+}
+
+// NewFileHandler creates a FileHandler appending to the file at path, creating the file
+// and its directory if needed
+func NewFileHandler(path string, options FileHandlerOptions) (*FileHandler, error) {
+	if path == "" {
+		return nil, errors.New("file path is required")
+	}
+	if options.ErrOut == nil {
+		options.ErrOut = os.Stderr
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	h := &FileHandler{path: path, options: options}
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// OnLog appends the formatted message to the file, writing failures to the error output
+func (h *FileHandler) OnLog(msg LogMessage) {
+	if err := h.WriteLog(msg); err != nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		fmt.Fprintf(h.options.ErrOut, "Error: %v\n", err)
+	}
+}
+
+// WriteLog appends the formatted message to the file, returning any failure
+func (h *FileHandler) WriteLog(msg LogMessage) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.write(msg.text() + "\n")
+}
+
+// OnError writes error messages to the error output writer
+func (h *FileHandler) OnError(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(h.options.ErrOut, "Error: %v\n", err)
+}
+
+// OnEnd flushes the buffered lines to the file
+func (h *FileHandler) OnEnd() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := h.flush(); err != nil {
+		fmt.Fprintf(h.options.ErrOut, "Error: %v\n", err)
+	}
+}
+
+// Reopen flushes and closes the file and opens the path again, for when an external
+// tool such as logrotate has moved the file away. Later lines go to the new file.
+func (h *FileHandler) Reopen() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := h.close(); err != nil {
+		return err
+	}
+	return h.open()
+}
+
+// Close flushes and closes the file. Later messages open it again.
+func (h *FileHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.close()
+}
+
+// Path returns the path of the file being written
+func (h *FileHandler) Path() string {
+	return h.path
+}
+
+// write appends a line to the file, rotating first if it would grow past MaxBytes
+func (h *FileHandler) write(line string) error {
+	if h.file == nil {
+		if err := h.open(); err != nil {
+			return err
+		}
+	}
+
+	if h.options.MaxBytes > 0 && h.written > 0 && h.written+int64(len(line)) > h.options.MaxBytes {
+		if err := h.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := h.writer.WriteString(line)
+	h.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+	return nil
+}
+
+// open opens the path for appending, counting the bytes it already holds
+func (h *FileHandler) open() error {
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	h.file = file
+	h.writer = bufio.NewWriter(file)
+	h.written = info.Size()
+	return nil
+}
+
+// flush writes the buffered lines to the file
+func (h *FileHandler) flush() error {
+	if h.writer == nil {
+		return nil
+	}
+	if err := h.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush log file: %w", err)
+	}
+	return nil
+}
+
+// close flushes and closes the file, if open
+func (h *FileHandler) close() error {
+	if h.file == nil {
+		return nil
+	}
+
+	flushErr := h.flush()
+	closeErr := h.file.Close()
+	h.file = nil
+	h.writer = nil
+
+	if err := errors.Join(flushErr, closeErr); err != nil {
+		return fmt.Errorf("failed to close log file %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// rotate closes the file, shifts the backups up by one, dropping the oldest, and opens a
+// new file at the path
+func (h *FileHandler) rotate() error {
+	if err := h.close(); err != nil {
+		return err
+	}
+
+	if h.options.MaxBackups <= 0 {
+		if err := os.Remove(h.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+		return h.open()
+	}
+
+	if err := os.Remove(h.backup(h.options.MaxBackups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove oldest log file: %w", err)
+	}
+	for i := h.options.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(h.backup(i), h.backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(h.path, h.backup(1)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return h.open()
+}
+
+// backup returns the path of the n-th most recent rotated file
+func (h *FileHandler) backup(n int) string {
+	return fmt.Sprintf("%s.%d", h.path, n)
+}
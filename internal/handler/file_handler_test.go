@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// readLines returns the lines of a file
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileHandler_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	h, err := NewFileHandler(path, FileHandlerOptions{MaxBytes: 24, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error = %v", err)
+	}
+
+	// Each line is 8 bytes with its newline, so the file rotates every 3 lines and the
+	// first 3 lines are rotated out of the backups
+	for i := 1; i <= 10; i++ {
+		h.OnLog(LogMessage{Message: fmt.Sprintf("line %02d", i)})
+	}
+	h.OnEnd()
+
+	want := map[string][]string{
+		path:        {"line 10"},
+		path + ".1": {"line 07", "line 08", "line 09"},
+		path + ".2": {"line 04", "line 05", "line 06"},
+	}
+	for file, lines := range want {
+		if got := readLines(t, file); strings.Join(got, ",") != strings.Join(lines, ",") {
+			t.Errorf("%s = %v, want %v", filepath.Base(file), got, lines)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want at most 2 backups", filepath.Base(path))
+	}
+}
+
+func TestFileHandler_NoBackupsTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h, err := NewFileHandler(path, FileHandlerOptions{MaxBytes: 16})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error = %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		h.OnLog(LogMessage{Message: fmt.Sprintf("line %02d", i)})
+	}
+	h.OnEnd()
+
+	if got := readLines(t, path); strings.Join(got, ",") != "line 05" {
+		t.Errorf("app.log = %v, want [line 05]", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("app.log.1 exists, want no backups")
+	}
+}
+
+func TestFileHandler_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old 01\nold 02\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewFileHandler(path, FileHandlerOptions{MaxBytes: 21, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error = %v", err)
+	}
+	// The existing 14 bytes count towards the size, so the first line already rotates
+	h.OnLog(LogMessage{Message: "new 01", Formatted: "[web] new 01"})
+	h.OnEnd()
+
+	if got := readLines(t, path+".1"); strings.Join(got, ",") != "old 01,old 02" {
+		t.Errorf("app.log.1 = %v, want the existing lines", got)
+	}
+	if got := readLines(t, path); strings.Join(got, ",") != "[web] new 01" {
+		t.Errorf("app.log = %v, want the formatted line", got)
+	}
+}
+
+func TestFileHandler_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h, err := NewFileHandler(path, FileHandlerOptions{})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error = %v", err)
+	}
+
+	h.OnLog(LogMessage{Message: "before"})
+	// An external tool moves the file away, lines keep going to the moved file until Reopen
+	if err := os.Rename(path, path+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	h.OnLog(LogMessage{Message: "moved"})
+	if err := h.Reopen(); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	h.OnLog(LogMessage{Message: "after"})
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := readLines(t, path+".moved"); strings.Join(got, ",") != "before,moved" {
+		t.Errorf("moved file = %v, want [before moved]", got)
+	}
+	if got := readLines(t, path); strings.Join(got, ",") != "after" {
+		t.Errorf("reopened file = %v, want [after]", got)
+	}
+}
+
+func TestFileHandler_ConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var errOut bytes.Buffer
+	h, err := NewFileHandler(path, FileHandlerOptions{MaxBytes: 1000, MaxBackups: 100, ErrOut: &errOut})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				h.OnLog(LogMessage{Message: fmt.Sprintf("g%d line %03d", g, i)})
+			}
+		}(g)
+	}
+	wg.Wait()
+	h.OnEnd()
+
+	if errOut.Len() > 0 {
+		t.Fatalf("unexpected errors: %s", errOut.String())
+	}
+	matches, _ := filepath.Glob(path + "*")
+	total := 0
+	for _, file := range matches {
+		for _, line := range readLines(t, file) {
+			if !strings.HasPrefix(line, "g") || len(line) != len("g0 line 000") {
+				t.Fatalf("%s has a torn line %q", filepath.Base(file), line)
+			}
+			total++
+		}
+	}
+	if total != 800 {
+		t.Errorf("wrote %d lines across %d files, want 800", total, len(matches))
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// webhookServer records the decoded bodies and headers of the requests it receives
+type webhookServer struct {
+	mu       sync.Mutex
+	bodies   []string
+	encoding []string
+	status   int
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(data))
+	s.encoding = append(s.encoding, r.Header.Get("Content-Encoding"))
+	s.mu.Unlock()
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+}
+
+func TestWebhookHandler_Compress(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		recorder := &webhookServer{}
+		server := httptest.NewServer(recorder)
+
+		h, err := NewWebhookHandler(WebhookConfig{URL: server.URL, Compress: compress})
+		if err != nil {
+			t.Fatalf("NewWebhookHandler() error = %v", err)
+		}
+		batch := []LogMessage{{Message: "first"}, {Message: "second", Formatted: "[web] second"}}
+		if err := h.WriteBatch(batch); err != nil {
+			t.Fatalf("WriteBatch(compress=%v) error = %v", compress, err)
+		}
+		server.Close()
+
+		if len(recorder.bodies) != 1 {
+			t.Fatalf("compress=%v: received %d requests, want 1", compress, len(recorder.bodies))
+		}
+		if want := "first\n[web] second\n"; recorder.bodies[0] != want {
+			t.Errorf("compress=%v: body = %q, want %q", compress, recorder.bodies[0], want)
+		}
+		wantEncoding := ""
+		if compress {
+			wantEncoding = "gzip"
+		}
+		if recorder.encoding[0] != wantEncoding {
+			t.Errorf("compress=%v: Content-Encoding = %q, want %q", compress, recorder.encoding[0], wantEncoding)
+		}
+	}
+}
+
+func TestWebhookHandler_Errors(t *testing.T) {
+	if _, err := NewWebhookHandler(WebhookConfig{}); err == nil {
+		t.Error("NewWebhookHandler() without a URL should fail")
+	}
+
+	server := httptest.NewServer(&webhookServer{status: http.StatusServiceUnavailable})
+	defer server.Close()
+
+	var errOut bytes.Buffer
+	h, err := NewWebhookHandler(WebhookConfig{URL: server.URL, ErrOut: &errOut})
+	if err != nil {
+		t.Fatalf("NewWebhookHandler() error = %v", err)
+	}
+	if err := h.WriteLog(LogMessage{Message: "line"}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("WriteLog() error = %v, want the response status", err)
+	}
+
+	h.OnLog(LogMessage{Message: "line"})
+	if !strings.Contains(errOut.String(), "webhook responded 503") {
+		t.Errorf("error output = %q, want the failed request reported", errOut.String())
+	}
+}
+
+// BenchmarkWebhookHandler_Compress compares posting a batch of typical log lines with and
+// without gzip, reporting the bytes sent per batch
+func BenchmarkWebhookHandler_Compress(b *testing.B) {
+	batch := make([]LogMessage, 100)
+	for i := range batch {
+		batch[i] = LogMessage{Message: `2024-01-01T00:00:00Z INFO request completed method=GET path=/api/v1/items status=200 duration=12ms`}
+	}
+
+	for _, compress := range []bool{false, true} {
+		name := "plain"
+		if compress {
+			name = "gzip"
+		}
+		b.Run(name, func(b *testing.B) {
+			var sent int64
+			var mu sync.Mutex
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n, _ := io.Copy(io.Discard, r.Body)
+				mu.Lock()
+				sent += n
+				mu.Unlock()
+			}))
+			defer server.Close()
+
+			h, err := NewWebhookHandler(WebhookConfig{URL: server.URL, Compress: compress})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := h.WriteBatch(batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(sent)/float64(b.N), "bytes/batch")
+		})
+	}
+}
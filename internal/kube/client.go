@@ -1,7 +1,9 @@
 package kube
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 
@@ -11,6 +13,13 @@ import (
 	"k8s.io/client-go/util/homedir"
 )
 
+var (
+	// ErrNoKubeConfig is returned when no kubeconfig can be found or it holds no configuration
+	ErrNoKubeConfig = errors.New("no kubernetes configuration provided")
+	// ErrNoKubeContext is returned when the requested context is missing from the kubeconfig
+	ErrNoKubeContext = errors.New("kubernetes context not found")
+)
+
 // ClientProvider handles Kubernetes client creation and configuration
 type ClientProvider struct {
 	// RestConfig is the kubernetes client configuration
@@ -22,7 +31,14 @@ type ClientProvider struct {
 	// UseInClusterConfig indicates whether to use in-cluster configuration
 	UseInClusterConfig bool
 	// Clientset is a direct Kubernetes clientset instance
-	Clientset *kubernetes.Clientset
+	Clientset kubernetes.Interface
+	// MaxIdleConnsPerHost tunes the idle connection pool shared by all log streams.
+	// Zero keeps the client-go default.
+	MaxIdleConnsPerHost int
+	// Compression requests gzip-encoded responses and counts the bytes saved in compressionStats
+	Compression bool
+
+	compressionStats CompressionStats
 }
 
 // NewClientProvider creates a new ClientProvider with default settings
@@ -41,12 +57,29 @@ func (p *ClientProvider) WithRestConfig(config *rest.Config) *ClientProvider {
 }
 
 // WithClientset sets a direct kubernetes clientset
-func (p *ClientProvider) WithClientset(clientset *kubernetes.Clientset) *ClientProvider {
+func (p *ClientProvider) WithClientset(clientset kubernetes.Interface) *ClientProvider {
 	p.Clientset = clientset
 	p.UseInClusterConfig = false
 	return p
 }
 
+// WithMaxIdleConnsPerHost sets how many idle connections are kept open to the API server
+func (p *ClientProvider) WithMaxIdleConnsPerHost(n int) *ClientProvider {
+	p.MaxIdleConnsPerHost = n
+	return p
+}
+
+// WithCompression enables gzip negotiation for API responses
+func (p *ClientProvider) WithCompression(enabled bool) *ClientProvider {
+	p.Compression = enabled
+	return p
+}
+
+// CompressionStats returns the byte counters of the gzip-negotiating transport
+func (p *ClientProvider) CompressionStats() *CompressionStats {
+	return &p.compressionStats
+}
+
 // WithKubeconfigPath sets the path to the kubeconfig file
 func (p *ClientProvider) WithKubeconfigPath(path string) *ClientProvider {
 	p.KubeconfigPath = path
@@ -90,13 +123,13 @@ func (p *ClientProvider) GetConfig() (*rest.Config, error) {
 	if kubeconfigPath == "" {
 		kubeconfigPath = getDefaultKubeconfigPath()
 		if kubeconfigPath == "" {
-			return nil, fmt.Errorf("unable to locate kubeconfig")
+			return nil, fmt.Errorf("unable to locate kubeconfig: %w", ErrNoKubeConfig)
 		}
 	}
 
 	// Check if kubeconfig file exists
 	if _, err := os.Stat(kubeconfigPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("kubeconfig file not found at %s", kubeconfigPath)
+		return nil, fmt.Errorf("kubeconfig file not found at %s: %w", kubeconfigPath, ErrNoKubeConfig)
 	}
 
 	// Load config with or without specific context
@@ -112,12 +145,20 @@ func (p *ClientProvider) GetConfig() (*rest.Config, error) {
 		configOverrides,
 	)
 
+	if err := p.checkContext(clientConfig); err != nil {
+		return nil, err
+	}
+
 	// Get the config
-	return clientConfig.ClientConfig()
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, loadError(err)
+	}
+	return config, nil
 }
 
 // GetClientset returns a kubernetes clientset based on the provider settings
-func (p *ClientProvider) GetClientset() (*kubernetes.Clientset, error) {
+func (p *ClientProvider) GetClientset() (kubernetes.Interface, error) {
 	// If a direct clientset is provided, use it
 	if p.Clientset != nil {
 		return p.Clientset, nil
@@ -129,7 +170,44 @@ func (p *ClientProvider) GetClientset() (*kubernetes.Clientset, error) {
 		return nil, err
 	}
 
-	return kubernetes.NewForConfig(config)
+	// The clientset is created once, so every log stream shares its transport and connection pool
+	return kubernetes.NewForConfig(p.tuneTransport(config))
+}
+
+// tuneTransport returns a copy of the config whose transport uses the configured
+// connection pool and compression settings
+func (p *ClientProvider) tuneTransport(config *rest.Config) *rest.Config {
+	if p.MaxIdleConnsPerHost <= 0 && !p.Compression {
+		return config
+	}
+
+	// Copy the config so the caller's config is left untouched
+	config = rest.CopyConfig(config)
+
+	if maxIdle := p.MaxIdleConnsPerHost; maxIdle > 0 {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			transport, ok := rt.(*http.Transport)
+			if !ok {
+				return rt
+			}
+
+			// Clone the transport since client-go caches and shares it between clients
+			transport = transport.Clone()
+			transport.MaxIdleConnsPerHost = maxIdle
+			if transport.MaxIdleConns > 0 && transport.MaxIdleConns < maxIdle {
+				transport.MaxIdleConns = maxIdle
+			}
+			return transport
+		})
+	}
+
+	if p.Compression {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &gzipRoundTripper{next: rt, stats: &p.compressionStats}
+		})
+	}
+
+	return config
 }
 
 // GetCurrentNamespace returns the current namespace from the context
@@ -144,13 +222,13 @@ func (p *ClientProvider) GetCurrentNamespace() (string, error) {
 	if kubeconfigPath == "" {
 		kubeconfigPath = getDefaultKubeconfigPath()
 		if kubeconfigPath == "" {
-			return "", fmt.Errorf("unable to locate kubeconfig")
+			return "", fmt.Errorf("unable to locate kubeconfig: %w", ErrNoKubeConfig)
 		}
 	}
 
 	// Check if kubeconfig file exists
 	if _, err := os.Stat(kubeconfigPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("kubeconfig file not found at %s", kubeconfigPath)
+		return "", fmt.Errorf("kubeconfig file not found at %s: %w", kubeconfigPath, ErrNoKubeConfig)
 	}
 
 	// Load config with or without specific context
@@ -166,7 +244,42 @@ func (p *ClientProvider) GetCurrentNamespace() (string, error) {
 		configOverrides,
 	)
 
+	if err := p.checkContext(clientConfig); err != nil {
+		return "", err
+	}
+
 	// Get namespace from the client config
 	namespace, _, err := clientConfig.Namespace()
-	return namespace, err
+	if err != nil {
+		return "", loadError(err)
+	}
+	return namespace, nil
+}
+
+// checkContext returns ErrNoKubeContext if the configured context is missing from the kubeconfig
+func (p *ClientProvider) checkContext(clientConfig clientcmd.ClientConfig) error {
+	if p.ContextName == "" {
+		return nil
+	}
+
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return loadError(err)
+	}
+	if _, ok := rawConfig.Contexts[p.ContextName]; !ok {
+		return fmt.Errorf("%w: %q", ErrNoKubeContext, p.ContextName)
+	}
+	return nil
+}
+
+// loadError wraps a kubeconfig loading error with ErrNoKubeContext or ErrNoKubeConfig when
+// the context is missing or the kubeconfig is empty
+func loadError(err error) error {
+	switch {
+	case clientcmd.IsContextNotFound(err):
+		return fmt.Errorf("%w: %v", ErrNoKubeContext, err)
+	case clientcmd.IsEmptyConfig(err):
+		return fmt.Errorf("%w: %v", ErrNoKubeConfig, err)
+	}
+	return err
 }
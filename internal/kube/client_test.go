@@ -1,10 +1,19 @@
 package kube
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -247,3 +256,109 @@ func TestClientProvider_GetConfigErrors(t *testing.T) {
 		t.Errorf("GetConfig() should fail with non-existent context name")
 	}
 }
+
+func TestClientProvider_MaxIdleConnsPerHost(t *testing.T) {
+	config := &rest.Config{
+		Host: "https://test-server:8443",
+	}
+
+	provider := NewClientProviderWithOptions(
+		WithRestConfig(config),
+		WithMaxIdleConnsPerHost(64),
+	)
+
+	tuned := provider.tuneTransport(config)
+	if tuned == config {
+		t.Fatal("tuneTransport() modified the caller's config in place")
+	}
+	if config.WrapTransport != nil {
+		t.Error("caller's config gained a transport wrapper")
+	}
+
+	base := &http.Transport{MaxIdleConns: 10, MaxIdleConnsPerHost: 2}
+	rt, ok := tuned.WrapTransport(base).(*http.Transport)
+	if !ok {
+		t.Fatal("wrapped transport is not an *http.Transport")
+	}
+	if rt == base {
+		t.Error("shared base transport was modified instead of cloned")
+	}
+	if rt.MaxIdleConnsPerHost != 64 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 64", rt.MaxIdleConnsPerHost)
+	}
+	if rt.MaxIdleConns != 64 {
+		t.Errorf("MaxIdleConns = %d, want 64", rt.MaxIdleConns)
+	}
+
+	// Without tuning the config is passed through unchanged
+	if got := NewClientProvider().tuneTransport(config); got != config {
+		t.Error("tuneTransport() copied the config without any tuning")
+	}
+}
+
+// BenchmarkClientProvider_LogStreamConnections reports how many new connections are
+// opened per burst of concurrent log requests against an HTTP/1.1 API server
+func BenchmarkClientProvider_LogStreamConnections(b *testing.B) {
+	const streams = 64
+
+	for _, bm := range []struct {
+		name    string
+		maxIdle int
+	}{
+		{name: "default", maxIdle: 0},
+		{name: "tuned", maxIdle: streams},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			var conns atomic.Int64
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, "log line\n")
+			}))
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			server.Start()
+			defer server.Close()
+
+			provider := NewClientProviderWithOptions(
+				// Lift client-side throttling so the burst is not rate limited
+				WithRestConfig(&rest.Config{Host: server.URL, QPS: 1000, Burst: streams}),
+				WithMaxIdleConnsPerHost(bm.maxIdle),
+			)
+			clientset, err := provider.GetClientset()
+			if err != nil {
+				b.Fatalf("GetClientset() error = %v", err)
+			}
+
+			burst := func() {
+				var wg sync.WaitGroup
+				for i := 0; i < streams; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						stream, err := clientset.CoreV1().Pods("default").
+							GetLogs("web-1", &corev1.PodLogOptions{}).Stream(context.Background())
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						_, _ = io.Copy(io.Discard, stream)
+						stream.Close()
+					}()
+				}
+				wg.Wait()
+			}
+
+			// Warm up the pool so only reconnects are measured
+			burst()
+			conns.Store(0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				burst()
+			}
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package kube
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// CompressionStats counts the bytes flowing through the gzip-negotiating transport
+type CompressionStats struct {
+	active       atomic.Bool
+	compressed   atomic.Uint64
+	decompressed atomic.Uint64
+}
+
+// Active reports whether the API server has sent any gzip-encoded response
+func (s *CompressionStats) Active() bool {
+	if s == nil {
+		return false
+	}
+	return s.active.Load()
+}
+
+// BytesCompressed returns the number of gzip-encoded bytes read from the wire
+func (s *CompressionStats) BytesCompressed() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.compressed.Load()
+}
+
+// BytesDecompressed returns the number of bytes produced by decompressing responses
+func (s *CompressionStats) BytesDecompressed() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.decompressed.Load()
+}
+
+// gzipRoundTripper requests gzip-encoded responses and decompresses them itself,
+// instead of leaving it to http.Transport, so the bytes on the wire can be counted
+type gzipRoundTripper struct {
+	next  http.RoundTripper
+	stats *CompressionStats
+}
+
+// RoundTrip negotiates gzip encoding and wraps compressed response bodies
+func (rt *gzipRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Respect an encoding chosen by the caller
+	if req.Header.Get("Accept-Encoding") != "" {
+		return rt.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, err
+	}
+
+	rt.stats.active.Store(true)
+
+	// Present the response as if it had been sent uncompressed
+	resp.Body = &gzipBody{body: resp.Body, stats: rt.stats}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipBody decompresses a response body while counting bytes on both sides
+type gzipBody struct {
+	body   io.ReadCloser
+	reader *gzip.Reader
+	stats  *CompressionStats
+}
+
+// Read decompresses the next bytes of the body
+func (b *gzipBody) Read(p []byte) (int, error) {
+	// Create the reader lazily since reading the gzip header blocks on a streaming body
+	if b.reader == nil {
+		reader, err := gzip.NewReader(&wireCounter{reader: b.body, count: &b.stats.compressed})
+		if err != nil {
+			return 0, err
+		}
+		b.reader = reader
+	}
+
+	n, err := b.reader.Read(p)
+	b.stats.decompressed.Add(uint64(n))
+	return n, err
+}
+
+// Close closes the underlying body
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
+
+// wireCounter counts the compressed bytes read from the response body
+type wireCounter struct {
+	reader io.Reader
+	count  *atomic.Uint64
+}
+
+// Read reads from the body and adds the bytes read to the counter
+func (r *wireCounter) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(uint64(n))
+	return n, err
+}
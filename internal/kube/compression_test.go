@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestClientProvider_Compression(t *testing.T) {
+	logs := strings.Repeat("2024-01-01T00:00:00Z INFO request handled status=200\n", 500)
+
+	tests := []struct {
+		name       string
+		serverGzip bool
+		wantActive bool
+	}{
+		{
+			name:       "gzip-encoded response",
+			serverGzip: true,
+			wantActive: true,
+		},
+		{
+			name:       "server without compression",
+			serverGzip: false,
+			wantActive: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.serverGzip || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					_, _ = io.WriteString(w, logs)
+					return
+				}
+
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				_, _ = io.WriteString(gz, logs)
+				_ = gz.Close()
+			}))
+			defer server.Close()
+
+			provider := NewClientProviderWithOptions(
+				WithRestConfig(&rest.Config{Host: server.URL}),
+				WithCompression(true),
+			)
+			clientset, err := provider.GetClientset()
+			if err != nil {
+				t.Fatalf("GetClientset() error = %v", err)
+			}
+
+			stream, err := clientset.CoreV1().Pods("default").
+				GetLogs("web-1", &corev1.PodLogOptions{}).Stream(context.Background())
+			if err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+			defer stream.Close()
+
+			got, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			if string(got) != logs {
+				t.Fatalf("stream returned %d bytes, want the %d bytes sent", len(got), len(logs))
+			}
+
+			stats := provider.CompressionStats()
+			if stats.Active() != tt.wantActive {
+				t.Errorf("Active() = %v, want %v", stats.Active(), tt.wantActive)
+			}
+			if !tt.wantActive {
+				if stats.BytesCompressed() != 0 || stats.BytesDecompressed() != 0 {
+					t.Errorf("counters = %d/%d for an uncompressed response, want 0/0",
+						stats.BytesCompressed(), stats.BytesDecompressed())
+				}
+				return
+			}
+
+			if stats.BytesDecompressed() != uint64(len(logs)) {
+				t.Errorf("BytesDecompressed() = %d, want %d", stats.BytesDecompressed(), len(logs))
+			}
+			if stats.BytesCompressed() == 0 || stats.BytesCompressed() >= stats.BytesDecompressed() {
+				t.Errorf("BytesCompressed() = %d, want less than BytesDecompressed() = %d",
+					stats.BytesCompressed(), stats.BytesDecompressed())
+			}
+		})
+	}
+}
@@ -16,7 +16,7 @@ func WithRestConfig(config *rest.Config) Option {
 }
 
 // WithClientset creates an option to configure a ClientProvider with a direct kubernetes clientset
-func WithClientset(clientset *kubernetes.Clientset) Option {
+func WithClientset(clientset kubernetes.Interface) Option {
 	return func(provider *ClientProvider) {
 		provider.WithClientset(clientset)
 	}
@@ -36,6 +36,20 @@ func WithContextName(name string) Option {
 	}
 }
 
+// WithMaxIdleConnsPerHost creates an option to tune the idle connection pool to the API server
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(provider *ClientProvider) {
+		provider.WithMaxIdleConnsPerHost(n)
+	}
+}
+
+// WithCompression creates an option to negotiate gzip-encoded API responses
+func WithCompression(enabled bool) Option {
+	return func(provider *ClientProvider) {
+		provider.WithCompression(enabled)
+	}
+}
+
 // UseDefaultConfig creates an option to configure a ClientProvider to use default in-cluster or
 // kubeconfig configuration
 func UseDefaultConfig() Option {
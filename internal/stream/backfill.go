@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"context"
+	"time"
+)
+
+// backfillPacer limits how fast the historical portion of a log stream is read.
+// The kubelet sends the backlog of a pod in one burst before following new lines, so
+// the backlog is paced at a fixed rate until the stream catches up. The stream is
+// considered caught up once the reader waits a full interval for the next line, after
+// which pacing is switched off and live lines are delivered as they arrive.
+type backfillPacer struct {
+	interval time.Duration
+	// slot is the time the most recent line was allowed through
+	slot time.Time
+	// last is the time the previous wait returned
+	last time.Time
+	live bool
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) bool
+}
+
+// newBackfillPacer creates a pacer for the given rate, or nil if the rate is unlimited
+func newBackfillPacer(linesPerSec int) *backfillPacer {
+	if linesPerSec <= 0 {
+		return nil
+	}
+
+	return &backfillPacer{
+		interval: time.Second / time.Duration(linesPerSec),
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+}
+
+// wait blocks until the next line may be delivered.
+// It returns false if the context was cancelled while waiting.
+func (p *backfillPacer) wait(ctx context.Context) bool {
+	if p == nil || p.live {
+		return true
+	}
+
+	now := p.now()
+	if p.last.IsZero() {
+		p.slot = now
+		p.last = now
+		return true
+	}
+
+	// The reader had to wait for this line, so the backlog has been drained
+	if now.Sub(p.last) >= p.interval {
+		p.live = true
+		return true
+	}
+
+	// Advance by a fixed interval rather than from now, so oversleeping lets the
+	// following lines through without waiting and the average rate is preserved
+	p.slot = p.slot.Add(p.interval)
+	if d := p.slot.Sub(now); d > 0 {
+		if !p.sleep(ctx, d) {
+			return false
+		}
+	}
+
+	p.last = p.now()
+	return true
+}
+
+// sleepContext sleeps for the duration, returning false if the context is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock whose sleeps complete instantly
+type fakeClock struct {
+	now    time.Time
+	sleeps int
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) bool {
+	c.sleeps++
+	c.now = c.now.Add(d)
+	return true
+}
+
+// newFakePacer creates a pacer driven by the fake clock
+func newFakePacer(linesPerSec int, clock *fakeClock) *backfillPacer {
+	pacer := newBackfillPacer(linesPerSec)
+	pacer.now = clock.Now
+	pacer.sleep = clock.Sleep
+	return pacer
+}
+
+func TestBackfillPacer_Rate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pacer := newFakePacer(10, clock)
+	start := clock.now
+
+	// The backlog is available immediately, so only the pacer advances the clock
+	for i := 0; i < 31; i++ {
+		if !pacer.wait(context.Background()) {
+			t.Fatalf("wait() = false for line %d", i)
+		}
+	}
+
+	if elapsed := clock.now.Sub(start); elapsed != 3*time.Second {
+		t.Errorf("31 lines at 10 lines/sec took %v, want 3s", elapsed)
+	}
+	if pacer.live {
+		t.Error("pacer switched to live while draining the backlog")
+	}
+}
+
+func TestBackfillPacer_SwitchesToLive(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pacer := newFakePacer(10, clock)
+
+	for i := 0; i < 5; i++ {
+		pacer.wait(context.Background())
+	}
+
+	// The next line takes longer than an interval to arrive, the backlog is drained
+	clock.now = clock.now.Add(time.Second)
+	sleeps := clock.sleeps
+
+	for i := 0; i < 20; i++ {
+		pacer.wait(context.Background())
+	}
+
+	if !pacer.live {
+		t.Fatal("pacer did not switch to live after catching up")
+	}
+	if clock.sleeps != sleeps {
+		t.Errorf("pacer slept %d more times after switching to live", clock.sleeps-sleeps)
+	}
+}
+
+func TestBackfillPacer_Oversleep(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pacer := newFakePacer(100, clock)
+
+	// Each sleep overshoots by half an interval
+	pacer.sleep = func(_ context.Context, d time.Duration) bool {
+		clock.now = clock.now.Add(d + 5*time.Millisecond)
+		return true
+	}
+
+	start := clock.now
+	for i := 0; i < 101; i++ {
+		pacer.wait(context.Background())
+	}
+
+	// Late wake ups are absorbed by skipping later sleeps, keeping the average rate
+	if elapsed := clock.now.Sub(start); elapsed > 1100*time.Millisecond {
+		t.Errorf("101 lines at 100 lines/sec took %v, want about 1s", elapsed)
+	}
+	if pacer.live {
+		t.Error("oversleeping was mistaken for the stream catching up")
+	}
+}
+
+func TestBackfillPacer_Cancelled(t *testing.T) {
+	pacer := newBackfillPacer(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pacer.wait(ctx)
+	cancel()
+
+	if pacer.wait(ctx) {
+		t.Error("wait() = true after the context was cancelled")
+	}
+}
+
+func TestBackfillPacer_Unlimited(t *testing.T) {
+	if pacer := newBackfillPacer(0); pacer != nil {
+		t.Fatal("newBackfillPacer(0) returned a pacer")
+	}
+
+	var pacer *backfillPacer
+	if !pacer.wait(context.Background()) {
+		t.Error("nil pacer blocked")
+	}
+}
@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"sync"
+)
+
+// BackpressureStrategy decides what happens to new log lines when the handler can't keep up
+type BackpressureStrategy int
+
+const (
+	// BackpressureBlock calls the handler directly from the stream readers. A slow handler
+	// slows down reading, which is lossless here but lets the kubelet's log buffer overflow.
+	BackpressureBlock BackpressureStrategy = iota
+	// BackpressureDropOldest queues lines for the handler and discards the oldest queued
+	// line when the queue is full, favoring the most recent logs
+	BackpressureDropOldest
+	// BackpressureDropNewest queues lines for the handler and discards incoming lines
+	// when the queue is full, favoring the logs already queued
+	BackpressureDropNewest
+)
+
+// DefaultBackpressureBufferSize is the default number of lines queued for a slow handler
+const DefaultBackpressureBufferSize = 1024
+
+// dropQueue is a bounded queue between the stream readers and the handler that never
+// blocks the readers, dropping lines according to the strategy when it is full
+type dropQueue struct {
+	strategy BackpressureStrategy
+	limit    int
+	// dropped is called for every line discarded
+	dropped func()
+
+	mu      sync.Mutex
+	pending []LogMessage
+	started bool
+	closed  bool
+	// ready is signalled when lines are queued or the queue is closed
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// newDropQueue creates a queue holding up to limit lines
+func newDropQueue(strategy BackpressureStrategy, limit int, dropped func()) *dropQueue {
+	return &dropQueue{
+		strategy: strategy,
+		limit:    limit,
+		dropped:  dropped,
+		ready:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// push queues the line, dropping a line if the queue is full
+func (q *dropQueue) push(msg LogMessage) {
+	q.mu.Lock()
+	switch {
+	case q.closed:
+		// The streamer has stopped, nothing will deliver the line
+		q.dropped()
+	case len(q.pending) < q.limit:
+		q.pending = append(q.pending, msg)
+	case q.strategy == BackpressureDropOldest:
+		q.pending = append(q.pending[1:], msg)
+		q.dropped()
+	default:
+		q.dropped()
+	}
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// signal wakes up the delivery loop without blocking
+func (q *dropQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// start runs the delivery loop in its own goroutine, once
+func (q *dropQueue) start(send func(LogMessage)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started || q.closed {
+		return
+	}
+	q.started = true
+	go q.run(send)
+}
+
+// run delivers queued lines until the queue is closed and drained
+func (q *dropQueue) run(send func(LogMessage)) {
+	defer close(q.done)
+
+	for range q.ready {
+		for {
+			q.mu.Lock()
+			if len(q.pending) == 0 {
+				closed := q.closed
+				q.mu.Unlock()
+				if closed {
+					return
+				}
+				break
+			}
+			msg := q.pending[0]
+			q.pending = q.pending[1:]
+			q.mu.Unlock()
+
+			send(msg)
+		}
+	}
+}
+
+// close stops accepting lines and waits for the queued ones to be delivered.
+// Lines queued before the delivery loop was started are dropped.
+func (q *dropQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	started := q.started
+	if !started {
+		for range q.pending {
+			q.dropped()
+		}
+		q.pending = nil
+	}
+	q.mu.Unlock()
+
+	if !started {
+		return
+	}
+	q.signal()
+	<-q.done
+}
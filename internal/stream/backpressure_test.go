@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// gatedHandler blocks every OnLog call until released, simulating a saturated handler
+type gatedHandler struct {
+	recordingHandler
+	started chan string
+	release chan struct{}
+}
+
+func newGatedHandler() *gatedHandler {
+	return &gatedHandler{
+		started: make(chan string, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *gatedHandler) OnLog(msg LogMessage) {
+	h.started <- msg.Message
+	<-h.release
+	h.recordingHandler.OnLog(msg)
+}
+
+func TestStreamer_Backpressure(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    BackpressureStrategy
+		want        []string
+		wantDropped uint64
+	}{
+		{
+			name:     "block delivers everything",
+			strategy: BackpressureBlock,
+			want:     []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:        "drop oldest keeps the latest lines",
+			strategy:    BackpressureDropOldest,
+			want:        []string{"1", "4", "5"},
+			wantDropped: 2,
+		},
+		{
+			name:        "drop newest keeps the queued lines",
+			strategy:    BackpressureDropNewest,
+			want:        []string{"1", "2", "3"},
+			wantDropped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newGatedHandler()
+			s := newTestStreamer(t, &StreamerConfig{
+				Handler:                handler,
+				Backpressure:           tt.strategy,
+				BackpressureBufferSize: 2,
+			})
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			// Produce more lines once the handler is stuck on the first one
+			next := make(chan struct{})
+			produced := make(chan struct{})
+			go func() {
+				defer close(produced)
+				s.deliver(LogMessage{Message: "1"})
+				<-next
+				for _, line := range []string{"2", "3", "4", "5"} {
+					s.deliver(LogMessage{Message: line})
+				}
+			}()
+
+			select {
+			case <-handler.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler never received the first line")
+			}
+			close(next)
+
+			blocked := false
+			select {
+			case <-produced:
+			case <-time.After(50 * time.Millisecond):
+				blocked = true
+			}
+			if want := tt.strategy == BackpressureBlock; blocked != want {
+				t.Fatalf("producer blocked = %v, want %v", blocked, want)
+			}
+
+			// Let the handler catch up and drain everything
+			close(handler.release)
+			<-produced
+			s.Stop()
+
+			got := handler.lines()
+			if len(got) != len(tt.want) {
+				t.Fatalf("handler received %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+
+			metrics := s.Metrics()
+			if metrics.LinesDropped != tt.wantDropped {
+				t.Errorf("LinesDropped = %d, want %d", metrics.LinesDropped, tt.wantDropped)
+			}
+			if metrics.LinesDelivered != uint64(len(tt.want)) {
+				t.Errorf("LinesDelivered = %d, want %d", metrics.LinesDelivered, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestStreamer_BackpressureStopWithoutStart(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:      handler,
+		Backpressure: BackpressureDropNewest,
+	})
+
+	// The delivery loop only runs once started, so a queued line has nowhere to go
+	s.deliver(LogMessage{Message: "1"})
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Stop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() without Start() never returned")
+	}
+
+	if got := handler.lines(); len(got) != 0 {
+		t.Errorf("handler received %v, want nothing", got)
+	}
+	if got := s.Metrics().LinesDropped; got != 1 {
+		t.Errorf("LinesDropped = %d, want 1", got)
+	}
+}
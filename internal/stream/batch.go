@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchLogHandler receives formatted log messages in batches
+type BatchLogHandler interface {
+	OnBatch(batch []LogMessage)
+}
+
+// podBatcher keeps a buffer of messages per pod and hands each pod's buffer to the
+// handler once it holds maxSize messages or its oldest message waited maxWait.
+// Batches are delivered one at a time, so the messages of a pod stay in order.
+type podBatcher struct {
+	handler BatchLogHandler
+	maxSize int
+	maxWait time.Duration
+	// delivered is called with the size of every batch handed to the handler
+	delivered func(n int)
+
+	// sending serializes deliveries, it is taken before mu
+	sending sync.Mutex
+	mu      sync.Mutex
+	batches map[string]*podBatch
+}
+
+// podBatch is the buffer of a single pod
+type podBatch struct {
+	messages []LogMessage
+	timer    *time.Timer
+}
+
+// newPodBatcher creates a batcher if a batch handler is set
+func newPodBatcher(handler BatchLogHandler, maxSize int, maxWait time.Duration, delivered func(n int)) *podBatcher {
+	if handler == nil {
+		return nil
+	}
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	return &podBatcher{
+		handler:   handler,
+		maxSize:   maxSize,
+		maxWait:   maxWait,
+		delivered: delivered,
+		batches:   make(map[string]*podBatch),
+	}
+}
+
+// add appends the message to its pod's buffer, delivering the buffer once it is full
+func (b *podBatcher) add(msg LogMessage) {
+	key := msg.Namespace + "/" + msg.PodName
+
+	b.mu.Lock()
+	batch, ok := b.batches[key]
+	if !ok {
+		batch = &podBatch{}
+		b.batches[key] = batch
+	}
+	batch.messages = append(batch.messages, msg)
+	full := len(batch.messages) >= b.maxSize
+
+	// The first message of a batch starts its wait
+	if !full && len(batch.messages) == 1 && b.maxWait > 0 {
+		batch.timer = time.AfterFunc(b.maxWait, func() {
+			b.flush(msg.Namespace, msg.PodName)
+		})
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.flush(msg.Namespace, msg.PodName)
+	}
+}
+
+// flush delivers the pod's buffer, if it holds any messages
+func (b *podBatcher) flush(namespace, podName string) {
+	if b == nil {
+		return
+	}
+
+	b.sending.Lock()
+	defer b.sending.Unlock()
+
+	b.mu.Lock()
+	messages := b.take(namespace + "/" + podName)
+	b.mu.Unlock()
+
+	b.send(messages)
+}
+
+// flushAll delivers the buffers of every pod
+func (b *podBatcher) flushAll() {
+	if b == nil {
+		return
+	}
+
+	b.sending.Lock()
+	defer b.sending.Unlock()
+
+	b.mu.Lock()
+	var pending [][]LogMessage
+	for key := range b.batches {
+		pending = append(pending, b.take(key))
+	}
+	b.mu.Unlock()
+
+	for _, messages := range pending {
+		b.send(messages)
+	}
+}
+
+// take removes the pod's buffer and returns its messages. The caller holds mu.
+func (b *podBatcher) take(key string) []LogMessage {
+	batch, ok := b.batches[key]
+	if !ok {
+		return nil
+	}
+
+	delete(b.batches, key)
+	if batch.timer != nil {
+		batch.timer.Stop()
+	}
+	return batch.messages
+}
+
+// send hands a batch to the handler. The caller holds sending.
+func (b *podBatcher) send(messages []LogMessage) {
+	if len(messages) == 0 {
+		return
+	}
+	b.handler.OnBatch(messages)
+	b.delivered(len(messages))
+}
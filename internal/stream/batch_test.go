@@ -0,0 +1,118 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder records the batches it receives and whether they came before OnEnd
+type batchRecorder struct {
+	recordingHandler
+	mu           sync.Mutex
+	batches      [][]LogMessage
+	afterEnd     bool
+	batchArrived chan struct{}
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{batchArrived: make(chan struct{}, 100)}
+}
+
+func (h *batchRecorder) OnBatch(batch []LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.batches = append(h.batches, batch)
+
+	h.recordingHandler.mu.Lock()
+	h.afterEnd = h.afterEnd || h.recordingHandler.ended
+	h.recordingHandler.mu.Unlock()
+	h.batchArrived <- struct{}{}
+}
+
+// summary returns the pod of each batch and the number of messages in it
+func (h *batchRecorder) summary(t *testing.T) []string {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var summary []string
+	for _, batch := range h.batches {
+		for _, msg := range batch {
+			if msg.PodName != batch[0].PodName {
+				t.Errorf("batch mixes pods %s and %s", batch[0].PodName, msg.PodName)
+			}
+		}
+		summary = append(summary, fmt.Sprintf("%s:%d", batch[0].PodName, len(batch)))
+	}
+	return summary
+}
+
+func TestStreamer_BatchByPodSize(t *testing.T) {
+	handler := newBatchRecorder()
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:      &handler.recordingHandler,
+		BatchHandler: handler,
+		BatchMaxSize: 3,
+	})
+
+	// Lines of two pods arrive interleaved
+	for i := 0; i < 4; i++ {
+		s.deliver(LogMessage{Namespace: "default", PodName: "web-1", Message: fmt.Sprint(i)})
+		s.deliver(LogMessage{Namespace: "default", PodName: "web-2", Message: fmt.Sprint(i)})
+	}
+	if got := fmt.Sprint(handler.summary(t)); got != "[web-1:3 web-2:3]" {
+		t.Errorf("batches = %s, want a full batch per pod", got)
+	}
+	if got := s.Metrics().LinesDelivered; got != 6 {
+		t.Errorf("LinesDelivered = %d, want the 6 lines handed over", got)
+	}
+
+	// A container ending hands over the rest of its pod's batch
+	s.batcher.flush("default", "web-1")
+	if got := fmt.Sprint(handler.summary(t)); got != "[web-1:3 web-2:3 web-1:1]" {
+		t.Errorf("batches = %s after web-1 ended", got)
+	}
+
+	// Stopping hands over every remaining batch before OnEnd
+	s.Stop()
+	if got := fmt.Sprint(handler.summary(t)); got != "[web-1:3 web-2:3 web-1:1 web-2:1]" {
+		t.Errorf("batches = %s after stopping", got)
+	}
+	if handler.afterEnd || !handler.recordingHandler.ended {
+		t.Error("a batch was delivered after OnEnd")
+	}
+
+	// Messages within a batch keep their order
+	for _, batch := range handler.batches[:2] {
+		for i, msg := range batch {
+			if msg.Message != fmt.Sprint(i) {
+				t.Errorf("batch of %s has %q at %d", msg.PodName, msg.Message, i)
+			}
+		}
+	}
+}
+
+func TestStreamer_BatchByPodWait(t *testing.T) {
+	handler := newBatchRecorder()
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:      &handler.recordingHandler,
+		BatchHandler: handler,
+		BatchMaxSize: 100,
+		BatchMaxWait: 10 * time.Millisecond,
+	})
+	defer s.Stop()
+
+	s.deliver(LogMessage{Namespace: "default", PodName: "web-1", Message: "first"})
+	s.deliver(LogMessage{Namespace: "default", PodName: "web-1", Message: "second"})
+
+	select {
+	case <-handler.batchArrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not delivered after its wait")
+	}
+	if got := fmt.Sprint(handler.summary(t)); got != "[web-1:2]" {
+		t.Errorf("batches = %s, want the waiting batch of web-1", got)
+	}
+}
@@ -0,0 +1,173 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultCheckpointInterval is how often checkpoints are saved while streaming
+const DefaultCheckpointInterval = 10 * time.Second
+
+// CheckpointKey identifies a single container log stream
+type CheckpointKey struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// CheckpointStore persists the time each container stream was last seen, so a restarted
+// streamer can resume near where it left off
+type CheckpointStore interface {
+	// Load returns the saved checkpoints, an empty map if none were saved yet
+	Load() (map[CheckpointKey]time.Time, error)
+	// Save replaces the saved checkpoints
+	Save(checkpoints map[CheckpointKey]time.Time) error
+}
+
+// checkpointer tracks the last delivered line of every container stream and saves it to
+// the store. A nil checkpointer does nothing.
+type checkpointer struct {
+	store    CheckpointStore
+	interval time.Duration
+	// saving keeps a periodic save from overwriting a newer final save
+	saving sync.Mutex
+
+	mu       sync.Mutex
+	lastSeen map[CheckpointKey]time.Time
+	dirty    bool
+}
+
+// newCheckpointer creates a checkpointer if a store is set
+func newCheckpointer(store CheckpointStore, interval time.Duration) *checkpointer {
+	if store == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	return &checkpointer{
+		store:    store,
+		interval: interval,
+		lastSeen: make(map[CheckpointKey]time.Time),
+	}
+}
+
+// load reads the saved checkpoints from the store
+func (c *checkpointer) load() error {
+	if c == nil {
+		return nil
+	}
+
+	checkpoints, err := c.store.Load()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, seen := range checkpoints {
+		c.lastSeen[key] = seen
+	}
+	return nil
+}
+
+// resumeFrom returns the time the container stream was last seen, if known
+func (c *checkpointer) resumeFrom(namespace, podName, containerName string) (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen, ok := c.lastSeen[CheckpointKey{Namespace: namespace, Pod: podName, Container: containerName}]
+	return seen, ok
+}
+
+// record moves the checkpoint of the message's container stream to the kubelet timestamp
+// of the message, which the since time of a resumed stream is compared against. Messages
+// without one, such as events, leave the checkpoint where it is.
+func (c *checkpointer) record(msg LogMessage) {
+	if c == nil || msg.IsEvent || msg.kubelet.IsZero() {
+		return
+	}
+
+	key := CheckpointKey{Namespace: msg.Namespace, Pod: msg.PodName, Container: msg.ContainerName}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.kubelet.After(c.lastSeen[key]) {
+		c.lastSeen[key] = msg.kubelet
+		c.dirty = true
+	}
+}
+
+// forget drops the checkpoints of a pod that is no longer streamed, so the checkpoints of
+// pods that went away don't accumulate. The next periodic or final save removes them from
+// the store.
+func (c *checkpointer) forget(namespace, podName string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.lastSeen {
+		if key.Namespace == namespace && key.Pod == podName {
+			delete(c.lastSeen, key)
+			c.dirty = true
+		}
+	}
+}
+
+// save writes the checkpoints to the store if they changed since the last save
+func (c *checkpointer) save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.saving.Lock()
+	defer c.saving.Unlock()
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	snapshot := make(map[CheckpointKey]time.Time, len(c.lastSeen))
+	for key, seen := range c.lastSeen {
+		snapshot[key] = seen
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	if err := c.store.Save(snapshot); err != nil {
+		// Try again on the next save
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// run saves the checkpoints periodically until the context is done
+func (c *checkpointer) run(ctx context.Context, reportError func(error)) {
+	if c == nil {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.save(); err != nil {
+				reportError(NewLogStreamError(err, false, "failed to save checkpoints"))
+			}
+		}
+	}
+}
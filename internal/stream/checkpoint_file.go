@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileCheckpointStore is a CheckpointStore keeping the checkpoints in a JSON file.
+// The file is replaced atomically on every save, so a crash leaves either the
+// previous or the new checkpoints behind, never a partial file.
+type FileCheckpointStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// fileCheckpoint is a single checkpoint as stored in the file
+type fileCheckpoint struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// NewFileCheckpointStore creates a store keeping the checkpoints in the file at path
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load reads the checkpoints from the file, a missing file holds no checkpoints
+func (s *FileCheckpointStore) Load() (map[CheckpointKey]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints := make(map[CheckpointKey]time.Time)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	var entries []fileCheckpoint
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint file %s: %w", s.path, err)
+	}
+	for _, entry := range entries {
+		checkpoints[CheckpointKey{Namespace: entry.Namespace, Pod: entry.Pod, Container: entry.Container}] = entry.LastSeen
+	}
+	return checkpoints, nil
+}
+
+// Save writes the checkpoints to a temporary file and renames it over the checkpoint file
+func (s *FileCheckpointStore) Save(checkpoints map[CheckpointKey]time.Time) error {
+	entries := make([]fileCheckpoint, 0, len(checkpoints))
+	for key, seen := range checkpoints {
+		entries = append(entries, fileCheckpoint{
+			Namespace: key.Namespace,
+			Pod:       key.Pod,
+			Container: key.Container,
+			LastSeen:  seen,
+		})
+	}
+	// Keep the file stable so it diffs well
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file: %w", err)
+	}
+	return nil
+}
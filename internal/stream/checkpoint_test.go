@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileCheckpointStore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileCheckpointStore(filepath.Join(dir, "checkpoints.json"))
+
+	// Nothing saved yet
+	loaded, err := store.Load()
+	if err != nil || len(loaded) != 0 {
+		t.Fatalf("Load() = %v, %v before the first save, want no checkpoints", loaded, err)
+	}
+
+	seen := time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.UTC)
+	want := map[CheckpointKey]time.Time{
+		{Namespace: "default", Pod: "web-1", Container: "nginx"}:   seen,
+		{Namespace: "default", Pod: "web-1", Container: "sidecar"}: seen.Add(time.Second),
+		{Namespace: "other", Pod: "db-1", Container: "postgres"}:   seen.Add(time.Minute),
+	}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err = NewFileCheckpointStore(filepath.Join(dir, "checkpoints.json")).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != len(want) {
+		t.Fatalf("Load() = %v, want %v", loaded, want)
+	}
+	for key, seen := range want {
+		if !loaded[key].Equal(seen) {
+			t.Errorf("checkpoint %v = %v, want %v", key, loaded[key], seen)
+		}
+	}
+
+	// The temporary file was renamed over the checkpoint file
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("checkpoint directory holds %d files, want only the checkpoint file", len(entries))
+	}
+}
+
+func TestFileCheckpointStore_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	if err := os.WriteFile(path, []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileCheckpointStore(path).Load(); err == nil {
+		t.Error("Load() of a truncated file succeeded")
+	}
+}
+
+func TestCheckpointer_ConcurrentStreams(t *testing.T) {
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	c := newCheckpointer(store, time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(container string) {
+			defer wg.Done()
+			for j := 1; j <= 100; j++ {
+				c.record(LogMessage{
+					Namespace:     "default",
+					PodName:       "web-1",
+					ContainerName: container,
+					kubelet:       start.Add(time.Duration(j) * time.Second),
+				})
+				if j%10 == 0 {
+					if err := c.save(); err != nil {
+						t.Errorf("save() error = %v", err)
+					}
+				}
+			}
+		}(fmt.Sprintf("c%d", i))
+	}
+	wg.Wait()
+
+	if err := c.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 8 {
+		t.Fatalf("Load() returned %d checkpoints, want 8", len(loaded))
+	}
+	for key, seen := range loaded {
+		if want := start.Add(100 * time.Second); !seen.Equal(want) {
+			t.Errorf("checkpoint %v = %v, want the last line at %v", key, seen, want)
+		}
+	}
+}
+
+func TestStreamer_ResumesFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	pod := newTestPod("default", "web-1", "nginx")
+
+	// The first run delivers some lines and saves its checkpoint when stopped. The
+	// checkpoint is the kubelet time of the last line, not the time it was read.
+	first := newTestStreamer(t, &StreamerConfig{
+		Handler:         &recordingHandler{},
+		CheckpointStore: NewFileCheckpointStore(path),
+		TimestampSource: TimestampIngestion,
+	})
+	stream := io.NopCloser(strings.NewReader("2024-01-01T12:00:00.5Z one\n2024-01-01T12:00:01.5Z two\n"))
+	if err := first.processLogStream(context.Background(), stream, pod.Name, "nginx", pod.Namespace, nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	first.Stop()
+
+	seen, ok := first.checkpoints.resumeFrom(pod.Namespace, pod.Name, "nginx")
+	if want := time.Date(2024, 1, 1, 12, 0, 1, 500000000, time.UTC); !ok || !seen.Equal(want) {
+		t.Fatalf("checkpoint = %v, %v, want the kubelet time %v", seen, ok, want)
+	}
+
+	// The next run starts streaming the container from the checkpoint
+	second := newTestStreamer(t, &StreamerConfig{
+		Handler:         &recordingHandler{},
+		CheckpointStore: NewFileCheckpointStore(path),
+	})
+	if err := second.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer second.Stop()
+
+	opts := second.podLogOptions(pod, "nginx")
+	if opts.SinceTime == nil || !opts.SinceTime.Time.Equal(seen) {
+		t.Errorf("SinceTime = %v, want the checkpoint %v", opts.SinceTime, seen)
+	}
+	if opts := second.podLogOptions(pod, "sidecar"); opts.SinceTime != nil {
+		t.Errorf("SinceTime = %v for a container without checkpoint, want nil", opts.SinceTime)
+	}
+}
+
+func TestCheckpointer_ForgetPod(t *testing.T) {
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	c := newCheckpointer(store, time.Hour)
+	seen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.record(LogMessage{Namespace: "default", PodName: "web-1", ContainerName: "app", kubelet: seen})
+	c.record(LogMessage{Namespace: "default", PodName: "web-2", ContainerName: "app", kubelet: seen})
+	if err := c.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	c.forget("default", "web-1")
+
+	// Forgetting leaves the store alone until the next save
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("Load() = %v, want both pods until the next save", loaded)
+	}
+	if _, ok := c.resumeFrom("default", "web-1", "app"); ok {
+		t.Error("checkpoint of a forgotten pod is still kept")
+	}
+	if _, ok := c.resumeFrom("default", "web-2", "app"); !ok {
+		t.Error("checkpoint of another pod was dropped")
+	}
+
+	// The next save removes the forgotten pod from the store
+	if err := c.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	loaded, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok := loaded[CheckpointKey{Namespace: "default", Pod: "web-1", Container: "app"}]; ok || len(loaded) != 1 {
+		t.Errorf("Load() = %v, want only web-2", loaded)
+	}
+}
@@ -0,0 +1,48 @@
+package stream
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// matchesContainerState checks a container's status against the container state filter.
+// "running" matches a running container, "terminated" a container that terminated, or
+// whose previous instance terminated before it was restarted. A container without a
+// status, such as one that hasn't been created yet, matches neither.
+func (s *Streamer) matchesContainerState(pod *corev1.Pod, name string) bool {
+	switch s.filter.ContainerState {
+	case "running":
+		status := containerStatus(pod, name)
+		return status != nil && status.State.Running != nil
+	case "terminated":
+		status := containerStatus(pod, name)
+		return status != nil && (status.State.Terminated != nil || status.LastTerminationState.Terminated != nil)
+	default:
+		return true
+	}
+}
+
+// applyContainerState makes the stream of a terminated container read the log of the
+// terminated instance once: the current one if the container is still terminated, the
+// previous one if it has been restarted since
+func (s *Streamer) applyContainerState(opts *corev1.PodLogOptions, pod *corev1.Pod) {
+	if s.filter.ContainerState != "terminated" {
+		return
+	}
+	status := containerStatus(pod, opts.Container)
+	if status == nil {
+		return
+	}
+
+	// The log of a terminated instance doesn't grow
+	opts.Follow = false
+	if status.State.Terminated == nil && status.LastTerminationState.Terminated != nil {
+		opts.Previous = true
+	}
+}
+
+// streamsByState reports whether the container state or restart count filter is set, so
+// containers whose status changes may start matching after the pod is first seen
+func (s *Streamer) streamsByState() bool {
+	return s.filter.ContainerState == "running" || s.filter.ContainerState == "terminated" ||
+		s.filter.MinRestartCount > 0
+}
@@ -0,0 +1,170 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	"github.com/archsyscall/klogstream/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// withContainerStatuses sets the statuses of the pod's containers
+func withContainerStatuses(pod *corev1.Pod, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	pod.Status.ContainerStatuses = statuses
+	return pod
+}
+
+var (
+	running    = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	waiting    = corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}
+	terminated = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}
+)
+
+// logRequests returns the options of the log requests made so far, keyed by container
+func logRequests(clientset *fake.Clientset) map[string]*corev1.PodLogOptions {
+	requests := make(map[string]*corev1.PodLogOptions)
+	for _, action := range clientset.Actions() {
+		if action.GetSubresource() != "log" {
+			continue
+		}
+		opts := action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+		requests[opts.Container] = opts
+	}
+	return requests
+}
+
+// waitForLogRequests waits until logs were requested for every container
+func waitForLogRequests(t *testing.T, clientset *fake.Clientset, containers ...string) map[string]*corev1.PodLogOptions {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		requests := logRequests(clientset)
+		missing := false
+		for _, container := range containers {
+			if requests[container] == nil {
+				missing = true
+			}
+		}
+		if !missing {
+			return requests
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("logs requested for %v, want %v", requests, containers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamer_ContainerStateRunning(t *testing.T) {
+	pod := withContainerStatuses(newTestPod("default", "web-1", "app", "sidecar"),
+		corev1.ContainerStatus{Name: "app", State: running},
+		corev1.ContainerStatus{Name: "sidecar", State: waiting},
+	)
+	clientset := fake.NewSimpleClientset(pod)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: "running",
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	// Only the running container is streamed
+	requests := waitForLogRequests(t, clientset, "app")
+	if requests["sidecar"] != nil {
+		t.Error("logs of the waiting container were requested")
+	}
+
+	// The sidecar is streamed once it is running too
+	started := pod.DeepCopy()
+	started.Status.ContainerStatuses[1].State = running
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: started})
+	waitForLogRequests(t, clientset, "app", "sidecar")
+}
+
+func TestStreamer_ContainerStateTerminated(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		// A job whose container terminated
+		withContainerStatuses(newTestPod("default", "job-1", "worker"),
+			corev1.ContainerStatus{Name: "worker", State: terminated},
+		),
+		// A pod whose app crashed and was restarted, next to a sidecar that never stopped
+		withContainerStatuses(newTestPod("default", "web-1", "app", "sidecar"),
+			corev1.ContainerStatus{Name: "app", State: running, LastTerminationState: terminated, RestartCount: 1},
+			corev1.ContainerStatus{Name: "sidecar", State: running},
+		),
+	)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: "terminated",
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	requests := waitForLogRequests(t, clientset, "worker", "app")
+	if requests["sidecar"] != nil {
+		t.Error("logs of the running container were requested")
+	}
+
+	// The terminated container's own log is read once
+	if worker := requests["worker"]; worker.Follow || worker.Previous {
+		t.Errorf("worker log options = %+v, want the current instance without following", worker)
+	}
+	// The restarted container's previous instance is read once
+	if app := requests["app"]; app.Follow || !app.Previous {
+		t.Errorf("app log options = %+v, want the previous instance without following", app)
+	}
+}
+
+func TestStreamer_MinRestartCountReached(t *testing.T) {
+	pod := withContainerStatuses(newTestPod("default", "web-1", "app", "sidecar"),
+		corev1.ContainerStatus{Name: "app", State: running, RestartCount: 1},
+		corev1.ContainerStatus{Name: "sidecar", State: running, RestartCount: 2},
+	)
+	clientset := fake.NewSimpleClientset(pod)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:      []string{"default"},
+			ContainerState:  filter.DefaultContainerState,
+			MinRestartCount: 2,
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	// Only the container that restarted often enough is streamed
+	requests := waitForLogRequests(t, clientset, "sidecar")
+	if requests["app"] != nil {
+		t.Error("logs of the container below the restart count were requested")
+	}
+
+	// The app is streamed once it crash loops after the start
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 2
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: restarted})
+	waitForLogRequests(t, clientset, "app", "sidecar")
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// graceTimers tracks the timers closing the streams of deleted pods once their grace
+// period is over, so they can be stopped with the streamer
+type graceTimers struct {
+	mu      sync.Mutex
+	timers  map[*time.Timer]struct{}
+	stopped bool
+	// running counts the callbacks in progress, stop waits for them
+	running sync.WaitGroup
+}
+
+// after calls f once d has passed, unless stop is called first
+func (g *graceTimers) after(d time.Duration, f func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stopped {
+		return
+	}
+	if g.timers == nil {
+		g.timers = make(map[*time.Timer]struct{})
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		g.mu.Lock()
+		if g.stopped {
+			g.mu.Unlock()
+			return
+		}
+		delete(g.timers, timer)
+		g.running.Add(1)
+		g.mu.Unlock()
+
+		defer g.running.Done()
+		f()
+	})
+	g.timers[timer] = struct{}{}
+}
+
+// stop cancels the pending timers and waits for the callbacks already running
+func (g *graceTimers) stop() {
+	g.mu.Lock()
+	g.stopped = true
+	for timer := range g.timers {
+		timer.Stop()
+	}
+	g.timers = nil
+	g.mu.Unlock()
+
+	g.running.Wait()
+}
@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestStreamer_PodChurnReleasesStreams(t *testing.T) {
+	s := newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}})
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := runtime.NumGoroutine()
+
+	// Each pod leaves in a different way: deleted, completed or replaced by a pod of the same name
+	ends := []func(pod *corev1.Pod){
+		func(pod *corev1.Pod) {
+			s.handlePodEvent(ctx, watch.Event{Type: watch.Deleted, Object: pod})
+		},
+		func(pod *corev1.Pod) {
+			completed := pod.DeepCopy()
+			completed.Status.Phase = corev1.PodSucceeded
+			s.handlePodEvent(ctx, watch.Event{Type: watch.Modified, Object: completed})
+		},
+		func(pod *corev1.Pod) {
+			replacement := pod.DeepCopy()
+			replacement.UID = pod.UID + "-new"
+			s.startPodLogStreamer(ctx, replacement)
+			s.handlePodEvent(ctx, watch.Event{Type: watch.Deleted, Object: replacement})
+		},
+	}
+
+	var streams []context.Context
+	for round := 0; round < 10; round++ {
+		for i, end := range ends {
+			pod := newTestPod("default", fmt.Sprintf("web-%d-%d", round, i), "app")
+			pod.UID = types.UID(pod.Name)
+			s.handlePodEvent(ctx, watch.Event{Type: watch.Added, Object: pod})
+
+			value, ok := s.active.Load(podKey(pod.Namespace, pod.Name))
+			if !ok {
+				t.Fatalf("pod %s is not streamed", pod.Name)
+			}
+			streams = append(streams, value.(*activePod).ctx)
+			end(pod)
+		}
+	}
+
+	for i, streamCtx := range streams {
+		if streamCtx.Err() == nil {
+			t.Errorf("streams of pod %d were not closed", i)
+		}
+	}
+	if n := countActive(s); n != 0 {
+		t.Errorf("%d pods still tracked as active", n)
+	}
+
+	// Every stream goroutine exits once its pod is gone
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines running, want at most %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamer_StopCancelsDeletionGrace(t *testing.T) {
+	pod := newTestPod("default", "web-1", "app")
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:                &recordingHandler{},
+		PodDeletionGracePeriod: time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.active.Store(podKey(pod.Namespace, pod.Name), &activePod{pod: pod, cancel: cancel})
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: pod})
+
+	s.Stop()
+
+	s.graceTimers.mu.Lock()
+	pending := len(s.graceTimers.timers)
+	s.graceTimers.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d grace timers still pending after Stop", pending)
+	}
+	if ctx.Err() != nil {
+		t.Error("grace period callback ran after Stop")
+	}
+}
+
+// countActive returns the number of pods tracked as active
+func countActive(s *Streamer) int {
+	n := 0
+	s.active.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
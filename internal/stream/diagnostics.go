@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// diagnostics writes the streamer's internal decisions, such as why a pod was skipped or
+// when a stream is retried, to a structured logger at debug level. They describe the
+// streamer itself, not the log data it delivers. A nil diagnostics writes nothing.
+type diagnostics struct {
+	logger *slog.Logger
+}
+
+// newDiagnostics creates diagnostics writing to logger, with the streamer's name on every
+// record if it has one. It returns nil without a logger.
+func newDiagnostics(logger *slog.Logger, name string) *diagnostics {
+	if logger == nil {
+		return nil
+	}
+	if name != "" {
+		logger = logger.With("streamer", name)
+	}
+	return &diagnostics{logger: logger}
+}
+
+// podMatched records that a pod passed the pod filters
+func (d *diagnostics) podMatched(pod *corev1.Pod) {
+	if d == nil {
+		return
+	}
+	d.logger.Debug("pod matched", "namespace", pod.Namespace, "pod", pod.Name)
+}
+
+// podSkipped records that a pod was rejected by a pod filter
+func (d *diagnostics) podSkipped(pod *corev1.Pod, reason string) {
+	if d == nil {
+		return
+	}
+	d.logger.Debug("pod skipped", "namespace", pod.Namespace, "pod", pod.Name, "reason", reason)
+}
+
+// containerSkipped records that a container of a matching pod was rejected by a container filter
+func (d *diagnostics) containerSkipped(pod *corev1.Pod, container, reason string) {
+	if d == nil {
+		return
+	}
+	d.logger.Debug("container skipped", "namespace", pod.Namespace, "pod", pod.Name,
+		"container", container, "reason", reason)
+}
+
+// streamOpened records that a container's log stream was opened
+func (d *diagnostics) streamOpened(namespace, podName, containerName string) {
+	if d == nil {
+		return
+	}
+	d.logger.Debug("stream opened", "namespace", namespace, "pod", podName, "container", containerName)
+}
+
+// streamClosed records that a container's log stream ended, with the read error if any
+func (d *diagnostics) streamClosed(namespace, podName, containerName string, err error) {
+	if d == nil {
+		return
+	}
+	args := []any{"namespace", namespace, "pod", podName, "container", containerName}
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	d.logger.Debug("stream closed", args...)
+}
+
+// retryScheduled records that opening a container's log stream failed and is retried after backoff
+func (d *diagnostics) retryScheduled(namespace, podName, containerName string, attempt int, backoff time.Duration, err error) {
+	if d == nil {
+		return
+	}
+	d.logger.Debug("retry scheduled", "namespace", namespace, "pod", podName, "container", containerName,
+		"attempt", attempt, "backoff", backoff, "error", err)
+}
@@ -0,0 +1,61 @@
+package stream
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestStreamer_DiagnosticsPodSkipped(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: &recordingHandler{},
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			PodNameRegex:   regexp.MustCompile(`^web-`),
+		},
+		PodFilter: func(pod *corev1.Pod) bool { return pod.Name != "web-2" },
+		Logger:    logger,
+		Name:      "frontend",
+	})
+
+	tests := []struct {
+		pod  string
+		want string
+	}{
+		{pod: "db-1", want: `msg="pod skipped" streamer=frontend namespace=default pod=db-1 reason="pod name does not match ^web-"`},
+		{pod: "web-2", want: `msg="pod skipped" streamer=frontend namespace=default pod=web-2 reason="rejected by the pod filter function"`},
+		{pod: "web-1", want: `msg="pod matched" streamer=frontend namespace=default pod=web-1`},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		s.shouldStreamPod(newTestPod("default", tt.pod, "app"))
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s: logged %q, want %q", tt.pod, out.String(), tt.want)
+		}
+	}
+}
+
+func TestStreamer_DiagnosticsWithoutLogger(t *testing.T) {
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: &recordingHandler{},
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			PodNameRegex:   regexp.MustCompile(`^web-`),
+		},
+	})
+
+	// Without a logger nothing is written and nothing panics
+	if s.shouldStreamPod(newTestPod("default", "db-1", "app")) {
+		t.Error("shouldStreamPod() = true, want the pod skipped")
+	}
+}
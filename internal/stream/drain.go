@@ -0,0 +1,36 @@
+package stream
+
+import (
+	"context"
+	"time"
+)
+
+// beginStopping records when the streamer began stopping, in Unix nanoseconds, for the
+// drain timeout
+func (s *Streamer) beginStopping() {
+	s.stopping.Store(time.Now().UnixNano())
+}
+
+// drainable reports whether a stream exiting now should still flush the message its
+// multiline matcher buffered. With a drain timeout set it may until the timeout has
+// passed since the streamer began stopping, later streams give up on their buffer.
+func (s *Streamer) drainable() bool {
+	if s.drainTimeout <= 0 {
+		return false
+	}
+	stopping := s.stopping.Load()
+	return stopping == 0 || time.Since(time.Unix(0, stopping)) < s.drainTimeout
+}
+
+// streamStopping reports whether a stream has to end because its context is done or the
+// streamer is stopping
+func (s *Streamer) streamStopping(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-s.stopCh:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// stoppingReader returns the first chunk, then waits for release before returning the
+// rest, like a container that keeps logging while the streamer stops. With err set the
+// read after release fails instead, like a stream whose request was cancelled.
+type stoppingReader struct {
+	chunks  []string
+	err     error
+	waiting chan struct{}
+	release chan struct{}
+}
+
+func (r *stoppingReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if len(r.chunks) == 1 {
+		close(r.waiting)
+		<-r.release
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func (r *stoppingReader) Close() error { return nil }
+
+func TestStreamer_DrainTimeout(t *testing.T) {
+	trace := "java.lang.IllegalStateException: boom\n  at com.example.Service.run(Service.java:42)\n  at java.lang.Thread.run(Thread.java:750)\n"
+
+	tests := []struct {
+		name         string
+		drainTimeout time.Duration
+		// late stops the stream after the drain timeout has passed
+		late bool
+		// readErr fails the read cut by the stop instead of returning more lines
+		readErr error
+		want    []string
+	}{
+		{
+			name: "buffer dropped without drain",
+			want: nil,
+		},
+		{
+			name:         "buffer delivered within the drain timeout",
+			drainTimeout: time.Minute,
+			want: []string{"java.lang.IllegalStateException: boom\n" +
+				"  at com.example.Service.run(Service.java:42)\n" +
+				"  at java.lang.Thread.run(Thread.java:750)"},
+		},
+		{
+			name:         "buffer delivered when the stop fails the read",
+			drainTimeout: time.Minute,
+			readErr:      context.Canceled,
+			want: []string{"java.lang.IllegalStateException: boom\n" +
+				"  at com.example.Service.run(Service.java:42)\n" +
+				"  at java.lang.Thread.run(Thread.java:750)"},
+		},
+		{
+			name:    "buffer dropped without drain when the stop fails the read",
+			readErr: context.Canceled,
+			want:    nil,
+		},
+		{
+			name:         "buffer dropped after the drain timeout",
+			drainTimeout: 10 * time.Millisecond,
+			late:         true,
+			want:         nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{Handler: handler, Matcher: indentMatcher{}, DrainTimeout: tt.drainTimeout})
+
+			stream := &stoppingReader{
+				chunks:  []string{trace, "  at java.lang.Thread.start(Thread.java:800)\n"},
+				err:     tt.readErr,
+				waiting: make(chan struct{}),
+				release: make(chan struct{}),
+			}
+			done := make(chan error, 1)
+			go func() {
+				done <- s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil)
+			}()
+
+			// The trace is buffered, waiting for its next line, when the streamer stops
+			<-stream.waiting
+			s.Stop()
+			if tt.late {
+				time.Sleep(2 * tt.drainTimeout)
+			}
+			close(stream.release)
+			if err := <-done; err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+
+			got := handler.lines()
+			if len(got) != len(tt.want) {
+				t.Fatalf("received %q, want %q", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("message %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package stream
+
+import (
+	"context"
+)
+
+// EndReason tells why a streamer ended
+type EndReason int
+
+const (
+	// EndReasonNone means the streamer has not ended
+	EndReasonNone EndReason = iota
+	// EndReasonStopped means Stop was called
+	EndReasonStopped
+	// EndReasonCompleted means the streamer stopped itself after its maximum duration
+	EndReasonCompleted
+)
+
+// String returns the name of the end reason
+func (r EndReason) String() string {
+	switch r {
+	case EndReasonStopped:
+		return "stopped"
+	case EndReasonCompleted:
+		return "completed"
+	default:
+		return "none"
+	}
+}
+
+// EndReason returns why the streamer ended, or EndReasonNone while it is running
+func (s *Streamer) EndReason() EndReason {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endReason
+}
+
+// Done returns a channel that is closed when the streamer starts stopping, whether
+// because Stop was called or because the maximum duration elapsed
+func (s *Streamer) Done() <-chan struct{} {
+	return s.stopCh
+}
+
+// stopAfterMaxDuration stops the streamer once the maximum duration elapses,
+// unless it is stopped or the context is cancelled first
+func (s *Streamer) stopAfterMaxDuration(ctx context.Context) {
+	if s.maxDuration <= 0 {
+		return
+	}
+
+	elapsed := s.after(s.maxDuration)
+	go func() {
+		select {
+		case <-elapsed:
+			s.stop(EndReasonCompleted)
+		case <-s.stopCh:
+		case <-ctx.Done():
+		}
+	}()
+}
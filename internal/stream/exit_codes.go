@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ContainerExit describes how a container terminated
+type ContainerExit struct {
+	// ExitCode is the exit code of the container's process
+	ExitCode int32
+	// Reason is the kubelet's brief reason for the termination, such as "Completed" or "OOMKilled"
+	Reason string
+}
+
+// containerExits holds the terminations of a pod's containers waiting to be delivered
+// after the end of the container's log
+type containerExits struct {
+	mu sync.Mutex
+	// seen identifies the last termination captured for each container
+	seen map[string]string
+	// pending holds the captured terminations not delivered yet
+	pending map[string]ContainerExit
+}
+
+// captureExits records the termination of every streamed container of the pod that
+// terminated since the pod was last seen
+func (s *Streamer) captureExits(active *activePod, pod *corev1.Pod) {
+	if !s.exitCodes {
+		return
+	}
+
+	exits := &active.exits
+	exits.mu.Lock()
+	defer exits.mu.Unlock()
+
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		status := containerStatus(pod, container.Name)
+		if status == nil || status.State.Terminated == nil || !s.shouldStreamContainer(pod, container) {
+			continue
+		}
+
+		// The same termination is reported by every later event until the container restarts
+		terminated := status.State.Terminated
+		id := terminated.ContainerID + "@" + terminated.FinishedAt.String()
+		if exits.seen[container.Name] == id {
+			continue
+		}
+
+		if exits.seen == nil {
+			exits.seen = make(map[string]string)
+			exits.pending = make(map[string]ContainerExit)
+		}
+		exits.seen[container.Name] = id
+		exits.pending[container.Name] = ContainerExit{ExitCode: terminated.ExitCode, Reason: terminated.Reason}
+	}
+}
+
+// deliverExit delivers an event message for the captured termination of the container,
+// if there is one, once its log has been read to the end
+func (s *Streamer) deliverExit(active *activePod, namespace, podName, containerName string) {
+	if !s.exitCodes {
+		return
+	}
+
+	exits := &active.exits
+	exits.mu.Lock()
+	exit, ok := exits.pending[containerName]
+	delete(exits.pending, containerName)
+	exits.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	message := fmt.Sprintf("Terminated with exit code %d", exit.ExitCode)
+	if exit.Reason != "" {
+		message += " (" + exit.Reason + ")"
+	}
+	msg := LogMessage{
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: containerName,
+		Timestamp:     time.Now(),
+		Message:       message,
+		Raw:           []byte(message),
+		IsEvent:       true,
+		Exit:          &exit,
+	}
+	active.label(&msg)
+	s.deliver(msg)
+}
@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestStreamer_ExitCodeCapture(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, BatchWorkloads: true, ExitCodeCapture: true})
+
+	pod := newTestPod("default", "job-1", "worker")
+	s.startPodLogStreamer(context.Background(), pod)
+
+	// The job's container fails while its log is being read
+	failed := pod.DeepCopy()
+	failed.Status.Phase = corev1.PodFailed
+	failed.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: "worker",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode:    1,
+			Reason:      "Error",
+			ContainerID: "containerd://abc",
+			FinishedAt:  metav1.NewTime(time.Now()),
+		}},
+	}}
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: failed})
+	// Later events report the same termination again
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: failed.DeepCopy()})
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream of a failed pod kept reconnecting after the end of its log")
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	var exits []LogMessage
+	for _, msg := range handler.messages {
+		if msg.Exit != nil {
+			exits = append(exits, msg)
+		}
+	}
+	if len(exits) != 1 {
+		t.Fatalf("received %d exit messages, want 1", len(exits))
+	}
+
+	exit := exits[0]
+	if !exit.IsEvent || exit.ContainerName != "worker" || exit.Exit.ExitCode != 1 || exit.Exit.Reason != "Error" {
+		t.Errorf("exit message = %+v, want an event for worker with exit code 1 (Error)", exit)
+	}
+	if exit.Message != "Terminated with exit code 1 (Error)" {
+		t.Errorf("exit message text = %q", exit.Message)
+	}
+	if last := handler.messages[len(handler.messages)-1]; last.Exit == nil {
+		t.Errorf("last message = %q, want the exit after the container's log", last.Message)
+	}
+}
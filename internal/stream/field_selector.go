@@ -0,0 +1,56 @@
+package stream
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// podFields returns the fields of a pod a field selector can select on, the same fields
+// the API server supports for pods
+func podFields(pod *corev1.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":            pod.Name,
+		"metadata.namespace":       pod.Namespace,
+		"spec.nodeName":            pod.Spec.NodeName,
+		"spec.restartPolicy":       string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":       pod.Spec.SchedulerName,
+		"spec.serviceAccountName":  pod.Spec.ServiceAccountName,
+		"spec.hostNetwork":         boolField(pod.Spec.HostNetwork),
+		"status.phase":             string(pod.Status.Phase),
+		"status.podIP":             pod.Status.PodIP,
+		"status.nominatedNodeName": pod.Status.NominatedNodeName,
+	}
+}
+
+// boolField formats a boolean field the way the API server selects on it
+func boolField(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+// fieldSelector returns the server-side field selector to list and watch pods with. When
+// listing cluster-wide, the excluded namespaces are left out by the server too.
+func (s *Streamer) fieldSelector() string {
+	var selectors []fields.Selector
+	if s.filter.FieldSelector != nil && !s.filter.FieldSelector.Empty() {
+		selectors = append(selectors, s.filter.FieldSelector)
+	}
+	if s.filter.AllNamespaces {
+		for _, namespace := range s.filter.ExcludeNamespaces {
+			selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.namespace", namespace))
+		}
+	}
+	if len(selectors) == 0 {
+		return ""
+	}
+	return fields.AndSelectors(selectors...).String()
+}
+
+// matchesFields checks the pod's fields against the field selector. The server already
+// selects on them, but a pod changed in place, such as one whose phase changed, is checked
+// again.
+func (s *Streamer) matchesFields(pod *corev1.Pod) bool {
+	return s.filter.FieldSelector == nil || s.filter.FieldSelector.Matches(podFields(pod))
+}
@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	"github.com/archsyscall/klogstream/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestStreamer_FieldSelector(t *testing.T) {
+	onWorker1 := newTestPod("default", "web-1", "app")
+	onWorker1.Spec.NodeName = "worker-1"
+	onWorker2 := newTestPod("default", "web-2", "other")
+	onWorker2.Spec.NodeName = "worker-2"
+	clientset := fake.NewSimpleClientset(onWorker1, onWorker2)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			FieldSelector:  fields.OneTermEqualSelector("spec.nodeName", "worker-1"),
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	waitForLogRequests(t, clientset, "app")
+
+	// The selector is sent to the server when listing and watching
+	for _, action := range clientset.Actions() {
+		if action.GetResource().Resource != "pods" || action.GetSubresource() != "" {
+			continue
+		}
+		_, fieldSelector := podSelectors(action)
+		if got := fieldSelector.String(); got != "spec.nodeName=worker-1" {
+			t.Errorf("%s pods with field selector %q, want spec.nodeName=worker-1", action.GetVerb(), got)
+		}
+	}
+
+	// The fake clientset doesn't select on fields, the pod on the other node is skipped
+	// by the streamer itself
+	time.Sleep(50 * time.Millisecond)
+	if logRequests(clientset)["other"] != nil {
+		t.Error("logs of the pod on worker-2 were requested")
+	}
+}
+
+// podSelectors returns the label and field selectors sent with a pod list or watch action
+func podSelectors(action k8stesting.Action) (labels.Selector, fields.Selector) {
+	switch action := action.(type) {
+	case k8stesting.ListAction:
+		restrictions := action.GetListRestrictions()
+		return restrictions.Labels, restrictions.Fields
+	case k8stesting.WatchAction:
+		restrictions := action.GetWatchRestrictions()
+		return restrictions.Labels, restrictions.Fields
+	}
+	return labels.Everything(), fields.Everything()
+}
+
+func TestStreamer_MatchesFields(t *testing.T) {
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:       &recordingHandler{},
+		MatchCacheTTL: time.Minute,
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			FieldSelector:  fields.OneTermEqualSelector("status.phase", string(corev1.PodRunning)),
+		},
+	})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.UID = "web-1-uid"
+	if !s.shouldStreamPod(pod) {
+		t.Fatal("shouldStreamPod() = false for a running pod")
+	}
+
+	// A phase change is noticed even though the labels, and the cached decision, didn't change
+	done := pod.DeepCopy()
+	done.Status.Phase = corev1.PodSucceeded
+	if s.shouldStreamPod(done) {
+		t.Error("shouldStreamPod() = true for a pod that is no longer running")
+	}
+}
@@ -0,0 +1,36 @@
+package stream
+
+import "time"
+
+// PodContainerRef identifies a container of a pod
+type PodContainerRef struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// firstLine reports whether no line was delivered yet since the cursor was created, or
+// since it was rearmed, and marks the next one delivered. A nil cursor never reports one.
+func (c *streamCursor) firstLine() bool {
+	if c == nil || c.delivered {
+		return false
+	}
+	c.delivered = true
+	return true
+}
+
+// rearm makes the next delivered line count as the first again
+func (c *streamCursor) rearm() {
+	if c != nil {
+		c.delivered = false
+	}
+}
+
+// notifyFirstLog calls the first log callback when the container's stream delivers its
+// first line
+func (s *Streamer) notifyFirstLog(cursor *streamCursor, namespace, podName, containerName string) {
+	if s.onFirstLog == nil || !cursor.firstLine() {
+		return
+	}
+	s.onFirstLog(PodContainerRef{Namespace: namespace, Pod: podName, Container: containerName}, time.Now())
+}
@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+)
+
+func TestStreamer_OnFirstLog(t *testing.T) {
+	type call struct {
+		ref PodContainerRef
+		at  time.Time
+	}
+	var calls []call
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: handler,
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			IncludeRegex:   regexp.MustCompile(`ready|serving`),
+		},
+		OnFirstLog: func(ref PodContainerRef, at time.Time) {
+			calls = append(calls, call{ref: ref, at: at})
+		},
+	})
+	cursor := newStreamCursor()
+
+	before := time.Now()
+	input := "starting\nready\nserving\n"
+	if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader(input)), "web-1", "app", "default", nil, nil, cursor); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	// A filtered line isn't delivered, so the first call is for "ready"
+	if len(calls) != 1 {
+		t.Fatalf("callback called %d times, want once", len(calls))
+	}
+	want := PodContainerRef{Namespace: "default", Pod: "web-1", Container: "app"}
+	if calls[0].ref != want {
+		t.Errorf("ref = %+v, want %+v", calls[0].ref, want)
+	}
+	if calls[0].at.Before(before) {
+		t.Errorf("at = %v, want a time after the stream opened", calls[0].at)
+	}
+
+	// A reconnected stream doesn't call it again unless the cursor is rearmed
+	if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader("ready\n")), "web-1", "app", "default", nil, nil, cursor); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("callback called %d times after a reconnect, want once", len(calls))
+	}
+
+	cursor.rearm()
+	if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader("ready\nserving\n")), "web-1", "app", "default", nil, nil, cursor); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("callback called %d times after rearming, want twice", len(calls))
+	}
+}
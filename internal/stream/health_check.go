@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultSinkHealthFailures is how many health checks in a row must fail before the sink
+// is reported unhealthy
+const DefaultSinkHealthFailures = 3
+
+// ErrSinkUnhealthy is reported when the handler's health check kept failing
+var ErrSinkUnhealthy = fmt.Errorf("sink is unhealthy")
+
+// healthChecker probes the handler's sink periodically and reports it unhealthy once the
+// probe failed a number of times in a row. It reports again only after the sink
+// recovered. A nil healthChecker does nothing.
+type healthChecker struct {
+	check    func() error
+	interval time.Duration
+	failures int
+	// onUnhealthy is called with the last probe error, from the checker's goroutine
+	onUnhealthy func(error)
+}
+
+// newHealthChecker creates a health checker if there is a check to run periodically
+func newHealthChecker(check func() error, interval time.Duration, failures int, onUnhealthy func(error)) *healthChecker {
+	if check == nil || interval <= 0 {
+		return nil
+	}
+	if failures <= 0 {
+		failures = DefaultSinkHealthFailures
+	}
+	return &healthChecker{
+		check:       check,
+		interval:    interval,
+		failures:    failures,
+		onUnhealthy: onUnhealthy,
+	}
+}
+
+// run probes the sink until the context is done. Without an onUnhealthy callback the
+// failure is reported as an error wrapping ErrSinkUnhealthy.
+func (h *healthChecker) run(ctx context.Context, reportError func(error)) {
+	if h == nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	failed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := h.check()
+			if err == nil {
+				failed = 0
+				continue
+			}
+
+			failed++
+			if failed != h.failures {
+				continue
+			}
+			if h.onUnhealthy != nil {
+				h.onUnhealthy(err)
+			} else {
+				reportError(NewLogStreamError(fmt.Errorf("%w: %v", ErrSinkUnhealthy, err), false,
+					fmt.Sprintf("health check failed %d times in a row", failed)))
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker(t *testing.T) {
+	// The sink fails three probes, recovers for one and fails again
+	results := []bool{false, false, false, false, true, false, false, false}
+	var mu sync.Mutex
+	probes := 0
+	check := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		probes++
+		if probes <= len(results) && !results[probes-1] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	unhealthy := make(chan error, 10)
+	h := newHealthChecker(check, 5*time.Millisecond, 3, func(err error) { unhealthy <- err })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.run(ctx, func(err error) { t.Errorf("reported error %v, want the callback", err) })
+		close(done)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		mu.Lock()
+		n := probes
+		mu.Unlock()
+		if n > len(results)+2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("%d probes ran, want %d", n, len(results)+2)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	// Once after the third failure in a row and again after the recovery
+	if got := len(unhealthy); got != 2 {
+		t.Errorf("sink reported unhealthy %d times, want 2", got)
+	}
+}
+
+func TestHealthChecker_Disabled(t *testing.T) {
+	if h := newHealthChecker(nil, time.Second, 0, nil); h != nil {
+		t.Error("newHealthChecker() without a check is not nil")
+	}
+	if h := newHealthChecker(func() error { return nil }, 0, 0, nil); h != nil {
+		t.Error("newHealthChecker() without an interval is not nil")
+	}
+	if h := newHealthChecker(func() error { return nil }, time.Second, 0, nil); h.failures != DefaultSinkHealthFailures {
+		t.Errorf("failures = %d, want %d", h.failures, DefaultSinkHealthFailures)
+	}
+}
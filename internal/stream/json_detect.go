@@ -0,0 +1,30 @@
+package stream
+
+import (
+	"strings"
+
+	"github.com/archsyscall/klogstream/internal/matcher"
+)
+
+// detectJSONMatcher inspects the first line of the stream and returns a JSON matcher for
+// the stream if it starts a JSON object, or nil for plain text. The line is left
+// to be read again. Only the first line is looked at so a quiet container's first log isn't
+// held back waiting for more lines.
+func detectJSONMatcher(scanner *scanner) MultilineMatcher {
+	if !scanner.Scan() {
+		return nil
+	}
+	scanner.unread()
+
+	if !looksLikeJSON(scanner.Text()) {
+		return nil
+	}
+
+	// The matcher tracks brackets, so every stream needs its own
+	return matcher.NewJSONMatcher()
+}
+
+// looksLikeJSON reports whether the line starts a JSON object
+func looksLikeJSON(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "{")
+}
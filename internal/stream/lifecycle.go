@@ -0,0 +1,69 @@
+package stream
+
+import "context"
+
+// StreamLifecycleHandler is told when the log stream of each container starts and ends
+type StreamLifecycleHandler interface {
+	OnStreamStart(namespace, pod, container string)
+	OnStreamEnd(namespace, pod, container string, reason error)
+}
+
+// containerLifecycle reports the start and the end of one container's stream to the
+// lifecycle handler. A nil lifecycle reports nothing.
+type containerLifecycle struct {
+	handler StreamLifecycleHandler
+	stopCh  <-chan struct{}
+	ref     PodContainerRef
+	started bool
+	// reason is the error that ended the stream, if any
+	reason error
+}
+
+// newContainerLifecycle creates the lifecycle of a container's stream, or nil if no
+// lifecycle handler is set
+func (s *Streamer) newContainerLifecycle(namespace, podName, containerName string) *containerLifecycle {
+	if s.lifecycle == nil {
+		return nil
+	}
+	return &containerLifecycle{
+		handler: s.lifecycle,
+		stopCh:  s.stopCh,
+		ref:     PodContainerRef{Namespace: namespace, Pod: podName, Container: containerName},
+	}
+}
+
+// opened reports the start of the stream when its log is first opened. Reopening it
+// after an error or a reconnect isn't reported again.
+func (l *containerLifecycle) opened() {
+	if l == nil || l.started {
+		return
+	}
+	l.started = true
+	l.handler.OnStreamStart(l.ref.Namespace, l.ref.Pod, l.ref.Container)
+}
+
+// failed records the error the stream is given up on
+func (l *containerLifecycle) failed(err error) {
+	if l != nil {
+		l.reason = err
+	}
+}
+
+// ended reports the end of a stream that was started. The reason is the error the stream
+// was given up on, the context's error if the pod's streams were cancelled, such as when
+// the pod was deleted, or nil if the log was read to its end or the streamer stopped.
+func (l *containerLifecycle) ended(ctx context.Context) {
+	if l == nil || !l.started {
+		return
+	}
+
+	reason := l.reason
+	if reason == nil {
+		select {
+		case <-l.stopCh:
+		default:
+			reason = ctx.Err()
+		}
+	}
+	l.handler.OnStreamEnd(l.ref.Namespace, l.ref.Pod, l.ref.Container, reason)
+}
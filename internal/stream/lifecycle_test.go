@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	"github.com/archsyscall/klogstream/internal/kube"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// recordingLifecycle records the stream lifecycle calls it receives
+type recordingLifecycle struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *recordingLifecycle) OnStreamStart(namespace, pod, container string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf("start %s/%s/%s", namespace, pod, container))
+}
+
+func (l *recordingLifecycle) OnStreamEnd(namespace, pod, container string, reason error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf("end %s/%s/%s: %v", namespace, pod, container, reason))
+}
+
+// waitForEvents waits until n lifecycle events were recorded and returns them
+func (l *recordingLifecycle) waitForEvents(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l.mu.Lock()
+		events := append([]string(nil), l.events...)
+		l.mu.Unlock()
+		if len(events) >= n {
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recorded lifecycle events %q, want %d", events, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamer_LifecycleLogReadToEnd(t *testing.T) {
+	lifecycle := &recordingLifecycle{}
+	until := time.Now().Add(time.Hour)
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		LifecycleHandler:   lifecycle,
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(fake.NewSimpleClientset(newTestPod("default", "web-1", "app")))),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			// The log isn't followed, so the stream ends once it has been read
+			Until: &until,
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	want := []string{"start default/web-1/app", "end default/web-1/app: <nil>"}
+	if got := lifecycle.waitForEvents(t, 2); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("lifecycle events = %q, want %q", got, want)
+	}
+}
+
+func TestStreamer_LifecyclePodDeleted(t *testing.T) {
+	lifecycle := &recordingLifecycle{}
+	pod := newTestPod("default", "web-1", "app")
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		LifecycleHandler:   lifecycle,
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(fake.NewSimpleClientset(pod))),
+		// Close the deleted pod's streams right away instead of leaving them to end on their own
+		PodDeletionGracePeriod: time.Millisecond,
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	// The followed log keeps the stream open until the pod is deleted
+	lifecycle.waitForEvents(t, 1)
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: pod})
+
+	events := lifecycle.waitForEvents(t, 2)
+	if events[1] != "end default/web-1/app: "+context.Canceled.Error() {
+		t.Errorf("end event = %q, want the stream cancelled", events[1])
+	}
+
+	// Reconnects while the stream was open aren't reported as new starts
+	if len(events) != 2 {
+		t.Errorf("lifecycle events = %q, want a single start and end", events)
+	}
+}
+
+func TestContainerLifecycle_Nil(t *testing.T) {
+	var life *containerLifecycle
+	life.opened()
+	life.failed(errors.New("failed"))
+	life.ended(context.Background())
+}
@@ -0,0 +1,24 @@
+package stream
+
+// lineCounter numbers the lines delivered for a single container.
+// A nil counter leaves lines unnumbered.
+type lineCounter struct {
+	next int64
+}
+
+// newLineCounter creates a counter if line numbering is enabled
+func newLineCounter(enabled bool) *lineCounter {
+	if !enabled {
+		return nil
+	}
+	return &lineCounter{}
+}
+
+// stamp sets the next line number on the message, starting from 1
+func (c *lineCounter) stamp(msg *LogMessage) {
+	if c == nil {
+		return
+	}
+	c.next++
+	msg.LineNumber = c.next
+}
@@ -0,0 +1,51 @@
+package stream
+
+import (
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodLogOptionsOverride replaces parts of the log options used for matching containers
+type PodLogOptionsOverride struct {
+	// TailLines, if set, starts with only this many of the most recent lines
+	TailLines *int64
+	// SinceSeconds, if set, starts with the lines of the last this many seconds instead of
+	// the since time otherwise used
+	SinceSeconds *int64
+	// Previous streams the logs of the previous, terminated instance of the container.
+	// The stream ends with that log instead of following the container.
+	Previous bool
+}
+
+// ContainerLogOptions overrides the log options of containers whose name matches ContainerRegex
+type ContainerLogOptions struct {
+	ContainerRegex *regexp.Regexp
+	Override       PodLogOptionsOverride
+}
+
+// applyContainerLogOptions merges the first override matching the container over the options
+func (s *Streamer) applyContainerLogOptions(opts *corev1.PodLogOptions, containerName string) {
+	for _, options := range s.logOverrides {
+		if !options.ContainerRegex.MatchString(containerName) {
+			continue
+		}
+
+		override := options.Override
+		if override.TailLines != nil {
+			tailLines := *override.TailLines
+			opts.TailLines = &tailLines
+		}
+		if override.SinceSeconds != nil {
+			// The API accepts only one of SinceSeconds and SinceTime
+			sinceSeconds := *override.SinceSeconds
+			opts.SinceSeconds = &sinceSeconds
+			opts.SinceTime = nil
+		}
+		if override.Previous {
+			opts.Previous = true
+			opts.Follow = false
+		}
+		return
+	}
+}
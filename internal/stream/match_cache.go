@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// matchEntry is a cached pod filter decision
+type matchEntry struct {
+	// labels are the pod's labels the decision was made for
+	labels  string
+	match   bool
+	expires time.Time
+}
+
+// matchCache remembers the pod filter decision for each pod by UID, so the modified events
+// of a pod that was already decided skip the regex and predicate evaluation. A decision
+// expires after the TTL and is made again when the pod's labels changed, such as when it
+// was relabeled. A nil cache caches nothing.
+type matchCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[types.UID]matchEntry
+	// swept is when expired entries were last removed
+	swept time.Time
+}
+
+// newMatchCache creates a cache keeping decisions for ttl, or nil if ttl isn't positive
+func newMatchCache(ttl time.Duration) *matchCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &matchCache{ttl: ttl, entries: make(map[types.UID]matchEntry), swept: time.Now()}
+}
+
+// lookup returns the cached decision for the pod, if there is one that is still valid
+func (c *matchCache) lookup(pod *corev1.Pod) (match, ok bool) {
+	if c == nil || pod.UID == "" {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[pod.UID]
+	if !ok || time.Now().After(entry.expires) || entry.labels != labels.Set(pod.Labels).String() {
+		return false, false
+	}
+	return entry.match, true
+}
+
+// store caches the decision for the pod, removing expired entries at most once per TTL so
+// the pods that are gone don't accumulate
+func (c *matchCache) store(pod *corev1.Pod, match bool) {
+	if c == nil || pod.UID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.swept) > c.ttl {
+		for uid, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, uid)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[pod.UID] = matchEntry{
+		labels:  labels.Set(pod.Labels).String(),
+		match:   match,
+		expires: now.Add(c.ttl),
+	}
+}
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestStreamer_MatchCache(t *testing.T) {
+	evaluations := 0
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: &recordingHandler{},
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			PodNameRegex:   regexp.MustCompile(`^web-`),
+		},
+		PodFilter: func(pod *corev1.Pod) bool {
+			evaluations++
+			return pod.Labels["tier"] != "batch"
+		},
+		MatchCacheTTL: 50 * time.Millisecond,
+	})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.UID = "uid-web-1"
+	pod.Labels = map[string]string{"tier": "frontend"}
+
+	// Repeated modified events reuse the first decision
+	for i := 0; i < 5; i++ {
+		if !s.shouldStreamPod(pod) {
+			t.Fatal("shouldStreamPod() = false, want the pod matched")
+		}
+	}
+	if evaluations != 1 {
+		t.Errorf("filters evaluated %d times within the TTL, want 1", evaluations)
+	}
+
+	// A relabeled pod is decided again
+	pod.Labels = map[string]string{"tier": "batch"}
+	if s.shouldStreamPod(pod) {
+		t.Error("shouldStreamPod() = true after relabeling, want the pod skipped")
+	}
+	if evaluations != 2 {
+		t.Errorf("filters evaluated %d times after relabeling, want 2", evaluations)
+	}
+
+	// An expired decision is made again
+	time.Sleep(60 * time.Millisecond)
+	s.shouldStreamPod(pod)
+	if evaluations != 3 {
+		t.Errorf("filters evaluated %d times after the TTL, want 3", evaluations)
+	}
+}
+
+func TestStreamer_MatchCacheDisabled(t *testing.T) {
+	evaluations := 0
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:   &recordingHandler{},
+		PodFilter: func(pod *corev1.Pod) bool { evaluations++; return true },
+	})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.UID = "uid-web-1"
+	for i := 0; i < 3; i++ {
+		s.shouldStreamPod(pod)
+	}
+	if evaluations != 3 {
+		t.Errorf("filters evaluated %d times without a cache, want 3", evaluations)
+	}
+}
@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrNoMatches is reported when a dry-run match finds no pods or containers for the filter
+var ErrNoMatches = fmt.Errorf("filter matched no pods or containers")
+
+// MatchReport summarizes how many pods and containers the filter matched at start time
+type MatchReport struct {
+	// PodsMatched is the number of pods passing the pod filters
+	PodsMatched int
+	// ContainersMatched is the number of containers of matched pods passing the container filters
+	ContainersMatched int
+}
+
+// MatchReport returns the result of the dry-run match performed by Start
+func (s *Streamer) MatchReport() MatchReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.matchReport
+}
+
+// countMatches lists pods once in every namespace and counts those matching the filter.
+// A pod found by several selectors is counted once.
+func (s *Streamer) countMatches(ctx context.Context) (MatchReport, error) {
+	var report MatchReport
+	selectors := s.labelSelectors()
+	seen := make(map[types.UID]bool)
+
+	for _, namespace := range s.namespaces() {
+		for _, selector := range selectors {
+			_, err := s.listPods(ctx, namespace, selector, func(pod *corev1.Pod) {
+				if !s.shouldStreamPod(pod) {
+					return
+				}
+				if len(selectors) > 1 {
+					if seen[pod.UID] {
+						return
+					}
+					seen[pod.UID] = true
+				}
+				report.PodsMatched++
+
+				for j := range pod.Spec.Containers {
+					if s.shouldStreamContainer(pod, &pod.Spec.Containers[j]) {
+						report.ContainersMatched++
+					}
+				}
+			})
+			if err != nil {
+				return report, NewLogStreamError(err, true, "failed to list pods")
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// runDryRunMatch records the match report and warns through OnError when nothing matched
+func (s *Streamer) runDryRunMatch(ctx context.Context) error {
+	report, err := s.countMatches(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.matchReport = report
+	s.mu.Unlock()
+
+	if report.PodsMatched == 0 || report.ContainersMatched == 0 {
+		s.reportError(NewLogStreamError(ErrNoMatches, false,
+			fmt.Sprintf("dry-run matched %d pods and %d containers", report.PodsMatched, report.ContainersMatched)))
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestStreamer_DryRunMatch(t *testing.T) {
+	provider := newFakeProvider(
+		newTestPod("default", "web-1", "nginx", "sidecar"),
+		newTestPod("default", "web-2", "nginx"),
+		newTestPod("default", "db-1", "postgres"),
+		newTestPod("other", "web-3", "nginx"),
+	)
+
+	tests := []struct {
+		name           string
+		podRegex       string
+		containerRegex string
+		want           MatchReport
+		wantWarning    bool
+	}{
+		{
+			name:     "pod regex",
+			podRegex: "^web-",
+			want:     MatchReport{PodsMatched: 2, ContainersMatched: 3},
+		},
+		{
+			name:           "pod and container regex",
+			podRegex:       "^web-",
+			containerRegex: "^nginx$",
+			want:           MatchReport{PodsMatched: 2, ContainersMatched: 2},
+		},
+		{
+			name:        "typo in pod regex",
+			podRegex:    "nginix",
+			want:        MatchReport{},
+			wantWarning: true,
+		},
+		{
+			name:           "pods match but containers do not",
+			podRegex:       "^db-",
+			containerRegex: "mysql",
+			want:           MatchReport{PodsMatched: 1},
+			wantWarning:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &filter.LogFilter{
+				PodNameRegex:   regexp.MustCompile(tt.podRegex),
+				Namespaces:     []string{"default"},
+				ContainerState: filter.DefaultContainerState,
+			}
+			if tt.containerRegex != "" {
+				f.ContainerRegex = regexp.MustCompile(tt.containerRegex)
+			}
+
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				KubeClientProvider: provider,
+				Filter:             f,
+				Handler:            handler,
+				DryRunMatch:        true,
+			})
+
+			if err := s.runDryRunMatch(context.Background()); err != nil {
+				t.Fatalf("runDryRunMatch() error = %v", err)
+			}
+
+			if got := s.MatchReport(); got != tt.want {
+				t.Errorf("MatchReport() = %+v, want %+v", got, tt.want)
+			}
+
+			warned := false
+			for _, err := range handler.errors {
+				if errors.Is(err, ErrNoMatches) {
+					warned = true
+				}
+			}
+			if warned != tt.wantWarning {
+				t.Errorf("zero-match warning = %v, want %v (errors: %v)", warned, tt.wantWarning, handler.errors)
+			}
+		})
+	}
+}
+
+func TestStreamer_PodFilter(t *testing.T) {
+	provider := newFakeProvider(
+		newTestPod("default", "web-1", "nginx", "sidecar"),
+		newTestPod("default", "web-2", "nginx"),
+		newTestPod("default", "db-1", "postgres", "exporter"),
+	)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		KubeClientProvider: provider,
+		Handler:            &recordingHandler{},
+		DryRunMatch:        true,
+		PodFilter: func(pod *corev1.Pod) bool {
+			return len(pod.Spec.Containers) > 1
+		},
+	})
+
+	if err := s.runDryRunMatch(context.Background()); err != nil {
+		t.Fatalf("runDryRunMatch() error = %v", err)
+	}
+
+	want := MatchReport{PodsMatched: 2, ContainersMatched: 4}
+	if got := s.MatchReport(); got != want {
+		t.Errorf("MatchReport() = %+v, want %+v", got, want)
+	}
+	if s.shouldStreamPod(newTestPod("default", "web-3", "nginx")) {
+		t.Error("shouldStreamPod() accepted a pod rejected by the pod filter")
+	}
+}
@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Metrics is a point-in-time snapshot of the streamer's counters
+type Metrics struct {
+	// ActiveStreams is the number of container log streams currently open
+	ActiveStreams int64
+	// LinesDelivered is the number of log messages passed to the handler
+	LinesDelivered uint64
+	// LinesDropped is the number of log messages discarded because the handler couldn't keep up
+	LinesDropped uint64
+	// LinesThrottled is the number of log messages discarded by the per-pod rate limit
+	LinesThrottled uint64
+	// LinesOversize is the number of log lines dropped for being longer than MaxLineBytesDrop
+	LinesOversize uint64
+	// LinesPauseDropped is the number of log messages dropped while paused, because the pause
+	// buffer was full or the streamer stopped before Resume
+	LinesPauseDropped uint64
+	// HandlerTimeouts is the number of OnLog calls abandoned for exceeding HandlerTimeout
+	HandlerTimeouts uint64
+	// PodLinesThrottled is the number of log messages discarded by the per-pod rate limit for
+	// each pod currently streamed, keyed by namespace/pod. Pods that were never throttled are left out.
+	PodLinesThrottled map[string]uint64
+	// BytesRead is the number of bytes read from container log streams
+	BytesRead uint64
+	// Retries is the number of times the pod watch or a container log stream was retried after an error
+	Retries uint64
+	// Errors is the number of errors reported to the handler
+	Errors uint64
+	// CompressionActive reports whether the API server has sent gzip-encoded responses
+	CompressionActive bool
+	// BytesCompressed is the number of gzip-encoded bytes received on the wire
+	BytesCompressed uint64
+	// BytesDecompressed is the number of bytes the compressed responses expanded to
+	BytesDecompressed uint64
+}
+
+// metrics holds the live counters behind a Metrics snapshot
+type metrics struct {
+	activeStreams   atomic.Int64
+	linesDelivered  atomic.Uint64
+	linesDropped    atomic.Uint64
+	linesThrottled  atomic.Uint64
+	linesOversize   atomic.Uint64
+	handlerTimeouts atomic.Uint64
+	bytesRead       atomic.Uint64
+	retries         atomic.Uint64
+	errors          atomic.Uint64
+}
+
+// Metrics returns a snapshot of the streamer's counters
+func (s *Streamer) Metrics() Metrics {
+	return Metrics{
+		ActiveStreams:  s.metrics.activeStreams.Load(),
+		LinesDelivered: s.metrics.linesDelivered.Load(),
+		LinesDropped:   s.metrics.linesDropped.Load(),
+		LinesThrottled: s.metrics.linesThrottled.Load(),
+		LinesOversize:  s.metrics.linesOversize.Load(),
+		BytesRead:      s.metrics.bytesRead.Load(),
+		Retries:        s.metrics.retries.Load(),
+		Errors:         s.metrics.errors.Load(),
+
+		LinesPauseDropped: s.pause.dropped.Load(),
+		HandlerTimeouts:   s.metrics.handlerTimeouts.Load(),
+
+		CompressionActive: s.compression.Active(),
+		BytesCompressed:   s.compression.BytesCompressed(),
+		BytesDecompressed: s.compression.BytesDecompressed(),
+
+		PodLinesThrottled: s.podRateLimit.dropped(),
+	}
+}
+
+// Stats is a compact snapshot of the main counters, cheaper to take than Metrics
+type Stats struct {
+	// ActiveStreams is the number of container log streams currently open
+	ActiveStreams int
+	// LinesDelivered is the number of log messages passed to the handler
+	LinesDelivered uint64
+	// BytesRead is the number of bytes read from container log streams
+	BytesRead uint64
+	// Retries is the number of times the pod watch or a container log stream was retried after an error
+	Retries uint64
+	// Errors is the number of errors reported to the handler
+	Errors uint64
+}
+
+// Stats returns a snapshot of the main counters
+func (s *Streamer) Stats() Stats {
+	return Stats{
+		ActiveStreams:  int(s.metrics.activeStreams.Load()),
+		LinesDelivered: s.metrics.linesDelivered.Load(),
+		BytesRead:      s.metrics.bytesRead.Load(),
+		Retries:        s.metrics.retries.Load(),
+		Errors:         s.metrics.errors.Load(),
+	}
+}
+
+// reportError counts the error and forwards it to the handler
+func (s *Streamer) reportError(err error) {
+	s.metrics.errors.Add(1)
+	s.handler.OnError(err)
+}
+
+// countingReader counts the bytes read from a log stream
+type countingReader struct {
+	io.ReadCloser
+	count *atomic.Uint64
+}
+
+// Read reads from the underlying stream and adds the bytes read to the counter
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// pauseGate holds back log messages from the handler while the streamer is paused
+type pauseGate struct {
+	paused  atomic.Bool
+	mu      sync.Mutex
+	limit   int
+	pending []LogMessage
+	dropped atomic.Uint64
+	// flushing counts the Resume calls delivering buffered messages, close waits for them
+	flushing sync.WaitGroup
+}
+
+// hold buffers or drops the message if the gate is paused.
+// It returns false if the message should be delivered immediately.
+func (g *pauseGate) hold(msg LogMessage) bool {
+	if !g.paused.Load() {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Re-check under the lock in case Resume raced with us
+	if !g.paused.Load() {
+		return false
+	}
+
+	// Buffer up to the limit, drop anything beyond it
+	if len(g.pending) < g.limit {
+		g.pending = append(g.pending, msg)
+	} else {
+		g.dropped.Add(1)
+	}
+	return true
+}
+
+// Pause stops delivering log messages to the handler without closing the underlying streams.
+// Messages received while paused are buffered up to the configured limit and dropped beyond it,
+// counting them in Metrics.LinesPauseDropped.
+func (s *Streamer) Pause() {
+	s.pause.paused.Store(true)
+}
+
+// Resume continues delivering log messages, first flushing any messages buffered while paused.
+// The buffer is flushed after the gate is reopened, so a slow handler doesn't block the
+// streams, and messages arriving during the flush may reach the handler before it ends.
+func (s *Streamer) Resume() {
+	s.pause.mu.Lock()
+	if !s.pause.paused.Load() {
+		s.pause.mu.Unlock()
+		return
+	}
+	pending := s.pause.pending
+	s.pause.pending = nil
+	s.pause.paused.Store(false)
+	s.pause.flushing.Add(1)
+	s.pause.mu.Unlock()
+	defer s.pause.flushing.Done()
+
+	for _, msg := range pending {
+		s.emit(msg)
+	}
+}
+
+// close drops the buffered messages, counting them in Metrics.LinesPauseDropped, and waits
+// for a Resume already flushing, so no buffered message reaches the handler after it ended
+func (g *pauseGate) close() {
+	g.mu.Lock()
+	g.dropped.Add(uint64(len(g.pending)))
+	g.pending = nil
+	g.mu.Unlock()
+
+	g.flushing.Wait()
+}
+
+// Paused reports whether delivery is currently paused
+func (s *Streamer) Paused() bool {
+	return s.pause.paused.Load()
+}
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+)
+
+// indentMatcher merges indented lines into the line before them
+type indentMatcher struct{}
+
+func (indentMatcher) ShouldMerge(previous, next string) bool {
+	return strings.HasPrefix(next, " ")
+}
+
+// TestStreamer_PipelineOrder pins the order of the stages documented on processLogStream
+func TestStreamer_PipelineOrder(t *testing.T) {
+	include := func(pattern string) *filter.LogFilter {
+		return &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			IncludeRegex:   regexp.MustCompile(pattern),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config *StreamerConfig
+		input  string
+		want   []string
+	}{
+		{
+			name:   "multiline before include",
+			config: &StreamerConfig{Filter: include(`at com\.example`), Matcher: indentMatcher{}},
+			input:  "ERROR failed\n  at com.example.Main\nINFO done\n",
+			want:   []string{"ERROR failed\n  at com.example.Main"},
+		},
+		{
+			name: "multiline before exclude",
+			config: &StreamerConfig{
+				Filter: &filter.LogFilter{
+					Namespaces:     []string{"default"},
+					ContainerState: filter.DefaultContainerState,
+					IncludeRegex:   regexp.MustCompile(`ERROR`),
+					ExcludeRegex:   regexp.MustCompile(`at com\.example\.Health`),
+				},
+				Matcher: indentMatcher{},
+			},
+			input: "ERROR failed\n  at com.example.Health\nERROR failed\n  at com.example.Main\n",
+			want:  []string{"ERROR failed\n  at com.example.Main"},
+		},
+		{
+			name:   "include before format",
+			config: &StreamerConfig{Filter: include(`^\[web\]`), Formatter: &prefixFormatter{prefix: "[web] "}},
+			input:  "ready\n",
+			want:   []string{},
+		},
+		{
+			name:   "include before rate limit",
+			config: &StreamerConfig{Filter: include(`keep`), PodRateLimit: 1},
+			input:  "drop\ndrop\nkeep\n",
+			want:   []string{"keep"},
+		},
+		{
+			name:   "include before numbering",
+			config: &StreamerConfig{Filter: include(`keep`), LineNumbering: true},
+			input:  "drop\nkeep one\ndrop\nkeep two\n",
+			want:   []string{"1 keep one", "2 keep two"},
+		},
+		{
+			name:   "max age before multiline",
+			config: &StreamerConfig{Matcher: indentMatcher{}, MaxLogAge: time.Hour},
+			input: "2020-01-01T00:00:00Z ERROR old\n" +
+				time.Now().UTC().Format(time.RFC3339Nano) + "   continued\n" +
+				time.Now().UTC().Format(time.RFC3339Nano) + " INFO new\n",
+			want: []string{"  continued", "INFO new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			tt.config.Handler = handler
+			s := newTestStreamer(t, tt.config)
+
+			stream := io.NopCloser(strings.NewReader(tt.input))
+			if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, newLineCounter(tt.config.LineNumbering), nil); err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+
+			handler.mu.Lock()
+			defer handler.mu.Unlock()
+			got := make([]string, 0, len(handler.messages))
+			for _, msg := range handler.messages {
+				if msg.LineNumber > 0 {
+					got = append(got, fmt.Sprintf("%d %s", msg.LineNumber, msg.Message))
+					continue
+				}
+				got = append(got, msg.Message)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("received %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"sync"
+)
+
+// podSerializer serializes delivery per pod, so that the lines of all containers of a pod
+// reach the handler as a single sequence in arrival order. A nil serializer does nothing.
+type podSerializer struct {
+	mu      sync.Mutex
+	writers map[string]*podWriter
+}
+
+// podWriter is the lock shared by the streams of a single pod
+type podWriter struct {
+	sync.Mutex
+	// users counts the deliveries holding or waiting for the lock
+	users int
+}
+
+// newPodSerializer creates a serializer if merging containers is enabled
+func newPodSerializer(enabled bool) *podSerializer {
+	if !enabled {
+		return nil
+	}
+	return &podSerializer{writers: make(map[string]*podWriter)}
+}
+
+// lock waits until no other line of the pod is being delivered and returns the unlock
+// function. Writers are dropped once unused so finished pods don't accumulate.
+func (p *podSerializer) lock(namespace, podName string) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	key := namespace + "/" + podName
+
+	p.mu.Lock()
+	writer, ok := p.writers[key]
+	if !ok {
+		writer = &podWriter{}
+		p.writers[key] = writer
+	}
+	writer.users++
+	p.mu.Unlock()
+
+	writer.Lock()
+	return func() {
+		writer.Unlock()
+
+		p.mu.Lock()
+		writer.users--
+		if writer.users == 0 {
+			delete(p.writers, key)
+		}
+		p.mu.Unlock()
+	}
+}
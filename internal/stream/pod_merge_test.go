@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unsyncedHandler records messages without locking and counts overlapping OnLog calls
+type unsyncedHandler struct {
+	inFlight atomic.Int32
+	overlaps atomic.Int32
+	messages []LogMessage
+}
+
+func (h *unsyncedHandler) OnLog(msg LogMessage) {
+	if h.inFlight.Add(1) > 1 {
+		h.overlaps.Add(1)
+	}
+	// Give the other container a chance to interleave
+	time.Sleep(10 * time.Microsecond)
+	h.messages = append(h.messages, msg)
+	h.inFlight.Add(-1)
+}
+
+func (h *unsyncedHandler) OnError(error) {}
+
+func (h *unsyncedHandler) OnEnd() {}
+
+func TestStreamer_MergePodContainers(t *testing.T) {
+	handler := &unsyncedHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            handler,
+		MergePodContainers: true,
+	})
+
+	const count = 200
+	var wg sync.WaitGroup
+	for _, container := range []string{"app", "sidecar"} {
+		var sb strings.Builder
+		for i := 0; i < count; i++ {
+			fmt.Fprintf(&sb, "%s %d\n", container, i)
+		}
+
+		wg.Add(1)
+		go func(container, input string) {
+			defer wg.Done()
+			stream := io.NopCloser(strings.NewReader(input))
+			if err := s.processLogStream(context.Background(), stream, "web-1", container, "default", nil, nil, nil); err != nil {
+				t.Errorf("processLogStream() error = %v", err)
+			}
+		}(container, sb.String())
+	}
+	wg.Wait()
+
+	if overlaps := handler.overlaps.Load(); overlaps != 0 {
+		t.Errorf("handler was called concurrently %d times for the same pod", overlaps)
+	}
+	if len(handler.messages) != 2*count {
+		t.Fatalf("handler received %d lines, want %d", len(handler.messages), 2*count)
+	}
+
+	// Each container's lines keep their order within the merged stream
+	next := map[string]int{}
+	for _, msg := range handler.messages {
+		want := fmt.Sprintf("%s %d", msg.ContainerName, next[msg.ContainerName])
+		if !strings.Contains(msg.Message, want) {
+			t.Fatalf("got %q from %s, want %q next", msg.Message, msg.ContainerName, want)
+		}
+		next[msg.ContainerName]++
+	}
+
+	if len(s.podSerializer.writers) != 0 {
+		t.Errorf("%d pod writers left after delivery finished", len(s.podSerializer.writers))
+	}
+}
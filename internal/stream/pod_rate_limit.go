@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// podRateLimiter keeps a token bucket per pod, so a pod logging faster than the limit
+// has its excess lines dropped without affecting other pods. A nil limiter lets every
+// line through.
+type podRateLimiter struct {
+	rate float64
+
+	mu      sync.Mutex
+	buckets map[string]*podBucket
+
+	now func() time.Time
+}
+
+// podBucket is the token bucket of a single pod
+type podBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped uint64
+}
+
+// newPodRateLimiter creates a limiter allowing linesPerSec lines per pod, or nil if the
+// rate is not positive
+func newPodRateLimiter(linesPerSec int) *podRateLimiter {
+	if linesPerSec <= 0 {
+		return nil
+	}
+	return &podRateLimiter{
+		rate:    float64(linesPerSec),
+		buckets: make(map[string]*podBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the pod's bucket, reporting false if the line must be dropped.
+// Each pod may burst up to a second worth of lines.
+func (l *podRateLimiter) allow(namespace, podName string) bool {
+	if l == nil {
+		return true
+	}
+
+	key := namespace + "/" + podName
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &podBucket{tokens: l.rate, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.rate {
+		bucket.tokens = l.rate
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		bucket.dropped++
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// forget drops the bucket of a pod that is no longer streamed
+func (l *podRateLimiter) forget(namespace, podName string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, namespace+"/"+podName)
+}
+
+// dropped returns the number of lines dropped per streamed pod, keyed by namespace/pod,
+// leaving out pods that never exceeded the limit
+func (l *podRateLimiter) dropped() map[string]uint64 {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dropped := make(map[string]uint64)
+	for key, bucket := range l.buckets {
+		if bucket.dropped > 0 {
+			dropped[key] = bucket.dropped
+		}
+	}
+	return dropped
+}
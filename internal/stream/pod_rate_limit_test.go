@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStreamer_PodRateLimit(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:      handler,
+		PodRateLimit: 10,
+	})
+	now := time.Unix(0, 0)
+	s.podRateLimit.now = func() time.Time { return now }
+
+	// The noisy pod logs 100 lines at once, the quiet pod a few
+	for i := 0; i < 100; i++ {
+		s.deliver(LogMessage{Namespace: "default", PodName: "noisy", Message: fmt.Sprint(i)})
+		if i%20 == 0 {
+			s.deliver(LogMessage{Namespace: "default", PodName: "quiet", Message: fmt.Sprint(i)})
+		}
+	}
+
+	delivered := map[string]int{}
+	for _, msg := range handler.messages {
+		delivered[msg.PodName]++
+	}
+	if delivered["noisy"] != 10 {
+		t.Errorf("noisy pod delivered %d lines, want its burst of 10", delivered["noisy"])
+	}
+	if delivered["quiet"] != 5 {
+		t.Errorf("quiet pod delivered %d lines, want all 5", delivered["quiet"])
+	}
+
+	metrics := s.Metrics()
+	if metrics.LinesThrottled != 90 {
+		t.Errorf("LinesThrottled = %d, want 90", metrics.LinesThrottled)
+	}
+	if len(metrics.PodLinesThrottled) != 1 || metrics.PodLinesThrottled["default/noisy"] != 90 {
+		t.Errorf("PodLinesThrottled = %v, want only default/noisy with 90", metrics.PodLinesThrottled)
+	}
+
+	// Half a second later the noisy pod has earned five more lines
+	now = now.Add(500 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		s.deliver(LogMessage{Namespace: "default", PodName: "noisy", Message: "later"})
+	}
+	if got := len(handler.lines()); got != 20 {
+		t.Errorf("handler received %d lines after refilling, want 20", got)
+	}
+
+	// Container restart events are never throttled
+	s.deliver(LogMessage{Namespace: "default", PodName: "noisy", IsEvent: true})
+	if got := len(handler.lines()); got != 21 {
+		t.Error("restart event was throttled")
+	}
+}
+
+func TestPodRateLimiter_Forget(t *testing.T) {
+	limiter := newPodRateLimiter(1)
+	limiter.allow("default", "web-1")
+	limiter.allow("default", "web-1")
+
+	if got := limiter.dropped(); got["default/web-1"] != 1 {
+		t.Fatalf("dropped() = %v, want one line of default/web-1", got)
+	}
+
+	limiter.forget("default", "web-1")
+	if len(limiter.buckets) != 0 || len(limiter.dropped()) != 0 {
+		t.Error("forget() kept the pod's bucket")
+	}
+
+	var disabled *podRateLimiter
+	if newPodRateLimiter(0) != nil || !disabled.allow("default", "web-1") {
+		t.Error("disabled limiter dropped a line")
+	}
+}
@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultPodMetricsTTL is how long the metrics of a pod are kept after it stops being streamed
+const DefaultPodMetricsTTL = 5 * time.Minute
+
+// ContainerMetrics counts what was delivered from a container
+type ContainerMetrics struct {
+	// Lines is the number of log messages delivered
+	Lines uint64
+	// Bytes is the number of bytes of the log lines delivered, as read with their kubelet timestamps
+	Bytes uint64
+	// LastSeen is when the last message was delivered
+	LastSeen time.Time
+}
+
+// PodMetrics counts what was delivered from a pod, in total and per container
+type PodMetrics struct {
+	ContainerMetrics
+	// Containers holds the metrics of each of the pod's containers, keyed by name
+	Containers map[string]ContainerMetrics
+}
+
+// podStat holds the live counters of a pod
+type podStat struct {
+	total      ContainerMetrics
+	containers map[string]*ContainerMetrics
+	// removed is when the pod stopped being streamed, zero while it is
+	removed time.Time
+}
+
+// podStats counts the messages delivered per pod and container. The metrics of pods
+// that are no longer streamed are evicted after the TTL. A nil podStats does nothing.
+type podStats struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	pods map[string]*podStat
+}
+
+// newPodStats creates per-pod metrics if enabled
+func newPodStats(enabled bool, ttl time.Duration) *podStats {
+	if !enabled {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = DefaultPodMetricsTTL
+	}
+	return &podStats{
+		ttl:  ttl,
+		pods: make(map[string]*podStat),
+	}
+}
+
+// record counts a message delivered from its pod and container
+func (p *podStats) record(msg LogMessage) {
+	if p == nil || msg.IsEvent {
+		return
+	}
+
+	key := msg.Namespace + "/" + msg.PodName
+	now := time.Now()
+	bytes := uint64(len(msg.Raw))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stat, ok := p.pods[key]
+	if !ok {
+		stat = &podStat{containers: make(map[string]*ContainerMetrics)}
+		p.pods[key] = stat
+	}
+	container, ok := stat.containers[msg.ContainerName]
+	if !ok {
+		container = &ContainerMetrics{}
+		stat.containers[msg.ContainerName] = container
+	}
+
+	for _, m := range []*ContainerMetrics{&stat.total, container} {
+		m.Lines++
+		m.Bytes += bytes
+		m.LastSeen = now
+	}
+	stat.removed = time.Time{}
+}
+
+// forget starts the TTL of a pod that is no longer streamed
+func (p *podStats) forget(namespace, podName string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stat, ok := p.pods[namespace+"/"+podName]; ok {
+		stat.removed = time.Now()
+	}
+	p.evictLocked()
+}
+
+// evictLocked drops the pods whose TTL expired, p.mu must be held
+func (p *podStats) evictLocked() {
+	for key, stat := range p.pods {
+		if !stat.removed.IsZero() && time.Since(stat.removed) > p.ttl {
+			delete(p.pods, key)
+		}
+	}
+}
+
+// snapshot returns the metrics of every pod keyed by namespace/pod, evicting the pods
+// whose TTL expired
+func (p *podStats) snapshot() map[string]PodMetrics {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictLocked()
+
+	snapshot := make(map[string]PodMetrics, len(p.pods))
+	for key, stat := range p.pods {
+		metrics := PodMetrics{
+			ContainerMetrics: stat.total,
+			Containers:       make(map[string]ContainerMetrics, len(stat.containers)),
+		}
+		for name, container := range stat.containers {
+			metrics.Containers[name] = *container
+		}
+		snapshot[key] = metrics
+	}
+	return snapshot
+}
+
+// PodStats returns the per-pod metrics keyed by namespace/pod, nil unless enabled
+func (s *Streamer) PodStats() map[string]PodMetrics {
+	return s.podStats.snapshot()
+}
@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestStreamer_PodStats(t *testing.T) {
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:       &recordingHandler{},
+		PodMetrics:    true,
+		PodMetricsTTL: 50 * time.Millisecond,
+	})
+
+	streams := map[string]string{
+		"app":     "one\ntwo\nthree\n",
+		"sidecar": "ready\n",
+	}
+	for container, input := range streams {
+		stream := io.NopCloser(strings.NewReader(input))
+		if err := s.processLogStream(context.Background(), stream, "web-1", container, "default", nil, nil, nil); err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+	}
+
+	stats := s.PodStats()["default/web-1"]
+	if stats.Lines != 4 || stats.Bytes != uint64(len("onetwothreeready")) || stats.LastSeen.IsZero() {
+		t.Errorf("pod metrics = %+v, want 4 lines of 16 bytes", stats.ContainerMetrics)
+	}
+	if got := stats.Containers["app"].Lines; got != 3 {
+		t.Errorf("app lines = %d, want 3", got)
+	}
+	if got := stats.Containers["sidecar"].Lines; got != 1 {
+		t.Errorf("sidecar lines = %d, want 1", got)
+	}
+
+	// The metrics of a deleted pod are kept until the TTL expires
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: newTestPod("default", "web-1", "app")})
+	if _, ok := s.PodStats()["default/web-1"]; !ok {
+		t.Fatal("metrics of a deleted pod were evicted before the TTL")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if stats := s.PodStats(); len(stats) != 0 {
+		t.Errorf("PodStats() = %v after the TTL, want the deleted pod evicted", stats)
+	}
+}
+
+func TestStreamer_PodStatsDisabled(t *testing.T) {
+	s := newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}})
+
+	stream := io.NopCloser(strings.NewReader("hello\n"))
+	if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	if stats := s.PodStats(); stats != nil {
+		t.Errorf("PodStats() = %v, want nil when disabled", stats)
+	}
+}
@@ -0,0 +1,39 @@
+package stream
+
+import (
+	"github.com/archsyscall/klogstream/internal/formatter"
+)
+
+// linePrefix renders a template prefix that is prepended to every log line
+type linePrefix struct {
+	template *formatter.TemplateFormatter
+}
+
+// newLinePrefix parses the prefix template using the same rules as the TemplateFormatter
+func newLinePrefix(text string) (*linePrefix, error) {
+	tmpl, err := formatter.NewTemplateFormatterWithTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &linePrefix{
+		template: tmpl,
+	}, nil
+}
+
+// render executes the prefix template against the log message
+func (p *linePrefix) render(msg LogMessage) string {
+	// Render the template without a message so only the prefix is produced
+	return p.template.Format(formatter.LogMessage{
+		Namespace:     msg.Namespace,
+		PodName:       msg.PodName,
+		ContainerName: msg.ContainerName,
+		NodeName:      msg.NodeName,
+		StreamLabel:   msg.StreamLabel,
+		LineNumber:    msg.LineNumber,
+		Stream:        msg.Stream.Label(),
+		IsEvent:       msg.IsEvent,
+		Timestamp:     msg.Timestamp,
+		Raw:           msg.Raw,
+	})
+}
@@ -0,0 +1,53 @@
+package stream
+
+import (
+	"testing"
+)
+
+func TestLinePrefix_Render(t *testing.T) {
+	msg := LogMessage{
+		Namespace:     "default",
+		PodName:       "web-1",
+		ContainerName: "nginx",
+		Message:       "GET /healthz",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "pod and container substitution",
+			template: "{{.Namespace}}/{{.PodName}}/{{.ContainerName}} | ",
+			want:     "default/web-1/nginx | ",
+		},
+		{
+			name:     "message is not rendered in the prefix",
+			template: "[{{.PodName}}]{{.Message}} ",
+			want:     "[web-1] ",
+		},
+		{
+			name:     "invalid template",
+			template: "{{.PodName",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, err := newLinePrefix(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLinePrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := prefix.render(msg); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
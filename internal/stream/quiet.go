@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQuietReprobeInterval is the default time to wait before reopening the stream of a
+// container that produced no output
+const DefaultQuietReprobeInterval = time.Minute
+
+// quietStream closes a log stream that produces no output within a grace period, so a
+// container that never logs doesn't hold a connection and goroutine for nothing
+type quietStream struct {
+	io.ReadCloser
+	quiet atomic.Bool
+
+	// output is closed when the first bytes are read
+	output     chan struct{}
+	outputOnce sync.Once
+	// closed is closed when the stream is closed by its reader
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newQuietStream wraps the stream, closing it if nothing is read within grace
+func newQuietStream(stream io.ReadCloser, grace time.Duration) *quietStream {
+	q := &quietStream{
+		ReadCloser: stream,
+		output:     make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+
+	go func() {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			// Closing the stream unblocks the pending read
+			q.quiet.Store(true)
+			q.ReadCloser.Close()
+		case <-q.output:
+		case <-q.closed:
+		}
+	}()
+
+	return q
+}
+
+// Read reads from the stream, noting when the first output arrives
+func (q *quietStream) Read(p []byte) (int, error) {
+	n, err := q.ReadCloser.Read(p)
+	if n > 0 {
+		q.outputOnce.Do(func() { close(q.output) })
+	}
+	return n, err
+}
+
+// Close closes the stream
+func (q *quietStream) Close() error {
+	q.closeOnce.Do(func() { close(q.closed) })
+	return q.ReadCloser.Close()
+}
+
+// isQuiet reports whether the stream was closed for producing no output
+func (q *quietStream) isQuiet() bool {
+	return q != nil && q.quiet.Load()
+}
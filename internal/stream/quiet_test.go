@@ -0,0 +1,66 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestQuietStream_SilentStreamReleased(t *testing.T) {
+	// A pipe that is never written to behaves like a container that never logs
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	s := newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}})
+	quiet := newQuietStream(reader, 50*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.processLogStream(context.Background(), quiet, "web-1", "pause", "default", nil, nil, nil)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("silent stream was not released after the grace period")
+	}
+	if !quiet.isQuiet() {
+		t.Error("isQuiet() = false for a stream released for silence")
+	}
+}
+
+func TestQuietStream_ActiveStreamKept(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler})
+	quiet := newQuietStream(reader, 50*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.processLogStream(context.Background(), quiet, "web-1", "app", "default", nil, nil, nil)
+	}()
+
+	if _, err := io.WriteString(writer, "started\n"); err != nil {
+		t.Fatalf("write error = %v", err)
+	}
+
+	// Outlive the grace period, the stream has produced output so it stays open
+	time.Sleep(150 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("stream with output was closed")
+	default:
+	}
+
+	quiet.Close()
+	<-done
+	if quiet.isQuiet() {
+		t.Error("isQuiet() = true for a stream with output")
+	}
+	if got := handler.lines(); len(got) != 1 || got[0] != "started" {
+		t.Errorf("received %q, want [started]", got)
+	}
+}
@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrReadDeadline is reported when a log stream read doesn't return within the read deadline
+var ErrReadDeadline = fmt.Errorf("log stream read deadline exceeded")
+
+// deadlineStream fails a read that doesn't return within the deadline, so a connection that
+// hangs without being closed, such as a half-open TCP connection, is torn down and retried
+type deadlineStream struct {
+	io.ReadCloser
+	deadline time.Duration
+	expired  atomic.Bool
+}
+
+// newDeadlineStream wraps the stream with a read deadline, a zero deadline returns it unchanged
+func newDeadlineStream(stream io.ReadCloser, deadline time.Duration) io.ReadCloser {
+	if deadline <= 0 {
+		return stream
+	}
+	return &deadlineStream{ReadCloser: stream, deadline: deadline}
+}
+
+// Read reads from the stream, closing it if the read outlives the deadline
+func (d *deadlineStream) Read(p []byte) (int, error) {
+	// Closing the stream unblocks the pending read
+	timer := time.AfterFunc(d.deadline, func() {
+		d.expired.Store(true)
+		d.ReadCloser.Close()
+	})
+	n, err := d.ReadCloser.Read(p)
+	timer.Stop()
+
+	if d.expired.Load() {
+		return n, ErrReadDeadline
+	}
+	return n, err
+}
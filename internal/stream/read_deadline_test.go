@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDeadlineStream_HungReadTornDown(t *testing.T) {
+	// A pipe that is never written to blocks like a half-open connection
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	s := newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.processLogStream(context.Background(), newDeadlineStream(reader, 50*time.Millisecond), "web-1", "app", "default", nil, nil, nil)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hung read was not torn down after the read deadline")
+	}
+
+	// The error is transient, so the stream is opened again by the retry loop
+	var lse *LogStreamError
+	if !errors.As(err, &lse) || lse.Permanent || !errors.Is(err, ErrReadDeadline) {
+		t.Errorf("processLogStream() error = %v, want a transient %v", err, ErrReadDeadline)
+	}
+	if _, err := writer.Write([]byte("late\n")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("write after the deadline error = %v, want the stream closed", err)
+	}
+}
+
+func TestDeadlineStream_ActiveStreamKept(t *testing.T) {
+	reader, writer := io.Pipe()
+
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.processLogStream(context.Background(), newDeadlineStream(reader, 100*time.Millisecond), "web-1", "app", "default", nil, nil, nil)
+	}()
+
+	// Lines arriving within the deadline keep the stream open for longer than the deadline
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		time.Sleep(40 * time.Millisecond)
+		if _, err := io.WriteString(writer, line); err != nil {
+			t.Fatalf("write error = %v", err)
+		}
+	}
+	writer.Close()
+
+	if err := <-done; err != nil {
+		t.Errorf("processLogStream() error = %v, want nil", err)
+	}
+	if got := handler.lines(); len(got) != 3 {
+		t.Errorf("received %q, want 3 lines", got)
+	}
+}
+
+func TestNewDeadlineStream_Disabled(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	if stream := newDeadlineStream(reader, 0); stream != io.ReadCloser(reader) {
+		t.Error("newDeadlineStream() with a zero deadline wrapped the stream")
+	}
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restartCounts records the restart count of every container of the pod
+func restartCounts(pod *corev1.Pod) map[string]int32 {
+	counts := make(map[string]int32, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		counts[status.Name] = status.RestartCount
+	}
+	return counts
+}
+
+// annotateRestarts delivers an event message for every streamed container of the pod whose
+// restart count went up since the pod was last seen, marking the restart in the log timeline
+func (s *Streamer) annotateRestarts(active *activePod, pod *corev1.Pod) {
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		status := containerStatus(pod, container.Name)
+		if status == nil {
+			continue
+		}
+
+		active.restartsMu.Lock()
+		previous, seen := active.restarts[container.Name]
+		active.restarts[container.Name] = status.RestartCount
+		active.restartsMu.Unlock()
+		if !seen || status.RestartCount <= previous || !s.shouldStreamContainer(pod, container) {
+			continue
+		}
+
+		reason := restartReason(status)
+		msg := LogMessage{
+			Namespace:     pod.Namespace,
+			PodName:       pod.Name,
+			ContainerName: container.Name,
+			Timestamp:     time.Now(),
+			Message:       reason,
+			Raw:           []byte(reason),
+			IsEvent:       true,
+		}
+		active.label(&msg)
+		s.deliver(msg)
+	}
+}
+
+// restartReason describes a container restart, including the exit code when it is known
+func restartReason(status *corev1.ContainerStatus) string {
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		return fmt.Sprintf("Restarted, previous exit code %d", terminated.ExitCode)
+	}
+	return "Restarted"
+}
@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// shortLivedStream is how soon after being opened a stream must end without error to count
+// towards the reconnect limit
+const shortLivedStream = 10 * time.Second
+
+// ErrMaxReconnects is reported when a container is abandoned because its stream kept
+// ending right after it was opened
+var ErrMaxReconnects = fmt.Errorf("log stream reconnected too many times")
+
+// streamCursor remembers the kubelet timestamp of the last line read from a container, so
+// a reconnected stream resumes after that line instead of from the original since time.
+// Its position is only used by the container's goroutine, the counters reported by
+// Snapshot are atomic. A nil cursor does nothing.
+type streamCursor struct {
+	// started is when the cursor was created, before the container's first stream opened
+	started time.Time
+	last    time.Time
+	// replayed is the time up to which lines of a reopened stream were already read
+	replayed time.Time
+	// delivered is whether a line was delivered, see firstLine
+	delivered bool
+
+	// lastSeen is when the last new line was read, in Unix nanoseconds
+	lastSeen atomic.Int64
+	// pending is the number of lines buffered by the multiline matcher
+	pending atomic.Int32
+	// retries is the number of times the container's stream was retried after an error
+	retries atomic.Int64
+}
+
+// newStreamCursor creates a cursor for a container stream
+func newStreamCursor() *streamCursor {
+	return &streamCursor{started: time.Now()}
+}
+
+// resume moves the options of a reopened stream to the last line read, if there is one.
+// The since time only has a precision of seconds, so the lines logged earlier within that
+// second are sent again and skipped by read. A positive overlap moves the stream back
+// further, so the lines logged within overlap before the last line read are delivered
+// again rather than risking a gap.
+func (c *streamCursor) resume(opts *corev1.PodLogOptions, overlap time.Duration) {
+	if c == nil || c.last.IsZero() {
+		return
+	}
+
+	from := c.last
+	if overlap > 0 {
+		from = from.Add(-overlap)
+	}
+	since := metav1.NewTime(from)
+	opts.SinceTime = &since
+	opts.SinceSeconds = nil
+	opts.TailLines = nil
+	c.replayed = from
+}
+
+// read records a line read with its kubelet timestamp, returning false if the line was
+// already read before the stream was reopened. Lines without a timestamp are always new.
+func (c *streamCursor) read(timestamp time.Time) bool {
+	if c == nil {
+		return true
+	}
+	if timestamp.IsZero() {
+		c.lastSeen.Store(time.Now().UnixNano())
+		return true
+	}
+
+	if !c.replayed.IsZero() {
+		if !timestamp.After(c.replayed) {
+			return false
+		}
+		c.replayed = time.Time{}
+	}
+	c.last = timestamp
+	c.lastSeen.Store(time.Now().UnixNano())
+	return true
+}
+
+// buffered records the number of lines waiting in the multiline buffer
+func (c *streamCursor) buffered(lines int) {
+	if c != nil {
+		c.pending.Store(int32(lines))
+	}
+}
+
+// retried counts a retry of the container's stream
+func (c *streamCursor) retried() {
+	if c != nil {
+		c.retries.Add(1)
+	}
+}
+
+// initial reports whether the container's stream first opened less than burst ago, when
+// the lines read are mostly the replay of its log history
+func (c *streamCursor) initial(burst time.Duration) bool {
+	return c != nil && burst > 0 && time.Since(c.started) < burst
+}
@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestStreamer_ReconnectResumesAfterLastLine(t *testing.T) {
+	// The ingestion time doesn't change where a reconnected stream resumes
+	for _, source := range []TimestampSource{TimestampKubelet, TimestampIngestion} {
+		t.Run(fmt.Sprint(source), func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{Handler: handler, TimestampSource: source})
+			cursor := newStreamCursor()
+
+			// The connection breaks after two lines
+			first := io.MultiReader(
+				strings.NewReader("2024-05-01T10:00:00.100000000Z one\n2024-05-01T10:00:00.200000000Z two\n"),
+				iotest.ErrReader(errors.New("connection reset by peer")),
+			)
+			if err := s.processLogStream(context.Background(), io.NopCloser(first), "web-1", "app", "default", nil, nil, cursor); err == nil {
+				t.Fatal("processLogStream() error = nil, want the read error")
+			}
+
+			// The reopened stream starts after the last line read, not from the original since time
+			tail := int64(10)
+			opts := &corev1.PodLogOptions{TailLines: &tail}
+			cursor.resume(opts, 0)
+			want := time.Date(2024, 5, 1, 10, 0, 0, 200000000, time.UTC)
+			if opts.SinceTime == nil || !opts.SinceTime.Time.Equal(want) || opts.TailLines != nil {
+				t.Fatalf("resumed options = %+v, want since %v and no tail", opts, want)
+			}
+
+			// The kubelet sends the whole second again, the lines read before are skipped
+			second := strings.NewReader("2024-05-01T10:00:00.100000000Z one\n2024-05-01T10:00:00.200000000Z two\n" +
+				"2024-05-01T10:00:00.300000000Z three\n2024-05-01T10:00:01.000000000Z four\n")
+			if err := s.processLogStream(context.Background(), io.NopCloser(second), "web-1", "app", "default", nil, nil, cursor); err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+
+			if got := handler.lines(); strings.Join(got, ",") != "one,two,three,four" {
+				t.Errorf("received %q, want each line once", got)
+			}
+		})
+	}
+}
+
+func TestStreamCursor_NothingRead(t *testing.T) {
+	// A stream without kubelet timestamps keeps its original options
+	cursor := newStreamCursor()
+	if !cursor.read(time.Time{}) {
+		t.Error("read() = false for a line without a timestamp")
+	}
+
+	tail := int64(10)
+	opts := &corev1.PodLogOptions{TailLines: &tail}
+	cursor.resume(opts, 0)
+	if opts.SinceTime != nil || opts.TailLines == nil {
+		t.Errorf("resume() changed the options of a stream without timestamps: %+v", opts)
+	}
+}
+
+func TestStreamer_MaxReconnects(t *testing.T) {
+	// The fake clientset's log stream ends right after its only line, on every connection
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, MaxReconnects: 2})
+
+	s.startPodLogStreamer(context.Background(), newTestPod("default", "web-1", "app"))
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("container stream kept reconnecting past the limit")
+	}
+
+	// The first stream and two reconnects
+	if got := handler.lines(); len(got) != 3 {
+		t.Errorf("received %d lines, want 3", len(got))
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.errors) != 1 || !errors.Is(handler.errors[0], ErrMaxReconnects) {
+		t.Errorf("errors = %v, want %v", handler.errors, ErrMaxReconnects)
+	}
+}
+
+func TestStreamer_InitialBurst(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, InitialBurst: 50 * time.Millisecond})
+	cursor := newStreamCursor()
+
+	read := func(input string) {
+		t.Helper()
+		if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader(input)), "web-1", "app", "default", nil, nil, cursor); err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+	}
+
+	// The replay read right after the stream opened is historical, later lines are live
+	read("ERROR: resolved yesterday\n")
+	time.Sleep(100 * time.Millisecond)
+	read("ERROR: happening now\n")
+
+	if len(handler.messages) != 2 {
+		t.Fatalf("received %d messages, want 2", len(handler.messages))
+	}
+	if !handler.messages[0].Historical {
+		t.Error("message read in the initial burst is not historical")
+	}
+	if handler.messages[1].Historical {
+		t.Error("message read after the initial burst is historical")
+	}
+}
+
+func TestStreamer_ReconnectOverlap(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, ReconnectOverlap: 150 * time.Millisecond})
+	cursor := newStreamCursor()
+
+	first := io.MultiReader(
+		strings.NewReader("2024-05-01T10:00:00.100000000Z one\n2024-05-01T10:00:00.200000000Z two\n"+
+			"2024-05-01T10:00:00.300000000Z three\n"),
+		iotest.ErrReader(errors.New("connection reset by peer")),
+	)
+	if err := s.processLogStream(context.Background(), io.NopCloser(first), "web-1", "app", "default", nil, nil, cursor); err == nil {
+		t.Fatal("processLogStream() error = nil, want the read error")
+	}
+
+	// The stream resumes the overlap before the last line read
+	opts := &corev1.PodLogOptions{}
+	cursor.resume(opts, s.overlap)
+	want := time.Date(2024, 5, 1, 10, 0, 0, 150000000, time.UTC)
+	if opts.SinceTime == nil || !opts.SinceTime.Time.Equal(want) {
+		t.Fatalf("resumed since = %v, want %v", opts.SinceTime, want)
+	}
+
+	// Lines within the overlap are delivered again, older ones are still skipped
+	second := strings.NewReader("2024-05-01T10:00:00.100000000Z one\n2024-05-01T10:00:00.200000000Z two\n" +
+		"2024-05-01T10:00:00.300000000Z three\n2024-05-01T10:00:00.400000000Z four\n")
+	if err := s.processLogStream(context.Background(), io.NopCloser(second), "web-1", "app", "default", nil, nil, cursor); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	if got := handler.lines(); strings.Join(got, ",") != "one,two,three,two,three,four" {
+		t.Errorf("received %q, want the lines within the overlap twice", got)
+	}
+}
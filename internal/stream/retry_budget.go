@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// retryBudget is a token bucket shared by every stream and watch of a streamer, bounding
+// the total rate of retries so an outage doesn't make every stream hit the API server at
+// once. Up to max retries may happen in a burst, after which tokens refill evenly over
+// the window.
+type retryBudget struct {
+	max      float64
+	interval time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) bool
+}
+
+// newRetryBudget creates a budget of maxPerWindow retries per window, or nil if either is
+// not positive
+func newRetryBudget(maxPerWindow int, window time.Duration) *retryBudget {
+	if maxPerWindow <= 0 || window <= 0 {
+		return nil
+	}
+
+	return &retryBudget{
+		max:      float64(maxPerWindow),
+		interval: window / time.Duration(maxPerWindow),
+		tokens:   float64(maxPerWindow),
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+}
+
+// wait blocks until a retry may be made, taking a token from the budget.
+// It returns false if the context was cancelled while waiting.
+func (b *retryBudget) wait(ctx context.Context) bool {
+	if b == nil {
+		return true
+	}
+
+	for {
+		b.mu.Lock()
+		b.refill()
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return true
+		}
+		d := time.Duration((1 - b.tokens) * float64(b.interval))
+		b.mu.Unlock()
+
+		if !b.sleep(ctx, d) {
+			return false
+		}
+	}
+}
+
+// refill adds the tokens earned since the last refill. The caller holds the lock.
+func (b *retryBudget) refill() {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+		if b.tokens > b.max {
+			b.tokens = b.max
+		}
+	}
+	b.last = now
+}
@@ -0,0 +1,127 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFakeRetryBudget creates a retry budget driven by the fake clock
+func newFakeRetryBudget(maxPerWindow int, window time.Duration, clock *fakeClock) *retryBudget {
+	budget := newRetryBudget(maxPerWindow, window)
+	budget.now = clock.Now
+	budget.sleep = clock.Sleep
+	return budget
+}
+
+func TestRetryBudget_Rate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	budget := newFakeRetryBudget(10, time.Second, clock)
+	start := clock.now
+
+	// The first 10 retries use the burst, the remaining 50 are refilled at 10 per second
+	for i := 0; i < 60; i++ {
+		if !budget.wait(context.Background()) {
+			t.Fatalf("wait() = false for retry %d", i)
+		}
+	}
+
+	if elapsed := clock.now.Sub(start); elapsed != 5*time.Second {
+		t.Errorf("60 retries with a budget of 10/s took %v, want 5s", elapsed)
+	}
+}
+
+func TestRetryBudget_Refills(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	budget := newFakeRetryBudget(5, time.Second, clock)
+
+	for i := 0; i < 5; i++ {
+		budget.wait(context.Background())
+	}
+
+	// After a quiet window the whole burst is available again, but not more
+	clock.now = clock.now.Add(time.Minute)
+	sleeps := clock.sleeps
+	for i := 0; i < 5; i++ {
+		budget.wait(context.Background())
+	}
+	if clock.sleeps != sleeps {
+		t.Errorf("retries waited %d times after the budget refilled", clock.sleeps-sleeps)
+	}
+
+	budget.wait(context.Background())
+	if clock.sleeps == sleeps {
+		t.Error("retry beyond the refilled burst did not wait")
+	}
+}
+
+func TestRetryBudget_SharedAcrossStreams(t *testing.T) {
+	const (
+		streams = 50
+		retries = 4
+		max     = 20
+		window  = 100 * time.Millisecond
+	)
+	budget := newRetryBudget(max, window)
+
+	var mu sync.Mutex
+	var times []time.Time
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < retries; j++ {
+				if budget.wait(context.Background()) {
+					mu.Lock()
+					times = append(times, time.Now())
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every window after the burst allows at most max retries
+	for _, from := range times {
+		count := 0
+		for _, at := range times {
+			if !at.Before(from) && at.Sub(from) < window {
+				count++
+			}
+		}
+		if count > 2*max {
+			t.Fatalf("%d retries within one window, budget allows %d plus the burst", count, max)
+		}
+	}
+
+	// 200 retries at 20 per 100ms, less the initial burst of 20, take at least 900ms
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("%d retries finished in %v, faster than the budget allows", streams*retries, elapsed)
+	}
+}
+
+func TestRetryBudget_Cancelled(t *testing.T) {
+	budget := newRetryBudget(1, time.Hour)
+	budget.wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if budget.wait(ctx) {
+		t.Error("wait() = true after the context was cancelled with the budget exhausted")
+	}
+}
+
+func TestRetryBudget_Unlimited(t *testing.T) {
+	if budget := newRetryBudget(0, time.Second); budget != nil {
+		t.Fatal("newRetryBudget(0, ...) returned a budget")
+	}
+
+	var budget *retryBudget
+	if !budget.wait(context.Background()) {
+		t.Error("nil budget blocked")
+	}
+}
@@ -0,0 +1,270 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+)
+
+// DefaultReadBufferSize is the default size of the buffer wrapping each log stream
+const DefaultReadBufferSize = 64 * 1024
+
+// DefaultMaxLineBytes is the default maximum length of a single log line, longer lines
+// are split into pieces of this length
+const DefaultMaxLineBytes = 1024 * 1024
+
+// NewScanner creates a new scanner for reading log lines
+func NewScanner(r io.Reader) *scanner {
+	return newScanner(r, DefaultReadBufferSize, DefaultMaxLineBytes)
+}
+
+// newScanner creates a scanner that reads through a buffer of bufferSize bytes,
+// growing it for long lines up to maxLineBytes. A longer line is returned in pieces of
+// maxLineBytes, each but the last one marked truncated.
+func newScanner(r io.Reader, bufferSize, maxLineBytes int) *scanner {
+	if bufferSize > maxLineBytes {
+		bufferSize = maxLineBytes
+	}
+
+	s := &scanner{
+		scanner:      bufio.NewScanner(r),
+		maxLineBytes: maxLineBytes,
+	}
+	// One byte more than a line so the newline after a line of maxLineBytes still fits
+	s.scanner.Buffer(make([]byte, 0, bufferSize), maxLineBytes+1)
+	s.scanner.Split(s.split)
+
+	return s
+}
+
+// scanner reads log lines using bufio.Scanner, keeping each token valid after the next Scan
+type scanner struct {
+	scanner *bufio.Scanner
+	token   []byte
+	// raw is the current token as read, before the kubelet timestamp is split off
+	raw []byte
+	// maxLineBytes is the length longer lines are cut at
+	maxLineBytes int
+	// cut is whether the last piece returned by split was cut off at maxLineBytes
+	cut bool
+	// truncated is whether the current token was cut off, the rest of its line follows
+	truncated bool
+	// continued is whether the current token continues a line that was cut off
+	continued bool
+	// trimCR strips a trailing carriage return from each token
+	trimCR bool
+	// kubeletTimestamps splits the kubelet's timestamp from the start of each token
+	kubeletTimestamps bool
+	// timestamp is the kubelet timestamp of the current token, zero if it has none
+	timestamp time.Time
+	// replay makes the next Scan return the current token again
+	replay bool
+
+	// dropOversize is the length of a whole line, across its pieces, above which the line
+	// is reported oversize once and its other pieces are skipped. Zero keeps every line.
+	dropOversize int
+	// lineBytes is the length of the current line read so far
+	lineBytes int
+	// held holds the pieces of a cut line read so far, until the line ends within
+	// dropOversize or turns out longer
+	held []heldPiece
+	// dropping is whether the rest of the current line is skipped, it was reported oversize
+	dropping bool
+	// oversized is whether the current token is the report of a line longer than dropOversize
+	oversized bool
+}
+
+// heldPiece is a piece of a cut line held back until the length of the line is known
+type heldPiece struct {
+	token     []byte
+	raw       []byte
+	truncated bool
+	continued bool
+}
+
+// Scan advances the scanner to the next token
+func (s *scanner) Scan() bool {
+	if s.replay {
+		s.replay = false
+		return true
+	}
+
+	// Hand out the held pieces of a line that ended within the drop limit
+	s.oversized = false
+	if len(s.held) > 0 {
+		s.release()
+		return true
+	}
+
+	for {
+		s.continued = s.truncated
+		if !s.scanner.Scan() {
+			return false
+		}
+		s.truncated = s.cut
+
+		// bufio.Scanner reuses its buffer, copy the token since messages keep the raw bytes
+		s.setToken(bytes.Clone(s.scanner.Bytes()))
+		if !s.measure() {
+			return true
+		}
+	}
+}
+
+// measure adds the current token to the length of its line when a drop limit is set,
+// holding back the pieces of a cut line until the line ends or exceeds the limit. It
+// reports whether the token is skipped, the scanner reads on.
+func (s *scanner) measure() bool {
+	if s.dropOversize <= 0 {
+		return false
+	}
+
+	if !s.continued {
+		s.lineBytes = 0
+		s.dropping = false
+	}
+	if s.dropping {
+		return true
+	}
+
+	s.lineBytes += len(s.token)
+	if s.lineBytes > s.dropOversize {
+		// Report the line once, the pieces held and still to come are skipped
+		s.held = nil
+		s.dropping = s.truncated
+		s.oversized = true
+		return false
+	}
+
+	if !s.truncated && len(s.held) == 0 {
+		return false
+	}
+	s.held = append(s.held, heldPiece{token: s.token, raw: s.raw, truncated: s.truncated, continued: s.continued})
+	if s.truncated {
+		return true
+	}
+
+	// The line ended within the limit, hand out its first piece
+	s.release()
+	return false
+}
+
+// release makes the first held piece the current token. The pieces share the kubelet
+// timestamp of the line's first piece.
+func (s *scanner) release() {
+	piece := s.held[0]
+	s.held = s.held[1:]
+	if len(s.held) == 0 {
+		s.held = nil
+	}
+	s.token = piece.token
+	s.raw = piece.raw
+	s.truncated = piece.truncated
+	s.continued = piece.continued
+}
+
+// unread makes the next Scan return the current token again, so a line can be inspected
+// before the stream is processed
+func (s *scanner) unread() {
+	s.replay = true
+}
+
+// setToken stores the current token, dropping a trailing carriage return and the kubelet
+// timestamp if configured. The raw token keeps the timestamp.
+func (s *scanner) setToken(token []byte) {
+	if s.trimCR && len(token) > 0 && token[len(token)-1] == '\r' {
+		token = token[:len(token)-1]
+	}
+	s.raw = token
+
+	// The rest of a line that was cut off keeps the timestamp of its first piece
+	if s.continued {
+		s.token = token
+		return
+	}
+
+	s.timestamp = time.Time{}
+	if s.kubeletTimestamps {
+		s.timestamp, token, _ = parseKubeletTimestamp(token)
+	}
+	s.token = token
+}
+
+// Oversize reports whether the current token stands for a line longer than the drop
+// limit. Such a line is reported once, whatever the number of pieces it was cut into.
+func (s *scanner) Oversize() bool {
+	return s.oversized
+}
+
+// Truncated reports whether the current token was cut off at the maximum line length, the
+// rest of its line is returned by the next Scan
+func (s *scanner) Truncated() bool {
+	return s.truncated
+}
+
+// Timestamp returns the kubelet timestamp of the current token, or the zero time if it has none
+func (s *scanner) Timestamp() time.Time {
+	return s.timestamp
+}
+
+// Text returns the current token as a string
+func (s *scanner) Text() string {
+	return string(s.token)
+}
+
+// Bytes returns the current token as bytes
+func (s *scanner) Bytes() []byte {
+	return s.token
+}
+
+// Raw returns the current token as read from the stream, including the kubelet timestamp
+func (s *scanner) Raw() []byte {
+	return s.raw
+}
+
+// Err returns the last error encountered, or nil if the stream ended with EOF
+func (s *scanner) Err() error {
+	return s.scanner.Err()
+}
+
+// split splits lines with scanLines, cutting a line that doesn't fit the maximum length
+// into pieces instead of failing with bufio.ErrTooLong, so a container that never writes a
+// newline can't grow the buffer without bound
+func (s *scanner) split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	advance, token, err = scanLines(data, atEOF)
+	s.cut = false
+	if token == nil && err == nil && len(data) > s.maxLineBytes {
+		s.cut = true
+		return s.maxLineBytes, data[:s.maxLineBytes], nil
+	}
+	return advance, token, err
+}
+
+// scanLines is bufio.ScanLines without dropping the carriage return before the newline,
+// so raw passthrough keeps the bytes intact and trimming stays under the scanner's control
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	// Return the final line without a newline
+	if atEOF {
+		return len(data), data, nil
+	}
+	// Request more data
+	return 0, nil, nil
+}
+
+// oversize reports whether the line read is longer than the drop limit, counting it as
+// dropped. Unlike MaxLineBytes, which splits a longer line into pieces, the whole line is
+// skipped: the scanner measures it across its pieces and reports it once.
+func (s *Streamer) oversize(scanner *scanner) bool {
+	if !scanner.Oversize() {
+		return false
+	}
+	s.metrics.linesOversize.Add(1)
+	return true
+}
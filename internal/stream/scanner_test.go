@@ -0,0 +1,414 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// scanAll scans every line from the reader and returns the lines and the final error
+func scanAll(r io.Reader, bufferSize, maxLineBytes int) ([]string, error) {
+	scanner := newScanner(r, bufferSize, maxLineBytes)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func TestScanner_Lines(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader func(io.Reader) io.Reader
+		input  string
+		buffer int
+		want   []string
+	}{
+		{
+			name:   "several lines in a single read",
+			input:  "first\nsecond\nthird\n",
+			buffer: 4096,
+			want:   []string{"first", "second", "third"},
+		},
+		{
+			name:   "lines split across reads",
+			reader: iotest.OneByteReader,
+			input:  "first\nsecond\n",
+			buffer: 4096,
+			want:   []string{"first", "second"},
+		},
+		{
+			name:   "lines split across half reads",
+			reader: iotest.HalfReader,
+			input:  "first\nsecond\nthird",
+			buffer: 4096,
+			want:   []string{"first", "second", "third"},
+		},
+		{
+			name:   "partial final line without newline",
+			input:  "first\nlast",
+			buffer: 4096,
+			want:   []string{"first", "last"},
+		},
+		{
+			name:   "line longer than the initial buffer",
+			input:  strings.Repeat("x", 100) + "\nshort\n",
+			buffer: 16,
+			want:   []string{strings.Repeat("x", 100), "short"},
+		},
+		{
+			name:   "empty lines",
+			input:  "\n\nlast\n",
+			buffer: 4096,
+			want:   []string{"", "", "last"},
+		},
+		{
+			name:   "empty input",
+			input:  "",
+			buffer: 4096,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r io.Reader = strings.NewReader(tt.input)
+			if tt.reader != nil {
+				r = tt.reader(r)
+			}
+
+			got, err := scanAll(r, tt.buffer, DefaultMaxLineBytes)
+			if err != nil {
+				t.Fatalf("Err() = %v, want nil at EOF", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Scan() produced %q, want %q", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScanner_TrimCarriageReturn(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		trimCR bool
+		want   string
+	}{
+		{
+			name:   "crlf line trimmed",
+			input:  "line\r\n",
+			trimCR: true,
+			want:   "line",
+		},
+		{
+			name:   "crlf line kept",
+			input:  "line\r\n",
+			trimCR: false,
+			want:   "line\r",
+		},
+		{
+			name:   "final line without newline trimmed",
+			input:  "line\r",
+			trimCR: true,
+			want:   "line",
+		},
+		{
+			name:   "lone carriage return inside line kept",
+			input:  "a\rb\n",
+			trimCR: true,
+			want:   "a\rb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewScanner(strings.NewReader(tt.input))
+			scanner.trimCR = tt.trimCR
+
+			if !scanner.Scan() {
+				t.Fatalf("Scan() = false, err = %v", scanner.Err())
+			}
+			if got := scanner.Text(); got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// chunkReader returns each chunk from a separate Read
+type chunkReader struct {
+	chunks [][]byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if r.chunks[0] = r.chunks[0][n:]; len(r.chunks[0]) == 0 {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+// TestScanner_PackedReads checks that the bytes following a newline in the same Read are
+// kept for the next line rather than discarded
+func TestScanner_PackedReads(t *testing.T) {
+	want := []string{"first", "second", "", "third", "fourth"}
+
+	readers := map[string]io.Reader{
+		"every line in one read": bytes.NewReader([]byte("first\r\nsecond\n\r\nthird\nfourth")),
+		"reads ending mid-line": &chunkReader{chunks: [][]byte{
+			[]byte("first\r\nsec"), []byte("ond\n\r"), []byte("\nthird\nfou"), []byte("rth"),
+		}},
+	}
+	for name, r := range readers {
+		t.Run(name, func(t *testing.T) {
+			scanner := NewScanner(r)
+			scanner.trimCR = true
+
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Err() = %v, want nil at EOF", err)
+			}
+			if strings.Join(got, "|") != strings.Join(want, "|") {
+				t.Errorf("Scan() produced %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestScanner_OversizedLine(t *testing.T) {
+	input := "short\n" + strings.Repeat("x", 40) + strings.Repeat("z", 30) + "\nafter\n"
+	scanner := newScanner(strings.NewReader(input), 16, 32)
+
+	type piece struct {
+		text      string
+		truncated bool
+	}
+	var got []piece
+	for scanner.Scan() {
+		got = append(got, piece{scanner.Text(), scanner.Truncated()})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Err() = %v, want the long line split instead", err)
+	}
+
+	want := []piece{
+		{"short", false},
+		{strings.Repeat("x", 32), true},
+		{strings.Repeat("x", 8) + strings.Repeat("z", 24), true},
+		{strings.Repeat("z", 6), false},
+		{"after", false},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Scan() produced %v, want %v", got, want)
+	}
+
+	// A line of exactly the limit is not split
+	got = nil
+	scanner = newScanner(strings.NewReader(strings.Repeat("y", 32)+"\nnext\n"), 16, 32)
+	for scanner.Scan() {
+		got = append(got, piece{scanner.Text(), scanner.Truncated()})
+	}
+	if want := []piece{{strings.Repeat("y", 32), false}, {"next", false}}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Scan() produced %v for a line at the limit, want %v", got, want)
+	}
+}
+
+func TestStreamer_MaxLineBytesSplitsLongLines(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler})
+
+	// A line of 5MiB without a newline until its end, such as binary output
+	line := strings.Repeat("0123456789abcdef", 5*DefaultMaxLineBytes/16)
+	input := io.NopCloser(strings.NewReader(line + "\nnext\n"))
+	if err := s.processLogStream(context.Background(), input, "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.messages) != 6 {
+		t.Fatalf("received %d messages, want 5 pieces and the next line", len(handler.messages))
+	}
+	var joined strings.Builder
+	for i, msg := range handler.messages[:5] {
+		if len(msg.Message) != DefaultMaxLineBytes {
+			t.Errorf("piece %d is %d bytes, want %d", i, len(msg.Message), DefaultMaxLineBytes)
+		}
+		if want := i < 4; msg.Truncated != want {
+			t.Errorf("piece %d Truncated = %v, want %v", i, msg.Truncated, want)
+		}
+		joined.WriteString(msg.Message)
+	}
+	if joined.String() != line {
+		t.Error("the pieces don't add up to the original line")
+	}
+	if next := handler.messages[5]; next.Message != "next" || next.Truncated {
+		t.Errorf("line after the split = %q (truncated %v), want \"next\"", next.Message, next.Truncated)
+	}
+}
+
+func TestScanner_BytesStayValid(t *testing.T) {
+	scanner := newScanner(strings.NewReader("first\nsecond\n"), 16, DefaultMaxLineBytes)
+
+	scanner.Scan()
+	first := scanner.Bytes()
+	scanner.Scan()
+
+	if string(first) != "first" {
+		t.Errorf("Bytes() from the previous Scan changed to %q", first)
+	}
+	if string(scanner.Bytes()) != "second" {
+		t.Errorf("Bytes() = %q, want %q", scanner.Bytes(), "second")
+	}
+}
+
+func TestScanner_Unread(t *testing.T) {
+	got, want := []string{}, []string{"first", "first", "second"}
+
+	scanner := newScanner(strings.NewReader("first\nsecond\n"), 16, DefaultMaxLineBytes)
+	scanner.Scan()
+	got = append(got, scanner.Text())
+	scanner.unread()
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Scan() produced %q after unread, want %q", got, want)
+	}
+}
+
+func TestScanner_ReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("line\n"), iotest.ErrReader(readErr))
+
+	got, err := scanAll(r, 4096, DefaultMaxLineBytes)
+	if !errors.Is(err, readErr) {
+		t.Errorf("Err() = %v, want %v", err, readErr)
+	}
+	if len(got) != 1 || got[0] != "line" {
+		t.Errorf("Scan() produced %q before the error, want [line]", got)
+	}
+}
+
+// readCounter counts the Read calls made on the underlying reader
+type readCounter struct {
+	io.Reader
+	reads int
+}
+
+func (r *readCounter) Read(p []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(p)
+}
+
+// BenchmarkScanner_ReadsPerThousandLines reports how many reads from the log stream
+// are needed to scan a thousand typical log lines for different buffer sizes
+func BenchmarkScanner_ReadsPerThousandLines(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "2024-01-01T00:00:00Z INFO request handled path=/api/v1/items/%d status=200 duration=3ms\n", i)
+	}
+	input := sb.String()
+
+	for _, size := range []int{4096, DefaultReadBufferSize} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			reads := 0
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				counter := &readCounter{Reader: strings.NewReader(input)}
+				scanner := newScanner(counter, size, DefaultMaxLineBytes)
+				for scanner.Scan() {
+				}
+				reads += counter.reads
+			}
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/1k-lines")
+		})
+	}
+}
+
+func TestStreamer_MaxLineBytesDrop(t *testing.T) {
+	for _, matcher := range []MultilineMatcher{nil, indentMatcher{}} {
+		handler := &recordingHandler{}
+		s := newTestStreamer(t, &StreamerConfig{Handler: handler, Matcher: matcher, MaxLineBytesDrop: 16})
+
+		input := "ready\n" + strings.Repeat("x", 17) + "\n" + strings.Repeat("y", 16) + "\nserving\n"
+		if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader(input)), "web-1", "app", "default", nil, nil, nil); err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+
+		want := []string{"ready", strings.Repeat("y", 16), "serving"}
+		if got := handler.lines(); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("matcher %T: received %q, want %q", matcher, got, want)
+		}
+		if got := s.Metrics().LinesOversize; got != 1 {
+			t.Errorf("matcher %T: LinesOversize = %d, want 1", matcher, got)
+		}
+	}
+}
+
+func TestStreamer_MaxLineBytesDropAcrossPieces(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	longer := strings.Repeat("z", 70)
+	input := "ready\n" + long + "\n" + longer + "\nserving\n"
+
+	tests := []struct {
+		name         string
+		dropOversize int
+		want         []string
+		wantOversize uint64
+	}{
+		{
+			// Every line cut into pieces is longer than the limit
+			name:         "drop limit below the line limit",
+			dropOversize: 16,
+			want:         []string{"ready", "serving"},
+			wantOversize: 2,
+		},
+		{
+			// The line of 40 bytes is delivered in its two pieces, the line of 70 exceeds
+			// the limit only with its second piece and none of its pieces are delivered
+			name:         "drop limit above the line limit",
+			dropOversize: 48,
+			want:         []string{"ready", long[:32], long[32:], "serving"},
+			wantOversize: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{Handler: handler, MaxLineBytes: 32, MaxLineBytesDrop: tt.dropOversize})
+
+			if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader(input)), "web-1", "app", "default", nil, nil, nil); err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+
+			if got := handler.lines(); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("received %q, want %q", got, tt.want)
+			}
+			if got := s.Metrics().LinesOversize; got != tt.wantOversize {
+				t.Errorf("LinesOversize = %d, want %d", got, tt.wantOversize)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// StreamPriority ranks a pod's container streams when the concurrency limit is reached,
+// streams of pods with a higher value are started first
+type StreamPriority func(pod *corev1.Pod) int
+
+// streamScheduler bounds how many container streams are open at once. Streams waiting for
+// a slot are started in priority order, and in arrival order among equal priorities.
+type streamScheduler struct {
+	limit    int
+	priority StreamPriority
+
+	mu      sync.Mutex
+	running int
+	pending []*pendingStream
+	seq     uint64
+}
+
+// pendingStream is a container stream waiting for a slot
+type pendingStream struct {
+	priority int
+	seq      uint64
+	// ready is closed when the stream is granted a slot
+	ready chan struct{}
+}
+
+// newStreamScheduler creates a scheduler allowing limit concurrent streams, or nil if the
+// limit is not positive
+func newStreamScheduler(limit int, priority StreamPriority) *streamScheduler {
+	if limit <= 0 {
+		return nil
+	}
+	return &streamScheduler{limit: limit, priority: priority}
+}
+
+// acquire waits for a slot to stream a container of the pod.
+// It returns false if the context is done first.
+func (s *streamScheduler) acquire(ctx context.Context, pod *corev1.Pod) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	if s.running < s.limit && len(s.pending) == 0 {
+		s.running++
+		s.mu.Unlock()
+		return true
+	}
+
+	waiter := &pendingStream{seq: s.seq, ready: make(chan struct{})}
+	if s.priority != nil {
+		waiter.priority = s.priority(pod)
+	}
+	s.seq++
+	s.enqueue(waiter)
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return true
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, p := range s.pending {
+		if p == waiter {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return false
+		}
+	}
+
+	// The slot was granted while giving up, pass it on
+	s.grant()
+	return false
+}
+
+// release frees a slot, handing it to the highest priority waiting stream
+func (s *streamScheduler) release() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grant()
+}
+
+// grant frees a slot and starts the next waiting stream, if any. The caller holds the lock.
+func (s *streamScheduler) grant() {
+	if len(s.pending) == 0 {
+		s.running--
+		return
+	}
+
+	next := s.pending[0]
+	s.pending = s.pending[1:]
+	close(next.ready)
+}
+
+// enqueue inserts the waiter behind every waiter of the same or higher priority.
+// The caller holds the lock.
+func (s *streamScheduler) enqueue(waiter *pendingStream) {
+	i := len(s.pending)
+	for i > 0 && s.pending[i-1].priority < waiter.priority {
+		i--
+	}
+	s.pending = append(s.pending, nil)
+	copy(s.pending[i+1:], s.pending[i:])
+	s.pending[i] = waiter
+}
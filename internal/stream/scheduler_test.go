@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restartPriority ranks pods by the restarts of their first container
+func restartPriority(pod *corev1.Pod) int {
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return 0
+	}
+	return int(pod.Status.ContainerStatuses[0].RestartCount)
+}
+
+// newRestartedPod creates a pod whose container restarted the given number of times
+func newRestartedPod(name string, restarts int32) *corev1.Pod {
+	pod := newTestPod("default", name, "app")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", RestartCount: restarts}}
+	return pod
+}
+
+// streamOrder queues a stream for each pod behind a busy slot, in order, and returns the
+// order in which they are granted a slot once it is released
+func streamOrder(t *testing.T, scheduler *streamScheduler, pods ...*corev1.Pod) []string {
+	t.Helper()
+
+	// Occupy the only slot
+	if !scheduler.acquire(context.Background(), newTestPod("default", "busy")) {
+		t.Fatal("acquire() = false for a free slot")
+	}
+
+	started := make(chan string, len(pods))
+	for i, pod := range pods {
+		go func() {
+			if scheduler.acquire(context.Background(), pod) {
+				started <- pod.Name
+				scheduler.release()
+			}
+		}()
+
+		// Wait for the stream to be queued so arrival order is deterministic
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			scheduler.mu.Lock()
+			queued := len(scheduler.pending)
+			scheduler.mu.Unlock()
+			if queued == i+1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("stream for %s was not queued", pod.Name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	scheduler.release()
+
+	var order []string
+	for range pods {
+		select {
+		case name := <-started:
+			order = append(order, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("streams started %v, the rest never got a slot", order)
+		}
+	}
+	return order
+}
+
+func TestStreamScheduler_Priority(t *testing.T) {
+	scheduler := newStreamScheduler(1, restartPriority)
+
+	order := streamOrder(t, scheduler,
+		newRestartedPod("healthy", 0),
+		newRestartedPod("crashing", 5),
+		newRestartedPod("flaky", 2),
+		newRestartedPod("also-healthy", 0),
+	)
+
+	if got, want := fmt.Sprint(order), "[crashing flaky healthy also-healthy]"; got != want {
+		t.Errorf("streams started in order %s, want %s", got, want)
+	}
+}
+
+func TestStreamScheduler_FIFO(t *testing.T) {
+	scheduler := newStreamScheduler(1, nil)
+
+	order := streamOrder(t, scheduler,
+		newRestartedPod("first", 0),
+		newRestartedPod("second", 5),
+		newRestartedPod("third", 2),
+	)
+
+	if got, want := fmt.Sprint(order), "[first second third]"; got != want {
+		t.Errorf("streams started in order %s, want %s", got, want)
+	}
+}
+
+func TestStreamScheduler_CancelledWhileWaiting(t *testing.T) {
+	scheduler := newStreamScheduler(1, nil)
+	scheduler.acquire(context.Background(), newTestPod("default", "busy"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if scheduler.acquire(ctx, newTestPod("default", "cancelled")) {
+		t.Fatal("acquire() = true with a cancelled context and no free slot")
+	}
+
+	// The cancelled stream gave up its place, the slot is free again after release
+	scheduler.release()
+	if !scheduler.acquire(context.Background(), newTestPod("default", "next")) {
+		t.Error("acquire() = false after the slot was released")
+	}
+	if len(scheduler.pending) != 0 || scheduler.running != 1 {
+		t.Errorf("scheduler has %d pending and %d running streams, want 0 and 1", len(scheduler.pending), scheduler.running)
+	}
+}
+
+func TestStreamScheduler_Unlimited(t *testing.T) {
+	scheduler := newStreamScheduler(0, restartPriority)
+	if scheduler != nil {
+		t.Fatal("newStreamScheduler(0) returned a scheduler")
+	}
+
+	for i := 0; i < 100; i++ {
+		if !scheduler.acquire(context.Background(), newTestPod("default", "pod")) {
+			t.Fatal("nil scheduler refused a stream")
+		}
+	}
+	scheduler.release()
+}
@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestStreamer_AdditionalSelectors(t *testing.T) {
+	web := newTestPod("default", "web-1", "app")
+	web.UID = types.UID("web-1")
+	web.Labels = map[string]string{"app": "web", "tier": "frontend"}
+	api := newTestPod("default", "api-1", "app")
+	api.UID = types.UID("api-1")
+	api.Labels = map[string]string{"app": "api", "tier": "frontend"}
+	db := newTestPod("default", "db-1", "app")
+	db.UID = types.UID("db-1")
+	db.Labels = map[string]string{"app": "db"}
+
+	s := newTestStreamer(t, &StreamerConfig{
+		KubeClientProvider: newFakeProvider(web, api, db),
+		Handler:            &recordingHandler{},
+		DryRunMatch:        true,
+		Filter: &filter.LogFilter{
+			Namespaces:          []string{"default"},
+			ContainerState:      filter.DefaultContainerState,
+			LabelSelector:       labels.SelectorFromSet(labels.Set{"app": "web"}),
+			AdditionalSelectors: []labels.Selector{labels.SelectorFromSet(labels.Set{"tier": "frontend"})},
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	// web-1 matches both selectors and is counted and streamed once
+	if report := s.MatchReport(); report.PodsMatched != 2 {
+		t.Errorf("MatchReport() = %+v, want 2 pods", report)
+	}
+	for _, name := range []string{"web-1", "api-1"} {
+		value, ok := s.active.Load(podKey("default", name))
+		if !ok {
+			t.Fatalf("pod %s matching a selector is not streamed", name)
+		}
+		if streams := value.(*activePod).streams.Load(); streams != 1 {
+			t.Errorf("pod %s has %d streams, want 1", name, streams)
+		}
+	}
+	if _, ok := s.active.Load(podKey("default", "db-1")); ok {
+		t.Error("pod db-1 matching no selector is streamed")
+	}
+
+	// Relabeled out of app=web, web-1 is reported deleted by that selector's watch but
+	// still matches tier=frontend
+	relabeled := web.DeepCopy()
+	relabeled.Labels["app"] = "web-v2"
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: relabeled})
+	if _, ok := s.active.Load(podKey("default", "web-1")); !ok {
+		t.Error("pod still matching a selector stopped streaming")
+	}
+}
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"runtime"
+	"sort"
+	"time"
+)
+
+// StreamSnapshot is the state of a container stream at the time of a Snapshot
+type StreamSnapshot struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// Retries is the number of times the stream was retried after an error
+	Retries int64
+	// LastSeen is when the last line was read from the stream, zero if none was
+	LastSeen time.Time
+	// PendingLines is the number of lines buffered by the multiline matcher
+	PendingLines int
+	// Completed is set when the pod completed and its stream ends at the end of its log
+	Completed bool
+}
+
+// Snapshot is the diagnostic state of a streamer
+type Snapshot struct {
+	// Taken is when the snapshot was taken
+	Taken time.Time
+	// ActivePods is the number of pods being streamed
+	ActivePods int
+	// Streams holds the container streams that started, sorted by namespace, pod and container
+	Streams []StreamSnapshot
+	// Goroutines is the number of goroutines of the process
+	Goroutines int
+}
+
+// Snapshot returns the state of the active pods and their container streams. Each
+// stream's values are read atomically, but not all at the same instant.
+func (s *Streamer) Snapshot() Snapshot {
+	snapshot := Snapshot{Taken: time.Now(), Goroutines: runtime.NumGoroutine()}
+
+	s.active.Range(func(_, value any) bool {
+		active := value.(*activePod)
+		snapshot.ActivePods++
+		active.cursors.Range(func(key, value any) bool {
+			cursor := value.(*streamCursor)
+			stream := StreamSnapshot{
+				Namespace:     active.pod.Namespace,
+				PodName:       active.pod.Name,
+				ContainerName: key.(string),
+				Retries:       cursor.retries.Load(),
+				PendingLines:  int(cursor.pending.Load()),
+				Completed:     active.completed.Load(),
+			}
+			if seen := cursor.lastSeen.Load(); seen != 0 {
+				stream.LastSeen = time.Unix(0, seen)
+			}
+			snapshot.Streams = append(snapshot.Streams, stream)
+			return true
+		})
+		return true
+	})
+
+	sort.Slice(snapshot.Streams, func(i, j int) bool {
+		a, b := snapshot.Streams[i], snapshot.Streams[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.PodName != b.PodName {
+			return a.PodName < b.PodName
+		}
+		return a.ContainerName < b.ContainerName
+	})
+	return snapshot
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStreamer_Snapshot(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+	s.startPodLogStreamer(ctx, newTestPod("default", "web-2", "app"))
+	s.startPodLogStreamer(ctx, newTestPod("default", "web-1", "app", "sidecar"))
+
+	// Wait until every stream read a line
+	var snapshot Snapshot
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot = s.Snapshot()
+		read := 0
+		for _, stream := range snapshot.Streams {
+			if !stream.LastSeen.IsZero() {
+				read++
+			}
+		}
+		if read == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot = %+v, want 3 streams that read a line", snapshot)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if snapshot.ActivePods != 2 || snapshot.Goroutines == 0 || snapshot.Taken.IsZero() {
+		t.Errorf("snapshot = %+v, want 2 active pods", snapshot)
+	}
+	want := []string{"web-1/app", "web-1/sidecar", "web-2/app"}
+	for i, stream := range snapshot.Streams {
+		if got := stream.PodName + "/" + stream.ContainerName; got != want[i] || stream.Namespace != "default" {
+			t.Errorf("stream %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestStreamer_SnapshotPendingLines(t *testing.T) {
+	s := newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}, Matcher: indentMatcher{}})
+
+	pod := newTestPod("default", "web-1", "app")
+	active := &activePod{pod: pod}
+	cursor := newStreamCursor()
+	active.cursors.Store("app", cursor)
+	s.active.Store(podKey(pod.Namespace, pod.Name), active)
+
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.processLogStream(context.Background(), reader, "web-1", "app", "default", nil, nil, cursor)
+	}()
+
+	// A stack trace waits in the multiline buffer for the line that ends it
+	if _, err := io.WriteString(writer, "ERROR failed\n  at com.example.Main\n"); err != nil {
+		t.Fatalf("write error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		streams := s.Snapshot().Streams
+		if len(streams) == 1 && streams[0].PendingLines == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("streams = %+v, want 2 pending lines", streams)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	writer.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	if pending := s.Snapshot().Streams[0].PendingLines; pending != 0 {
+		t.Errorf("pending lines = %d after the buffer was flushed, want 0", pending)
+	}
+}
@@ -0,0 +1,38 @@
+package stream
+
+// StreamKind identifies which output of the container a log line was written to.
+// The kubelet merges stdout and stderr into a single log stream, so the kind is only
+// known when a StreamClassifier recognizes it from the line itself.
+type StreamKind int
+
+const (
+	// StreamUnknown is used when no classifier is set or it couldn't tell the stream
+	StreamUnknown StreamKind = iota
+	// StreamStdout marks lines written to the container's standard output
+	StreamStdout
+	// StreamStderr marks lines written to the container's standard error
+	StreamStderr
+)
+
+// StreamClassifier decides which output of the container a log line was written to
+type StreamClassifier func(line string) StreamKind
+
+// String returns the name of the stream kind
+func (k StreamKind) String() string {
+	switch k {
+	case StreamStdout:
+		return "stdout"
+	case StreamStderr:
+		return "stderr"
+	default:
+		return "unknown"
+	}
+}
+
+// Label returns the name of the stream kind for display, or an empty string if it is unknown
+func (k StreamKind) Label() string {
+	if k == StreamUnknown {
+		return ""
+	}
+	return k.String()
+}
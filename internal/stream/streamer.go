@@ -2,16 +2,25 @@ package stream
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/archsyscall/klogstream/internal/errors"
 	"github.com/archsyscall/klogstream/internal/filter"
 	"github.com/archsyscall/klogstream/internal/kube"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -22,6 +31,12 @@ type LogHandler interface {
 	OnEnd()
 }
 
+// RawLogHandler receives the unformatted bytes of each log line.
+// The message carries the line's metadata, its Message field is not populated.
+type RawLogHandler interface {
+	OnRawLog(raw []byte, meta LogMessage)
+}
+
 // LogFormatter is an interface for formatting log messages
 type LogFormatter interface {
 	Format(LogMessage) string
@@ -45,9 +60,23 @@ type LogMessage struct {
 	Namespace     string
 	PodName       string
 	ContainerName string
+	NodeName      string
+	StreamLabel   string
+	LineNumber    int64
+	Stream        StreamKind
+	IsEvent       bool
 	Timestamp     time.Time
 	Message       string
+	Formatted     string
+	SinkLabels    map[string]string
+	PodLabels     map[string]string
+	Historical    bool
+	Truncated     bool
+	Exit          *ContainerExit
 	Raw           []byte
+	// kubelet is the kubelet timestamp of the message's last line, zero if it has none.
+	// Checkpoints are recorded from it whatever the timestamp source.
+	kubelet time.Time
 }
 
 // LogStreamError represents an error that occurred during log streaming
@@ -79,20 +108,113 @@ func NewLogStreamError(err error, permanent bool, reason string) *LogStreamError
 	}
 }
 
+// activePod tracks a pod whose containers are being streamed
+type activePod struct {
+	// pod is the pod object as seen when streaming started
+	pod *corev1.Pod
+	// cancel closes all container streams of the pod
+	cancel context.CancelFunc
+	// restarts holds the restart count of each container as last seen by the watch, guarded
+	// by restartsMu as the pod's events arrive from the watch of every selector
+	restarts   map[string]int32
+	restartsMu sync.Mutex
+	// sinkLabels holds the pod labels copied to the messages of the pod
+	sinkLabels map[string]string
+	// completed is set when the pod succeeded or failed in batch workload mode, its
+	// streams end at EOF instead of reconnecting
+	completed atomic.Bool
+	// streams is the number of the pod's container streams still running
+	streams atomic.Int32
+	// exits holds the container terminations not delivered yet
+	exits containerExits
+	// cursors holds the cursor of each started container stream, keyed by container name
+	cursors sync.Map
+	// ctx is the context of the pod's streams, cancelled by cancel
+	ctx context.Context
+	// containers holds the names of the containers whose stream was started
+	containers sync.Map
+}
+
 // Streamer handles streaming logs from multiple pods
 type Streamer struct {
-	clientset     *kubernetes.Clientset
-	filter        *filter.LogFilter
-	handler       LogHandler
-	formatter     LogFormatter
-	matcher       MultilineMatcher
-	retryPolicy   RetryPolicy
-	maxMultilines int
-	active        sync.Map
-	stopped       bool
-	stopOnce      sync.Once
-	stopCh        chan struct{}
-	wg            sync.WaitGroup
+	clientset       kubernetes.Interface
+	filter          *filter.LogFilter
+	handler         LogHandler
+	rawHandler      RawLogHandler
+	formatter       LogFormatter
+	matcher         MultilineMatcher
+	retryPolicy     RetryPolicy
+	maxMultilines   int
+	linePrefix      *linePrefix
+	trimCR          bool
+	sanitizeUTF8    bool
+	streamLabel     string
+	dryRunMatch     bool
+	lineNumbering   bool
+	classifier      StreamClassifier
+	maxDuration     time.Duration
+	listPageSize    int64
+	restartEvents   bool
+	handlerStart    func(ctx context.Context) error
+	handlerClose    func() error
+	scheduler       *streamScheduler
+	onListComplete  func(namespace string, matched int)
+	autoDetectJSON  bool
+	quietGrace      time.Duration
+	quietReprobe    time.Duration
+	retryBudget     *retryBudget
+	podFilter       func(pod *corev1.Pod) bool
+	podSerializer   *podSerializer
+	checkpoints     *checkpointer
+	followFromNow   bool
+	followFrom      time.Time
+	podRateLimit    *podRateLimiter
+	logOverrides    []ContainerLogOptions
+	timeSource      TimestampSource
+	timeExtractor   TimestampExtractor
+	replaceMessage  bool
+	readDeadline    time.Duration
+	drainTimeout    time.Duration
+	stopping        atomic.Int64
+	maxReconnects   int
+	sinkLabelKeys   []string
+	podStats        *podStats
+	initialBurst    time.Duration
+	maxLogAge       time.Duration
+	batchWorkloads  bool
+	exitCodes       bool
+	healthChecker   *healthChecker
+	overlap         time.Duration
+	onFirstLog      func(ref PodContainerRef, at time.Time)
+	firstLogReset   bool
+	lifecycle       StreamLifecycleHandler
+	diag            *diagnostics
+	matchCache      *matchCache
+	summary         runSummary
+	batcher         *podBatcher
+	after           func(time.Duration) <-chan time.Time
+	endReason       EndReason
+	deletionGrace   time.Duration
+	graceTimers     graceTimers
+	sinceAfterStart *time.Duration
+	backfillRate    int
+	listConcurrency int
+	readBufferSize  int
+	maxLineBytes    int
+	dropOversize    int
+	matchReport     MatchReport
+	metrics         metrics
+	compression     *kube.CompressionStats
+	pause           pauseGate
+	queue           *dropQueue
+	// active holds the activePod of every streamed pod, keyed by podKey
+	active   sync.Map
+	mu       sync.Mutex
+	stopped  bool
+	stopOnce sync.Once
+	endOnce  sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
 }
 
 // StreamerConfig contains configuration for the streamer
@@ -104,11 +226,195 @@ type StreamerConfig struct {
 	Matcher            MultilineMatcher
 	RetryPolicy        RetryPolicy
 	MaxMultilines      int
+	// LinePrefix is a template rendered and prepended to every message before it reaches the handler,
+	// to both Message and Formatted
+	LinePrefix string
+	// PauseBufferSize is the number of messages buffered while paused, messages beyond it are dropped
+	PauseBufferSize int
+	// TrimCarriageReturn strips a trailing \r from each line, for containers emitting CRLF line endings
+	TrimCarriageReturn bool
+	// SanitizeUTF8 replaces invalid UTF-8 sequences in messages with the replacement rune
+	SanitizeUTF8 bool
+	// StreamLabel is stamped on every message to identify the streamer that produced it
+	StreamLabel string
+	// HandlerTimeout bounds each OnLog call, calls exceeding it are abandoned, counted in
+	// Metrics.HandlerTimeouts and reported via OnError at most every DefaultTimeoutReportInterval
+	HandlerTimeout time.Duration
+	// DryRunMatch lists pods once on Start and reports how many pods and containers the filter matches
+	DryRunMatch bool
+	// PodDeletionGracePeriod keeps a deleted pod's streams open for its termination grace period,
+	// capped at this duration, to capture shutdown logs. Zero leaves streams to end on their own.
+	PodDeletionGracePeriod time.Duration
+	// BackfillRate paces the historical lines sent when a stream opens, in lines per second,
+	// until the stream catches up with live output. Zero reads the backlog as fast as possible.
+	BackfillRate int
+	// RawHandler, if set, receives each line's bytes exactly as read from the container, kubelet
+	// timestamp included, in place of Handler.OnLog, skipping formatting, sanitizing and line prefixes. Handler still receives
+	// errors and the end of the stream.
+	RawHandler RawLogHandler
+	// NamespaceListConcurrency bounds how many namespaces are listed in parallel on Start.
+	// Defaults to DefaultNamespaceListConcurrency.
+	NamespaceListConcurrency int
+	// SinceAfterStart streams each pod's logs from its status.startTime plus this offset,
+	// or from Filter.Since if that is later
+	SinceAfterStart *time.Duration
+	// Backpressure decides what happens when the handler can't keep up, defaults to BackpressureBlock
+	Backpressure BackpressureStrategy
+	// BackpressureBufferSize is the number of lines queued for the handler by the drop strategies.
+	// Defaults to DefaultBackpressureBufferSize.
+	BackpressureBufferSize int
+	// ReadBufferSize is the size of the buffer each log stream is read through.
+	// Defaults to DefaultReadBufferSize.
+	ReadBufferSize int
+	// MaxLineBytes caps the bytes read into a single line, a longer line is delivered in
+	// pieces of this length with LogMessage.Truncated set on each but the last. Defaults to
+	// DefaultMaxLineBytes.
+	MaxLineBytes int
+	// MaxLineBytesDrop drops any line longer than this many bytes before it is merged or
+	// delivered, counting it in Metrics.LinesOversize. Zero keeps lines of any length.
+	MaxLineBytesDrop int
+	// LineNumbering stamps each message with its position in the container's stream, starting
+	// from 1. Numbering continues across reconnects of the same container.
+	LineNumbering bool
+	// StreamClassifier, if set, tags each message with the container output it was written to.
+	// Multiline messages are classified by their joined text.
+	StreamClassifier StreamClassifier
+	// MaxDuration stops the streamer by itself once it has been streaming this long, zero streams
+	// until stopped
+	MaxDuration time.Duration
+	// ListPageSize pages through the initial pod listing of each namespace this many pods at a
+	// time, starting streams as pages arrive. Zero lists every pod in a single response.
+	ListPageSize int64
+	// ContainerRestartEvents delivers an event message, flagged with IsEvent, when a streamed
+	// container's restart count goes up
+	ContainerRestartEvents bool
+	// HandlerStart, if set, is called on Start before any pod is listed so the handler can
+	// acquire its resources. An error aborts Start.
+	HandlerStart func(ctx context.Context) error
+	// HandlerClose, if set, is called on Stop after the handler's OnEnd to release its resources
+	HandlerClose func() error
+	// MaxConcurrentStreams bounds how many container streams are open at once, further
+	// containers wait for a stream to end. Zero leaves the number of streams unbounded.
+	MaxConcurrentStreams int
+	// StreamPriority orders the containers waiting for a stream when MaxConcurrentStreams is
+	// reached, higher first. Without it containers are started in the order they were found.
+	StreamPriority StreamPriority
+	// OnListComplete, if set, is called with the number of matching pods after each namespace's
+	// initial listing. Namespaces are listed in parallel, so calls may be concurrent.
+	OnListComplete func(namespace string, matched int)
+	// AutoDetectJSON merges multiline JSON for each container stream whose first line starts
+	// a JSON object, leaving other streams line by line. Ignored when Matcher is set.
+	AutoDetectJSON bool
+	// QuietGrace closes a container stream that produces no output within this period and
+	// opens it again after QuietReprobeInterval. Zero keeps silent streams open.
+	QuietGrace time.Duration
+	// QuietReprobeInterval is how long a silent container waits before its stream is opened
+	// again. Defaults to DefaultQuietReprobeInterval.
+	QuietReprobeInterval time.Duration
+	// RetryBudgetMax and RetryBudgetWindow bound the retries of all streams and watches together
+	// to RetryBudgetMax per RetryBudgetWindow, further retries wait. Zero leaves retries unbounded.
+	RetryBudgetMax    int
+	RetryBudgetWindow time.Duration
+	// PodFilter, if set, must also accept a pod for it to be streamed. It runs after the
+	// built-in filters on every listed pod and every added or modified pod event.
+	PodFilter func(pod *corev1.Pod) bool
+	// MergePodContainers delivers the lines of all containers of a pod one at a time,
+	// in arrival order, instead of concurrently per container
+	MergePodContainers bool
+	// CheckpointStore, if set, persists the last delivered line of every container stream.
+	// Streams resume from their checkpoint when the streamer is started again.
+	CheckpointStore CheckpointStore
+	// CheckpointInterval is how often checkpoints are saved, defaults to DefaultCheckpointInterval
+	CheckpointInterval time.Duration
+	// FollowFromNow streams only lines logged after Start instead of each container's
+	// full log history
+	FollowFromNow bool
+	// PodRateLimit caps the lines per second delivered from each pod, excess lines are
+	// dropped. Zero disables the limit.
+	PodRateLimit int
+	// ContainerLogOptions override the log options of matching containers, the first
+	// matching entry is applied
+	ContainerLogOptions []ContainerLogOptions
+	// TimestampSource decides which time populates LogMessage.Timestamp, defaults to TimestampKubelet
+	TimestampSource TimestampSource
+	// TimestampExtractor reads the application's timestamp from a line for TimestampAppParsed
+	TimestampExtractor TimestampExtractor
+	// ReplaceMessage also stores the formatter's output in LogMessage.Message, replacing the
+	// text read from the container. By default Message is left as read, apart from the line
+	// prefix, and the formatter's output is only set in LogMessage.Formatted.
+	ReplaceMessage bool
+	// BatchWorkloads reads the logs of pods that succeeded or failed, such as the pods of
+	// Jobs, to their end before the pods stop being tracked, instead of reconnecting
+	BatchWorkloads bool
+	// ExitCodeCapture delivers an event message, flagged with IsEvent and carrying
+	// LogMessage.Exit, when a streamed container terminates, after its log has been read
+	ExitCodeCapture bool
+	// HealthCheck, if set, probes the handler's sink every HealthCheckInterval. Zero
+	// HealthCheckInterval disables the probe.
+	HealthCheck         func() error
+	HealthCheckInterval time.Duration
+	// HealthCheckFailures is how many probes in a row must fail before the sink is reported
+	// unhealthy, defaults to DefaultSinkHealthFailures
+	HealthCheckFailures int
+	// ReconnectOverlap resumes a reconnected stream this long before the last line read,
+	// delivering the lines logged within it again. Zero resumes right after the last line.
+	ReconnectOverlap time.Duration
+	// OnSinkUnhealthy is called with the last probe error once the sink is unhealthy, and
+	// again only after it recovered. Without it the failure is reported through OnError.
+	OnSinkUnhealthy func(error)
+	// OnFirstLog, if set, is called with the container and the time when a container's
+	// stream delivers its first line. It is called once per container unless
+	// FirstLogOnReconnect is set, then again after every reconnect.
+	OnFirstLog          func(ref PodContainerRef, at time.Time)
+	FirstLogOnReconnect bool
+	// LifecycleHandler, if set, is told when each container's stream starts and ends
+	LifecycleHandler StreamLifecycleHandler
+	// Logger, if set, receives the streamer's internal diagnostics at debug level, such as
+	// why a pod was skipped and when a stream is opened, closed or retried
+	Logger *slog.Logger
+	// Name is added to every diagnostic record as the "streamer" attribute
+	Name string
+	// MatchCacheTTL caches the pod filter decision for each pod by UID for this long, so
+	// repeated modified events don't evaluate the filters again. A decision is made again
+	// when the pod's labels change. Zero evaluates the filters on every event.
+	MatchCacheTTL time.Duration
+	// MaxLogAge drops lines whose kubelet timestamp is older than this, lines without one
+	// are kept. Zero keeps lines of any age.
+	MaxLogAge time.Duration
+	// InitialBurst marks the messages read within this period after a container's stream
+	// first opened as LogMessage.Historical. Zero marks none.
+	InitialBurst time.Duration
+	// PodMetrics counts the messages delivered per pod and container, see Streamer.PodStats
+	PodMetrics bool
+	// PodMetricsTTL is how long the metrics of a pod are kept after it stops being streamed,
+	// defaults to DefaultPodMetricsTTL
+	PodMetricsTTL time.Duration
+	// SinkLabelKeys are the pod labels copied to LogMessage.SinkLabels
+	SinkLabelKeys []string
+	// MaxReconnects abandons a container after its stream ended without error this many
+	// times in a row within seconds of being opened. Zero reconnects without limit.
+	MaxReconnects int
+	// ReadDeadline fails a log stream read that doesn't return within this period, and the
+	// stream is opened again like after any other read error. Zero lets reads block.
+	ReadDeadline time.Duration
+	// DrainTimeout makes a stream that stops while its multiline matcher holds lines
+	// deliver them as a message, if it stops within this period after Stop. Zero drops them.
+	DrainTimeout time.Duration
+	// BatchHandler, if set, receives the messages in per-pod batches in place of the handler's OnLog
+	BatchHandler BatchLogHandler
+	// BatchMaxSize is the number of messages that fills a pod's batch
+	BatchMaxSize int
+	// BatchMaxWait is how long the first message of a batch waits for the batch to fill,
+	// zero waits until the batch is full or the pod's container ends
+	BatchMaxWait time.Duration
 }
 
 // DefaultMaxMultilines is the default maximum number of lines in a multiline log
 const DefaultMaxMultilines = 500
 
+// DefaultNamespaceListConcurrency is the default number of namespaces listed in parallel on Start
+const DefaultNamespaceListConcurrency = 8
+
 // NewStreamer creates a new Streamer with the provided configuration
 func NewStreamer(config *StreamerConfig) (*Streamer, error) {
 	if config.KubeClientProvider == nil {
@@ -128,6 +434,12 @@ func NewStreamer(config *StreamerConfig) (*Streamer, error) {
 		return nil, fmt.Errorf("log handler is required")
 	}
 
+	// Bound slow handlers if a timeout is configured
+	var handler LogHandler = config.Handler
+	if config.HandlerTimeout > 0 {
+		handler = newTimeoutHandler(handler, config.HandlerTimeout, DefaultTimeoutQueueSize)
+	}
+
 	// Set default formatter if not provided
 	formatter := config.Formatter
 	if formatter == nil {
@@ -141,16 +453,132 @@ func NewStreamer(config *StreamerConfig) (*Streamer, error) {
 		maxMultilines = DefaultMaxMultilines
 	}
 
-	return &Streamer{
-		clientset:     clientset,
-		filter:        config.Filter,
-		handler:       config.Handler,
-		formatter:     formatter,
-		matcher:       config.Matcher,
-		retryPolicy:   config.RetryPolicy,
-		maxMultilines: maxMultilines,
-		stopCh:        make(chan struct{}),
-	}, nil
+	// Set default namespace list concurrency if not provided
+	listConcurrency := config.NamespaceListConcurrency
+	if listConcurrency <= 0 {
+		listConcurrency = DefaultNamespaceListConcurrency
+	}
+
+	// Set default reprobe interval for silent containers if not provided
+	quietReprobe := config.QuietReprobeInterval
+	if quietReprobe <= 0 {
+		quietReprobe = DefaultQuietReprobeInterval
+	}
+
+	// Set default read buffer size if not provided
+	readBufferSize := config.ReadBufferSize
+	if readBufferSize <= 0 {
+		readBufferSize = DefaultReadBufferSize
+	}
+
+	// Set default max line length if not provided
+	maxLineBytes := config.MaxLineBytes
+	if maxLineBytes <= 0 {
+		maxLineBytes = DefaultMaxLineBytes
+	}
+
+	// Parse the line prefix template if provided
+	var prefix *linePrefix
+	if config.LinePrefix != "" {
+		prefix, err = newLinePrefix(config.LinePrefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line prefix template: %w", err)
+		}
+	}
+
+	s := &Streamer{
+		clientset:       clientset,
+		filter:          config.Filter,
+		handler:         handler,
+		rawHandler:      config.RawHandler,
+		formatter:       formatter,
+		matcher:         config.Matcher,
+		retryPolicy:     config.RetryPolicy,
+		maxMultilines:   maxMultilines,
+		linePrefix:      prefix,
+		trimCR:          config.TrimCarriageReturn && config.RawHandler == nil,
+		sanitizeUTF8:    config.SanitizeUTF8,
+		streamLabel:     config.StreamLabel,
+		dryRunMatch:     config.DryRunMatch,
+		lineNumbering:   config.LineNumbering,
+		classifier:      config.StreamClassifier,
+		maxDuration:     config.MaxDuration,
+		listPageSize:    config.ListPageSize,
+		restartEvents:   config.ContainerRestartEvents,
+		handlerStart:    config.HandlerStart,
+		handlerClose:    config.HandlerClose,
+		scheduler:       newStreamScheduler(config.MaxConcurrentStreams, config.StreamPriority),
+		onListComplete:  config.OnListComplete,
+		autoDetectJSON:  config.AutoDetectJSON,
+		quietGrace:      config.QuietGrace,
+		quietReprobe:    quietReprobe,
+		retryBudget:     newRetryBudget(config.RetryBudgetMax, config.RetryBudgetWindow),
+		podFilter:       config.PodFilter,
+		podSerializer:   newPodSerializer(config.MergePodContainers),
+		checkpoints:     newCheckpointer(config.CheckpointStore, config.CheckpointInterval),
+		followFromNow:   config.FollowFromNow,
+		podRateLimit:    newPodRateLimiter(config.PodRateLimit),
+		logOverrides:    config.ContainerLogOptions,
+		timeSource:      config.TimestampSource,
+		timeExtractor:   config.TimestampExtractor,
+		replaceMessage:  config.ReplaceMessage,
+		readDeadline:    config.ReadDeadline,
+		drainTimeout:    config.DrainTimeout,
+		maxReconnects:   config.MaxReconnects,
+		sinkLabelKeys:   config.SinkLabelKeys,
+		podStats:        newPodStats(config.PodMetrics, config.PodMetricsTTL),
+		initialBurst:    config.InitialBurst,
+		maxLogAge:       config.MaxLogAge,
+		batchWorkloads:  config.BatchWorkloads,
+		exitCodes:       config.ExitCodeCapture,
+		overlap:         config.ReconnectOverlap,
+		onFirstLog:      config.OnFirstLog,
+		firstLogReset:   config.FirstLogOnReconnect,
+		lifecycle:       config.LifecycleHandler,
+		diag:            newDiagnostics(config.Logger, config.Name),
+		matchCache:      newMatchCache(config.MatchCacheTTL),
+		healthChecker:   newHealthChecker(config.HealthCheck, config.HealthCheckInterval, config.HealthCheckFailures, config.OnSinkUnhealthy),
+		after:           time.After,
+		deletionGrace:   config.PodDeletionGracePeriod,
+		sinceAfterStart: config.SinceAfterStart,
+		backfillRate:    config.BackfillRate,
+		listConcurrency: listConcurrency,
+		readBufferSize:  readBufferSize,
+		maxLineBytes:    maxLineBytes,
+		dropOversize:    config.MaxLineBytesDrop,
+		compression:     config.KubeClientProvider.CompressionStats(),
+		stopCh:          make(chan struct{}),
+	}
+	s.pause.limit = config.PauseBufferSize
+
+	// Count the handler's timeouts like any other error
+	if timeouts, ok := handler.(*timeoutHandler); ok {
+		timeouts.report = s.reportError
+		timeouts.timedOut = func() {
+			s.metrics.handlerTimeouts.Add(1)
+		}
+		timeouts.dropped = func() {
+			s.metrics.linesDropped.Add(1)
+		}
+	}
+
+	// Group the messages of each pod into batches for a batch handler
+	s.batcher = newPodBatcher(config.BatchHandler, config.BatchMaxSize, config.BatchMaxWait, func(n int) {
+		s.metrics.linesDelivered.Add(uint64(n))
+	})
+
+	// Decouple the stream readers from the handler when lines may be dropped
+	if config.Backpressure != BackpressureBlock {
+		bufferSize := config.BackpressureBufferSize
+		if bufferSize <= 0 {
+			bufferSize = DefaultBackpressureBufferSize
+		}
+		s.queue = newDropQueue(config.Backpressure, bufferSize, func() {
+			s.metrics.linesDropped.Add(1)
+		})
+	}
+
+	return s, nil
 }
 
 // passthrough formatter just returns the message as is
@@ -161,7 +589,7 @@ func (f *passthroughFormatter) Format(msg LogMessage) string {
 }
 
 // Start begins streaming logs for matching pods
-func (s *Streamer) Start(ctx context.Context) error {
+func (s *Streamer) Start(ctx context.Context) (err error) {
 	// Check if already stopped
 	if s.stopped {
 		return NewLogStreamError(fmt.Errorf("streamer is stopped"), true, "streamer stopped")
@@ -169,7 +597,10 @@ func (s *Streamer) Start(ctx context.Context) error {
 
 	// Create a context that can be canceled when Stop is called
 	ctx, cancel := context.WithCancel(ctx)
+	var background sync.WaitGroup
+	background.Add(1)
 	go func() {
+		defer background.Done()
 		select {
 		case <-s.stopCh:
 			cancel()
@@ -178,197 +609,664 @@ func (s *Streamer) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Undo what was started, in reverse order, if starting fails
+	handlerStarted := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		cancel()
+		background.Wait()
+		s.wg.Wait()
+		if s.queue != nil {
+			s.queue.close()
+		}
+		if handlerStarted {
+			s.endHandler()
+		}
+	}()
+
+	s.summary.start()
+
+	// Skip everything logged before now, including after reconnects
+	if s.followFromNow {
+		s.followFrom = time.Now()
+	}
+
+	// Let the handler set up before the first log
+	if s.handlerStart != nil {
+		if err := s.handlerStart(ctx); err != nil {
+			return NewLogStreamError(err, true, "failed to start handler")
+		}
+		handlerStarted = true
+	}
+
+	// Resume streams from where the last run left off
+	if err := s.checkpoints.load(); err != nil {
+		return NewLogStreamError(err, true, "failed to load checkpoints")
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		s.checkpoints.run(ctx, s.reportError)
+	}()
+
+	// Watch the sink for persistent failures
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		s.healthChecker.run(ctx, s.reportError)
+	}()
+
+	// Check up front whether the filter matches anything
+	if s.dryRunMatch {
+		if err := s.runDryRunMatch(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Deliver queued lines to the handler until Stop
+	if s.queue != nil {
+		s.queue.start(s.send)
+	}
+
 	// Start the pod watcher to continuously watch for matching pods
-	return s.startPodWatcher(ctx)
+	if err := s.startPodWatcher(ctx); err != nil {
+		return err
+	}
+
+	// End the capture window on time
+	s.stopAfterMaxDuration(ctx)
+	return nil
 }
 
 // Stop stops all log streaming activity
 func (s *Streamer) Stop() {
+	s.stop(EndReasonStopped)
+}
+
+// stop stops all log streaming activity, recording why the streamer ended
+func (s *Streamer) stop(reason EndReason) {
 	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.endReason = reason
+		s.mu.Unlock()
+
 		s.stopped = true
+		s.beginStopping()
 		close(s.stopCh)
+		s.graceTimers.stop()
 		s.wg.Wait()
+		s.pause.close()
+		if s.queue != nil {
+			s.queue.close()
+		}
+		s.batcher.flushAll()
+
+		// Every line has been delivered, save where each stream got to
+		if err := s.checkpoints.save(); err != nil {
+			s.reportError(NewLogStreamError(err, false, "failed to save checkpoints"))
+		}
+		s.summary.end()
+		s.endHandler()
+	})
+}
+
+// endHandler tells the handler the stream ended and releases its resources, once
+func (s *Streamer) endHandler() {
+	s.endOnce.Do(func() {
 		s.handler.OnEnd()
+
+		// The handler has seen the end, release its resources. OnError is not called after
+		// OnEnd, so a failure to close only shows in the error count.
+		if s.handlerClose != nil {
+			if err := s.handlerClose(); err != nil {
+				s.metrics.errors.Add(1)
+			}
+		}
 	})
 }
 
 // startPodWatcher starts a goroutine to watch for pods matching the filter
 func (s *Streamer) startPodWatcher(ctx context.Context) error {
-	// Start a watcher for each namespace
-	for _, namespace := range s.filter.Namespaces {
-		// Create watch for pods in this namespace
-		labelSelector := ""
-		if s.filter.LabelSelector != nil {
-			labelSelector = s.filter.LabelSelector.String()
+	selectors := s.labelSelectors()
+	namespaces := s.namespaces()
+
+	// List the namespaces in parallel so startup time doesn't grow with the namespace count,
+	// once per selector when there are several
+	errs := make([]error, len(namespaces)*len(selectors))
+	workers := make(chan struct{}, s.listConcurrency)
+	var wg sync.WaitGroup
+	for i, namespace := range namespaces {
+		for j, selector := range selectors {
+			wg.Add(1)
+			workers <- struct{}{}
+			go func(i int, ns, selector string) {
+				defer wg.Done()
+				defer func() { <-workers }()
+				errs[i] = s.listAndWatchNamespace(ctx, ns, selector)
+			}(i*len(selectors)+j, namespace, selector)
 		}
+	}
+	wg.Wait()
 
-		// Start by listing existing pods
-		pods, err := s.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-			LabelSelector: labelSelector,
-		})
+	var failed []error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		namespace := namespaces[i/len(selectors)]
+		if len(selectors) > 1 {
+			failed = append(failed, fmt.Errorf("namespace %s selector %q: %w", namespace, selectors[i%len(selectors)], err))
+		} else {
+			failed = append(failed, fmt.Errorf("namespace %s: %w", namespace, err))
+		}
+	}
+
+	// Nothing can be streamed if every namespace failed
+	if len(failed) > 0 && len(failed) == len(errs) {
+		return NewLogStreamError(stderrors.Join(failed...), true, "failed to list pods")
+	}
+
+	// Otherwise keep streaming the namespaces that worked and report the rest
+	for _, err := range failed {
+		s.reportError(NewLogStreamError(err, true, "failed to list pods"))
+	}
+
+	return nil
+}
+
+// listAndWatchNamespace starts streaming the existing pods of a namespace and then
+// watches it for pod changes in the background
+func (s *Streamer) listAndWatchNamespace(ctx context.Context, namespace, labelSelector string) error {
+	// Start streaming logs for existing pods as each page arrives
+	matched := 0
+	resourceVersion, err := s.listPods(ctx, namespace, labelSelector, func(pod *corev1.Pod) {
+		if s.shouldStreamPod(pod) {
+			matched++
+			s.startPodLogStreamer(ctx, pod)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.onListComplete != nil {
+		s.onListComplete(namespace, matched)
+	}
+
+	// Now watch for new pods, starting right after the list
+	s.wg.Add(1)
+	go s.watchNamespace(ctx, namespace, labelSelector, resourceVersion)
+
+	return nil
+}
+
+// listPods lists the pods of a namespace, a page at a time when a page size is set,
+// calling fn for every pod. It returns the resource version of the list.
+func (s *Streamer) listPods(ctx context.Context, namespace, labelSelector string, fn func(pod *corev1.Pod)) (string, error) {
+	opts := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: s.fieldSelector(),
+		Limit:         s.listPageSize,
+	}
+
+	for {
+		pods, err := s.clientset.CoreV1().Pods(namespace).List(ctx, opts)
 		if err != nil {
-			return NewLogStreamError(err, true, "failed to list pods")
+			return "", err
 		}
 
-		// Start streaming logs for existing pods
-		for _, pod := range pods.Items {
-			if s.shouldStreamPod(&pod) {
-				s.startPodLogStreamer(ctx, &pod)
-			}
+		for i := range pods.Items {
+			fn(&pods.Items[i])
 		}
 
-		// Now watch for new pods
-		s.wg.Add(1)
-		go func(ns string) {
-			defer s.wg.Done()
+		if pods.Continue == "" {
+			return pods.ResourceVersion, nil
+		}
+		opts.Continue = pods.Continue
+	}
+}
 
-			// Use a retry loop for the watcher
-			retry := 0
-			backoff := s.retryPolicy.InitialInterval
+// watchNamespace watches a namespace for pod changes from the resource version until the
+// streamer stops. Watches closed by the API server resume from the last version seen.
+func (s *Streamer) watchNamespace(ctx context.Context, ns, labelSelector, resourceVersion string) {
+	defer s.wg.Done()
 
+	// Use a retry loop for the watcher
+	retry := 0
+	backoff := s.retryPolicy.InitialInterval
+
+	for {
+		// Check if we should stop
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		default:
+			// Continue
+		}
+
+		// Without a known resource version, start from the current state
+		if resourceVersion == "" {
+			resourceVersion = "0"
+		}
+
+		// Create a watch for pods
+		watcher, err := s.clientset.CoreV1().Pods(ns).Watch(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+			FieldSelector: s.fieldSelector(),
+			// Continue where the list or the previous watch left off
+			ResourceVersion: resourceVersion,
+			// Keep the resource version current even when no pod changes
+			AllowWatchBookmarks: true,
+			// Timeout after a while so we can check for cancellation
+			TimeoutSeconds: new(int64),
+		})
+
+		if err == nil {
+			// Process events until the watch channel is closed or the watch fails
+			events := watcher.ResultChan()
+		eventLoop:
 			for {
-				// Check if we should stop
 				select {
 				case <-ctx.Done():
+					watcher.Stop()
 					return
 				case <-s.stopCh:
+					watcher.Stop()
 					return
-				default:
-					// Continue
-				}
-
-				// Create a watch for pods
-				watcher, err := s.clientset.CoreV1().Pods(ns).Watch(ctx, metav1.ListOptions{
-					LabelSelector: labelSelector,
-					// Ignore too old events by setting the resource version
-					ResourceVersion: "0",
-					// Timeout after a while so we can check for cancellation
-					TimeoutSeconds: new(int64),
-				})
+				case event, ok := <-events:
+					if !ok {
+						break eventLoop
+					}
 
-				if err != nil {
-					// Check if this is a permanent error
-					if isPermError(err) {
-						s.handler.OnError(NewLogStreamError(err, true, "failed to watch pods"))
-						return
+					if event.Type == watch.Error {
+						watcher.Stop()
+						err = apierrors.FromObject(event.Object)
+						break eventLoop
 					}
 
-					// Handle transient error
-					s.handler.OnError(NewLogStreamError(err, false, "failed to watch pods"))
+					// Reset retry counter once the watch works
+					retry = 0
+					backoff = s.retryPolicy.InitialInterval
 
-					// Retry with backoff
-					retry++
-					if retry > s.retryPolicy.MaxRetries {
-						s.handler.OnError(NewLogStreamError(fmt.Errorf("exceeded maximum retries"), true, "pod watch retries exceeded"))
-						return
+					if pod, ok := event.Object.(*corev1.Pod); ok && pod.ResourceVersion != "" {
+						resourceVersion = pod.ResourceVersion
 					}
+					s.handlePodEvent(ctx, event)
+				}
+			}
 
-					// Sleep with backoff
-					select {
-					case <-time.After(backoff):
-						// Increase backoff for next retry
-						backoff = time.Duration(float64(backoff) * s.retryPolicy.Multiplier)
-						if backoff > s.retryPolicy.MaxInterval {
-							backoff = s.retryPolicy.MaxInterval
-						}
-					case <-ctx.Done():
-						return
-					case <-s.stopCh:
-						return
-					}
+			// The watch channel was closed, resume from the last event
+			if err == nil {
+				continue
+			}
 
-					continue
-				}
+			// The resource version is too old, the API server no longer has the events
+			// since then. Watch again from the current state, pods already streamed are skipped.
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				resourceVersion = ""
+				continue
+			}
+		}
 
-				// Reset retry counter on successful watch
-				retry = 0
-				backoff = s.retryPolicy.InitialInterval
+		// Check if this is a permanent error
+		if isPermError(err) || isPermWatchError(err) {
+			s.reportError(NewLogStreamError(err, true, "failed to watch pods"))
+			return
+		}
 
-				// Process events
-				for event := range watcher.ResultChan() {
-					// Check if we should stop
-					select {
-					case <-ctx.Done():
-						watcher.Stop()
-						return
-					case <-s.stopCh:
-						watcher.Stop()
-						return
-					default:
-						// Continue
+		// Handle transient error
+		s.reportError(NewLogStreamError(err, false, "failed to watch pods"))
+
+		// Retry with backoff
+		retry++
+		s.metrics.retries.Add(1)
+		if retry > s.retryPolicy.MaxRetries {
+			s.reportError(NewLogStreamError(fmt.Errorf("exceeded maximum retries"), true, "pod watch retries exceeded"))
+			return
+		}
+
+		// Wait for the retry budget shared by all streams
+		if !s.retryBudget.wait(ctx) {
+			return
+		}
+
+		// Sleep with backoff
+		select {
+		case <-time.After(backoff):
+			// Increase backoff for next retry
+			backoff = time.Duration(float64(backoff) * s.retryPolicy.Multiplier)
+			if backoff > s.retryPolicy.MaxInterval {
+				backoff = s.retryPolicy.MaxInterval
+			}
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// labelSelector returns the server-side label selector for listing and watching pods
+func (s *Streamer) labelSelector() string {
+	if s.filter.LabelSelector != nil {
+		return s.filter.LabelSelector.String()
+	}
+	return ""
+}
+
+// namespaces returns the namespaces to list and watch pods in, the single all namespaces
+// scope when the filter streams every namespace. Excluded namespaces are left out.
+func (s *Streamer) namespaces() []string {
+	if s.filter.AllNamespaces {
+		return []string{metav1.NamespaceAll}
+	}
+	if len(s.filter.ExcludeNamespaces) == 0 {
+		return s.filter.Namespaces
+	}
+
+	namespaces := make([]string, 0, len(s.filter.Namespaces))
+	for _, namespace := range s.filter.Namespaces {
+		if !s.excludesNamespace(namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// excludesNamespace reports whether the namespace's pods are never streamed
+func (s *Streamer) excludesNamespace(namespace string) bool {
+	return slices.Contains(s.filter.ExcludeNamespaces, namespace)
+}
+
+// labelSelectors returns the server-side label selectors to list and watch pods with. Each
+// is listed and watched on its own and the pods found are unioned.
+func (s *Streamer) labelSelectors() []string {
+	if len(s.filter.AdditionalSelectors) == 0 {
+		return []string{s.labelSelector()}
+	}
+
+	var selectors []string
+	if s.filter.LabelSelector != nil {
+		selectors = append(selectors, s.filter.LabelSelector.String())
+	}
+	for _, selector := range s.filter.AdditionalSelectors {
+		selectors = append(selectors, selector.String())
+	}
+	return selectors
+}
+
+// matchesLabels checks the pod's labels against the label selector, or against any of the
+// selectors when there are additional ones
+func (s *Streamer) matchesLabels(pod *corev1.Pod) bool {
+	set := labels.Set(pod.Labels)
+	if len(s.filter.AdditionalSelectors) == 0 {
+		return s.filter.LabelSelector == nil || s.filter.LabelSelector.Matches(set)
+	}
+
+	if s.filter.LabelSelector != nil && s.filter.LabelSelector.Matches(set) {
+		return true
+	}
+	for _, selector := range s.filter.AdditionalSelectors {
+		if selector.Matches(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePodEvent starts or stops tracking pods based on a watch event
+func (s *Streamer) handlePodEvent(ctx context.Context, event watch.Event) {
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		if pod, ok := event.Object.(*corev1.Pod); ok {
+			if s.shouldStreamPod(pod) {
+				// Check if we're already streaming this pod
+				if value, exists := s.active.Load(podKey(pod.Namespace, pod.Name)); !exists {
+					s.startPodLogStreamer(ctx, pod)
+				} else if event.Type == watch.Modified {
+					// Mark container restarts inline with the logs
+					if s.restartEvents {
+						s.annotateRestarts(value.(*activePod), pod)
 					}
+					s.captureExits(value.(*activePod), pod)
 
-					// Process the pod event
-					switch event.Type {
-					case "ADDED", "MODIFIED":
-						if pod, ok := event.Object.(*corev1.Pod); ok {
-							if s.shouldStreamPod(pod) {
-								// Check if we're already streaming this pod
-								if _, exists := s.active.Load(pod.Name); !exists {
-									s.startPodLogStreamer(ctx, pod)
-								}
-							}
-
-							// Check if pod has completed (Succeeded or Failed phase)
-							if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
-								// Pod has completed, stop tracking it
-								s.active.Delete(pod.Name)
-							}
-						}
-					case "DELETED":
-						if pod, ok := event.Object.(*corev1.Pod); ok {
-							// Pod is gone, stop any active streamers
-							s.active.Delete(pod.Name)
-						}
+					// Start the containers that reached the state or the restart count streamed
+					if s.streamsByState() {
+						s.startContainerStreams(value.(*activePod), pod)
 					}
 				}
+			} else if s.untrackPod(pod.Namespace, pod.Name) != nil {
+				// The pod was changed, such as relabeled, so it no longer matches
+				s.forgetPod(pod.Namespace, pod.Name)
+			}
 
-				// If we get here, the watch channel was closed, retry
+			// Check if pod has completed (Succeeded or Failed phase)
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				if value, exists := s.active.Load(podKey(pod.Namespace, pod.Name)); exists && s.batchWorkloads {
+					// Read the rest of the pod's logs, it stops being tracked when they end
+					value.(*activePod).completed.Store(true)
+				} else {
+					// Pod has completed, stop tracking it
+					s.untrackPod(pod.Namespace, pod.Name)
+				}
+			}
+		}
+	case watch.Deleted:
+		if pod, ok := event.Object.(*corev1.Pod); ok {
+			// A pod relabeled out of one selector's watch is reported deleted by it, but is
+			// still streamed while it matches another selector
+			if len(s.filter.AdditionalSelectors) > 0 && pod.DeletionTimestamp == nil && s.shouldStreamPod(pod) {
+				return
+			}
+
+			// Pod is gone, stop any active streamers
+			if value, exists := s.active.LoadAndDelete(podKey(pod.Namespace, pod.Name)); exists && s.deletionGrace > 0 {
+				// Keep reading shutdown logs for the pod's grace period before closing the streams
+				active := value.(*activePod)
+				s.graceTimers.after(s.podDeletionGrace(active.pod), func() {
+					active.cancel()
+					s.forgetPod(pod.Namespace, pod.Name)
+				})
+			} else {
+				if exists {
+					value.(*activePod).cancel()
+				}
+				s.forgetPod(pod.Namespace, pod.Name)
 			}
-		}(namespace)
+		}
 	}
+}
 
-	return nil
+// podDeletionGrace returns how long to keep streaming a deleted pod, using the pod's own
+// termination grace period capped by the configured maximum
+func (s *Streamer) podDeletionGrace(pod *corev1.Pod) time.Duration {
+	grace := time.Duration(corev1.DefaultTerminationGracePeriodSeconds) * time.Second
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		grace = time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+	}
+
+	if grace > s.deletionGrace {
+		return s.deletionGrace
+	}
+	return grace
 }
 
-// shouldStreamPod checks if a pod matches the filter criteria
+// shouldStreamPod checks if a pod matches the filter criteria, reusing the cached decision
+// for the pod while it is valid
 func (s *Streamer) shouldStreamPod(pod *corev1.Pod) bool {
+	if s.excludesNamespace(pod.Namespace) {
+		s.diag.podSkipped(pod, "namespace is excluded")
+		return false
+	}
+
+	// The fields, such as the phase, change without the labels the cache is checked against
+	if !s.matchesFields(pod) {
+		s.diag.podSkipped(pod, "fields do not match the selector")
+		return false
+	}
+
+	if match, ok := s.matchCache.lookup(pod); ok {
+		return match
+	}
+	match := s.matchPod(pod)
+	s.matchCache.store(pod, match)
+	return match
+}
+
+// matchPod evaluates the pod filters against a pod
+func (s *Streamer) matchPod(pod *corev1.Pod) bool {
 	// Check pod name regex if specified
 	if s.filter.PodNameRegex != nil && !s.filter.PodNameRegex.MatchString(pod.Name) {
+		s.diag.podSkipped(pod, "pod name does not match "+s.filter.PodNameRegex.String())
+		return false
+	}
+
+	// Check the labels again, a modified pod may no longer match the watch's selector
+	if !s.matchesLabels(pod) {
+		s.diag.podSkipped(pod, "labels do not match the selector")
+		return false
+	}
+
+	// Check the caller's predicate last, the built-in filters are cheaper
+	if s.podFilter != nil && !s.podFilter(pod) {
+		s.diag.podSkipped(pod, "rejected by the pod filter function")
 		return false
 	}
 
 	// Always match at the pod level even if we filter at the container level
+	s.diag.podMatched(pod)
+	return true
+}
+
+// shouldStreamContainer checks if a container of a matching pod passes the filter criteria
+func (s *Streamer) shouldStreamContainer(pod *corev1.Pod, container *corev1.Container) bool {
+	// Check container name regex if specified
+	if s.filter.ContainerRegex != nil && !s.filter.ContainerRegex.MatchString(container.Name) {
+		s.diag.containerSkipped(pod, container.Name, "container name does not match "+s.filter.ContainerRegex.String())
+		return false
+	}
+
+	// Check container image regex against the spec and the resolved image if specified
+	if s.filter.ImageRegex != nil && !s.matchesImage(pod, container) {
+		s.diag.containerSkipped(pod, container.Name, "image does not match "+s.filter.ImageRegex.String())
+		return false
+	}
+
+	// Check container restart count if specified
+	if s.filter.MinRestartCount > 0 {
+		status := containerStatus(pod, container.Name)
+		if status == nil || status.RestartCount < s.filter.MinRestartCount {
+			s.diag.containerSkipped(pod, container.Name,
+				fmt.Sprintf("restart count is below %d", s.filter.MinRestartCount))
+			return false
+		}
+	}
+
+	// Check container state if specified
+	if !s.matchesContainerState(pod, container.Name) {
+		s.diag.containerSkipped(pod, container.Name, "container is not "+s.filter.ContainerState)
+		return false
+	}
+
 	return true
 }
 
+// matchesImage checks the image regex against the container image and the image reported in its status
+func (s *Streamer) matchesImage(pod *corev1.Pod, container *corev1.Container) bool {
+	if s.filter.ImageRegex.MatchString(container.Image) {
+		return true
+	}
+
+	if status := containerStatus(pod, container.Name); status != nil {
+		return s.filter.ImageRegex.MatchString(status.Image) || s.filter.ImageRegex.MatchString(status.ImageID)
+	}
+
+	return false
+}
+
+// containerStatus returns the status of the named container, or nil if it has not been reported
+func containerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
 // startPodLogStreamer starts a goroutine to stream logs for each matching container in the pod
 func (s *Streamer) startPodLogStreamer(ctx context.Context, pod *corev1.Pod) {
-	// Mark this pod as active
-	s.active.Store(pod.Name, true)
+	// Give the pod's streams their own context so they can be closed independently
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Mark this pod as active, unless it already is because it matched another selector
+	active := &activePod{pod: pod, ctx: ctx, cancel: cancel, restarts: restartCounts(pod), sinkLabels: s.podSinkLabels(pod)}
+	if value, loaded := s.active.LoadOrStore(podKey(pod.Namespace, pod.Name), active); loaded {
+		if value.(*activePod).pod.UID == pod.UID {
+			cancel()
+			return
+		}
+		// A new pod took the name, close the streams of the one it replaced
+		s.active.Store(podKey(pod.Namespace, pod.Name), active)
+		value.(*activePod).cancel()
+	}
+	s.captureExits(active, pod)
+	s.startContainerStreams(active, pod)
+}
+
+// startContainerStreams starts a goroutine streaming the logs of each container of the pod
+// that matches and isn't streamed yet
+func (s *Streamer) startContainerStreams(active *activePod, pod *corev1.Pod) {
+	ctx := active.ctx
 
 	// Start a streamer for each container that matches
 	for _, container := range pod.Spec.Containers {
-		// Check container name regex if specified
-		if s.filter.ContainerRegex != nil && !s.filter.ContainerRegex.MatchString(container.Name) {
+		if !s.shouldStreamContainer(pod, &container) {
 			continue
 		}
-
-		// Check container state if specified
-		if s.filter.ContainerState != "all" {
-			// TODO: Implement container state filtering
-			// For now we always stream
+		if _, started := active.containers.LoadOrStore(container.Name, struct{}{}); started {
+			continue
 		}
+		s.summary.streamed(pod.Namespace, pod.Name, container.Name)
 
 		// Start the container log streamer
 		s.wg.Add(1)
+		active.streams.Add(1)
 		go func(podName, containerName, namespace string) {
 			defer s.wg.Done()
+			defer s.endPodStream(active)
+
+			// Report the end of the stream after its last messages
+			life := s.newContainerLifecycle(namespace, podName, containerName)
+			defer life.ended(ctx)
+
+			// Hand over what the container logged last without waiting for the batch to fill
+			defer s.batcher.flush(namespace, podName)
+
+			// Wait for a free slot when the number of streams is limited
+			if !s.scheduler.acquire(ctx, pod) {
+				return
+			}
+			defer s.scheduler.release()
 
 			// Use a retry loop for the log streaming
 			retry := 0
 			backoff := s.retryPolicy.InitialInterval
 
+			// Number lines across reconnects so references stay valid for the whole capture
+			lines := newLineCounter(s.lineNumbering)
+
+			// Reopen the stream after the last line read instead of from the start
+			cursor := newStreamCursor()
+			active.cursors.Store(containerName, cursor)
+
+			// Count streams in a row that ended soon after they were opened
+			reconnects := 0
+
 			for {
 				// Check if we should stop
 				select {
@@ -381,37 +1279,46 @@ func (s *Streamer) startPodLogStreamer(ctx context.Context, pod *corev1.Pod) {
 				}
 
 				// Create the log options
-				opts := &corev1.PodLogOptions{
-					Container: containerName,
-					Follow:    true,
-				}
-
-				// Set the since time if specified
-				if s.filter.Since != nil {
-					sinceTime := metav1.NewTime(*s.filter.Since)
-					opts.SinceTime = &sinceTime
-				}
+				opts := s.podLogOptions(pod, containerName)
+				cursor.resume(opts, s.overlap)
 
 				// Start streaming logs
 				req := s.clientset.CoreV1().Pods(namespace).GetLogs(podName, opts)
 				stream, err := req.Stream(ctx)
 				if err != nil {
+					// The pod's streams were cancelled while the log was being opened
+					if ctx.Err() != nil {
+						return
+					}
+
 					// Check if this is a permanent error
 					if isPermError(err) {
-						s.handler.OnError(NewLogStreamError(err, true,
-							fmt.Sprintf("failed to stream logs for pod %s container %s", podName, containerName)))
+						permanent := NewLogStreamError(err, true,
+							fmt.Sprintf("failed to stream logs for pod %s container %s", podName, containerName))
+						s.reportError(permanent)
+						life.failed(permanent)
 						return
 					}
 
 					// Handle transient error
-					s.handler.OnError(NewLogStreamError(err, false,
+					s.reportError(NewLogStreamError(err, false,
 						fmt.Sprintf("failed to stream logs for pod %s container %s", podName, containerName)))
 
 					// Retry with backoff
 					retry++
+					s.metrics.retries.Add(1)
+					cursor.retried()
 					if retry > s.retryPolicy.MaxRetries {
-						s.handler.OnError(NewLogStreamError(fmt.Errorf("exceeded maximum retries"), true,
-							fmt.Sprintf("log stream retries exceeded for pod %s container %s", podName, containerName)))
+						exceeded := NewLogStreamError(fmt.Errorf("exceeded maximum retries"), true,
+							fmt.Sprintf("log stream retries exceeded for pod %s container %s", podName, containerName))
+						s.reportError(exceeded)
+						life.failed(exceeded)
+						return
+					}
+					s.diag.retryScheduled(namespace, podName, containerName, retry, backoff, err)
+
+					// Wait for the retry budget shared by all streams
+					if !s.retryBudget.wait(ctx) {
 						return
 					}
 
@@ -435,9 +1342,31 @@ func (s *Streamer) startPodLogStreamer(ctx context.Context, pod *corev1.Pod) {
 				// Reset retry counter on successful stream
 				retry = 0
 				backoff = s.retryPolicy.InitialInterval
+				life.opened()
+
+				// Fail reads that hang on a connection that is no longer alive
+				stream = newDeadlineStream(stream, s.readDeadline)
+
+				// Give up on the stream if the container stays silent
+				var quiet *quietStream
+				if s.quietGrace > 0 {
+					quiet = newQuietStream(stream, s.quietGrace)
+					stream = quiet
+				}
+
+				// Report the first line of every stream instead of only the container's first
+				if s.firstLogReset {
+					cursor.rearm()
+				}
 
 				// Process the log stream
-				err = s.processLogStream(ctx, stream, podName, containerName, namespace)
+				opened := time.Now()
+				s.diag.streamOpened(namespace, podName, containerName)
+				s.metrics.activeStreams.Add(1)
+				err = s.processLogStream(ctx, &countingReader{ReadCloser: stream, count: &s.metrics.bytesRead},
+					podName, containerName, namespace, active, lines, cursor)
+				s.metrics.activeStreams.Add(-1)
+				s.diag.streamClosed(namespace, podName, containerName, err)
 
 				// Close the stream
 				stream.Close()
@@ -452,16 +1381,74 @@ func (s *Streamer) startPodLogStreamer(ctx context.Context, pod *corev1.Pod) {
 					// Continue
 				}
 
+				// Report how the container terminated once its log has been read
+				if err == nil && !opts.Previous {
+					s.deliverExit(active, namespace, podName, containerName)
+				}
+
+				// The log of a previous container instance doesn't grow, and a log that isn't
+				// followed ends at the time window, either has been read completely
+				if (opts.Previous || !opts.Follow) && err == nil {
+					return
+				}
+
+				// The log of a completed batch pod has been read to its end
+				if active.completed.Load() && err == nil {
+					return
+				}
+
+				// A silent container gives up its slot until it is probed again
+				if quiet.isQuiet() {
+					s.scheduler.release()
+					select {
+					case <-time.After(s.quietReprobe):
+					case <-ctx.Done():
+						return
+					case <-s.stopCh:
+						return
+					}
+					if !s.scheduler.acquire(ctx, pod) {
+						// The deferred release must not free a slot that isn't held
+						return
+					}
+					continue
+				}
+
+				// Give up on a container whose streams keep ending right after they open
+				if err == nil {
+					if time.Since(opened) < shortLivedStream {
+						reconnects++
+					} else {
+						reconnects = 0
+					}
+					if s.maxReconnects > 0 && reconnects > s.maxReconnects {
+						abandoned := NewLogStreamError(ErrMaxReconnects, true,
+							fmt.Sprintf("log stream of pod %s container %s ended %d times in a row within %v of opening",
+								podName, containerName, reconnects, shortLivedStream))
+						s.reportError(abandoned)
+						life.failed(abandoned)
+						return
+					}
+				}
+
 				// If there was an error, decide whether to retry
 				if err != nil {
 					// Check if this is a permanent error
 					if lse, ok := err.(*LogStreamError); ok && lse.Permanent {
-						s.handler.OnError(lse)
+						s.reportError(lse)
+						life.failed(lse)
 						return
 					}
 
 					// Handle transient error
-					s.handler.OnError(err)
+					s.reportError(err)
+					s.metrics.retries.Add(1)
+					cursor.retried()
+
+					// Wait for the retry budget shared by all streams
+					if !s.retryBudget.wait(ctx) {
+						return
+					}
 
 					// Sleep with backoff before retrying
 					select {
@@ -482,15 +1469,131 @@ func (s *Streamer) startPodLogStreamer(ctx context.Context, pod *corev1.Pod) {
 	}
 }
 
-// processLogStream reads log lines from the stream and processes them
-func (s *Streamer) processLogStream(ctx context.Context, stream io.ReadCloser, podName, containerName, namespace string) error {
+// podSinkLabels copies the pod's labels named by the sink label keys, nil if it has none
+func (s *Streamer) podSinkLabels(pod *corev1.Pod) map[string]string {
+	var sinkLabels map[string]string
+	for _, key := range s.sinkLabelKeys {
+		if value, ok := pod.Labels[key]; ok {
+			if sinkLabels == nil {
+				sinkLabels = make(map[string]string, len(s.sinkLabelKeys))
+			}
+			sinkLabels[key] = value
+		}
+	}
+	return sinkLabels
+}
+
+// podLogOptions builds the log request options for a container of the pod
+func (s *Streamer) podLogOptions(pod *corev1.Pod, containerName string) *corev1.PodLogOptions {
+	opts := &corev1.PodLogOptions{
+		Container: containerName,
+		Follow:    true,
+		// Always request kubelet timestamps, the reconnect cursor, the time window, the
+		// maximum age and the checkpoints rely on them whatever the timestamp source
+		Timestamps: true,
+	}
+
+	// Set the since time if specified
+	since := s.filter.Since
+
+	// Skip the start of the pod's logs, unless the filter already starts later
+	if s.sinceAfterStart != nil && pod.Status.StartTime != nil {
+		afterStart := pod.Status.StartTime.Add(*s.sinceAfterStart)
+		if since == nil || afterStart.After(*since) {
+			since = &afterStart
+		}
+	}
+
+	// Don't request lines older than the maximum age
+	if s.maxLogAge > 0 {
+		oldest := time.Now().Add(-s.maxLogAge)
+		if since == nil || oldest.After(*since) {
+			since = &oldest
+		}
+	}
+
+	// Only follow lines logged after the streamer started
+	if s.followFromNow && (since == nil || s.followFrom.After(*since)) {
+		since = &s.followFrom
+	}
+
+	// Resume after the last line delivered by a previous run
+	if seen, ok := s.checkpoints.resumeFrom(pod.Namespace, pod.Name, containerName); ok {
+		if since == nil || seen.After(*since) {
+			since = &seen
+		}
+	}
+
+	if since != nil {
+		sinceTime := metav1.NewTime(*since)
+		opts.SinceTime = &sinceTime
+	}
+
+	// Read the log up to the end of the time window once instead of following it
+	if s.filter.Until != nil {
+		opts.Follow = false
+	}
+
+	// Start with only the most recent lines
+	if s.filter.TailLines != nil {
+		tailLines := *s.filter.TailLines
+		opts.TailLines = &tailLines
+	}
+
+	// The log of a previous instance can't be followed, it is read once
+	if s.filter.Previous {
+		opts.Previous = true
+		opts.Follow = false
+	}
+
+	// Read the log of a terminated container's instance once
+	s.applyContainerState(opts, pod)
+
+	// Apply the options of the container's pattern over the defaults
+	s.applyContainerLogOptions(opts, containerName)
+
+	return opts
+}
+
+// processLogStream reads log lines from the stream and processes them. Every line goes
+// through the same stages in this order, pinned by TestStreamer_PipelineOrder:
+//
+//  1. read: the line is split off the stream, its kubelet timestamp removed and a
+//     trailing carriage return trimmed
+//  2. resume: lines a reconnected stream sends again are skipped
+//  3. time window, max age and size: reading stops after the window, older lines and
+//     lines longer than the drop limit are dropped
+//  4. multiline: lines are merged into one message by the matcher, if any
+//  5. include and exclude: the message, merged and not yet formatted, must match the
+//     include regex and must not match the exclude regex
+//  6. numbering: the timestamp and the line number are set
+//  7. rate limit: lines beyond the pod's rate limit are dropped, see deliver
+//  8. classify and format: the stream classifier and the formatter see the message
+//     text as read, the line prefix is added to the message and the formatted text
+//  9. handler: the message is held while paused, queued by the backpressure strategy
+//     and handed to the handler
+//
+// Lines dropped at a stage never reach the later ones, so an excluded line doesn't count
+// towards the rate limit and isn't numbered.
+func (s *Streamer) processLogStream(ctx context.Context, stream io.ReadCloser, podName, containerName, namespace string, active *activePod, lines *lineCounter, cursor *streamCursor) error {
+	scanner := newScanner(stream, s.readBufferSize, s.maxLineBytes)
+	scanner.trimCR = s.trimCR
+	scanner.kubeletTimestamps = true
+	scanner.dropOversize = s.dropOversize
+
+	// Merge JSON spread over several lines for containers that turn out to log JSON
+	matcher := s.matcher
+	if matcher == nil && s.autoDetectJSON {
+		matcher = detectJSONMatcher(scanner)
+	}
+
 	// If we have a multiline matcher, use buffering logic
-	if s.matcher != nil {
-		return s.processMultilineLogStream(ctx, stream, podName, containerName, namespace)
+	if matcher != nil {
+		return s.processMultilineLogStream(ctx, scanner, matcher, podName, containerName, namespace, active, lines, cursor)
 	}
 
 	// Simple single-line processing
-	scanner := NewScanner(stream)
+	pacer := newBackfillPacer(s.backfillRate)
 	for scanner.Scan() {
 		// Check if we should stop
 		select {
@@ -502,36 +1605,68 @@ func (s *Streamer) processLogStream(ctx context.Context, stream io.ReadCloser, p
 			// Continue
 		}
 
+		// Skip the lines a reopened stream sends again
+		if !cursor.read(scanner.Timestamp()) {
+			continue
+		}
+
+		// Stop reading at the end of the time window
+		if s.afterWindow(scanner.Timestamp()) {
+			break
+		}
+
+		// Drop lines older than the maximum age
+		if s.tooOld(scanner.Timestamp()) {
+			continue
+		}
+
+		// Drop lines longer than the drop limit
+		if s.oversize(scanner) {
+			continue
+		}
+
+		// Slow down the initial backlog
+		if !pacer.wait(ctx) {
+			return nil
+		}
+
 		line := scanner.Text()
 
-		// Check include regex if specified
-		if s.filter.IncludeRegex != nil && !s.filter.IncludeRegex.MatchString(line) {
+		// Check include and exclude regex if specified
+		if !s.filter.MatchesLine(line) {
 			continue
 		}
 
 		// Create the log message
-		timestamp := time.Now() // Ideally we'd parse from the log line if possible
+		timestamp := s.messageTimestamp(line, scanner.Timestamp())
 		msg := LogMessage{
 			Namespace:     namespace,
 			PodName:       podName,
 			ContainerName: containerName,
 			Timestamp:     timestamp,
 			Message:       line,
-			Raw:           scanner.Bytes(),
+			Historical:    cursor.initial(s.initialBurst),
+			Truncated:     scanner.Truncated(),
+			Raw:           scanner.Raw(),
+			kubelet:       scanner.Timestamp(),
 		}
 
-		// Format the message
-		msg.Message = s.formatter.Format(msg)
+		lines.stamp(&msg)
+		active.label(&msg)
+		s.notifyFirstLog(cursor, namespace, podName, containerName)
+		s.deliver(msg)
+	}
 
-		// Send to handler
-		s.handler.OnLog(msg)
+	// A stream cut by Stop ends with a read error rather than EOF, which isn't a failure
+	if s.streamStopping(ctx) {
+		return nil
 	}
 
 	if err := scanner.Err(); err != nil {
 		// Check if this is a pod deletion error (normal termination)
 		if errors.IsPodDeletedError(err) {
 			// Pod deleted, remove from active tracking
-			s.active.Delete(podName)
+			s.untrackPod(namespace, podName)
 			// Just return nil for normal pod termination
 			return nil
 		}
@@ -547,12 +1682,16 @@ func (s *Streamer) processLogStream(ctx context.Context, stream io.ReadCloser, p
 }
 
 // processMultilineLogStream reads log lines from the stream and processes them with multiline support
-func (s *Streamer) processMultilineLogStream(ctx context.Context, stream io.ReadCloser, podName, containerName, namespace string) error {
-	scanner := NewScanner(stream)
-
+func (s *Streamer) processMultilineLogStream(ctx context.Context, scanner *scanner, matcher MultilineMatcher, podName, containerName, namespace string, active *activePod, lines *lineCounter, cursor *streamCursor) error {
 	var buffer []string
 	var rawBuffer [][]byte
+	// truncated is whether a line of the buffer was cut off at the maximum line length
+	var truncated bool
 	var lastLine string
+	// firstTimestamp and lastTimestamp are the kubelet timestamps of the buffer's first
+	// and last line
+	var firstTimestamp, lastTimestamp time.Time
+	pacer := newBackfillPacer(s.backfillRate)
 
 	flush := func() {
 		if len(buffer) == 0 {
@@ -565,16 +1704,17 @@ func (s *Streamer) processMultilineLogStream(ctx context.Context, stream io.Read
 			message += "\n" + buffer[i]
 		}
 
-		// Check include regex if specified
-		if s.filter.IncludeRegex != nil && !s.filter.IncludeRegex.MatchString(message) {
+		// Check include and exclude regex if specified
+		if !s.filter.MatchesLine(message) {
 			// Reset buffer
 			buffer = nil
 			rawBuffer = nil
+			truncated = false
 			return
 		}
 
 		// Create the log message
-		timestamp := time.Now() // Ideally we'd parse from the log line if possible
+		timestamp := s.messageTimestamp(buffer[0], firstTimestamp)
 
 		// Combine raw bytes
 		var rawBytes []byte
@@ -591,47 +1731,92 @@ func (s *Streamer) processMultilineLogStream(ctx context.Context, stream io.Read
 			ContainerName: containerName,
 			Timestamp:     timestamp,
 			Message:       message,
+			Historical:    cursor.initial(s.initialBurst),
+			Truncated:     truncated,
 			Raw:           rawBytes,
+			kubelet:       lastTimestamp,
 		}
 
-		// Format the message
-		msg.Message = s.formatter.Format(msg)
-
-		// Send to handler
-		s.handler.OnLog(msg)
+		lines.stamp(&msg)
+		active.label(&msg)
+		s.notifyFirstLog(cursor, namespace, podName, containerName)
+		s.deliver(msg)
 
 		// Reset buffer
 		buffer = nil
 		rawBuffer = nil
+		truncated = false
+		cursor.buffered(0)
+	}
+
+	// drain delivers the buffered message of a stream that is stopping, if still in time
+	drain := func() {
+		if s.drainable() {
+			flush()
+		}
 	}
 
 	for scanner.Scan() {
 		// Check if we should stop
 		select {
 		case <-ctx.Done():
+			drain()
 			return nil
 		case <-s.stopCh:
+			drain()
 			return nil
 		default:
 			// Continue
 		}
 
+		// Skip the lines a reopened stream sends again
+		if !cursor.read(scanner.Timestamp()) {
+			continue
+		}
+
+		// Stop reading at the end of the time window
+		if s.afterWindow(scanner.Timestamp()) {
+			break
+		}
+
+		// Drop lines older than the maximum age
+		if s.tooOld(scanner.Timestamp()) {
+			continue
+		}
+
+		// Drop lines longer than the drop limit
+		if s.oversize(scanner) {
+			continue
+		}
+
+		// Slow down the initial backlog
+		if !pacer.wait(ctx) {
+			drain()
+			return nil
+		}
+
 		line := scanner.Text()
 
 		// Handle first line
 		if len(buffer) == 0 {
 			buffer = append(buffer, line)
-			rawBuffer = append(rawBuffer, scanner.Bytes())
+			rawBuffer = append(rawBuffer, scanner.Raw())
+			truncated = truncated || scanner.Truncated()
 			lastLine = line
+			firstTimestamp = scanner.Timestamp()
+			lastTimestamp = scanner.Timestamp()
+			cursor.buffered(len(buffer))
 			continue
 		}
 
 		// Check if we should merge this line
-		if s.matcher.ShouldMerge(lastLine, line) {
+		if matcher.ShouldMerge(lastLine, line) {
 			// Add to buffer
 			buffer = append(buffer, line)
-			rawBuffer = append(rawBuffer, scanner.Bytes())
+			rawBuffer = append(rawBuffer, scanner.Raw())
+			truncated = truncated || scanner.Truncated()
 			lastLine = line
+			lastTimestamp = scanner.Timestamp()
 
 			// Check if we've exceeded max lines
 			if len(buffer) >= s.maxMultilines {
@@ -644,9 +1829,19 @@ func (s *Streamer) processMultilineLogStream(ctx context.Context, stream io.Read
 
 			// Start a new buffer
 			buffer = append(buffer, line)
-			rawBuffer = append(rawBuffer, scanner.Bytes())
+			rawBuffer = append(rawBuffer, scanner.Raw())
+			truncated = truncated || scanner.Truncated()
 			lastLine = line
+			firstTimestamp = scanner.Timestamp()
+			lastTimestamp = scanner.Timestamp()
 		}
+		cursor.buffered(len(buffer))
+	}
+
+	// A stream cut by Stop ends with a read error rather than EOF, it only drains in time
+	if s.streamStopping(ctx) {
+		drain()
+		return nil
 	}
 
 	// Flush any remaining buffer
@@ -656,7 +1851,7 @@ func (s *Streamer) processMultilineLogStream(ctx context.Context, stream io.Read
 		// Check if this is a pod deletion error (normal termination)
 		if errors.IsPodDeletedError(err) {
 			// Pod deleted, remove from active tracking
-			s.active.Delete(podName)
+			s.untrackPod(namespace, podName)
 			// Just return nil for normal pod termination
 			return nil
 		}
@@ -671,82 +1866,148 @@ func (s *Streamer) processMultilineLogStream(ctx context.Context, stream io.Read
 	return nil
 }
 
-// isPermError checks if an error should be considered permanent
-func isPermError(err error) bool {
-	// TODO: Implement better detection of permanent errors
-	return false
-}
+// deliver formats a log message and sends it to the handler
+func (s *Streamer) deliver(msg LogMessage) {
+	// Drop the excess lines of a pod logging faster than its limit
+	if !msg.IsEvent && !s.podRateLimit.allow(msg.Namespace, msg.PodName) {
+		s.metrics.linesThrottled.Add(1)
+		return
+	}
+
+	// Deliver one line of a pod at a time when its containers are merged
+	defer s.podSerializer.lock(msg.Namespace, msg.PodName)()
 
-// NewScanner creates a new scanner for reading log lines
-func NewScanner(r io.Reader) *scanner {
-	return &scanner{
-		reader: r,
-		buf:    make([]byte, 4096),
+	// Identify the streamer that produced the message
+	msg.StreamLabel = s.streamLabel
+
+	// Tell stdout from stderr before the message text is changed
+	if s.classifier != nil {
+		msg.Stream = s.classifier(msg.Message)
 	}
-}
 
-// scanner is a simple line scanner similar to bufio.Scanner but with more control
-type scanner struct {
-	reader io.Reader
-	buf    []byte
-	token  []byte
-	err    error
-}
+	// Pass raw bytes through untouched
+	if s.rawHandler != nil {
+		msg.Message = ""
+		if !s.pause.hold(msg) {
+			s.emit(msg)
+		}
+		return
+	}
 
-// Scan advances the scanner to the next token
-func (s *scanner) Scan() bool {
-	if s.err != nil {
-		return false
+	// Replace invalid UTF-8 before the formatter sees the message
+	if s.sanitizeUTF8 && !utf8.ValidString(msg.Message) {
+		msg.Message = strings.ToValidUTF8(msg.Message, string(utf8.RuneError))
 	}
 
-	var token []byte
-	for {
-		n, err := s.reader.Read(s.buf)
-		if n > 0 {
-			// Find newline
-			for i := 0; i < n; i++ {
-				if s.buf[i] == '\n' {
-					token = append(token, s.buf[:i]...)
-					s.token = token
-
-					// Handle remaining data
-					// TODO: Properly handle remaining data
-					return true
-				}
-			}
+	// Format the message
+	msg.Formatted = s.formatter.Format(msg)
 
-			// No newline found, append all and continue
-			token = append(token, s.buf[:n]...)
-		}
+	// Prepend the line prefix independently of the formatter, to the message as well as
+	// to the formatted text
+	if s.linePrefix != nil {
+		prefix := s.linePrefix.render(msg)
+		msg.Formatted = prefix + msg.Formatted
+		msg.Message = prefix + msg.Message
+	}
+	if s.replaceMessage {
+		msg.Message = msg.Formatted
+	}
 
-		if err != nil {
-			s.err = err
-			if err == io.EOF {
-				// Return last token if any
-				if len(token) > 0 {
-					s.token = token
-					return true
-				}
-			}
-			return false
-		}
+	// Hold the message back while paused
+	if s.pause.hold(msg) {
+		return
+	}
+
+	// Send to handler
+	s.emit(msg)
+}
+
+// emit sends a message towards the handler, through the backpressure queue if one is used
+func (s *Streamer) emit(msg LogMessage) {
+	if s.queue != nil {
+		s.queue.push(msg)
+		return
 	}
+	s.send(msg)
 }
 
-// Text returns the current token as a string
-func (s *scanner) Text() string {
-	return string(s.token)
+// send delivers a message to the raw handler if one is set, to the pod's batch if a batch
+// handler is set, otherwise to the log handler
+func (s *Streamer) send(msg LogMessage) {
+	switch {
+	case s.rawHandler != nil:
+		s.rawHandler.OnRawLog(msg.Raw, msg)
+	case s.batcher != nil:
+		// Counted as delivered once the batch is handed over
+		s.batcher.add(msg)
+		s.checkpoints.record(msg)
+		s.podStats.record(msg)
+		return
+	default:
+		s.handler.OnLog(msg)
+	}
+	s.metrics.linesDelivered.Add(1)
+	s.checkpoints.record(msg)
+	s.podStats.record(msg)
+}
+
+// endPodStream stops tracking a completed pod once the last of its container streams ended
+func (s *Streamer) endPodStream(active *activePod) {
+	if active.streams.Add(-1) == 0 && active.completed.Load() {
+		if s.active.CompareAndDelete(podKey(active.pod.Namespace, active.pod.Name), active) {
+			active.cancel()
+			s.forgetPod(active.pod.Namespace, active.pod.Name)
+		}
+	}
 }
 
-// Bytes returns the current token as bytes
-func (s *scanner) Bytes() []byte {
-	return s.token
+// label sets the node and the labels of the pod on a message of its streams, as seen when
+// streaming started. The label maps are shared by the pod's messages rather than copied per
+// line. A nil activePod leaves the message alone.
+func (a *activePod) label(msg *LogMessage) {
+	if a == nil {
+		return
+	}
+	msg.NodeName = a.pod.Spec.NodeName
+	msg.PodLabels = a.pod.Labels
+	msg.SinkLabels = a.sinkLabels
 }
 
-// Err returns the last error encountered
-func (s *scanner) Err() error {
-	if s.err == io.EOF {
+// untrackPod stops tracking the pod and closes its streams, returning the activePod removed
+func (s *Streamer) untrackPod(namespace, podName string) *activePod {
+	value, exists := s.active.LoadAndDelete(podKey(namespace, podName))
+	if !exists {
 		return nil
 	}
-	return s.err
+	active := value.(*activePod)
+	active.cancel()
+	return active
+}
+
+// podKey identifies a pod across namespaces, pods of the same name may be streamed from several
+func podKey(namespace, podName string) string {
+	return namespace + "/" + podName
+}
+
+// forgetPod drops the per-pod state of a pod that is no longer streamed
+func (s *Streamer) forgetPod(namespace, podName string) {
+	s.podRateLimit.forget(namespace, podName)
+	s.podStats.forget(namespace, podName)
+	s.checkpoints.forget(namespace, podName)
+}
+
+// isPermError checks if an error should be considered permanent
+func isPermError(err error) bool {
+	// TODO: Implement better detection of permanent errors
+	return false
+}
+
+// isPermWatchError checks if a pod watch failed for a reason retrying won't fix, like
+// missing permissions or a rejected label selector
+func isPermWatchError(err error) bool {
+	return apierrors.IsForbidden(err) ||
+		apierrors.IsUnauthorized(err) ||
+		apierrors.IsBadRequest(err) ||
+		apierrors.IsInvalid(err) ||
+		apierrors.IsMethodNotSupported(err)
 }
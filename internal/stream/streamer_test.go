@@ -0,0 +1,1705 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+	"unicode/utf8"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	"github.com/archsyscall/klogstream/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// recordingHandler records every message and error it receives
+type recordingHandler struct {
+	mu       sync.Mutex
+	messages []LogMessage
+	errors   []error
+	ended    bool
+}
+
+func (h *recordingHandler) OnLog(msg LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, msg)
+}
+
+func (h *recordingHandler) OnError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errors = append(h.errors, err)
+}
+
+func (h *recordingHandler) OnEnd() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ended = true
+}
+
+// lines returns the messages received so far
+func (h *recordingHandler) lines() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lines := make([]string, 0, len(h.messages))
+	for _, msg := range h.messages {
+		lines = append(lines, msg.Message)
+	}
+	return lines
+}
+
+// rawRecordingHandler records the raw bytes it receives
+type rawRecordingHandler struct {
+	recordingHandler
+	raw  [][]byte
+	meta []LogMessage
+}
+
+func (h *rawRecordingHandler) OnRawLog(raw []byte, meta LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.raw = append(h.raw, raw)
+	h.meta = append(h.meta, meta)
+}
+
+// prefixFormatter prepends a fixed string to every message
+type prefixFormatter struct {
+	prefix string
+}
+
+func (f *prefixFormatter) Format(msg LogMessage) string {
+	return f.prefix + msg.Message
+}
+
+// newTestPod creates a running pod with the given containers
+func newTestPod(namespace, name string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+	}
+	return pod
+}
+
+// newFakeProvider creates a client provider backed by a fake clientset holding the objects
+func newFakeProvider(objects ...runtime.Object) *kube.ClientProvider {
+	return kube.NewClientProviderWithOptions(kube.WithClientset(fake.NewSimpleClientset(objects...)))
+}
+
+// newTestStreamer creates a streamer backed by a fake clientset
+func newTestStreamer(t *testing.T, config *StreamerConfig) *Streamer {
+	t.Helper()
+
+	if config.KubeClientProvider == nil {
+		config.KubeClientProvider = newFakeProvider()
+	}
+	if config.Filter == nil {
+		config.Filter = &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+		}
+	}
+
+	s, err := NewStreamer(config)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	return s
+}
+
+func TestStreamer_PauseResume(t *testing.T) {
+	tests := []struct {
+		name        string
+		bufferSize  int
+		want        []string
+		wantDropped uint64
+	}{
+		{
+			name:        "drop while paused",
+			bufferSize:  0,
+			want:        []string{"before", "after"},
+			wantDropped: 2,
+		},
+		{
+			name:        "buffer while paused",
+			bufferSize:  10,
+			want:        []string{"before", "paused-1", "paused-2", "after"},
+			wantDropped: 0,
+		},
+		{
+			name:        "bounded buffer drops overflow",
+			bufferSize:  1,
+			want:        []string{"before", "paused-1", "after"},
+			wantDropped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				Handler:         handler,
+				PauseBufferSize: tt.bufferSize,
+			})
+
+			s.deliver(LogMessage{Message: "before"})
+
+			s.Pause()
+			if !s.Paused() {
+				t.Fatal("Paused() = false after Pause()")
+			}
+			s.deliver(LogMessage{Message: "paused-1"})
+			s.deliver(LogMessage{Message: "paused-2"})
+
+			if got := len(handler.lines()); got != 1 {
+				t.Fatalf("handler received %d messages while paused, want 1", got)
+			}
+
+			s.Resume()
+			s.deliver(LogMessage{Message: "after"})
+
+			got := handler.lines()
+			if len(got) != len(tt.want) {
+				t.Fatalf("handler received %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("message %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+			if got := s.Metrics().LinesPauseDropped; got != tt.wantDropped {
+				t.Errorf("LinesPauseDropped = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestStreamer_ResumeAfterStop(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, PauseBufferSize: 10})
+
+	s.Pause()
+	s.deliver(LogMessage{Message: "paused-1"})
+	s.deliver(LogMessage{Message: "paused-2"})
+	s.Stop()
+	s.Resume()
+
+	// The handler has ended, the buffered messages are dropped instead of delivered
+	if got := handler.lines(); len(got) != 0 {
+		t.Errorf("handler received %v after it ended", got)
+	}
+	if got := s.Metrics().LinesPauseDropped; got != 2 {
+		t.Errorf("LinesPauseDropped = %d, want 2", got)
+	}
+}
+
+func TestStreamer_SanitizeUTF8(t *testing.T) {
+	invalid := "ok \xff\xfe truncated \xe2\x82"
+
+	tests := []struct {
+		name     string
+		sanitize bool
+		want     string
+	}{
+		{
+			name:     "sanitized",
+			sanitize: true,
+			want:     "ok � truncated �",
+		},
+		{
+			name:     "passed through",
+			sanitize: false,
+			want:     invalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				Handler:      handler,
+				SanitizeUTF8: tt.sanitize,
+			})
+
+			s.deliver(LogMessage{Message: invalid, Raw: []byte(invalid)})
+
+			got := handler.lines()
+			if len(got) != 1 {
+				t.Fatalf("handler received %d messages, want 1", len(got))
+			}
+			if got[0] != tt.want {
+				t.Errorf("message = %q, want %q", got[0], tt.want)
+			}
+			if tt.sanitize && !utf8.ValidString(got[0]) {
+				t.Errorf("message %q is not valid UTF-8", got[0])
+			}
+			if string(handler.messages[0].Raw) != invalid {
+				t.Errorf("raw bytes were modified: %q", handler.messages[0].Raw)
+			}
+		})
+	}
+}
+
+func TestStreamer_ShouldStreamContainer(t *testing.T) {
+	pod := newTestPod("default", "web-1")
+	pod.Spec.Containers = []corev1.Container{
+		{Name: "app", Image: "registry.local/myapp:v2.3.1"},
+		{Name: "stable", Image: "registry.local/myapp:v2.3.0"},
+		{Name: "pinned", Image: "registry.local/myapp@sha256:abc"},
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", RestartCount: 7},
+		{Name: "stable", RestartCount: 1},
+		{Name: "pinned", Image: "registry.local/myapp:v2.3.1", ImageID: "registry.local/myapp@sha256:abc"},
+	}
+
+	tests := []struct {
+		name   string
+		filter *filter.LogFilter
+		want   map[string]bool
+	}{
+		{
+			name: "image regex matches spec image",
+			filter: &filter.LogFilter{
+				ImageRegex:     regexp.MustCompile(`myapp:v2\.3\.1$`),
+				ContainerState: filter.DefaultContainerState,
+			},
+			want: map[string]bool{"app": true, "stable": false, "pinned": true},
+		},
+		{
+			name: "min restart count",
+			filter: &filter.LogFilter{
+				MinRestartCount: 2,
+				ContainerState:  filter.DefaultContainerState,
+			},
+			want: map[string]bool{"app": true, "stable": false, "pinned": false},
+		},
+		{
+			name: "min restart count at threshold",
+			filter: &filter.LogFilter{
+				MinRestartCount: 1,
+				ContainerState:  filter.DefaultContainerState,
+			},
+			want: map[string]bool{"app": true, "stable": true, "pinned": false},
+		},
+		{
+			name: "no container filters",
+			filter: &filter.LogFilter{
+				ContainerState: filter.DefaultContainerState,
+			},
+			want: map[string]bool{"app": true, "stable": true, "pinned": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Streamer{filter: tt.filter}
+			for i := range pod.Spec.Containers {
+				container := &pod.Spec.Containers[i]
+				if got := s.shouldStreamContainer(pod, container); got != tt.want[container.Name] {
+					t.Errorf("shouldStreamContainer(%s) = %v, want %v", container.Name, got, tt.want[container.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamer_PodDeletionGrace(t *testing.T) {
+	grace := func(seconds int64) *int64 { return &seconds }
+
+	tests := []struct {
+		name     string
+		podGrace *int64
+		maxGrace time.Duration
+		want     time.Duration
+	}{
+		{
+			name:     "pod grace within cap",
+			podGrace: grace(30),
+			maxGrace: time.Minute,
+			want:     30 * time.Second,
+		},
+		{
+			name:     "pod grace capped",
+			podGrace: grace(30),
+			maxGrace: 10 * time.Second,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "default grace when unset",
+			podGrace: nil,
+			maxGrace: time.Minute,
+			want:     30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod("default", "web-1", "app")
+			pod.Spec.TerminationGracePeriodSeconds = tt.podGrace
+
+			s := &Streamer{deletionGrace: tt.maxGrace}
+			if got := s.podDeletionGrace(pod); got != tt.want {
+				t.Errorf("podDeletionGrace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamer_RelabeledPodStopsStreaming(t *testing.T) {
+	selector, err := labels.Parse("track=canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: &recordingHandler{},
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			LabelSelector:  selector,
+		},
+	})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.Labels = map[string]string{"track": "canary"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.active.Store(podKey(pod.Namespace, pod.Name), &activePod{pod: pod, cancel: cancel})
+
+	// A modification that keeps the pod matching leaves its streams alone
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: pod.DeepCopy()})
+	if ctx.Err() != nil {
+		t.Fatal("streams of a still matching pod were closed")
+	}
+
+	// The canary is promoted by relabeling, so it no longer matches the selector
+	promoted := pod.DeepCopy()
+	promoted.Labels["track"] = "stable"
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: promoted})
+
+	if ctx.Err() == nil {
+		t.Error("streams of a relabeled pod were not closed")
+	}
+	if _, exists := s.active.Load(podKey(pod.Namespace, pod.Name)); exists {
+		t.Error("relabeled pod is still tracked as active")
+	}
+}
+
+func TestStreamer_DeletedPodKeepsStreamingWithinGrace(t *testing.T) {
+	pod := newTestPod("default", "web-1", "app")
+	seconds := int64(30)
+	pod.Spec.TerminationGracePeriodSeconds = &seconds
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:                &recordingHandler{},
+		PodDeletionGracePeriod: 50 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.active.Store(podKey(pod.Namespace, pod.Name), &activePod{pod: pod, cancel: cancel})
+
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: pod})
+
+	if _, exists := s.active.Load(podKey(pod.Namespace, pod.Name)); exists {
+		t.Error("deleted pod is still tracked as active")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("pod streams were closed immediately on deletion")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("pod streams were not closed after the grace period")
+	}
+}
+
+func TestStreamer_RawPassthrough(t *testing.T) {
+	handler := &rawRecordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            handler,
+		RawHandler:         handler,
+		Formatter:          &prefixFormatter{prefix: "formatted: "},
+		LinePrefix:         "{{.PodName}} ",
+		TrimCarriageReturn: true,
+		SanitizeUTF8:       true,
+		StreamLabel:        "raw",
+	})
+
+	input := "plain\nlatin1 caf\xe9\r\n\x00\xff\xfe binary"
+	stream := io.NopCloser(iotest.OneByteReader(strings.NewReader(input)))
+	if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	want := []string{"plain", "latin1 caf\xe9\r", "\x00\xff\xfe binary"}
+	if len(handler.raw) != len(want) {
+		t.Fatalf("received %d raw lines, want %d", len(handler.raw), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(handler.raw[i], []byte(want[i])) {
+			t.Errorf("raw line %d = %q, want %q", i, handler.raw[i], want[i])
+		}
+	}
+	if got := handler.lines(); len(got) != 0 {
+		t.Errorf("OnLog received %v in raw passthrough mode", got)
+	}
+
+	meta := handler.meta[0]
+	if meta.PodName != "web-1" || meta.ContainerName != "app" || meta.Namespace != "default" || meta.StreamLabel != "raw" {
+		t.Errorf("metadata = %+v, want default/web-1/app labelled raw", meta)
+	}
+}
+
+func TestStreamer_RawPassthroughKeepsKubeletTimestamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher MultilineMatcher
+		want    []string
+	}{
+		{
+			name: "single lines",
+			want: []string{"2024-01-01T12:00:00.5Z hello", "2024-01-01T12:00:01Z  indented"},
+		},
+		{
+			name:    "multiline",
+			matcher: indentMatcher{},
+			want:    []string{"2024-01-01T12:00:00.5Z hello\n2024-01-01T12:00:01Z  indented"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &rawRecordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{Handler: handler, RawHandler: handler, Matcher: tt.matcher})
+
+			input := "2024-01-01T12:00:00.5Z hello\n2024-01-01T12:00:01Z  indented\n"
+			stream := io.NopCloser(strings.NewReader(input))
+			if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+
+			if len(handler.raw) != len(tt.want) {
+				t.Fatalf("received %d raw lines, want %d", len(handler.raw), len(tt.want))
+			}
+			for i := range tt.want {
+				if string(handler.raw[i]) != tt.want[i] {
+					t.Errorf("raw line %d = %q, want %q", i, handler.raw[i], tt.want[i])
+				}
+				if !bytes.HasPrefix(handler.raw[i], []byte("2024-01-01T12:00:0")) {
+					t.Errorf("raw line %d = %q, want the kubelet timestamp first", i, handler.raw[i])
+				}
+			}
+
+			// The timestamp is still parsed for the metadata
+			want := time.Date(2024, 1, 1, 12, 0, 0, 500000000, time.UTC)
+			if got := handler.meta[0].Timestamp; !got.Equal(want) {
+				t.Errorf("Timestamp = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestStreamer_LinePrefixOnMessage(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:    handler,
+		LinePrefix: "{{.Namespace}}/{{.PodName}}/{{.ContainerName}} | ",
+	})
+
+	stream := io.NopCloser(strings.NewReader("hello\n"))
+	if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	// Handlers reading Message see the prefix without a formatter
+	if got := handler.lines(); len(got) != 1 || got[0] != "default/web-1/app | hello" {
+		t.Errorf("received %q, want the prefixed line", got)
+	}
+}
+
+func TestStreamer_PreserveMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		replace     bool
+		wantMessage string
+	}{
+		{name: "message preserved by default", replace: false, wantMessage: "web-1 hello"},
+		{name: "message replaced", replace: true, wantMessage: "web-1 formatted: hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				Handler:        handler,
+				Formatter:      &prefixFormatter{prefix: "formatted: "},
+				LinePrefix:     "{{.PodName}} ",
+				ReplaceMessage: tt.replace,
+			})
+
+			stream := io.NopCloser(strings.NewReader("hello\n"))
+			if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+
+			if len(handler.messages) != 1 {
+				t.Fatalf("received %d messages, want 1", len(handler.messages))
+			}
+			msg := handler.messages[0]
+			if msg.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", msg.Message, tt.wantMessage)
+			}
+			if msg.Formatted != "web-1 formatted: hello" {
+				t.Errorf("Formatted = %q, want %q", msg.Formatted, "web-1 formatted: hello")
+			}
+
+			// Formatting again in the handler must not stack on the streamer's formatter,
+			// only the line prefix is kept
+			if !tt.replace {
+				if got := (&prefixFormatter{prefix: "handler: "}).Format(msg); got != "handler: web-1 hello" {
+					t.Errorf("handler-side Format() = %q, want %q", got, "handler: web-1 hello")
+				}
+			}
+		})
+	}
+}
+
+func TestStreamer_SinkLabels(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, SinkLabelKeys: []string{"app", "version", "team"}})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.Labels = map[string]string{"app": "checkout", "team": "payments", "pod-template-hash": "5d9f"}
+	active := &activePod{pod: pod, sinkLabels: s.podSinkLabels(pod)}
+
+	stream := io.NopCloser(strings.NewReader("hello\n"))
+	if err := s.processLogStream(context.Background(), stream, pod.Name, "app", pod.Namespace, active, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	if len(handler.messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(handler.messages))
+	}
+	want := map[string]string{"app": "checkout", "team": "payments"}
+	if got := handler.messages[0].SinkLabels; !reflect.DeepEqual(got, want) {
+		t.Errorf("SinkLabels = %v, want %v", got, want)
+	}
+}
+
+func TestStreamer_NodeName(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.Spec.NodeName = "worker-1"
+	active := &activePod{pod: pod}
+
+	stream := io.NopCloser(strings.NewReader("hello\n"))
+	if err := s.processLogStream(context.Background(), stream, pod.Name, "app", pod.Namespace, active, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	if len(handler.messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(handler.messages))
+	}
+	if got := handler.messages[0].NodeName; got != "worker-1" {
+		t.Errorf("NodeName = %q, want %q", got, "worker-1")
+	}
+}
+
+func TestStreamer_LabelsFromStreamedPod(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, SinkLabelKeys: []string{"app"}})
+
+	old := newTestPod("default", "web-1", "app")
+	old.Spec.NodeName = "worker-1"
+	old.Labels = map[string]string{"app": "checkout"}
+	oldActive := &activePod{pod: old, sinkLabels: s.podSinkLabels(old)}
+
+	// The pod was deleted and a new pod of the same name was scheduled elsewhere while the
+	// old pod's stream is still read within its grace period
+	replacement := newTestPod("default", "web-1", "app")
+	replacement.Spec.NodeName = "worker-2"
+	replacement.Labels = map[string]string{"app": "cart"}
+	s.active.Store(podKey(replacement.Namespace, replacement.Name), &activePod{pod: replacement, sinkLabels: s.podSinkLabels(replacement)})
+
+	stream := io.NopCloser(strings.NewReader("shutting down\n"))
+	if err := s.processLogStream(context.Background(), stream, old.Name, "app", old.Namespace, oldActive, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	if len(handler.messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(handler.messages))
+	}
+	msg := handler.messages[0]
+	if msg.NodeName != "worker-1" {
+		t.Errorf("NodeName = %q, want the old pod's worker-1", msg.NodeName)
+	}
+	if !reflect.DeepEqual(msg.PodLabels, old.Labels) {
+		t.Errorf("PodLabels = %v, want the old pod's %v", msg.PodLabels, old.Labels)
+	}
+	if want := map[string]string{"app": "checkout"}; !reflect.DeepEqual(msg.SinkLabels, want) {
+		t.Errorf("SinkLabels = %v, want %v", msg.SinkLabels, want)
+	}
+}
+
+func TestStreamer_PodLabels(t *testing.T) {
+	pod := newTestPod("default", "web-1", "app")
+	pod.Labels = map[string]string{"app": "checkout", "version": "v2"}
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            handler,
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(fake.NewSimpleClientset(pod))),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(handler.lines()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for log messages")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	for _, msg := range handler.messages {
+		if !reflect.DeepEqual(msg.PodLabels, pod.Labels) {
+			t.Fatalf("PodLabels = %v, want %v", msg.PodLabels, pod.Labels)
+		}
+	}
+
+	// The messages share a single map instead of a copy each
+	first, second := handler.messages[0].PodLabels, handler.messages[1].PodLabels
+	if reflect.ValueOf(first).UnsafePointer() != reflect.ValueOf(second).UnsafePointer() {
+		t.Error("the messages of a pod carry copies of its labels")
+	}
+}
+
+func TestStreamer_LineNumbering(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, LineNumbering: true})
+
+	app := newLineCounter(s.lineNumbering)
+	sidecar := newLineCounter(s.lineNumbering)
+	streams := []struct {
+		container string
+		lines     *lineCounter
+		input     string
+	}{
+		{container: "app", lines: app, input: "a1\na2\na3\n"},
+		{container: "sidecar", lines: sidecar, input: "s1\ns2\n"},
+		// A reconnect of the same container continues its numbering
+		{container: "app", lines: app, input: "a4\na5\n"},
+	}
+	for _, st := range streams {
+		stream := io.NopCloser(strings.NewReader(st.input))
+		if err := s.processLogStream(context.Background(), stream, "web-1", st.container, "default", nil, st.lines, nil); err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+	}
+
+	want := map[string][]int64{
+		"app":     {1, 2, 3, 4, 5},
+		"sidecar": {1, 2},
+	}
+	got := map[string][]int64{}
+	for _, msg := range handler.messages {
+		got[msg.ContainerName] = append(got[msg.ContainerName], msg.LineNumber)
+	}
+	for container, numbers := range want {
+		if fmt.Sprint(got[container]) != fmt.Sprint(numbers) {
+			t.Errorf("%s line numbers = %v, want %v", container, got[container], numbers)
+		}
+	}
+
+	// Without numbering the field is left unset
+	handler = &recordingHandler{}
+	s = newTestStreamer(t, &StreamerConfig{Handler: handler})
+	stream := io.NopCloser(strings.NewReader("line\n"))
+	if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, newLineCounter(s.lineNumbering), nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	if n := handler.messages[0].LineNumber; n != 0 {
+		t.Errorf("LineNumber = %d with numbering disabled, want 0", n)
+	}
+}
+
+func TestStreamer_StreamClassifier(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: handler,
+		StreamClassifier: func(line string) StreamKind {
+			switch {
+			case strings.HasPrefix(line, "E "):
+				return StreamStderr
+			case strings.HasPrefix(line, "O "):
+				return StreamStdout
+			}
+			return StreamUnknown
+		},
+	})
+
+	stream := io.NopCloser(strings.NewReader("O started\nE failed to connect\nuntagged\n"))
+	if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	want := []StreamKind{StreamStdout, StreamStderr, StreamUnknown}
+	if len(handler.messages) != len(want) {
+		t.Fatalf("received %d messages, want %d", len(handler.messages), len(want))
+	}
+	for i, kind := range want {
+		if got := handler.messages[i].Stream; got != kind {
+			t.Errorf("message %d stream = %v, want %v", i, got, kind)
+		}
+	}
+
+	// Without a classifier every message is unknown
+	handler = &recordingHandler{}
+	s = newTestStreamer(t, &StreamerConfig{Handler: handler})
+	stream = io.NopCloser(strings.NewReader("E failed\n"))
+	if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+	if got := handler.messages[0].Stream; got != StreamUnknown {
+		t.Errorf("stream = %v without a classifier, want %v", got, StreamUnknown)
+	}
+}
+
+func TestStreamer_ParallelNamespaceListing(t *testing.T) {
+	namespaces := []string{"ns-a", "ns-b", "ns-c", "ns-d"}
+
+	tests := []struct {
+		name       string
+		failing    map[string]bool
+		wantErr    bool
+		wantErrors int
+	}{
+		{
+			name: "all namespaces listed",
+		},
+		{
+			name:       "one failing namespace doesn't stop the others",
+			failing:    map[string]bool{"ns-b": true},
+			wantErrors: 1,
+		},
+		{
+			name:    "all namespaces failing",
+			failing: map[string]bool{"ns-a": true, "ns-b": true, "ns-c": true, "ns-d": true},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, ns := range namespaces {
+				// Pods without containers are tracked without opening log streams
+				objects = append(objects, newTestPod(ns, "pod-"+ns))
+			}
+			clientset := fake.NewSimpleClientset(objects...)
+
+			var mu sync.Mutex
+			listed := make(map[string]bool)
+			clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				ns := action.GetNamespace()
+				mu.Lock()
+				listed[ns] = true
+				mu.Unlock()
+				if tt.failing[ns] {
+					return true, nil, fmt.Errorf("list %s: forbidden", ns)
+				}
+				return false, nil, nil
+			})
+
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+				Filter: &filter.LogFilter{
+					Namespaces:     namespaces,
+					ContainerState: filter.DefaultContainerState,
+				},
+				Handler:                  handler,
+				NamespaceListConcurrency: 2,
+			})
+			defer s.Stop()
+
+			err := s.Start(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, ns := range namespaces {
+				if !listed[ns] {
+					t.Errorf("namespace %s was not listed", ns)
+				}
+				_, streaming := s.active.Load(podKey(ns, "pod-"+ns))
+				if want := !tt.failing[ns]; streaming != want {
+					t.Errorf("pod in %s streaming = %v, want %v", ns, streaming, want)
+				}
+			}
+
+			handler.mu.Lock()
+			gotErrors := len(handler.errors)
+			handler.mu.Unlock()
+			if gotErrors != tt.wantErrors {
+				t.Errorf("handler received %d errors, want %d", gotErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestStreamer_MaxDuration(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		KubeClientProvider: newFakeProvider(newTestPod("default", "web-1")),
+		Handler:            handler,
+		MaxDuration:        10 * time.Minute,
+	})
+	defer s.Stop()
+
+	// Drive the capture window with a fake clock
+	elapsed := make(chan time.Time, 1)
+	var waited time.Duration
+	s.after = func(d time.Duration) <-chan time.Time {
+		waited = d
+		return elapsed
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if waited != 10*time.Minute {
+		t.Fatalf("streamer waited for %v, want 10m", waited)
+	}
+
+	select {
+	case <-s.Done():
+		t.Fatal("streamer stopped before the maximum duration elapsed")
+	default:
+	}
+	if reason := s.EndReason(); reason != EndReasonNone {
+		t.Errorf("EndReason() = %v while streaming, want %v", reason, EndReasonNone)
+	}
+
+	elapsed <- time.Now()
+
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamer did not stop after the maximum duration")
+	}
+
+	// Stop waits for the self-stop to finish
+	s.Stop()
+	handler.mu.Lock()
+	ended := handler.ended
+	handler.mu.Unlock()
+	if !ended {
+		t.Error("OnEnd was not called after the maximum duration")
+	}
+	if reason := s.EndReason(); reason != EndReasonCompleted {
+		t.Errorf("EndReason() = %v, want %v", reason, EndReasonCompleted)
+	}
+}
+
+func TestStreamer_ListPageSize(t *testing.T) {
+	var pods []corev1.Pod
+	for i := 0; i < 7; i++ {
+		// Pods without containers are tracked without opening log streams
+		pods = append(pods, *newTestPod("default", fmt.Sprintf("pod-%d", i)))
+	}
+
+	// Serve the pods in pages, the fake clientset ignores Limit and Continue by itself
+	clientset := fake.NewSimpleClientset()
+	var limits []int64
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).ListOptions
+		limits = append(limits, opts.Limit)
+
+		start := 0
+		if opts.Continue != "" {
+			start, _ = strconv.Atoi(opts.Continue)
+		}
+		end := min(start+int(opts.Limit), len(pods))
+
+		list := &corev1.PodList{Items: pods[start:end]}
+		if end < len(pods) {
+			list.Continue = strconv.Itoa(end)
+		}
+		return true, list, nil
+	})
+
+	s := newTestStreamer(t, &StreamerConfig{
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Handler:            &recordingHandler{},
+		ListPageSize:       3,
+	})
+	defer s.Stop()
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if fmt.Sprint(limits) != "[3 3 3]" {
+		t.Errorf("list calls used limits %v, want three pages of 3", limits)
+	}
+	for _, pod := range pods {
+		if _, streaming := s.active.Load(podKey(pod.Namespace, pod.Name)); !streaming {
+			t.Errorf("pod %s from a later page is not streaming", pod.Name)
+		}
+	}
+}
+
+// fakeWatches serves pod watches from fake watchers the test controls, recording the
+// resource version each watch was started from
+type fakeWatches struct {
+	mu       sync.Mutex
+	versions []string
+	watchers chan *watch.FakeWatcher
+}
+
+// newFakeWatches installs a watch reactor on the clientset
+func newFakeWatches(clientset *fake.Clientset) *fakeWatches {
+	w := &fakeWatches{watchers: make(chan *watch.FakeWatcher, 10)}
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w.mu.Lock()
+		w.versions = append(w.versions, action.(k8stesting.WatchActionImpl).WatchRestrictions.ResourceVersion)
+		w.mu.Unlock()
+
+		watcher := watch.NewFake()
+		w.watchers <- watcher
+		return true, watcher, nil
+	})
+	return w
+}
+
+// next waits for the streamer to start the next watch
+func (w *fakeWatches) next(t *testing.T) *watch.FakeWatcher {
+	t.Helper()
+	select {
+	case watcher := <-w.watchers:
+		return watcher
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamer did not start a watch")
+		return nil
+	}
+}
+
+// startedFrom returns the resource versions the watches were started from
+func (w *fakeWatches) startedFrom() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.versions...)
+}
+
+func TestStreamer_WatchResumesFromResourceVersion(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: "10"}}, nil
+	})
+	watches := newFakeWatches(clientset)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Handler:            &recordingHandler{},
+	})
+	defer s.Stop()
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// The first watch continues from the list, and a closed watch from its last event
+	first := watches.next(t)
+	pod := newTestPod("default", "web-1")
+	pod.ResourceVersion = "11"
+	first.Add(pod)
+	first.Stop()
+
+	// A watch whose resource version expired is re-established from the current state
+	second := watches.next(t)
+	second.Error(&metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusGone,
+		Reason:  metav1.StatusReasonExpired,
+		Message: "too old resource version: 11 (20)",
+	})
+
+	third := watches.next(t)
+	later := newTestPod("default", "web-2")
+	later.ResourceVersion = "12"
+	third.Add(later)
+	third.Stop()
+	watches.next(t)
+
+	if got, want := fmt.Sprint(watches.startedFrom()), "[10 11 0 12]"; got != want {
+		t.Errorf("watches started from resource versions %s, want %s", got, want)
+	}
+	for _, name := range []string{"web-1", "web-2"} {
+		if _, streaming := s.active.Load(podKey("default", name)); !streaming {
+			t.Errorf("pod %s added through the watch is not streaming", name)
+		}
+	}
+}
+
+func TestStreamer_WatchErrorEvents(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        *metav1.Status
+		wantRewatch   bool
+		wantPermanent bool
+	}{
+		{
+			name: "transient error retries from the same version",
+			status: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusInternalServerError,
+				Reason:  metav1.StatusReasonInternalError,
+				Message: "etcd leader changed",
+			},
+			wantRewatch: true,
+		},
+		{
+			name: "forbidden stops watching",
+			status: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: "pods is forbidden",
+			},
+			wantPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &corev1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: "10"}}, nil
+			})
+			watches := newFakeWatches(clientset)
+
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+				Handler:            handler,
+				RetryPolicy: RetryPolicy{
+					MaxRetries:      3,
+					InitialInterval: time.Millisecond,
+					MaxInterval:     time.Millisecond,
+					Multiplier:      1,
+				},
+			})
+			defer s.Stop()
+
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			watches.next(t).Error(tt.status)
+
+			if tt.wantRewatch {
+				watches.next(t)
+				if got, want := fmt.Sprint(watches.startedFrom()), "[10 10]"; got != want {
+					t.Errorf("watches started from resource versions %s, want %s", got, want)
+				}
+			} else {
+				select {
+				case <-watches.watchers:
+					t.Fatal("watch was re-established after a permanent error")
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+
+			handler.mu.Lock()
+			defer handler.mu.Unlock()
+			if len(handler.errors) != 1 {
+				t.Fatalf("handler received errors %v, want the watch error", handler.errors)
+			}
+			var streamErr *LogStreamError
+			if !errors.As(handler.errors[0], &streamErr) || streamErr.Permanent != tt.wantPermanent {
+				t.Errorf("error = %v, want permanent %v", handler.errors[0], tt.wantPermanent)
+			}
+			if !strings.Contains(handler.errors[0].Error(), tt.status.Message) {
+				t.Errorf("error = %v, want the status message %q", handler.errors[0], tt.status.Message)
+			}
+		})
+	}
+}
+
+func TestStreamer_ContainerRestartEvents(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerRegex: regexp.MustCompile("^app$"),
+			ContainerState: filter.DefaultContainerState,
+		},
+		Handler:                handler,
+		ContainerRestartEvents: true,
+	})
+
+	pod := newTestPod("default", "web-1", "app", "sidecar")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", RestartCount: 2},
+		{Name: "sidecar", RestartCount: 0},
+	}
+
+	// Track the pod as if it was streaming, without opening log streams
+	s.active.Store(podKey(pod.Namespace, pod.Name), &activePod{pod: pod, cancel: func() {}, restarts: restartCounts(pod)})
+
+	// A status update without restarts is not annotated
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: pod.DeepCopy()})
+	if len(handler.messages) != 0 {
+		t.Fatalf("received %v without a restart", handler.lines())
+	}
+
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 3
+	restarted.Status.ContainerStatuses[0].LastTerminationState.Terminated = &corev1.ContainerStateTerminated{ExitCode: 137}
+	// The sidecar isn't streamed, so its restart is not annotated
+	restarted.Status.ContainerStatuses[1].RestartCount = 1
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: restarted})
+
+	if len(handler.messages) != 1 {
+		t.Fatalf("received %d messages, want 1 restart annotation", len(handler.messages))
+	}
+	msg := handler.messages[0]
+	if !msg.IsEvent || msg.ContainerName != "app" || msg.Message != "Restarted, previous exit code 137" {
+		t.Errorf("annotation = %+v, want an app event with the exit code", msg)
+	}
+
+	// The same restart count seen again is not annotated twice
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: restarted.DeepCopy()})
+	if len(handler.messages) != 1 {
+		t.Errorf("restart annotated %d times", len(handler.messages))
+	}
+}
+
+func TestStreamer_ContainerRestartEventsConcurrentWatches(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, ContainerRestartEvents: true})
+
+	pod := newTestPod("default", "web-1", "app")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", RestartCount: 0}}
+	s.active.Store(podKey(pod.Namespace, pod.Name), &activePod{pod: pod, cancel: func() {}, restarts: restartCounts(pod)})
+
+	// The watch of every selector reports the same restart at the same time
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 1
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: restarted.DeepCopy()})
+		}()
+	}
+	wg.Wait()
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.messages) != 1 {
+		t.Errorf("restart annotated %d times, want once", len(handler.messages))
+	}
+}
+
+func TestStreamer_OnListComplete(t *testing.T) {
+	// Pods without containers are tracked without opening log streams
+	clientset := fake.NewSimpleClientset(
+		newTestPod("ns-a", "web-1"),
+		newTestPod("ns-a", "web-2"),
+		newTestPod("ns-a", "worker-1"),
+		newTestPod("ns-b", "web-3"),
+		newTestPod("ns-c", "worker-2"),
+	)
+
+	var mu sync.Mutex
+	matched := make(map[string]int)
+	s := newTestStreamer(t, &StreamerConfig{
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"ns-a", "ns-b", "ns-c"},
+			PodNameRegex:   regexp.MustCompile("^web-"),
+			ContainerState: filter.DefaultContainerState,
+		},
+		Handler: &recordingHandler{},
+		OnListComplete: func(namespace string, n int) {
+			mu.Lock()
+			defer mu.Unlock()
+			matched[namespace] = n
+		},
+	})
+	defer s.Stop()
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	want := map[string]int{"ns-a": 2, "ns-b": 1, "ns-c": 0}
+	if fmt.Sprint(matched) != fmt.Sprint(want) {
+		t.Errorf("OnListComplete reported %v, want %v", matched, want)
+	}
+}
+
+func TestStreamer_AutoDetectJSON(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, AutoDetectJSON: true})
+
+	streams := map[string]string{
+		"api":    "{\n  \"level\": \"info\",\n  \"msg\": \"started\"\n}\n{\"level\": \"info\", \"msg\": \"ready\"}\n",
+		"worker": "Exception in thread main\n    at Worker.run(Worker.java:12)\n  {not json}\n",
+	}
+	for container, input := range streams {
+		stream := io.NopCloser(strings.NewReader(input))
+		if err := s.processLogStream(context.Background(), stream, "web-1", container, "default", nil, nil, nil); err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+	}
+
+	got := map[string][]string{}
+	for _, msg := range handler.messages {
+		got[msg.ContainerName] = append(got[msg.ContainerName], msg.Message)
+	}
+
+	wantAPI := []string{"{\n  \"level\": \"info\",\n  \"msg\": \"started\"\n}", "{\"level\": \"info\", \"msg\": \"ready\"}"}
+	if fmt.Sprintf("%q", got["api"]) != fmt.Sprintf("%q", wantAPI) {
+		t.Errorf("JSON stream delivered %q, want %q", got["api"], wantAPI)
+	}
+
+	wantWorker := []string{"Exception in thread main", "    at Worker.run(Worker.java:12)", "  {not json}"}
+	if fmt.Sprintf("%q", got["worker"]) != fmt.Sprintf("%q", wantWorker) {
+		t.Errorf("plain stream delivered %q, want %q", got["worker"], wantWorker)
+	}
+}
+
+func TestStreamer_PodLogOptionsSinceAfterStart(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	offset := 10 * time.Second
+	early := startTime.Add(-time.Hour)
+	late := startTime.Add(time.Hour)
+
+	tests := []struct {
+		name            string
+		since           *time.Time
+		sinceAfterStart *time.Duration
+		startTime       *time.Time
+		want            *time.Time
+	}{
+		{
+			name:            "start time plus offset",
+			sinceAfterStart: &offset,
+			startTime:       &startTime,
+			want:            ptrTo(startTime.Add(offset)),
+		},
+		{
+			name:            "later than filter since",
+			since:           &early,
+			sinceAfterStart: &offset,
+			startTime:       &startTime,
+			want:            ptrTo(startTime.Add(offset)),
+		},
+		{
+			name:            "filter since is later",
+			since:           &late,
+			sinceAfterStart: &offset,
+			startTime:       &startTime,
+			want:            &late,
+		},
+		{
+			name:            "pod not started yet",
+			sinceAfterStart: &offset,
+			want:            nil,
+		},
+		{
+			name:      "option not set",
+			startTime: &startTime,
+			want:      nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod("default", "job-1", "app")
+			if tt.startTime != nil {
+				start := metav1.NewTime(*tt.startTime)
+				pod.Status.StartTime = &start
+			}
+
+			s := &Streamer{
+				filter:          &filter.LogFilter{Since: tt.since},
+				sinceAfterStart: tt.sinceAfterStart,
+			}
+			opts := s.podLogOptions(pod, "app")
+
+			if opts.Container != "app" || !opts.Follow {
+				t.Errorf("options = %+v, want container app with follow", opts)
+			}
+			if tt.want == nil {
+				if opts.SinceTime != nil {
+					t.Errorf("SinceTime = %v, want unset", opts.SinceTime)
+				}
+				return
+			}
+			if opts.SinceTime == nil || !opts.SinceTime.Time.Equal(*tt.want) {
+				t.Errorf("SinceTime = %v, want %v", opts.SinceTime, *tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamer_FollowFromNow(t *testing.T) {
+	pod := newTestPod("default", "web-1", "nginx")
+
+	// By default the full history is requested
+	s := newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}})
+	if opts := s.podLogOptions(pod, "nginx"); opts.SinceTime != nil {
+		t.Errorf("SinceTime = %v without the option, want the full history", opts.SinceTime)
+	}
+
+	s = newTestStreamer(t, &StreamerConfig{Handler: &recordingHandler{}, FollowFromNow: true})
+	before := time.Now()
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+	after := time.Now()
+
+	// Lines logged before Start are skipped, also when the stream reconnects later
+	for i := 0; i < 2; i++ {
+		opts := s.podLogOptions(pod, "nginx")
+		if opts.SinceTime == nil || opts.SinceTime.Time.Before(before) || opts.SinceTime.Time.After(after) {
+			t.Errorf("SinceTime = %v, want the start time between %v and %v", opts.SinceTime, before, after)
+		}
+	}
+
+	// A later filter since still wins
+	late := time.Now().Add(time.Hour)
+	s.filter.Since = &late
+	if opts := s.podLogOptions(pod, "nginx"); opts.SinceTime == nil || !opts.SinceTime.Time.Equal(late) {
+		t.Errorf("SinceTime = %v, want the later filter since %v", opts.SinceTime, late)
+	}
+}
+
+func TestStreamer_ContainerLogOptions(t *testing.T) {
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestStreamer(t, &StreamerConfig{
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			Since:          &since,
+			ContainerState: filter.DefaultContainerState,
+		},
+		Handler: &recordingHandler{},
+		ContainerLogOptions: []ContainerLogOptions{
+			{
+				ContainerRegex: regexp.MustCompile("^envoy"),
+				Override:       PodLogOptionsOverride{TailLines: ptrTo[int64](10), SinceSeconds: ptrTo[int64](60)},
+			},
+			{
+				ContainerRegex: regexp.MustCompile("proxy|envoy"),
+				Override:       PodLogOptionsOverride{Previous: true},
+			},
+		},
+	})
+	pod := newTestPod("default", "web-1", "app", "envoy", "istio-proxy")
+
+	// Containers without a matching pattern use the defaults
+	app := s.podLogOptions(pod, "app")
+	if app.TailLines != nil || app.SinceSeconds != nil || app.Previous || app.SinceTime == nil || !app.SinceTime.Time.Equal(since) {
+		t.Errorf("app options = %+v, want the defaults", app)
+	}
+
+	// The first matching pattern is merged over the defaults
+	envoy := s.podLogOptions(pod, "envoy")
+	if envoy.TailLines == nil || *envoy.TailLines != 10 || envoy.SinceSeconds == nil || *envoy.SinceSeconds != 60 {
+		t.Errorf("envoy options = %+v, want tail 10 and since 60s", envoy)
+	}
+	if envoy.SinceTime != nil || envoy.Previous || !envoy.Follow {
+		t.Errorf("envoy options = %+v, want SinceSeconds to replace the since time", envoy)
+	}
+
+	proxy := s.podLogOptions(pod, "istio-proxy")
+	if !proxy.Previous || proxy.Follow || proxy.TailLines != nil || proxy.SinceTime == nil {
+		t.Errorf("istio-proxy options = %+v, want the previous log since the default time", proxy)
+	}
+}
+
+func TestStreamer_AllNamespaces(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		newTestPod("default", "web-1", "app"),
+		newTestPod("kube-system", "coredns-1", "coredns"),
+	)
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			ContainerState: filter.DefaultContainerState,
+			AllNamespaces:  true,
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	// The pods of both namespaces are streamed
+	waitForLogRequests(t, clientset, "app", "coredns")
+
+	// Pods are listed and watched once, across all namespaces
+	for _, action := range clientset.Actions() {
+		if action.GetResource().Resource == "pods" && action.GetSubresource() == "" && action.GetNamespace() != metav1.NamespaceAll {
+			t.Errorf("%s pods in namespace %q, want all namespaces", action.GetVerb(), action.GetNamespace())
+		}
+	}
+}
+
+func TestStreamer_ExcludeNamespace(t *testing.T) {
+	web := newTestPod("default", "web-1", "app")
+	web.Labels = map[string]string{"tier": "web"}
+	web.Spec.NodeName = "worker-1"
+	dns := newTestPod("kube-system", "coredns-1", "coredns")
+	dns.Labels = map[string]string{"tier": "web"}
+	dns.Spec.NodeName = "worker-1"
+	clientset := fake.NewSimpleClientset(web, dns)
+
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			ContainerState:    filter.DefaultContainerState,
+			AllNamespaces:     true,
+			ExcludeNamespaces: []string{"kube-system"},
+			LabelSelector:     labels.SelectorFromSet(labels.Set{"tier": "web"}),
+			FieldSelector:     fields.OneTermEqualSelector("spec.nodeName", "worker-1"),
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	waitForLogRequests(t, clientset, "app")
+
+	// The exclusion is sent to the server along with the field selector
+	for _, action := range clientset.Actions() {
+		if action.GetResource().Resource != "pods" || action.GetSubresource() != "" {
+			continue
+		}
+		labelSelector, fieldSelector := podSelectors(action)
+		if got, want := fieldSelector.String(), "metadata.namespace!=kube-system,spec.nodeName=worker-1"; got != want {
+			t.Errorf("%s pods with field selector %q, want %q", action.GetVerb(), got, want)
+		}
+		if got := labelSelector.String(); got != "tier=web" {
+			t.Errorf("%s pods with label selector %q, want tier=web", action.GetVerb(), got)
+		}
+	}
+
+	// The fake clientset doesn't select on fields, the excluded pod is skipped by the
+	// streamer itself
+	time.Sleep(50 * time.Millisecond)
+	if logRequests(clientset)["coredns"] != nil {
+		t.Error("logs of the kube-system pod were requested")
+	}
+}
+
+func TestStreamer_ExcludeNamespaceFromList(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		newTestPod("default", "web-1", "app"),
+		newTestPod("kube-system", "coredns-1", "coredns"),
+	)
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:        []string{"default", "kube-system"},
+			ContainerState:    filter.DefaultContainerState,
+			ExcludeNamespaces: []string{"kube-system"},
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	waitForLogRequests(t, clientset, "app")
+
+	// The excluded namespace isn't listed or watched at all
+	for _, action := range clientset.Actions() {
+		if action.GetNamespace() == "kube-system" {
+			t.Errorf("%s %s in the excluded namespace", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestStreamer_Previous(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestPod("default", "web-1", "app"))
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:            &recordingHandler{},
+		KubeClientProvider: kube.NewClientProviderWithOptions(kube.WithClientset(clientset)),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			Previous:       true,
+		},
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	requests := waitForLogRequests(t, clientset, "app")
+	if app := requests["app"]; !app.Previous || app.Follow {
+		t.Errorf("app log options = %+v, want the previous instance without following", app)
+	}
+
+	// The stream ends after the previous log instead of reconnecting
+	time.Sleep(100 * time.Millisecond)
+	reads := 0
+	for _, action := range clientset.Actions() {
+		if action.GetSubresource() == "log" {
+			reads++
+		}
+	}
+	if reads != 1 {
+		t.Errorf("the previous log was requested %d times, want once", reads)
+	}
+}
+
+// ptrTo returns a pointer to the value
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+func TestStreamer_BatchWorkloadMode(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{Handler: handler, BatchWorkloads: true})
+
+	pod := newTestPod("default", "job-1", "worker")
+	s.startPodLogStreamer(context.Background(), pod)
+
+	// The job finishes while its log is being read
+	done := pod.DeepCopy()
+	done.Status.Phase = corev1.PodSucceeded
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: done})
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream of a completed pod kept reconnecting after the end of its log")
+	}
+
+	if got := handler.lines(); len(got) == 0 || got[len(got)-1] != "fake logs" {
+		t.Errorf("received %q, want the pod's log", got)
+	}
+	if _, exists := s.active.Load(podKey(pod.Namespace, pod.Name)); exists {
+		t.Error("completed pod is still tracked after its logs were read")
+	}
+}
+
+func TestStreamer_SameNamedPodsInNamespaces(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: handler,
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default", "staging"},
+			ContainerState: filter.DefaultContainerState,
+			LabelSelector:  labels.SelectorFromSet(labels.Set{"app": "web"}),
+		},
+	})
+	defer s.Stop()
+
+	// Pods without containers are tracked without opening log streams
+	prod := newTestPod("default", "web-1")
+	prod.UID = types.UID("prod")
+	prod.Labels = map[string]string{"app": "web"}
+	prod.Spec.NodeName = "worker-1"
+	staging := newTestPod("staging", "web-1")
+	staging.UID = types.UID("staging")
+	staging.Labels = map[string]string{"app": "web"}
+	staging.Spec.NodeName = "worker-2"
+	s.startPodLogStreamer(context.Background(), prod)
+	s.startPodLogStreamer(context.Background(), staging)
+
+	// Each message is labeled from the pod of its own namespace
+	for _, namespace := range []string{"default", "staging"} {
+		value, ok := s.active.Load(podKey(namespace, "web-1"))
+		if !ok {
+			t.Fatalf("pod %s/web-1 is not tracked", namespace)
+		}
+		stream := io.NopCloser(strings.NewReader(namespace + "\n"))
+		if err := s.processLogStream(context.Background(), stream, "web-1", "app", namespace, value.(*activePod), nil, nil); err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+	}
+	handler.mu.Lock()
+	for _, msg := range handler.messages {
+		want := map[string]string{"default": "worker-1", "staging": "worker-2"}[msg.Namespace]
+		if msg.NodeName != want {
+			t.Errorf("message from %s has node %q, want %q", msg.Namespace, msg.NodeName, want)
+		}
+	}
+	handler.mu.Unlock()
+
+	// The staging pod stops matching, the pod of the same name in default keeps streaming
+	relabeled := staging.DeepCopy()
+	relabeled.Labels = map[string]string{"app": "web-v2"}
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: relabeled})
+	if _, ok := s.active.Load(podKey("staging", "web-1")); ok {
+		t.Error("pod no longer matching is still streamed")
+	}
+	if _, ok := s.active.Load(podKey("default", "web-1")); !ok {
+		t.Error("pod of the same name in another namespace stopped streaming")
+	}
+
+	// Deleting the pod in one namespace leaves the other one
+	s.startPodLogStreamer(context.Background(), staging)
+	s.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: staging})
+	if _, ok := s.active.Load(podKey("default", "web-1")); !ok {
+		t.Error("pod of the same name in another namespace stopped streaming after a delete")
+	}
+	if value, ok := s.active.Load(podKey("default", "web-1")); ok && value.(*activePod).pod.UID != prod.UID {
+		t.Error("pod of the same name in another namespace was replaced")
+	}
+}
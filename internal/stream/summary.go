@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// Summary totals what a streamer captured over its run
+type Summary struct {
+	// Lines is the number of log messages passed to the handler
+	Lines uint64
+	// Bytes is the number of bytes read from container log streams
+	Bytes uint64
+	// Pods is the number of distinct pods whose logs were streamed
+	Pods int
+	// Containers is the number of distinct containers whose logs were streamed
+	Containers int
+	// Errors is the number of errors reported to the handler
+	Errors uint64
+	// Duration is how long the streamer ran, from Start until it stopped or until now
+	// while it is still running
+	Duration time.Duration
+	// EndReason is why the streamer ended, EndReasonNone while it is running
+	EndReason EndReason
+}
+
+// runSummary records the parts of the Summary that the metrics don't count
+type runSummary struct {
+	mu         sync.Mutex
+	started    time.Time
+	ended      time.Time
+	pods       map[string]struct{}
+	containers map[string]struct{}
+}
+
+// start records when the streamer started
+func (r *runSummary) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = time.Now()
+}
+
+// end records when the streamer finished stopping
+func (r *runSummary) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = time.Now()
+}
+
+// streamed records that a container's logs are streamed
+func (r *runSummary) streamed(namespace, podName, containerName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pods == nil {
+		r.pods = make(map[string]struct{})
+		r.containers = make(map[string]struct{})
+	}
+	r.pods[namespace+"/"+podName] = struct{}{}
+	r.containers[namespace+"/"+podName+"/"+containerName] = struct{}{}
+}
+
+// Summary returns the totals of the streamer's run so far. Called after the streamer
+// stopped, it reports the final totals of the whole run, e.g. for a capture tool to print
+// "captured 42198 lines from 17 pods in 5m2s".
+func (s *Streamer) Summary() Summary {
+	s.summary.mu.Lock()
+	var duration time.Duration
+	switch {
+	case s.summary.started.IsZero():
+	case s.summary.ended.IsZero():
+		duration = time.Since(s.summary.started)
+	default:
+		duration = s.summary.ended.Sub(s.summary.started)
+	}
+	pods, containers := len(s.summary.pods), len(s.summary.containers)
+	s.summary.mu.Unlock()
+
+	return Summary{
+		Lines:      s.metrics.linesDelivered.Load(),
+		Bytes:      s.metrics.bytesRead.Load(),
+		Pods:       pods,
+		Containers: containers,
+		Errors:     s.metrics.errors.Load(),
+		Duration:   duration,
+		EndReason:  s.EndReason(),
+	}
+}
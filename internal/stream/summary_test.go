@@ -0,0 +1,66 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+)
+
+func TestStreamer_Summary(t *testing.T) {
+	// Read each log once instead of following it
+	until := time.Now().Add(time.Hour)
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler: handler,
+		KubeClientProvider: newFakeProvider(
+			newTestPod("default", "web-1", "app", "sidecar"),
+			newTestPod("default", "web-2", "app"),
+		),
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			Until:          &until,
+		},
+	})
+
+	if got := s.Summary(); got.Duration != 0 || got.Pods != 0 {
+		t.Errorf("Summary() before Start = %+v, want zero totals", got)
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Metrics().LinesDelivered < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %d lines, want one per container", s.Metrics().LinesDelivered)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+
+	got := s.Summary()
+	want := Summary{
+		Lines:      3,
+		Bytes:      3 * uint64(len("fake logs")),
+		Pods:       2,
+		Containers: 3,
+		EndReason:  EndReasonStopped,
+	}
+	duration := got.Duration
+	got.Duration = 0
+	if got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+	if duration <= 0 {
+		t.Errorf("Summary().Duration = %v, want the time between Start and Stop", duration)
+	}
+
+	// The totals are final once the streamer stopped
+	time.Sleep(10 * time.Millisecond)
+	if again := s.Summary(); again.Duration != duration {
+		t.Errorf("Summary().Duration changed after Stop from %v to %v", duration, again.Duration)
+	}
+}
@@ -0,0 +1,197 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeoutQueueSize is the number of messages queued for a handler that fell behind
+// after a timed out call, further messages are dropped until it catches up
+const DefaultTimeoutQueueSize = 16
+
+// DefaultTimeoutReportInterval is the minimum time between two errors reported for
+// timed out or dropped messages, the ones in between are summed up in the next report
+const DefaultTimeoutReportInterval = 10 * time.Second
+
+// timeoutHandler bounds how long a single OnLog call may block the stream.
+// The handler is called from a single worker goroutine, in the order of the messages.
+// A call that exceeds the timeout is abandoned: the worker keeps running it until the
+// handler returns, but the stream continues reading. Until the worker caught up, messages
+// are queued without waiting for them, and dropped once the queue is full, so a hung
+// handler holds neither the stream nor more than one goroutine.
+type timeoutHandler struct {
+	next    LogHandler
+	timeout time.Duration
+	calls   chan timeoutCall
+	// behind is set when a call timed out, and cleared by the worker once the queue is empty
+	behind atomic.Bool
+	// pending tracks the calls queued or running, OnEnd waits for them
+	pending  sync.WaitGroup
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// report passes the timeout errors on, through the streamer's error path so they
+	// are counted. Defaults to the wrapped handler's OnError.
+	report func(error)
+	// timedOut and dropped count the abandoned calls and the messages dropped
+	timedOut func()
+	dropped  func()
+
+	// reportEvery is the minimum time between two reports, suppressed counts the
+	// timeouts and drops since the last one
+	reportEvery time.Duration
+	reportMu    sync.Mutex
+	lastReport  time.Time
+	suppressed  int
+}
+
+// timeoutCall is a message for the worker, done is closed once the handler returned
+type timeoutCall struct {
+	msg  LogMessage
+	done chan struct{}
+}
+
+// newTimeoutHandler wraps a handler so that each OnLog call is bounded by the timeout,
+// queueing at most queueSize messages while the handler is behind, and starts its worker
+func newTimeoutHandler(next LogHandler, timeout time.Duration, queueSize int) *timeoutHandler {
+	h := &timeoutHandler{
+		next:        next,
+		timeout:     timeout,
+		calls:       make(chan timeoutCall, queueSize),
+		quit:        make(chan struct{}),
+		report:      next.OnError,
+		timedOut:    func() {},
+		dropped:     func() {},
+		reportEvery: DefaultTimeoutReportInterval,
+	}
+	go h.run()
+	return h
+}
+
+// run calls the handler with the queued messages until OnEnd, dropping the messages
+// still queued then
+func (h *timeoutHandler) run() {
+	for {
+		select {
+		case <-h.quit:
+			for {
+				select {
+				case <-h.calls:
+					h.dropped()
+					h.pending.Done()
+				default:
+					return
+				}
+			}
+		case call := <-h.calls:
+			select {
+			case <-h.quit:
+				h.dropped()
+			default:
+				h.next.OnLog(call.msg)
+			}
+			close(call.done)
+			if len(h.calls) == 0 {
+				h.behind.Store(false)
+			}
+			h.pending.Done()
+		}
+	}
+}
+
+// OnLog delivers the message, giving up if the handler does not return within the timeout
+func (h *timeoutHandler) OnLog(msg LogMessage) {
+	select {
+	case <-h.quit:
+		return
+	default:
+	}
+
+	call := timeoutCall{msg: msg, done: make(chan struct{})}
+	h.pending.Add(1)
+	select {
+	case h.calls <- call:
+	default:
+		// The queue is full behind a hung call
+		h.pending.Done()
+		h.dropped()
+		h.problem(msg, "dropped")
+		return
+	}
+
+	// The handler is still busy with an abandoned call, don't wait for it again
+	if h.behind.Load() {
+		return
+	}
+
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-call.done:
+	case <-timer.C:
+		h.behind.Store(true)
+		h.timedOut()
+		h.problem(msg, "abandoned")
+	}
+}
+
+// problem reports a timed out or dropped message, at most once per report interval.
+// The messages in between are counted in the next report.
+func (h *timeoutHandler) problem(msg LogMessage, what string) {
+	h.reportMu.Lock()
+	now := time.Now()
+	if !h.lastReport.IsZero() && now.Sub(h.lastReport) < h.reportEvery {
+		h.suppressed++
+		h.reportMu.Unlock()
+		return
+	}
+	suppressed := h.suppressed
+	h.suppressed = 0
+	h.lastReport = now
+	h.reportMu.Unlock()
+
+	context := fmt.Sprintf("%s log message for pod %s container %s", what, msg.PodName, msg.ContainerName)
+	if suppressed > 0 {
+		context += fmt.Sprintf(", %d more timed out or dropped since the last report", suppressed)
+	}
+	h.report(NewLogStreamError(fmt.Errorf("handler did not return within %v", h.timeout), false, context))
+}
+
+// OnError forwards the error to the wrapped handler
+func (h *timeoutHandler) OnError(err error) {
+	h.next.OnError(err)
+}
+
+// OnEnd reports the timeouts not reported yet and forwards the end signal to the wrapped
+// handler once the queued messages were delivered, waiting for them at most the timeout.
+// A call still hung after that may return after OnEnd, the messages queued behind it are
+// dropped.
+func (h *timeoutHandler) OnEnd() {
+	returned := make(chan struct{})
+	go func() {
+		h.pending.Wait()
+		close(returned)
+	}()
+
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+	select {
+	case <-returned:
+	case <-timer.C:
+	}
+	h.quitOnce.Do(func() { close(h.quit) })
+
+	h.reportMu.Lock()
+	suppressed := h.suppressed
+	h.suppressed = 0
+	h.reportMu.Unlock()
+	if suppressed > 0 {
+		h.report(NewLogStreamError(fmt.Errorf("handler did not return within %v", h.timeout), false,
+			fmt.Sprintf("%d log messages timed out or dropped since the last report", suppressed)))
+	}
+
+	h.next.OnEnd()
+}
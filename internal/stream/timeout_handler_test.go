@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingHandler blocks OnLog for messages listed in block until release is closed
+type blockingHandler struct {
+	recordingHandler
+	block   map[string]bool
+	release chan struct{}
+}
+
+func (h *blockingHandler) OnLog(msg LogMessage) {
+	if h.block[msg.Message] {
+		<-h.release
+	}
+	h.recordingHandler.OnLog(msg)
+}
+
+// waitLines waits for the handler to have received n messages
+func waitLines(t *testing.T, h *blockingHandler, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(h.lines()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return h.lines()
+}
+
+func TestTimeoutHandler_ContinuesAfterTimeout(t *testing.T) {
+	next := &blockingHandler{
+		block:   map[string]bool{"slow": true},
+		release: make(chan struct{}),
+	}
+
+	handler := newTimeoutHandler(next, 20*time.Millisecond, DefaultTimeoutQueueSize)
+
+	start := time.Now()
+	handler.OnLog(LogMessage{Message: "slow"})
+	handler.OnLog(LogMessage{Message: "fast"})
+	elapsed := time.Since(start)
+
+	if elapsed > time.Second {
+		t.Errorf("stream was blocked for %v by the slow handler", elapsed)
+	}
+
+	next.mu.Lock()
+	errCount := len(next.errors)
+	next.mu.Unlock()
+	if errCount != 1 {
+		t.Errorf("expected 1 timeout error, got %d", errCount)
+	}
+
+	// The queued message is delivered in order once the handler returns
+	close(next.release)
+	if got := waitLines(t, next, 2); strings.Join(got, ",") != "slow,fast" {
+		t.Errorf("handler received %v, want [slow fast]", got)
+	}
+	handler.OnEnd()
+}
+
+func TestTimeoutHandler_BoundedQueue(t *testing.T) {
+	next := &blockingHandler{
+		block:   map[string]bool{"slow": true},
+		release: make(chan struct{}),
+	}
+
+	before := runtime.NumGoroutine()
+	handler := newTimeoutHandler(next, 5*time.Millisecond, 2)
+	var timedOut, dropped atomic.Int32
+	handler.timedOut = func() { timedOut.Add(1) }
+	handler.dropped = func() { dropped.Add(1) }
+
+	// The first call hangs the worker, two messages fit the queue and the rest are dropped
+	handler.OnLog(LogMessage{Message: "slow"})
+	for i := 0; i < 100; i++ {
+		handler.OnLog(LogMessage{Message: "queued"})
+	}
+
+	if got := timedOut.Load(); got != 1 {
+		t.Errorf("timed out calls = %d, want 1", got)
+	}
+	if got := dropped.Load(); got != 98 {
+		t.Errorf("dropped messages = %d, want 98", got)
+	}
+	if got := runtime.NumGoroutine(); got > before+1 {
+		t.Errorf("%d goroutines running, want at most %d", got, before+1)
+	}
+
+	// Releasing the handler delivers the queued messages
+	close(next.release)
+	if got := waitLines(t, next, 3); len(got) != 3 {
+		t.Errorf("handler received %v, want the hung and the queued messages", got)
+	}
+	handler.OnEnd()
+}
+
+func TestTimeoutHandler_AggregatesReports(t *testing.T) {
+	next := &blockingHandler{
+		block:   map[string]bool{"slow": true},
+		release: make(chan struct{}),
+	}
+	defer close(next.release)
+
+	handler := newTimeoutHandler(next, 5*time.Millisecond, 2)
+	handler.reportEvery = time.Hour
+	var reports []string
+	handler.report = func(err error) { reports = append(reports, err.Error()) }
+
+	handler.OnLog(LogMessage{Message: "slow"})
+	for i := 0; i < 10; i++ {
+		handler.OnLog(LogMessage{Message: "queued"})
+	}
+	if len(reports) != 1 {
+		t.Fatalf("reported %d errors, want 1 until the interval passed: %v", len(reports), reports)
+	}
+
+	// The drops not reported yet are summed up at the end
+	handler.OnEnd()
+	if len(reports) != 2 || !strings.Contains(reports[1], "8 log messages timed out or dropped") {
+		t.Errorf("reports = %v, want a summary of the 8 dropped messages", reports)
+	}
+}
+
+func TestTimeoutHandler_ErrorsCounted(t *testing.T) {
+	next := &blockingHandler{
+		block:   map[string]bool{"slow": true},
+		release: make(chan struct{}),
+	}
+	defer close(next.release)
+
+	s := newTestStreamer(t, &StreamerConfig{Handler: next, HandlerTimeout: 5 * time.Millisecond})
+	s.deliver(LogMessage{Message: "slow"})
+
+	metrics := s.Metrics()
+	if metrics.Errors != 1 {
+		t.Errorf("Errors = %d, want the timeout counted", metrics.Errors)
+	}
+	if metrics.HandlerTimeouts != 1 {
+		t.Errorf("HandlerTimeouts = %d, want 1", metrics.HandlerTimeouts)
+	}
+	next.mu.Lock()
+	errCount := len(next.errors)
+	next.mu.Unlock()
+	if errCount != 1 {
+		t.Errorf("handler received %d errors, want 1", errCount)
+	}
+}
+
+func TestTimeoutHandler_EndWaitsForAbandonedCalls(t *testing.T) {
+	next := &blockingHandler{
+		block:   map[string]bool{"slow": true},
+		release: make(chan struct{}),
+	}
+	handler := newTimeoutHandler(next, 50*time.Millisecond, DefaultTimeoutQueueSize)
+	handler.OnLog(LogMessage{Message: "slow"})
+
+	// The abandoned call returns while OnEnd waits, and is delivered before the end
+	time.AfterFunc(10*time.Millisecond, func() { close(next.release) })
+	handler.OnEnd()
+
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	if len(next.messages) != 1 || !next.ended {
+		t.Errorf("handler received %d messages, ended %v, want the abandoned message before the end", len(next.messages), next.ended)
+	}
+
+	// A call that stays hung doesn't hold up OnEnd beyond the timeout
+	hung := &blockingHandler{
+		block:   map[string]bool{"slow": true},
+		release: make(chan struct{}),
+	}
+	defer close(hung.release)
+	handler = newTimeoutHandler(hung, 10*time.Millisecond, DefaultTimeoutQueueSize)
+	handler.OnLog(LogMessage{Message: "slow"})
+	start := time.Now()
+	handler.OnEnd()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("OnEnd waited %v for a hung call", elapsed)
+	}
+}
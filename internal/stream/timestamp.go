@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"bytes"
+	"time"
+)
+
+// TimestampSource decides which time populates LogMessage.Timestamp
+type TimestampSource int
+
+const (
+	// TimestampKubelet uses the time the kubelet recorded for each line, falling back to
+	// the ingestion time for lines without one
+	TimestampKubelet TimestampSource = iota
+	// TimestampIngestion uses the time each line was received. Logs are still requested
+	// with kubelet timestamps, which reconnected streams resume from.
+	TimestampIngestion
+	// TimestampAppParsed uses the time returned by the timestamp extractor for the line's
+	// text, falling back to the kubelet time and then the ingestion time
+	TimestampAppParsed
+)
+
+// TimestampExtractor reads a timestamp embedded in a log line by the application,
+// returning false if the line has none
+type TimestampExtractor func(line string) (time.Time, bool)
+
+// parseKubeletTimestamp splits the RFC3339 timestamp the kubelet prefixes each line with
+// from the line, returning false and the unchanged line if it has none
+func parseKubeletTimestamp(line []byte) (time.Time, []byte, bool) {
+	i := bytes.IndexByte(line, ' ')
+	if i <= 0 {
+		return time.Time{}, line, false
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, string(line[:i]))
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return timestamp, line[i+1:], true
+}
+
+// afterWindow reports whether a line's kubelet timestamp is past the end of the time
+// window. Lines without a timestamp are kept.
+func (s *Streamer) afterWindow(kubelet time.Time) bool {
+	return s.filter.Until != nil && kubelet.After(*s.filter.Until)
+}
+
+// tooOld reports whether a line's kubelet timestamp is older than the maximum age.
+// Lines without a timestamp are kept.
+func (s *Streamer) tooOld(kubelet time.Time) bool {
+	return s.maxLogAge > 0 && !kubelet.IsZero() && time.Since(kubelet) > s.maxLogAge
+}
+
+// messageTimestamp picks the timestamp of a message from its first line and the
+// kubelet timestamp of that line, which is zero if there is none
+func (s *Streamer) messageTimestamp(line string, kubelet time.Time) time.Time {
+	if s.timeSource == TimestampAppParsed && s.timeExtractor != nil {
+		if timestamp, ok := s.timeExtractor(line); ok {
+			return timestamp
+		}
+	}
+
+	if s.timeSource != TimestampIngestion && !kubelet.IsZero() {
+		return kubelet
+	}
+	return time.Now()
+}
@@ -0,0 +1,241 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+)
+
+func TestParseKubeletTimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		want     time.Time
+		wantLine string
+		wantOK   bool
+	}{
+		{
+			name:     "nanosecond timestamp",
+			line:     "2024-01-01T10:00:00.123456789Z hello world",
+			want:     time.Date(2024, 1, 1, 10, 0, 0, 123456789, time.UTC),
+			wantLine: "hello world",
+			wantOK:   true,
+		},
+		{
+			name:     "empty line",
+			line:     "2024-01-01T10:00:00Z ",
+			want:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			wantLine: "",
+			wantOK:   true,
+		},
+		{
+			name:     "no timestamp",
+			line:     "hello world",
+			wantLine: "hello world",
+		},
+		{
+			name:     "leading space",
+			line:     " 2024-01-01T10:00:00Z hello",
+			wantLine: " 2024-01-01T10:00:00Z hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, line, ok := parseKubeletTimestamp([]byte(tt.line))
+			if ok != tt.wantOK || !got.Equal(tt.want) || string(line) != tt.wantLine {
+				t.Errorf("parseKubeletTimestamp() = %v, %q, %v, want %v, %q, %v", got, line, ok, tt.want, tt.wantLine, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStreamer_TimestampSource(t *testing.T) {
+	kubelet := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	app := time.Date(2023, 5, 5, 0, 0, 0, 0, time.UTC)
+
+	// extractApp reads a ts=<RFC3339> field from the line
+	extractApp := func(line string) (time.Time, bool) {
+		_, value, ok := strings.Cut(line, "ts=")
+		if !ok {
+			return time.Time{}, false
+		}
+		timestamp, err := time.Parse(time.RFC3339, strings.Fields(value)[0])
+		return timestamp, err == nil
+	}
+
+	tests := []struct {
+		name      string
+		source    TimestampSource
+		extractor TimestampExtractor
+		input     string
+		// want are the expected timestamps, zero for the ingestion time
+		want        []time.Time
+		wantMessage []string
+	}{
+		{
+			name:        "kubelet",
+			source:      TimestampKubelet,
+			input:       "2024-01-01T10:00:00Z ts=2023-05-05T00:00:00Z started\nnot stamped\n",
+			want:        []time.Time{kubelet, {}},
+			wantMessage: []string{"ts=2023-05-05T00:00:00Z started", "not stamped"},
+		},
+		{
+			name:        "ingestion",
+			source:      TimestampIngestion,
+			input:       "ts=2023-05-05T00:00:00Z started\n",
+			want:        []time.Time{{}},
+			wantMessage: []string{"ts=2023-05-05T00:00:00Z started"},
+		},
+		{
+			name:        "app parsed",
+			source:      TimestampAppParsed,
+			extractor:   extractApp,
+			input:       "2024-01-01T10:00:00Z ts=2023-05-05T00:00:00Z started\n2024-01-01T10:00:00Z no app time\n",
+			want:        []time.Time{app, kubelet},
+			wantMessage: []string{"ts=2023-05-05T00:00:00Z started", "no app time"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			s := newTestStreamer(t, &StreamerConfig{
+				Handler:            handler,
+				Formatter:          &prefixFormatter{},
+				TimestampSource:    tt.source,
+				TimestampExtractor: tt.extractor,
+			})
+
+			// Kubelet timestamps are requested for reconnects whatever the source
+			if opts := s.podLogOptions(newTestPod("default", "web-1", "app"), "app"); !opts.Timestamps {
+				t.Errorf("Timestamps = false for source %d", tt.source)
+			}
+
+			before := time.Now()
+			stream := io.NopCloser(strings.NewReader(tt.input))
+			if err := s.processLogStream(context.Background(), stream, "web-1", "app", "default", nil, nil, nil); err != nil {
+				t.Fatalf("processLogStream() error = %v", err)
+			}
+			after := time.Now()
+
+			if len(handler.messages) != len(tt.want) {
+				t.Fatalf("handler received %d messages, want %d", len(handler.messages), len(tt.want))
+			}
+			for i, msg := range handler.messages {
+				if msg.Message != tt.wantMessage[i] {
+					t.Errorf("message %d = %q, want %q", i, msg.Message, tt.wantMessage[i])
+				}
+				if tt.want[i].IsZero() {
+					if msg.Timestamp.Before(before) || msg.Timestamp.After(after) {
+						t.Errorf("message %d timestamp = %v, want the ingestion time", i, msg.Timestamp)
+					}
+				} else if !msg.Timestamp.Equal(tt.want[i]) {
+					t.Errorf("message %d timestamp = %v, want %v", i, msg.Timestamp, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamer_TimestampMultiline(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:        handler,
+		Formatter:      &prefixFormatter{},
+		AutoDetectJSON: true,
+		MaxMultilines:  10,
+	})
+
+	input := "2024-01-01T10:00:00Z {\n2024-01-01T10:00:01Z   \"level\": \"info\"\n2024-01-01T10:00:02Z }\n"
+	if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader(input)), "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	if len(handler.messages) != 1 {
+		t.Fatalf("handler received %d messages, want one merged JSON object", len(handler.messages))
+	}
+	msg := handler.messages[0]
+	if want := "{\n  \"level\": \"info\"\n}"; msg.Message != want {
+		t.Errorf("message = %q, want %q without kubelet timestamps", msg.Message, want)
+	}
+	if want := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC); !msg.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want the first line's %v", msg.Timestamp, want)
+	}
+}
+
+func TestStreamer_TimeWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 1, 14, 10, 0, 0, time.UTC)
+
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:         handler,
+		TimestampSource: TimestampIngestion,
+		Filter: &filter.LogFilter{
+			Namespaces:     []string{"default"},
+			ContainerState: filter.DefaultContainerState,
+			Since:          &start,
+			Until:          &end,
+		},
+	})
+
+	// The log is read once from the start of the window, with timestamps to find its end
+	opts := s.podLogOptions(newTestPod("default", "web-1", "app"), "app")
+	if opts.Follow || !opts.Timestamps || opts.SinceTime == nil || !opts.SinceTime.Time.Equal(start) {
+		t.Errorf("log options = %+v, want timestamps since %v without follow", opts, start)
+	}
+
+	// The stream is left at the first line past the end, even if it doesn't end there
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go io.WriteString(writer, "2024-01-01T14:00:00Z first\n2024-01-01T14:10:00Z last\n"+
+		"2024-01-01T14:10:00.000000001Z after\n2024-01-01T14:20:00Z later\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.processLogStream(context.Background(), reader, "web-1", "app", "default", nil, nil, nil)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("processLogStream() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not left at the end of the time window")
+	}
+
+	if got := handler.lines(); strings.Join(got, ",") != "first,last" {
+		t.Errorf("received %q, want the lines within the window", got)
+	}
+}
+
+func TestStreamer_MaxLogAge(t *testing.T) {
+	handler := &recordingHandler{}
+	s := newTestStreamer(t, &StreamerConfig{
+		Handler:         handler,
+		TimestampSource: TimestampIngestion,
+		MaxLogAge:       time.Hour,
+	})
+
+	// Logs are requested with timestamps from no earlier than the maximum age
+	opts := s.podLogOptions(newTestPod("default", "web-1", "app"), "app")
+	if !opts.Timestamps || opts.SinceTime == nil || time.Since(opts.SinceTime.Time) > time.Hour+time.Second {
+		t.Errorf("log options = %+v, want timestamps since an hour ago", opts)
+	}
+
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+	recent := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)
+	input := old + " stale\n" + recent + " fresh\nno timestamp\n"
+	if err := s.processLogStream(context.Background(), io.NopCloser(strings.NewReader(input)), "web-1", "app", "default", nil, nil, nil); err != nil {
+		t.Fatalf("processLogStream() error = %v", err)
+	}
+
+	if got := handler.lines(); strings.Join(got, ",") != "fresh,no timestamp" {
+		t.Errorf("received %q, want the stale line dropped", got)
+	}
+}
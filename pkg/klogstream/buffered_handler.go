@@ -0,0 +1,148 @@
+package klogstream
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// DropPolicy decides what a BufferedHandler does with a message when its buffer is full
+type DropPolicy int
+
+const (
+	// DropPolicyDrop discards the message and passes it to the onDrop callback, so the
+	// streams are never held up by the handler
+	DropPolicyDrop DropPolicy = iota
+	// DropPolicyBlock waits for room in the buffer, holding up the stream that delivers the
+	// message like a slow handler would
+	DropPolicyBlock
+)
+
+// BufferedHandler decouples a slow handler from the streams: messages and errors are
+// queued on a buffered channel and passed to the wrapped handler by a dedicated
+// goroutine, one at a time and in the order they were queued, so the wrapped handler
+// doesn't need to be safe for concurrent use.
+//
+// When the buffer is full, messages are dropped and passed to the onDrop callback, or with
+// DropPolicyBlock the stream waits for room. Errors are never dropped. OnEnd stops taking
+// new messages, waits for the ones still buffered to reach the wrapped handler and then
+// calls its OnEnd.
+type BufferedHandler struct {
+	next   LogHandler
+	onDrop func(LogMessage)
+	policy DropPolicy
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan bufferedEntry
+	done   chan struct{}
+}
+
+// bufferedEntry is a message or an error queued for the wrapped handler
+type bufferedEntry struct {
+	msg LogMessage
+	err error
+}
+
+// NewBufferedHandler creates a BufferedHandler queuing up to bufferSize messages for next.
+// onDrop is called with every message dropped because the buffer was full and may be nil.
+// The goroutine delivering the messages runs until OnEnd.
+func NewBufferedHandler(next LogHandler, bufferSize int, onDrop func(LogMessage)) *BufferedHandler {
+	if bufferSize < 0 {
+		bufferSize = 0
+	}
+	h := &BufferedHandler{
+		next:   next,
+		onDrop: onDrop,
+		queue:  make(chan bufferedEntry, bufferSize),
+		done:   make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// WithDropPolicy sets what happens to messages when the buffer is full, DropPolicyDrop by
+// default. It must be set before the first message is delivered.
+func (h *BufferedHandler) WithDropPolicy(policy DropPolicy) *BufferedHandler {
+	h.policy = policy
+	return h
+}
+
+// run passes the queued entries to the wrapped handler until the queue is closed and drained
+func (h *BufferedHandler) run() {
+	defer close(h.done)
+	for entry := range h.queue {
+		if entry.err != nil {
+			h.next.OnError(entry.err)
+		} else {
+			h.next.OnLog(entry.msg)
+		}
+	}
+}
+
+// OnLog queues the message, dropping it or waiting for room if the buffer is full
+func (h *BufferedHandler) OnLog(msg LogMessage) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		h.drop(msg)
+		return
+	}
+	if h.policy == DropPolicyBlock {
+		h.queue <- bufferedEntry{msg: msg}
+		return
+	}
+	select {
+	case h.queue <- bufferedEntry{msg: msg}:
+	default:
+		h.drop(msg)
+	}
+}
+
+// drop passes a message that couldn't be queued to the onDrop callback
+func (h *BufferedHandler) drop(msg LogMessage) {
+	if h.onDrop != nil {
+		h.onDrop(msg)
+	}
+}
+
+// OnError queues the error, waiting for room if the buffer is full
+func (h *BufferedHandler) OnError(err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.closed {
+		h.queue <- bufferedEntry{err: err}
+	}
+}
+
+// OnEnd delivers the buffered messages and then signals the end of streaming to the
+// wrapped handler. Messages arriving afterwards are dropped.
+func (h *BufferedHandler) OnEnd() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	close(h.queue)
+	h.mu.Unlock()
+
+	<-h.done
+	h.next.OnEnd()
+}
+
+// Start starts the wrapped handler if it implements HandlerStarter
+func (h *BufferedHandler) Start(ctx context.Context) error {
+	if starter, ok := h.next.(HandlerStarter); ok {
+		return starter.Start(ctx)
+	}
+	return nil
+}
+
+// Close closes the wrapped handler if it implements io.Closer
+func (h *BufferedHandler) Close() error {
+	if closer, ok := h.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package klogstream
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedHandler records the messages it receives, holding up OnLog until release is closed
+type gatedHandler struct {
+	recorder shutdownRecorder
+	// received is signalled when OnLog is entered
+	received chan struct{}
+	release  chan struct{}
+}
+
+func newGatedHandler() *gatedHandler {
+	return &gatedHandler{received: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (h *gatedHandler) OnLog(msg LogMessage) {
+	h.received <- struct{}{}
+	<-h.release
+	h.recorder.record(msg.Message)
+}
+
+func (h *gatedHandler) OnError(err error) { h.recorder.record("error: " + err.Error()) }
+func (h *gatedHandler) OnEnd()            { h.recorder.record("end") }
+
+func (h *gatedHandler) events() string {
+	h.recorder.mu.Lock()
+	defer h.recorder.mu.Unlock()
+	return strings.Join(h.recorder.events, ", ")
+}
+
+func TestBufferedHandler_DropPolicy(t *testing.T) {
+	next := newGatedHandler()
+	var dropped []string
+	h := NewBufferedHandler(next, 1, func(msg LogMessage) { dropped = append(dropped, msg.Message) })
+
+	// The first message is held by the wrapped handler, the second fills the buffer
+	h.OnLog(LogMessage{Message: "first"})
+	<-next.received
+	h.OnLog(LogMessage{Message: "second"})
+	h.OnLog(LogMessage{Message: "third"})
+
+	if got := strings.Join(dropped, ", "); got != "third" {
+		t.Errorf("dropped = %q, want third", got)
+	}
+
+	close(next.release)
+	h.OnEnd()
+	if got, want := next.events(), "first, second, end"; got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestBufferedHandler_BlockPolicy(t *testing.T) {
+	next := newGatedHandler()
+	dropped := 0
+	h := NewBufferedHandler(next, 1, func(LogMessage) { dropped++ }).WithDropPolicy(DropPolicyBlock)
+
+	h.OnLog(LogMessage{Message: "first"})
+	<-next.received
+	h.OnLog(LogMessage{Message: "second"})
+
+	queued := make(chan struct{})
+	go func() {
+		h.OnLog(LogMessage{Message: "third"})
+		close(queued)
+	}()
+
+	select {
+	case <-queued:
+		t.Fatal("OnLog returned while the buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(next.release)
+	select {
+	case <-queued:
+	case <-time.After(time.Second):
+		t.Fatal("OnLog still blocked after the buffer drained")
+	}
+
+	h.OnEnd()
+	if dropped != 0 {
+		t.Errorf("dropped %d messages, want none", dropped)
+	}
+	if got, want := next.events(), "first, second, third, end"; got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestBufferedHandler_OnEndFlushes(t *testing.T) {
+	next := newGatedHandler()
+	close(next.release)
+	var dropped []string
+	h := NewBufferedHandler(next, 10, func(msg LogMessage) { dropped = append(dropped, msg.Message) })
+
+	h.OnLog(LogMessage{Message: "a"})
+	h.OnError(errors.New("boom"))
+	h.OnLog(LogMessage{Message: "b"})
+	h.OnEnd()
+
+	if got, want := next.events(), "a, error: boom, b, end"; got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+
+	// Messages after the end are dropped, and ending again is a no-op
+	h.OnLog(LogMessage{Message: "late"})
+	h.OnEnd()
+	if got := strings.Join(dropped, ", "); got != "late" {
+		t.Errorf("dropped = %q, want late", got)
+	}
+	if got, want := next.events(), "a, error: boom, b, end"; got != want {
+		t.Errorf("events after second OnEnd = %q, want %q", got, want)
+	}
+}
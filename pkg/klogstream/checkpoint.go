@@ -0,0 +1,27 @@
+package klogstream
+
+import (
+	"github.com/archsyscall/klogstream/internal/stream"
+)
+
+// DefaultCheckpointInterval is how often checkpoints are saved while streaming
+const DefaultCheckpointInterval = stream.DefaultCheckpointInterval
+
+// CheckpointKey identifies a single container log stream
+type CheckpointKey = stream.CheckpointKey
+
+// CheckpointStore persists the time each container stream was last seen, so a restarted
+// streamer can resume near where it left off. Implementations are called from a single
+// goroutine at a time per streamer.
+type CheckpointStore = stream.CheckpointStore
+
+// FileCheckpointStore is a CheckpointStore keeping the checkpoints in a JSON file.
+// The file is replaced atomically on every save, so a crash leaves either the
+// previous or the new checkpoints behind, never a partial file.
+type FileCheckpointStore = stream.FileCheckpointStore
+
+// NewFileCheckpointStore creates a store keeping the checkpoints in the file at path.
+// A missing file holds no checkpoints.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return stream.NewFileCheckpointStore(path)
+}
@@ -1,21 +1,140 @@
 package klogstream
 
-import "errors"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/archsyscall/klogstream/internal/filter"
+	"github.com/archsyscall/klogstream/internal/kube"
+	"github.com/archsyscall/klogstream/internal/stream"
+)
 
 // Error definitions
 var (
-	// ErrNoKubeConfig is returned when no kubernetes config is provided
-	ErrNoKubeConfig = errors.New("no kubernetes configuration provided")
+	// ErrNoKubeConfig is returned when no kubernetes config is provided, or by NewStreamer
+	// when no kubeconfig can be found
+	ErrNoKubeConfig = kube.ErrNoKubeConfig
 	// ErrNoFilter is returned when no log filter is provided
 	ErrNoFilter = errors.New("no log filter provided")
 	// ErrNoHandler is returned when no log handler is provided
 	ErrNoHandler = errors.New("no log handler provided")
-	// ErrNoKubeContext is returned when the specified kubernetes context is not found
-	ErrNoKubeContext = errors.New("kubernetes context not found")
+	// ErrNoKubeContext is returned by NewStreamer when the context set by WithKubeContext is
+	// not found in the kubeconfig
+	ErrNoKubeContext = kube.ErrNoKubeContext
 	// ErrStreamClosed is returned when attempting to use a closed stream
 	ErrStreamClosed = errors.New("log stream has been closed")
 	// ErrMultilineTimeout is returned when a multiline log times out
 	ErrMultilineTimeout = errors.New("timed out waiting for multiline log")
 	// ErrTooManyLines is returned when a multiline log exceeds the maximum lines
 	ErrTooManyLines = errors.New("multiline log exceeds maximum number of lines")
+	// ErrNoMatches is reported through OnError when a dry-run match finds no pods or containers
+	ErrNoMatches = stream.ErrNoMatches
+	// ErrReadDeadline is reported through OnError when a log stream read exceeds the read deadline
+	ErrReadDeadline = stream.ErrReadDeadline
+	// ErrMaxReconnects is reported through OnError when a container is abandoned after too
+	// many short-lived streams
+	ErrMaxReconnects = stream.ErrMaxReconnects
+	// ErrSinkUnhealthy is reported through OnError when the handler's health check kept
+	// failing and no WithOnSinkUnhealthy callback is set
+	ErrSinkUnhealthy = stream.ErrSinkUnhealthy
+	// ErrInvalidOutputFormat is returned by NewStreamer when WithOutputFormat names an unknown
+	// format, or the template format lacks a valid template
+	ErrInvalidOutputFormat = errors.New("invalid output format")
+	// ErrInvalidColorPalette is returned by NewStreamer when a WithColorPalette code isn't an
+	// ANSI color sequence
+	ErrInvalidColorPalette = errors.New("invalid color palette")
+)
+
+// Filter validation errors, returned wrapped by LogFilterBuilder.Build and NewStreamer
+var (
+	// ErrEmptyFilter is returned when no filter criteria are provided
+	ErrEmptyFilter = filter.ErrEmptyFilter
+	// ErrNoNamespaceSpecified is returned when the filter has no namespace
+	ErrNoNamespaceSpecified = filter.ErrNoNamespaceSpecified
+	// ErrInvalidContainerState is returned when the container state filter is not recognized
+	ErrInvalidContainerState = filter.ErrInvalidContainerState
+	// ErrInvalidSinceTime is returned when the since time is in the future
+	ErrInvalidSinceTime = filter.ErrInvalidSinceTime
+	// ErrInvalidTimeWindow is returned when a time window ends in the future or before it starts
+	ErrInvalidTimeWindow = filter.ErrInvalidTimeWindow
+	// ErrInvalidTailLines is returned when the number of tail lines is negative
+	ErrInvalidTailLines = filter.ErrInvalidTailLines
+	// ErrInvalidRegex is returned when a regular expression option can't be compiled
+	ErrInvalidRegex = filter.ErrInvalidRegex
+	// ErrInvalidLabelSelector is returned when a label selector string can't be parsed
+	ErrInvalidLabelSelector = filter.ErrInvalidLabelSelector
+	// ErrInvalidFieldSelector is returned when a field selector string can't be parsed
+	ErrInvalidFieldSelector = filter.ErrInvalidFieldSelector
 )
+
+// errorKinds names the errors reported with their own kind in structured error output,
+// checked in order
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{ErrNoMatches, "no_matches"},
+	{ErrStreamClosed, "stream_closed"},
+	{ErrMultilineTimeout, "multiline_timeout"},
+	{ErrTooManyLines, "too_many_lines"},
+	{ErrReadDeadline, "read_deadline"},
+	{ErrMaxReconnects, "max_reconnects"},
+	{ErrSinkUnhealthy, "sink_unhealthy"},
+	{context.DeadlineExceeded, "timeout"},
+	{context.Canceled, "canceled"},
+}
+
+// ErrorJSON is the structured form of an error written by EncodeErrorJSON
+type ErrorJSON struct {
+	// Level is always "error"
+	Level string `json:"level"`
+	// Message is the full error message
+	Message string `json:"message"`
+	// Reason is the reason of a LogStreamError, if any
+	Reason string `json:"reason,omitempty"`
+	// Permanent tells whether the streamer gave up on what failed
+	Permanent bool `json:"permanent"`
+	// Kind names the type of error: one of the known errors such as "no_matches",
+	// "log_stream" for other log stream errors, or "error"
+	Kind string `json:"kind"`
+}
+
+// EncodeErrorJSON renders an error as a single line JSON object, taking the reason and
+// permanence of a LogStreamError
+func EncodeErrorJSON(err error) string {
+	entry := ErrorJSON{
+		Level:   "error",
+		Message: err.Error(),
+		Kind:    "error",
+	}
+
+	// The streamer reports its internal error type, handlers may report the public one
+	var streamErr *stream.LogStreamError
+	var publicErr *LogStreamError
+	switch {
+	case errors.As(err, &streamErr):
+		entry.Reason = streamErr.Reason
+		entry.Permanent = streamErr.Permanent
+		entry.Kind = "log_stream"
+	case errors.As(err, &publicErr):
+		entry.Reason = publicErr.Reason
+		entry.Permanent = publicErr.Permanent
+		entry.Kind = "log_stream"
+	}
+
+	for _, known := range errorKinds {
+		if errors.Is(err, known.err) {
+			entry.Kind = known.kind
+			break
+		}
+	}
+
+	data, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		// Fallback in case of marshaling error
+		return fmt.Sprintf(`{"level":"error","message":%q,"kind":"error"}`, err.Error())
+	}
+	return string(data)
+}
@@ -0,0 +1,261 @@
+package klogstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/archsyscall/klogstream/internal/stream"
+	"k8s.io/client-go/rest"
+)
+
+func TestErrors_Is(t *testing.T) {
+	restConfig := &rest.Config{
+		Host: "https://test-server:8443",
+	}
+
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	kubeconfig := `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://test-server:8443
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    user: test-user
+  name: test-context
+current-context: test-context
+users:
+- name: test-user
+  user:
+    token: test-token
+`
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		build   func() error
+		wantErr error
+	}{
+		{
+			name: "streamer without filter",
+			build: func() error {
+				_, err := NewStreamer(WithRestConfig(restConfig), WithHandler(&MockHandler{}))
+				return err
+			},
+			wantErr: ErrNoFilter,
+		},
+		{
+			name: "streamer without handler",
+			build: func() error {
+				_, err := NewStreamer(WithRestConfig(restConfig), WithNamespace("default"))
+				return err
+			},
+			wantErr: ErrNoHandler,
+		},
+		{
+			name: "streamer without kubeconfig",
+			build: func() error {
+				_, err := NewStreamer(
+					WithKubeconfigPath(filepath.Join(t.TempDir(), "missing")),
+					WithNamespace("default"),
+					WithHandler(&MockHandler{}),
+				)
+				return err
+			},
+			wantErr: ErrNoKubeConfig,
+		},
+		{
+			name: "streamer with unknown kube context",
+			build: func() error {
+				_, err := NewStreamer(
+					WithKubeconfigPath(kubeconfigPath),
+					WithKubeContext("missing-context"),
+					WithNamespace("default"),
+					WithHandler(&MockHandler{}),
+				)
+				return err
+			},
+			wantErr: ErrNoKubeContext,
+		},
+		{
+			name: "streamer with empty filter",
+			build: func() error {
+				_, err := NewStreamer(WithRestConfig(restConfig), WithFilter(&LogFilter{}), WithHandler(&MockHandler{}))
+				return err
+			},
+			wantErr: ErrEmptyFilter,
+		},
+		{
+			name: "streamer without namespace",
+			build: func() error {
+				_, err := NewStreamer(WithRestConfig(restConfig), WithPodRegex("nginx"), WithHandler(&MockHandler{}))
+				return err
+			},
+			wantErr: ErrNoNamespaceSpecified,
+		},
+		{
+			name: "streamer with invalid container state",
+			build: func() error {
+				_, err := NewStreamer(
+					WithRestConfig(restConfig),
+					WithNamespace("default"),
+					WithContainerState("sleeping"),
+					WithHandler(&MockHandler{}),
+				)
+				return err
+			},
+			wantErr: ErrInvalidContainerState,
+		},
+		{
+			name: "filter builder without criteria",
+			build: func() error {
+				_, err := NewLogFilterBuilder().Build()
+				return err
+			},
+			wantErr: ErrEmptyFilter,
+		},
+		{
+			name: "filter builder without namespace",
+			build: func() error {
+				_, err := NewLogFilterBuilder().PodRegex("nginx").Build()
+				return err
+			},
+			wantErr: ErrNoNamespaceSpecified,
+		},
+		{
+			name: "config builder without kube config",
+			build: func() error {
+				_, err := NewConfigBuilder().Build()
+				return err
+			},
+			wantErr: ErrNoKubeConfig,
+		},
+		{
+			name: "config builder without filter",
+			build: func() error {
+				_, err := NewConfigBuilder().WithKubeConfig(restConfig).Build()
+				return err
+			},
+			wantErr: ErrNoFilter,
+		},
+		{
+			name: "config builder without handler",
+			build: func() error {
+				_, err := NewConfigBuilder().
+					WithKubeConfig(restConfig).
+					WithFilter(&LogFilter{PodNameRegex: regexp.MustCompile("nginx")}).
+					Build()
+				return err
+			},
+			wantErr: ErrNoHandler,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.build()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want errors.Is(err, %v)", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConsoleHandler_JSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorJSON
+	}{
+		{
+			name: "transient stream error",
+			err:  stream.NewLogStreamError(errors.New("connection reset"), false, "log stream read error"),
+			want: ErrorJSON{
+				Level:   "error",
+				Message: "log stream read error: connection reset",
+				Reason:  "log stream read error",
+				Kind:    "log_stream",
+			},
+		},
+		{
+			name: "permanent stream error",
+			err:  stream.NewLogStreamError(errors.New("forbidden"), true, "failed to watch pods"),
+			want: ErrorJSON{
+				Level:     "error",
+				Message:   "failed to watch pods: forbidden",
+				Reason:    "failed to watch pods",
+				Permanent: true,
+				Kind:      "log_stream",
+			},
+		},
+		{
+			name: "known error wrapped in a stream error",
+			err:  stream.NewLogStreamError(ErrNoMatches, false, "dry-run matched 0 pods and 0 containers"),
+			want: ErrorJSON{
+				Level:   "error",
+				Message: "dry-run matched 0 pods and 0 containers: " + ErrNoMatches.Error(),
+				Reason:  "dry-run matched 0 pods and 0 containers",
+				Kind:    "no_matches",
+			},
+		},
+		{
+			name: "public stream error",
+			err:  &LogStreamError{Err: errors.New("boom"), Permanent: true, Reason: "handler failed"},
+			want: ErrorJSON{
+				Level:     "error",
+				Message:   "handler failed: boom",
+				Reason:    "handler failed",
+				Permanent: true,
+				Kind:      "log_stream",
+			},
+		},
+		{
+			name: "plain error",
+			err:  errors.New("something else"),
+			want: ErrorJSON{Level: "error", Message: "something else", Kind: "error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			handler := NewConsoleHandlerWithWriters(&out, &errOut).WithJSONErrors(true)
+
+			handler.OnError(tt.err)
+
+			line := errOut.String()
+			if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+				t.Fatalf("error output %q is not a single line", line)
+			}
+			var got ErrorJSON
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatalf("error output %q is not JSON: %v", line, err)
+			}
+			if got != tt.want {
+				t.Errorf("error JSON = %+v, want %+v", got, tt.want)
+			}
+			if out.Len() != 0 {
+				t.Errorf("error written to the log output: %q", out.String())
+			}
+		})
+	}
+
+	// Plain text remains the default
+	var errOut bytes.Buffer
+	NewConsoleHandlerWithWriters(io.Discard, &errOut).OnError(errors.New("boom"))
+	if errOut.String() != "Error: boom\n" {
+		t.Errorf("plain error output = %q", errOut.String())
+	}
+}
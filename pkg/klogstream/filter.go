@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/archsyscall/klogstream/internal/filter"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
@@ -16,14 +17,34 @@ type LogFilter struct {
 	ContainerRegex *regexp.Regexp
 	// LabelSelector filters pods by their labels
 	LabelSelector labels.Selector
+	// AdditionalSelectors also match pods, see WithAdditionalSelector
+	AdditionalSelectors []labels.Selector
+	// FieldSelector filters pods by their fields, see WithFieldSelector
+	FieldSelector fields.Selector
 	// IncludeRegex only includes log lines matching this regex
 	IncludeRegex *regexp.Regexp
+	// ExcludeRegex drops log lines matching this regex, even if they match IncludeRegex
+	ExcludeRegex *regexp.Regexp
+	// ImageRegex filters containers by image name
+	ImageRegex *regexp.Regexp
+	// MinRestartCount only includes containers that restarted at least this many times
+	MinRestartCount int32
 	// Since only includes logs newer than this time
 	Since *time.Time
+	// Until only includes logs up to this time, see WithTimeWindow
+	Until *time.Time
+	// TailLines starts each container's log with its most recent lines, see WithTailLines
+	TailLines *int64
 	// ContainerState filters by container state ("all", "running", "terminated", ...)
 	ContainerState string
+	// Previous streams the logs of the previous container instances, see WithPrevious
+	Previous bool
 	// Namespaces is a list of namespaces to filter logs from
 	Namespaces []string
+	// AllNamespaces streams the pods of every namespace, see WithAllNamespaces
+	AllNamespaces bool
+	// ExcludeNamespaces lists namespaces whose pods are never streamed, see WithExcludeNamespace
+	ExcludeNamespaces []string
 }
 
 // NewLogFilterBuilder creates a new LogFilterBuilder
@@ -62,6 +83,24 @@ func (b *LogFilterBuilder) Include(pattern string) *LogFilterBuilder {
 	return b
 }
 
+// Exclude sets the regex for log lines to drop, even if they match the include regex
+func (b *LogFilterBuilder) Exclude(pattern string) *LogFilterBuilder {
+	b.builder.Exclude(pattern)
+	return b
+}
+
+// ImageRegex sets the container image regex pattern
+func (b *LogFilterBuilder) ImageRegex(pattern string) *LogFilterBuilder {
+	b.builder.ImageRegex(pattern)
+	return b
+}
+
+// MinRestartCount sets the minimum container restart count
+func (b *LogFilterBuilder) MinRestartCount(count int32) *LogFilterBuilder {
+	b.builder.MinRestartCount(count)
+	return b
+}
+
 // Since sets the time to stream logs from
 func (b *LogFilterBuilder) Since(duration time.Duration) *LogFilterBuilder {
 	b.builder.Since(duration)
@@ -88,12 +127,22 @@ func (b *LogFilterBuilder) Build() (*LogFilter, error) {
 	}
 
 	return &LogFilter{
-		PodNameRegex:   internalFilter.PodNameRegex,
-		ContainerRegex: internalFilter.ContainerRegex,
-		LabelSelector:  internalFilter.LabelSelector,
-		IncludeRegex:   internalFilter.IncludeRegex,
-		Since:          internalFilter.Since,
-		ContainerState: internalFilter.ContainerState,
-		Namespaces:     internalFilter.Namespaces,
+		PodNameRegex:        internalFilter.PodNameRegex,
+		ContainerRegex:      internalFilter.ContainerRegex,
+		LabelSelector:       internalFilter.LabelSelector,
+		AdditionalSelectors: internalFilter.AdditionalSelectors,
+		FieldSelector:       internalFilter.FieldSelector,
+		IncludeRegex:        internalFilter.IncludeRegex,
+		ExcludeRegex:        internalFilter.ExcludeRegex,
+		ImageRegex:          internalFilter.ImageRegex,
+		MinRestartCount:     internalFilter.MinRestartCount,
+		Since:               internalFilter.Since,
+		Until:               internalFilter.Until,
+		TailLines:           internalFilter.TailLines,
+		ContainerState:      internalFilter.ContainerState,
+		Previous:            internalFilter.Previous,
+		Namespaces:          internalFilter.Namespaces,
+		AllNamespaces:       internalFilter.AllNamespaces,
+		ExcludeNamespaces:   internalFilter.ExcludeNamespaces,
 	}, nil
 }
@@ -1,6 +1,10 @@
 package klogstream
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/archsyscall/klogstream/internal/formatter"
 )
 
@@ -14,10 +18,25 @@ type TextFormatter struct {
 	ShowPodName bool
 	// ShowContainerName controls whether to display the container name
 	ShowContainerName bool
+	// ShowNodeName controls whether to display the node name, after the container name.
+	// It is off by default.
+	ShowNodeName bool
+	// ShowStreamLabel controls whether to display the stream label when one is set
+	ShowStreamLabel bool
+	// ShowLineNumber controls whether to display the line number when line numbering is enabled
+	ShowLineNumber bool
 	// TimestampFormat defines the format for timestamps
 	TimestampFormat string
+	// TruncateTo truncates the timestamp to this precision (e.g. time.Second) before formatting.
+	// It controls precision independently of TimestampFormat. Zero keeps full precision.
+	TruncateTo time.Duration
 	// ColorOutput enables colorized output
 	ColorOutput bool
+	// ColorByPod colors the prefix of each pod with its own color from Palette, instead
+	// of cyan for all. Lines written to stderr stay red.
+	ColorByPod bool
+	// Palette holds the ANSI color codes pods are colored with, DefaultColorPalette if empty
+	Palette []string
 
 	internal *formatter.TextFormatter
 }
@@ -30,6 +49,8 @@ func NewTextFormatter() *TextFormatter {
 		ShowNamespace:     internal.ShowNamespace,
 		ShowPodName:       internal.ShowPodName,
 		ShowContainerName: internal.ShowContainerName,
+		ShowStreamLabel:   internal.ShowStreamLabel,
+		ShowLineNumber:    internal.ShowLineNumber,
 		TimestampFormat:   internal.TimestampFormat,
 		ColorOutput:       internal.ColorOutput,
 		internal:          internal,
@@ -43,22 +64,22 @@ func (f *TextFormatter) Format(msg LogMessage) string {
 	f.internal.ShowNamespace = f.ShowNamespace
 	f.internal.ShowPodName = f.ShowPodName
 	f.internal.ShowContainerName = f.ShowContainerName
+	f.internal.ShowNodeName = f.ShowNodeName
+	f.internal.ShowStreamLabel = f.ShowStreamLabel
+	f.internal.ShowLineNumber = f.ShowLineNumber
 	f.internal.TimestampFormat = f.TimestampFormat
+	f.internal.TruncateTo = f.TruncateTo
 	f.internal.ColorOutput = f.ColorOutput
+	f.internal.ColorByPod = f.ColorByPod
+	f.internal.Palette = f.Palette
 
-	// Convert our LogMessage to the internal type
-	internalMsg := formatter.LogMessage{
-		Namespace:     msg.Namespace,
-		PodName:       msg.PodName,
-		ContainerName: msg.ContainerName,
-		Timestamp:     msg.Timestamp,
-		Message:       msg.Message,
-		Raw:           msg.Raw,
-	}
-
-	return f.internal.Format(internalMsg)
+	return f.internal.Format(toFormatterMessage(msg))
 }
 
+// DefaultColorPalette holds the ANSI color codes pods are colored with by TextFormatter.ColorByPod
+// when no palette is set
+var DefaultColorPalette = slices.Clone(formatter.DefaultColorPalette)
+
 // JSONFormatter formats log messages as JSON
 type JSONFormatter struct {
 	// IncludeTimestamp controls whether to include the timestamp in the JSON
@@ -69,6 +90,18 @@ type JSONFormatter struct {
 	IncludePodName bool
 	// IncludeContainerName controls whether to include the container name in the JSON
 	IncludeContainerName bool
+	// IncludeNodeName controls whether to include the node name in the JSON, as node_name.
+	// It is off by default.
+	IncludeNodeName bool
+	// TruncateTo truncates the timestamp to this precision before formatting, zero keeps full precision
+	TruncateTo time.Duration
+	// Pretty indents the JSON over several lines for human reading. Compact single-line JSON,
+	// the default, is what line-oriented (NDJSON) consumers expect.
+	Pretty bool
+	// StaticFields are added to every JSON object, for deployment metadata such as
+	// "env" or "service". They follow the log fields sorted by key, and keys used by the
+	// log fields, such as "message" or "pod_name", are skipped.
+	StaticFields map[string]string
 
 	internal *formatter.JSONFormatter
 }
@@ -92,18 +125,12 @@ func (f *JSONFormatter) Format(msg LogMessage) string {
 	f.internal.IncludeNamespace = f.IncludeNamespace
 	f.internal.IncludePodName = f.IncludePodName
 	f.internal.IncludeContainerName = f.IncludeContainerName
+	f.internal.IncludeNodeName = f.IncludeNodeName
+	f.internal.TruncateTo = f.TruncateTo
+	f.internal.Pretty = f.Pretty
+	f.internal.StaticFields = f.StaticFields
 
-	// Convert our LogMessage to the internal type
-	internalMsg := formatter.LogMessage{
-		Namespace:     msg.Namespace,
-		PodName:       msg.PodName,
-		ContainerName: msg.ContainerName,
-		Timestamp:     msg.Timestamp,
-		Message:       msg.Message,
-		Raw:           msg.Raw,
-	}
-
-	return f.internal.Format(internalMsg)
+	return f.internal.Format(toFormatterMessage(msg))
 }
 
 // TemplateFormatter formats log messages using Go templates
@@ -142,15 +169,108 @@ func NewTemplateFormatterWithTemplate(templateStr string) (*TemplateFormatter, e
 
 // Format converts a LogMessage to a formatted string using the template
 func (f *TemplateFormatter) Format(msg LogMessage) string {
-	// Convert our LogMessage to the internal type
-	internalMsg := formatter.LogMessage{
+	return f.internal.Format(toFormatterMessage(msg))
+}
+
+// LogfmtFormatter formats log messages as logfmt key=value pairs, with the keys of the JSON format
+type LogfmtFormatter struct {
+	// IncludeTimestamp controls whether to include the timestamp
+	IncludeTimestamp bool
+	// IncludeNamespace controls whether to include the namespace
+	IncludeNamespace bool
+	// IncludePodName controls whether to include the pod name
+	IncludePodName bool
+	// IncludeContainerName controls whether to include the container name
+	IncludeContainerName bool
+	// TruncateTo truncates the timestamp to this precision before formatting, zero keeps full precision
+	TruncateTo time.Duration
+
+	internal *formatter.LogfmtFormatter
+}
+
+// NewLogfmtFormatter creates a new LogfmtFormatter with default settings
+func NewLogfmtFormatter() *LogfmtFormatter {
+	internal := formatter.NewLogfmtFormatter()
+	return &LogfmtFormatter{
+		IncludeTimestamp:     internal.IncludeTimestamp,
+		IncludeNamespace:     internal.IncludeNamespace,
+		IncludePodName:       internal.IncludePodName,
+		IncludeContainerName: internal.IncludeContainerName,
+		internal:             internal,
+	}
+}
+
+// Format converts a LogMessage to a logfmt line
+func (f *LogfmtFormatter) Format(msg LogMessage) string {
+	// Update internal formatter with current settings
+	f.internal.IncludeTimestamp = f.IncludeTimestamp
+	f.internal.IncludeNamespace = f.IncludeNamespace
+	f.internal.IncludePodName = f.IncludePodName
+	f.internal.IncludeContainerName = f.IncludeContainerName
+	f.internal.TruncateTo = f.TruncateTo
+
+	return f.internal.Format(toFormatterMessage(msg))
+}
+
+// RawFormatter formats log messages as the text read from the container, without metadata
+type RawFormatter struct{}
+
+// Format returns the message's text
+func (RawFormatter) Format(msg LogMessage) string {
+	return msg.Message
+}
+
+// Output formats accepted by WithOutputFormat
+const (
+	OutputFormatRaw      = "raw"
+	OutputFormatText     = "text"
+	OutputFormatJSON     = "json"
+	OutputFormatLogfmt   = "logfmt"
+	OutputFormatTemplate = "template"
+)
+
+// outputFormats lists the names accepted by WithOutputFormat
+var outputFormats = []string{OutputFormatRaw, OutputFormatText, OutputFormatJSON, OutputFormatLogfmt, OutputFormatTemplate}
+
+// newOutputFormatter creates the built-in formatter of a named output format with its
+// defaults. The template format requires a template.
+func newOutputFormatter(format, templateStr string) (LogFormatter, error) {
+	switch format {
+	case OutputFormatRaw:
+		return RawFormatter{}, nil
+	case OutputFormatText:
+		return NewTextFormatter(), nil
+	case OutputFormatJSON:
+		return NewJSONFormatter(), nil
+	case OutputFormatLogfmt:
+		return NewLogfmtFormatter(), nil
+	case OutputFormatTemplate:
+		if templateStr == "" {
+			return nil, fmt.Errorf("%w %q: requires WithTemplate", ErrInvalidOutputFormat, format)
+		}
+		formatter, err := NewTemplateFormatterWithTemplate(templateStr)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidOutputFormat, format, err)
+		}
+		return formatter, nil
+	default:
+		return nil, fmt.Errorf("%w %q: must be one of %v", ErrInvalidOutputFormat, format, outputFormats)
+	}
+}
+
+// toFormatterMessage converts a LogMessage to the internal formatter type
+func toFormatterMessage(msg LogMessage) formatter.LogMessage {
+	return formatter.LogMessage{
 		Namespace:     msg.Namespace,
 		PodName:       msg.PodName,
 		ContainerName: msg.ContainerName,
+		NodeName:      msg.NodeName,
+		StreamLabel:   msg.StreamLabel,
+		LineNumber:    msg.LineNumber,
+		Stream:        msg.Stream.Label(),
+		IsEvent:       msg.IsEvent,
 		Timestamp:     msg.Timestamp,
 		Message:       msg.Message,
 		Raw:           msg.Raw,
 	}
-
-	return f.internal.Format(internalMsg)
 }
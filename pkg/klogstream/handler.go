@@ -1,7 +1,11 @@
 package klogstream
 
 import (
+	"context"
 	"io"
+	"os"
+	"os/signal"
+	"sync"
 
 	"github.com/archsyscall/klogstream/internal/handler"
 )
@@ -25,27 +29,513 @@ func NewConsoleHandlerWithWriters(out, errOut io.Writer) *ConsoleHandler {
 	}
 }
 
+// NewJSONErrorConsoleHandler creates a ConsoleHandler writing logs to stdout and errors to
+// stderr as JSON objects, see WithJSONErrors
+func NewJSONErrorConsoleHandler() *ConsoleHandler {
+	return NewConsoleHandler().WithJSONErrors(true)
+}
+
+// WithJSONErrors makes OnError write each error as a single line JSON object that tools
+// reading the error output can parse, e.g.
+//
+//	{"level":"error","message":"...","reason":"log stream read error","permanent":false,"kind":"log_stream"}
+//
+// Reason and permanent are taken from a LogStreamError, kind names the type of error.
+func (h *ConsoleHandler) WithJSONErrors(enabled bool) *ConsoleHandler {
+	if enabled {
+		h.internal.WithErrorEncoder(EncodeErrorJSON)
+	} else {
+		h.internal.WithErrorEncoder(nil)
+	}
+	return h
+}
+
 // OnLog writes formatted log messages to the configured output writer
 func (h *ConsoleHandler) OnLog(msg LogMessage) {
-	// Convert our LogMessage to the internal type
-	internalMsg := handler.LogMessage{
+	h.internal.OnLog(toHandlerMessage(msg))
+}
+
+// OnError writes error messages to the error output writer
+func (h *ConsoleHandler) OnError(err error) {
+	h.internal.OnError(err)
+}
+
+// OnEnd is called when the stream ends
+func (h *ConsoleHandler) OnEnd() {
+	h.internal.OnEnd()
+}
+
+// ArchiveConfig configures where an ArchiveHandler writes archives and when it rotates them
+type ArchiveConfig = handler.ArchiveConfig
+
+// ArchiveHandler writes formatted log lines into gzip compressed archives for long term
+// storage. It rotates to a new timestamped .gz file once the current one reaches
+// ArchiveConfig.MaxBytes or ArchiveConfig.MaxAge, and lists every completed archive in a
+// manifest file. OnEnd finalizes the current archive so it is a valid gzip file.
+type ArchiveHandler struct {
+	internal *handler.ArchiveHandler
+}
+
+// NewArchiveHandler creates an ArchiveHandler writing to config.Dir, creating it if needed
+func NewArchiveHandler(config ArchiveConfig) (*ArchiveHandler, error) {
+	internal, err := handler.NewArchiveHandler(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveHandler{internal: internal}, nil
+}
+
+// OnLog compresses the formatted message into the current archive
+func (h *ArchiveHandler) OnLog(msg LogMessage) {
+	h.internal.OnLog(toHandlerMessage(msg))
+}
+
+// WriteLog compresses the formatted message into the current archive, returning write
+// and rotation failures instead of printing them
+func (h *ArchiveHandler) WriteLog(msg LogMessage) error {
+	return h.internal.WriteLog(toHandlerMessage(msg))
+}
+
+// toHandlerMessage converts a message for the internal handlers
+func toHandlerMessage(msg LogMessage) handler.LogMessage {
+	return handler.LogMessage{
 		Namespace:     msg.Namespace,
 		PodName:       msg.PodName,
 		ContainerName: msg.ContainerName,
+		NodeName:      msg.NodeName,
+		StreamLabel:   msg.StreamLabel,
+		LineNumber:    msg.LineNumber,
 		Timestamp:     msg.Timestamp,
 		Message:       msg.Message,
+		Formatted:     msg.Formatted,
 		Raw:           msg.Raw,
 	}
+}
 
-	h.internal.OnLog(internalMsg)
+// OnError writes error messages to the configured error output
+func (h *ArchiveHandler) OnError(err error) {
+	h.internal.OnError(err)
 }
 
-// OnError writes error messages to the error output writer
-func (h *ConsoleHandler) OnError(err error) {
+// OnEnd finalizes the current archive
+func (h *ArchiveHandler) OnEnd() {
+	h.internal.OnEnd()
+}
+
+// Close finalizes the current archive and records it in the manifest, later messages
+// start a new archive
+func (h *ArchiveHandler) Close() error {
+	return h.internal.Close()
+}
+
+// Archives returns the paths of the completed archives, oldest first
+func (h *ArchiveHandler) Archives() []string {
+	return h.internal.Archives()
+}
+
+// ManifestPath returns the path of the manifest listing the completed archives
+func (h *ArchiveHandler) ManifestPath() string {
+	return h.internal.ManifestPath()
+}
+
+// FileHandlerOptions configures when a FileHandler rotates its file and how many old
+// files it keeps
+type FileHandlerOptions = handler.FileHandlerOptions
+
+// FileHandler appends formatted log lines to a file. Once the file reaches
+// FileHandlerOptions.MaxBytes it is rotated by renaming, app.log becoming app.log.1 and
+// the older backups moving up to FileHandlerOptions.MaxBackups. It is safe for concurrent
+// use, buffers its writes and flushes them on OnEnd. When an external tool rotates the
+// file, call Reopen or use ReopenOnSignal:
+//
+//	handler, err := klogstream.NewFileHandler("/var/log/app.log", klogstream.FileHandlerOptions{})
+//	...
+//	stop := handler.ReopenOnSignal(syscall.SIGHUP)
+//	defer stop()
+type FileHandler struct {
+	internal *handler.FileHandler
+}
+
+// NewFileHandler creates a FileHandler appending to the file at path, creating the file
+// and its directory if needed
+func NewFileHandler(path string, opts FileHandlerOptions) (*FileHandler, error) {
+	internal, err := handler.NewFileHandler(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &FileHandler{internal: internal}, nil
+}
+
+// OnLog appends the formatted message to the file
+func (h *FileHandler) OnLog(msg LogMessage) {
+	h.internal.OnLog(toHandlerMessage(msg))
+}
+
+// WriteLog appends the formatted message to the file, returning write and rotation
+// failures instead of printing them
+func (h *FileHandler) WriteLog(msg LogMessage) error {
+	return h.internal.WriteLog(toHandlerMessage(msg))
+}
+
+// OnError writes error messages to the configured error output
+func (h *FileHandler) OnError(err error) {
+	h.internal.OnError(err)
+}
+
+// OnEnd flushes the buffered lines to the file
+func (h *FileHandler) OnEnd() {
+	h.internal.OnEnd()
+}
+
+// Close flushes and closes the file, later messages open it again
+func (h *FileHandler) Close() error {
+	return h.internal.Close()
+}
+
+// Reopen closes the file and opens the path again, so lines go to a new file after an
+// external tool moved the old one away
+func (h *FileHandler) Reopen() error {
+	return h.internal.Reopen()
+}
+
+// ReopenOnSignal reopens the file whenever the process receives one of the signals,
+// typically SIGHUP sent by logrotate. Failures go to the error output. The returned
+// function stops listening.
+func (h *FileHandler) ReopenOnSignal(signals ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := h.internal.Reopen(); err != nil {
+					h.internal.OnError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Path returns the path of the file being written
+func (h *FileHandler) Path() string {
+	return h.internal.Path()
+}
+
+// WebhookConfig configures where a WebhookHandler posts log lines
+type WebhookConfig = handler.WebhookConfig
+
+// WebhookHandler posts formatted log lines to an HTTP endpoint, newline separated, one
+// request per message or per batch with WithBatchByPod. Set WebhookConfig.Compress to gzip
+// the bodies for endpoints that accept Content-Encoding: gzip.
+type WebhookHandler struct {
+	internal *handler.WebhookHandler
+}
+
+// NewWebhookHandler creates a WebhookHandler posting to config.URL
+func NewWebhookHandler(config WebhookConfig) (*WebhookHandler, error) {
+	internal, err := handler.NewWebhookHandler(config)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookHandler{internal: internal}, nil
+}
+
+// OnLog posts the formatted message, writing failures to the configured error output
+func (h *WebhookHandler) OnLog(msg LogMessage) {
+	h.internal.OnLog(toHandlerMessage(msg))
+}
+
+// WriteLog posts the formatted message, returning failures instead of printing them
+func (h *WebhookHandler) WriteLog(msg LogMessage) error {
+	return h.internal.WriteLog(toHandlerMessage(msg))
+}
+
+// OnBatch posts the formatted messages in a single request
+func (h *WebhookHandler) OnBatch(batch []LogMessage) {
+	messages := make([]handler.LogMessage, len(batch))
+	for i, msg := range batch {
+		messages[i] = toHandlerMessage(msg)
+	}
+	h.internal.OnBatch(messages)
+}
+
+// OnError writes error messages to the configured error output
+func (h *WebhookHandler) OnError(err error) {
 	h.internal.OnError(err)
 }
 
 // OnEnd is called when the stream ends
-func (h *ConsoleHandler) OnEnd() {
+func (h *WebhookHandler) OnEnd() {
 	h.internal.OnEnd()
 }
+
+// callbackHandler passes each log message to a function and discards errors
+type callbackHandler struct {
+	onLog func(LogMessage)
+}
+
+// OnLog calls the function with the message
+func (h *callbackHandler) OnLog(msg LogMessage) {
+	h.onLog(msg)
+}
+
+// OnError discards the error
+func (h *callbackHandler) OnError(err error) {}
+
+// OnEnd does nothing
+func (h *callbackHandler) OnEnd() {}
+
+// SynchronizedHandler serializes the calls to a handler that isn't safe for concurrent
+// use. The streamer calls OnLog from a goroutine per container stream, so handlers must
+// otherwise synchronize themselves. Every call holds a single mutex, so a slow OnLog
+// holds up the delivery of all streams; handlers that lock only around shared state
+// have more throughput.
+//
+// The optional interfaces of the wrapped handler, such as FallibleHandler, BatchHandler,
+// RawHandler and HealthCheckable, are forwarded under the same mutex. The streamer uses
+// an interface only if the wrapped handler implements it.
+type SynchronizedHandler struct {
+	mu      sync.Mutex
+	handler LogHandler
+}
+
+// NewSynchronizedHandler wraps the handler so its methods are never called concurrently
+func NewSynchronizedHandler(handler LogHandler) *SynchronizedHandler {
+	return &SynchronizedHandler{handler: handler}
+}
+
+// OnLog passes the message to the handler
+func (h *SynchronizedHandler) OnLog(msg LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnLog(msg)
+}
+
+// OnError passes the error to the handler
+func (h *SynchronizedHandler) OnError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnError(err)
+}
+
+// OnEnd signals the end of streaming to the handler
+func (h *SynchronizedHandler) OnEnd() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnEnd()
+}
+
+// Start starts the handler if it implements HandlerStarter
+func (h *SynchronizedHandler) Start(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if starter, ok := h.handler.(HandlerStarter); ok {
+		return starter.Start(ctx)
+	}
+	return nil
+}
+
+// OnStreamStart passes the start of a stream to the handler if it implements
+// StreamLifecycleHandler
+func (h *SynchronizedHandler) OnStreamStart(namespace, pod, container string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lifecycle, ok := h.handler.(StreamLifecycleHandler); ok {
+		lifecycle.OnStreamStart(namespace, pod, container)
+	}
+}
+
+// OnStreamEnd passes the end of a stream to the handler if it implements
+// StreamLifecycleHandler
+func (h *SynchronizedHandler) OnStreamEnd(namespace, pod, container string, reason error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lifecycle, ok := h.handler.(StreamLifecycleHandler); ok {
+		lifecycle.OnStreamEnd(namespace, pod, container, reason)
+	}
+}
+
+// Close closes the handler if it implements io.Closer
+func (h *SynchronizedHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if closer, ok := h.handler.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// WriteLog passes the message to the handler's WriteLog if it implements FallibleHandler,
+// otherwise to its OnLog
+func (h *SynchronizedHandler) WriteLog(msg LogMessage) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fallible, ok := h.handler.(FallibleHandler); ok {
+		return fallible.WriteLog(msg)
+	}
+	h.handler.OnLog(msg)
+	return nil
+}
+
+// OnBatch passes the batch to the handler if it implements BatchHandler, otherwise its
+// messages to OnLog one by one
+func (h *SynchronizedHandler) OnBatch(batch []LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if batcher, ok := h.handler.(BatchHandler); ok {
+		batcher.OnBatch(batch)
+		return
+	}
+	for _, msg := range batch {
+		h.handler.OnLog(msg)
+	}
+}
+
+// OnRawLog passes the raw line to the handler if it implements RawHandler
+func (h *SynchronizedHandler) OnRawLog(raw []byte, meta LogMetadata) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if rawHandler, ok := h.handler.(RawHandler); ok {
+		rawHandler.OnRawLog(raw, meta)
+	}
+}
+
+// HealthCheck probes the handler's sink if it implements HealthCheckable
+func (h *SynchronizedHandler) HealthCheck() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if checkable, ok := h.handler.(HealthCheckable); ok {
+		return checkable.HealthCheck()
+	}
+	return nil
+}
+
+// supports reports whether the handler implements the optional interface T. A
+// SynchronizedHandler implements them all, it supports those of the handler it wraps. A
+// ContainerRouter supports those of any handler it routes to.
+func supports[T any](handler LogHandler) bool {
+	switch h := handler.(type) {
+	case *SynchronizedHandler:
+		return supports[T](h.handler)
+	case *ContainerRouter:
+		for _, routed := range h.handlers {
+			if supports[T](routed) {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := handler.(T)
+	return ok
+}
+
+// ChannelHandler delivers log messages and errors on channels, for consumers that would
+// rather range over a channel than implement LogHandler:
+//
+//	handler := klogstream.NewChannelHandler(100)
+//	streamer, err := klogstream.NewStreamer(klogstream.WithHandler(handler), ...)
+//	...
+//	for msg := range handler.Messages() {
+//		fmt.Println(msg.Message)
+//	}
+//
+// Both channels are closed by OnEnd, when the streamer stops. A full Messages channel
+// blocks the stream that delivers the next message, holding up its container like a slow
+// handler would, unless WithBackpressure sets a strategy that drops lines instead. The
+// messages channel must be read until it is closed, or Stop waits for the blocked streams.
+// Messages still waiting for room when OnEnd is called, such as those of OnLog calls
+// abandoned by WithHandlerTimeout, are dropped. Errors never block: they are dropped when
+// the Errors channel is full, so consumers that only read Messages are not held up.
+type ChannelHandler struct {
+	mu     sync.RWMutex
+	closed bool
+	// done is closed by OnEnd, releasing the OnLog calls waiting for room
+	done chan struct{}
+	// sending counts the OnLog calls sending outside the lock, the channels are closed
+	// once they returned
+	sending  sync.WaitGroup
+	messages chan LogMessage
+	errors   chan error
+}
+
+// NewChannelHandler creates a ChannelHandler whose channels each buffer bufSize values
+func NewChannelHandler(bufSize int) *ChannelHandler {
+	if bufSize < 0 {
+		bufSize = 0
+	}
+	return &ChannelHandler{
+		done:     make(chan struct{}),
+		messages: make(chan LogMessage, bufSize),
+		errors:   make(chan error, bufSize),
+	}
+}
+
+// Messages returns the channel the log messages are delivered on
+func (h *ChannelHandler) Messages() <-chan LogMessage {
+	return h.messages
+}
+
+// Errors returns the channel the errors are delivered on
+func (h *ChannelHandler) Errors() <-chan error {
+	return h.errors
+}
+
+// OnLog sends the message on the Messages channel, waiting for room in it until OnEnd
+func (h *ChannelHandler) OnLog(msg LogMessage) {
+	h.mu.RLock()
+	if h.closed {
+		h.mu.RUnlock()
+		return
+	}
+	h.sending.Add(1)
+	h.mu.RUnlock()
+	defer h.sending.Done()
+
+	// Wait without the lock, so a stalled consumer doesn't hold up OnEnd
+	select {
+	case h.messages <- msg:
+	case <-h.done:
+	}
+}
+
+// OnError sends the error on the Errors channel if it has room
+func (h *ChannelHandler) OnError(err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return
+	}
+	select {
+	case h.errors <- err:
+	default:
+	}
+}
+
+// OnEnd closes both channels, dropping the messages still waiting for room
+func (h *ChannelHandler) OnEnd() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	close(h.done)
+	h.mu.Unlock()
+
+	// No OnLog starts sending any more, wait for those released by done
+	h.sending.Wait()
+	close(h.messages)
+	close(h.errors)
+}
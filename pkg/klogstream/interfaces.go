@@ -1,6 +1,12 @@
 package klogstream
 
-// LogHandler handles log messages and errors
+import (
+	"context"
+)
+
+// LogHandler handles log messages and errors.
+// Handlers owning resources may also implement HandlerStarter to set them up when the
+// streamer starts, and io.Closer to release them after OnEnd when the streamer stops.
 type LogHandler interface {
 	// OnLog is called for each log message
 	OnLog(LogMessage)
@@ -10,6 +16,66 @@ type LogHandler interface {
 	OnEnd()
 }
 
+// RawHandler is a LogHandler that can receive log lines as raw bytes.
+// With WithRawPassthrough enabled, OnRawLog is called instead of OnLog.
+type RawHandler interface {
+	LogHandler
+	// OnRawLog is called with the bytes of each log line exactly as the kubelet sent them,
+	// starting with its timestamp
+	OnRawLog(raw []byte, meta LogMetadata)
+}
+
+// HandlerStarter is a LogHandler that sets up its resources, such as files or network
+// connections, when the streamer starts. Start is called before the first log is delivered,
+// and an error aborts the streamer's Start.
+type HandlerStarter interface {
+	LogHandler
+	// Start prepares the handler to receive logs
+	Start(ctx context.Context) error
+}
+
+// HealthCheckable is a LogHandler whose sink, such as a database or an HTTP endpoint, can
+// be probed. With WithSinkHealthCheck set, HealthCheck is called periodically and an
+// error reports the sink as failing.
+type HealthCheckable interface {
+	LogHandler
+	// HealthCheck returns an error if the sink can't currently accept logs
+	HealthCheck() error
+}
+
+// BatchHandler is a LogHandler that can receive log messages in batches.
+// With WithBatchByPod set, OnBatch is called instead of OnLog.
+type BatchHandler interface {
+	LogHandler
+	// OnBatch is called with formatted messages that all come from the same pod, in order
+	OnBatch(batch []LogMessage)
+}
+
+// StreamLifecycleHandler is a LogHandler that is told when the log stream of each
+// container starts and ends, for operators tracking which containers are being streamed.
+// OnStreamStart is called once the container's log is first opened, reconnects aren't
+// reported again. OnStreamEnd is called when the streamer gives up on the container, with
+// the error that ended its stream, the context's error if the stream was cancelled, such
+// as when the pod was deleted or stopped matching the filter, or nil if the log was read
+// to its end or the streamer stopped. Both are called from the container's goroutine.
+type StreamLifecycleHandler interface {
+	LogHandler
+	// OnStreamStart is called when streaming the container's log begins
+	OnStreamStart(namespace, pod, container string)
+	// OnStreamEnd is called when streaming the container's log ends, with the reason
+	OnStreamEnd(namespace, pod, container string, reason error)
+}
+
+// FallibleHandler is a LogHandler whose writes can fail, such as one writing to a file.
+// With WithHandlerErrorCallback set, WriteLog is called instead of OnLog and its errors
+// are passed to the callback, apart from the streaming errors sent to OnError. With
+// WithDeadLetterHandler set, the messages WriteLog failed on go to the dead-letter handler.
+type FallibleHandler interface {
+	LogHandler
+	// WriteLog handles a log message like OnLog, returning the error if it failed
+	WriteLog(LogMessage) error
+}
+
 // LogFormatter formats log messages as strings
 type LogFormatter interface {
 	// Format converts a log message to a formatted string
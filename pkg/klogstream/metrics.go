@@ -0,0 +1,126 @@
+package klogstream
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricDesc describes a single metric in the exposition output
+type metricDesc struct {
+	name  string
+	help  string
+	kind  string
+	value func(Metrics) string
+}
+
+// metricDescs lists the exported metrics in output order
+var metricDescs = []metricDesc{
+	{
+		name:  "klogstream_active_streams",
+		help:  "Number of container log streams currently open.",
+		kind:  "gauge",
+		value: func(m Metrics) string { return fmt.Sprint(m.ActiveStreams) },
+	},
+	{
+		name:  "klogstream_lines_delivered_total",
+		help:  "Total number of log messages passed to the handler.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.LinesDelivered) },
+	},
+	{
+		name:  "klogstream_lines_dropped_total",
+		help:  "Total number of log messages dropped because the handler could not keep up.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.LinesDropped) },
+	},
+	{
+		name:  "klogstream_lines_throttled_total",
+		help:  "Total number of log messages dropped by the per-pod rate limit.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.LinesThrottled) },
+	},
+	{
+		name:  "klogstream_lines_oversize_total",
+		help:  "Total number of log lines dropped for exceeding the maximum line size.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.LinesOversize) },
+	},
+	{
+		name:  "klogstream_lines_pause_dropped_total",
+		help:  "Total number of log messages dropped while paused because the pause buffer was full or the streamer stopped.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.LinesPauseDropped) },
+	},
+	{
+		name:  "klogstream_handler_timeouts_total",
+		help:  "Total number of handler calls abandoned for exceeding the handler timeout.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.HandlerTimeouts) },
+	},
+	{
+		name:  "klogstream_bytes_read_total",
+		help:  "Total number of bytes read from container log streams.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.BytesRead) },
+	},
+	{
+		name:  "klogstream_retries_total",
+		help:  "Total number of pod watch and log stream retries.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.Retries) },
+	},
+	{
+		name:  "klogstream_errors_total",
+		help:  "Total number of errors reported to the handler.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.Errors) },
+	},
+	{
+		name: "klogstream_compression_active",
+		help: "Whether the API server is sending gzip-encoded responses.",
+		kind: "gauge",
+		value: func(m Metrics) string {
+			if m.CompressionActive {
+				return "1"
+			}
+			return "0"
+		},
+	},
+	{
+		name:  "klogstream_bytes_compressed_total",
+		help:  "Total number of gzip-encoded bytes received on the wire.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.BytesCompressed) },
+	},
+	{
+		name:  "klogstream_bytes_decompressed_total",
+		help:  "Total number of bytes produced by decompressing responses.",
+		kind:  "counter",
+		value: func(m Metrics) string { return fmt.Sprint(m.BytesDecompressed) },
+	},
+}
+
+// NewMetricsHTTPHandler returns an http.Handler that renders the streamer's metrics in the
+// Prometheus text exposition format. It has no dependency on the Prometheus client library,
+// so it can be mounted on any mux, e.g. mux.Handle("/metrics", streamer.MetricsHTTPHandler()).
+func NewMetricsHTTPHandler(streamer Streamer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		_ = WriteMetrics(w, streamer.Metrics())
+	})
+}
+
+// WriteMetrics writes the metrics in the Prometheus text exposition format
+func WriteMetrics(w io.Writer, metrics Metrics) error {
+	for _, desc := range metricDescs {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			desc.name, desc.help, desc.name, desc.kind, desc.name, desc.value(metrics)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package klogstream
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// discardHandler ignores everything it receives
+type discardHandler struct{}
+
+func (h *discardHandler) OnLog(msg LogMessage) {}
+func (h *discardHandler) OnError(err error)    {}
+func (h *discardHandler) OnEnd()               {}
+
+// parseMetrics parses Prometheus text exposition output into sample values and metric types
+func parseMetrics(t *testing.T, text string) (map[string]float64, map[string]string) {
+	t.Helper()
+
+	samples := make(map[string]float64)
+	types := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			if len(fields) != 4 {
+				t.Fatalf("malformed TYPE line %q", line)
+			}
+			types[fields[2]] = fields[3]
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			t.Fatalf("sample %q has invalid value: %v", line, err)
+		}
+		samples[fields[0]] = value
+	}
+	return samples, types
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var sb strings.Builder
+	err := WriteMetrics(&sb, Metrics{
+		ActiveStreams:  3,
+		LinesDelivered: 42198,
+		LinesDropped:   17,
+		BytesRead:      1 << 20,
+		Retries:        2,
+		Errors:         1,
+
+		CompressionActive: true,
+		BytesCompressed:   4096,
+		BytesDecompressed: 65536,
+	})
+	if err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+
+	samples, types := parseMetrics(t, sb.String())
+
+	want := map[string]float64{
+		"klogstream_active_streams":        3,
+		"klogstream_lines_delivered_total": 42198,
+		"klogstream_lines_dropped_total":   17,
+		"klogstream_bytes_read_total":      1 << 20,
+		"klogstream_retries_total":         2,
+		"klogstream_errors_total":          1,
+
+		"klogstream_compression_active":       1,
+		"klogstream_bytes_compressed_total":   4096,
+		"klogstream_bytes_decompressed_total": 65536,
+	}
+	for name, value := range want {
+		if got, ok := samples[name]; !ok || got != value {
+			t.Errorf("%s = %v (present %v), want %v", name, got, ok, value)
+		}
+	}
+	if types["klogstream_active_streams"] != "gauge" {
+		t.Errorf("klogstream_active_streams type = %q, want gauge", types["klogstream_active_streams"])
+	}
+	if types["klogstream_lines_delivered_total"] != "counter" {
+		t.Errorf("klogstream_lines_delivered_total type = %q, want counter", types["klogstream_lines_delivered_total"])
+	}
+}
+
+func TestStreamer_MetricsHTTPHandler(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset(pod)),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(&discardHandler{}),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// The fake clientset returns a single line per request, wait for some traffic
+	deadline := time.Now().Add(5 * time.Second)
+	for streamer.Metrics().LinesDelivered < 10 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for log traffic")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	streamer.Stop()
+
+	recorder := httptest.NewRecorder()
+	streamer.MetricsHTTPHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want Prometheus text format", ct)
+	}
+
+	samples, _ := parseMetrics(t, recorder.Body.String())
+	metrics := streamer.Metrics()
+
+	if got := samples["klogstream_lines_delivered_total"]; got < 10 || got != float64(metrics.LinesDelivered) {
+		t.Errorf("klogstream_lines_delivered_total = %v, want %d", got, metrics.LinesDelivered)
+	}
+	if got := samples["klogstream_bytes_read_total"]; got < float64(len("fake logs")*10) {
+		t.Errorf("klogstream_bytes_read_total = %v, want at least %d", got, len("fake logs")*10)
+	}
+	if got := samples["klogstream_active_streams"]; got != 0 {
+		t.Errorf("klogstream_active_streams = %v after Stop, want 0", got)
+	}
+}
+
+func TestStreamer_Stats(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	// The first pod watch fails, which is reported and retried
+	clientset := fake.NewSimpleClientset(pod)
+	var watchFailed atomic.Bool
+	clientset.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+		if watchFailed.CompareAndSwap(false, true) {
+			return true, nil, errors.New("connection reset by peer")
+		}
+		return false, nil, nil
+	})
+
+	streamer, err := NewStreamer(
+		withFakeClientset(clientset),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(&discardHandler{}),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if got := streamer.Stats(); got != (StreamerStats{}) {
+		t.Errorf("Stats() before Start = %+v, want zero", got)
+	}
+
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// The fake clientset returns a single line per request, so the counters keep advancing
+	deadline := time.Now().Add(5 * time.Second)
+	for stats := streamer.Stats(); stats.LinesDelivered < 5 || stats.Retries == 0; stats = streamer.Stats() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the counters to advance, Stats() = %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	streamer.Stop()
+
+	stats := streamer.Stats()
+	metrics := streamer.Metrics()
+	if stats.LinesDelivered != metrics.LinesDelivered || stats.BytesRead != metrics.BytesRead ||
+		stats.Retries != metrics.Retries || stats.Errors != metrics.Errors {
+		t.Errorf("Stats() = %+v, want the counters of Metrics() %+v", stats, metrics)
+	}
+	if stats.BytesRead < uint64(len("fake logs")*5) {
+		t.Errorf("BytesRead = %d, want at least %d", stats.BytesRead, len("fake logs")*5)
+	}
+	if stats.Errors == 0 {
+		t.Error("Errors = 0, want the failed watch counted")
+	}
+	if stats.ActiveStreams != 0 {
+		t.Errorf("ActiveStreams after Stop = %d, want 0", stats.ActiveStreams)
+	}
+}
@@ -12,14 +12,66 @@ type LogMessage struct {
 	PodName string
 	// ContainerName is the name of the container within the pod
 	ContainerName string
+	// NodeName is the name of the node the pod is scheduled on, as seen when its streams
+	// started
+	NodeName string
+	// StreamLabel is the user-defined label of the streamer that produced the message
+	StreamLabel string
+	// LineNumber is the message's sequence number within its container, when line numbering is enabled
+	LineNumber int64
+	// Stream is the container output the message was written to, as told by the stream classifier
+	Stream StreamKind
+	// IsEvent marks a message generated by the streamer, such as a container restart annotation,
+	// rather than read from the container's log
+	IsEvent bool
 	// Timestamp is the time when the log message was created
 	Timestamp time.Time
-	// Message is the log content
+	// Message is the log content, after any line prefix
 	Message string
-	// Raw contains the original bytes of the log message
+	// Formatted is the formatter's output for the message, including any line prefix.
+	// Handlers that print messages should print Formatted when it is set and fall back
+	// to Message otherwise.
+	Formatted string
+	// Historical marks a message read within the initial burst of its container's stream,
+	// see WithSuppressInitialBurst. Alerting handlers can skip it as likely backfill.
+	Historical bool
+	// Truncated marks a message cut off at the maximum line length, see WithMaxLineBytes.
+	// The rest of the line follows in the next messages, the last of which isn't marked.
+	Truncated bool
+	// SinkLabels holds the pod labels named with WithSinkLabelsFromPodLabels, for sinks that
+	// index messages by label. It is shared by the messages of a pod and must not be modified.
+	SinkLabels map[string]string
+	// PodLabels holds all the labels of the pod as seen when its streams started, for
+	// handlers that route or tag messages by label, such as app or version. It is shared by
+	// the messages of a pod and must not be modified.
+	PodLabels map[string]string
+	// Exit tells how the container terminated on the event message delivered for it,
+	// see WithExitCodeCapture. It is nil on every other message.
+	Exit *ContainerExit
+	// Raw contains the original bytes of the log message, starting with the kubelet timestamp
 	Raw []byte
 }
 
+// LogMetadata identifies where a raw log line came from
+type LogMetadata struct {
+	// Namespace is the kubernetes namespace of the pod
+	Namespace string
+	// PodName is the name of the pod
+	PodName string
+	// ContainerName is the name of the container within the pod
+	ContainerName string
+	// StreamLabel is the user-defined label of the streamer that produced the line
+	StreamLabel string
+	// LineNumber is the line's sequence number within its container, when line numbering is enabled
+	LineNumber int64
+	// Stream is the container output the line was written to, as told by the stream classifier
+	Stream StreamKind
+	// IsEvent marks a line generated by the streamer rather than read from the container's log
+	IsEvent bool
+	// Timestamp is the time when the line was read
+	Timestamp time.Time
+}
+
 // LogStreamError represents an error that occurred during log streaming
 type LogStreamError struct {
 	// Err is the underlying error
@@ -42,3 +94,138 @@ func (e *LogStreamError) Error() string {
 func (e *LogStreamError) Unwrap() error {
 	return e.Err
 }
+
+// MatchReport summarizes how many pods and containers a filter matched when the streamer started
+type MatchReport struct {
+	// PodsMatched is the number of pods passing the pod filters
+	PodsMatched int
+	// ContainersMatched is the number of containers of matched pods passing the container filters
+	ContainersMatched int
+}
+
+// Metrics is a point-in-time snapshot of a streamer's runtime counters
+type Metrics struct {
+	// ActiveStreams is the number of container log streams currently open
+	ActiveStreams int64
+	// LinesDelivered is the number of log messages passed to the handler
+	LinesDelivered uint64
+	// LinesDropped is the number of log messages discarded because the handler couldn't keep up
+	LinesDropped uint64
+	// LinesThrottled is the number of log messages discarded by WithPerPodRateLimit
+	LinesThrottled uint64
+	// LinesOversize is the number of log lines dropped by WithMaxLineBytesDrop
+	LinesOversize uint64
+	// LinesPauseDropped is the number of log messages dropped while paused, because the
+	// WithPauseBufferSize buffer was full or the streamer stopped before Resume
+	LinesPauseDropped uint64
+	// HandlerTimeouts is the number of OnLog calls abandoned by WithHandlerTimeout
+	HandlerTimeouts uint64
+	// PodLinesThrottled is the number of log messages discarded by WithPerPodRateLimit for
+	// each pod currently streamed, keyed by namespace/pod. Pods never throttled are left out.
+	PodLinesThrottled map[string]uint64
+	// BytesRead is the number of bytes read from container log streams
+	BytesRead uint64
+	// Retries is the number of times the pod watch or a container log stream was retried
+	Retries uint64
+	// Errors is the number of errors reported to the handler
+	Errors uint64
+	// CompressionActive reports whether the API server has sent gzip-encoded responses
+	CompressionActive bool
+	// BytesCompressed is the number of gzip-encoded bytes received on the wire
+	BytesCompressed uint64
+	// BytesDecompressed is the number of bytes the compressed responses expanded to
+	BytesDecompressed uint64
+}
+
+// StreamSnapshot is the state of a container stream at the time of a Snapshot
+type StreamSnapshot struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// Retries is the number of times the stream was retried after an error
+	Retries int64
+	// LastSeen is when the last line was read from the stream, zero if none was
+	LastSeen time.Time
+	// PendingLines is the number of lines buffered by the multiline matcher
+	PendingLines int
+	// Completed is set when the pod completed and its stream ends at the end of its log,
+	// see WithBatchWorkloadMode
+	Completed bool
+}
+
+// Snapshot is the diagnostic state of a streamer, for debugging streams that stopped
+type Snapshot struct {
+	// Taken is when the snapshot was taken
+	Taken time.Time
+	// ActivePods is the number of pods being streamed
+	ActivePods int
+	// Streams holds the container streams that started, sorted by namespace, pod and container
+	Streams []StreamSnapshot
+	// Goroutines is the number of goroutines of the process
+	Goroutines int
+}
+
+// StreamerStats is a compact snapshot of a streamer's main counters, for polling them
+// cheaply. Metrics has the full set.
+type StreamerStats struct {
+	// ActiveStreams is the number of container log streams currently open
+	ActiveStreams int
+	// LinesDelivered is the number of log messages passed to the handler
+	LinesDelivered uint64
+	// BytesRead is the number of bytes read from container log streams
+	BytesRead uint64
+	// Retries is the number of times the pod watch or a container log stream was retried after an error
+	Retries uint64
+	// Errors is the number of errors reported to the handler
+	Errors uint64
+}
+
+// Summary totals what a streamer captured over its run
+type Summary struct {
+	// Lines is the number of log messages passed to the handler
+	Lines uint64
+	// Bytes is the number of bytes read from container log streams
+	Bytes uint64
+	// Pods is the number of distinct pods whose logs were streamed
+	Pods int
+	// Containers is the number of distinct containers whose logs were streamed
+	Containers int
+	// Errors is the number of errors reported to the handler
+	Errors uint64
+	// Duration is how long the streamer ran, until now while it is still running
+	Duration time.Duration
+	// EndReason is why the streamer ended, EndReasonNone while it is running
+	EndReason EndReason
+}
+
+// PodContainerRef identifies a container of a pod
+type PodContainerRef struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// ContainerExit describes how a container terminated
+type ContainerExit struct {
+	// ExitCode is the exit code of the container's process
+	ExitCode int32
+	// Reason is the kubelet's brief reason for the termination, such as "Completed" or "OOMKilled"
+	Reason string
+}
+
+// ContainerMetrics counts what was delivered from a container
+type ContainerMetrics struct {
+	// Lines is the number of log messages delivered
+	Lines uint64
+	// Bytes is the number of bytes of the log lines delivered
+	Bytes uint64
+	// LastSeen is when the last message was delivered
+	LastSeen time.Time
+}
+
+// PodMetrics counts what was delivered from a pod, see WithMetricsPerPod
+type PodMetrics struct {
+	ContainerMetrics
+	// Containers holds the metrics of each of the pod's containers, keyed by name
+	Containers map[string]ContainerMetrics
+}
@@ -0,0 +1,114 @@
+package klogstream
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// MultiHandler sends every log message and error to several handlers, such as a console
+// and a file sink. Messages are passed to the handlers one after the other in the order
+// they were given.
+//
+// OnEnd and Close shut the handlers down sequentially, each one returning before the next
+// starts, in the flush order set by WithFlushOrder or else the order they were given. This
+// makes shutdown deterministic when a sink depends on another being flushed first, e.g. a
+// buffering handler that writes into a file handler must end before the file is closed.
+type MultiHandler struct {
+	handlers []LogHandler
+	// order is the shutdown order, every handler once
+	order []LogHandler
+}
+
+// NewMultiHandler creates a MultiHandler over the handlers
+func NewMultiHandler(handlers ...LogHandler) *MultiHandler {
+	return &MultiHandler{handlers: handlers, order: handlers}
+}
+
+// WithFlushOrder sets the order OnEnd and Close shut the handlers down in. The handlers
+// listed go first, in the order given, the handlers left out follow in the order they
+// were added. Handlers that aren't part of the MultiHandler are ignored.
+func (m *MultiHandler) WithFlushOrder(first ...LogHandler) *MultiHandler {
+	member := make(map[LogHandler]bool, len(m.handlers))
+	for _, h := range m.handlers {
+		member[h] = true
+	}
+
+	order := make([]LogHandler, 0, len(m.handlers))
+	seen := make(map[LogHandler]bool, len(m.handlers))
+	for _, h := range append(first, m.handlers...) {
+		if member[h] && !seen[h] {
+			seen[h] = true
+			order = append(order, h)
+		}
+	}
+	m.order = order
+	return m
+}
+
+// OnLog passes the message to every handler
+func (m *MultiHandler) OnLog(msg LogMessage) {
+	for _, h := range m.handlers {
+		h.OnLog(msg)
+	}
+}
+
+// OnError passes the error to every handler
+func (m *MultiHandler) OnError(err error) {
+	for _, h := range m.handlers {
+		h.OnError(err)
+	}
+}
+
+// OnEnd signals the end of streaming to every handler in flush order, waiting for each
+// to return before the next
+func (m *MultiHandler) OnEnd() {
+	for _, h := range m.order {
+		h.OnEnd()
+	}
+}
+
+// OnStreamStart passes the start of a stream to every handler implementing
+// StreamLifecycleHandler
+func (m *MultiHandler) OnStreamStart(namespace, pod, container string) {
+	for _, h := range m.handlers {
+		if lifecycle, ok := h.(StreamLifecycleHandler); ok {
+			lifecycle.OnStreamStart(namespace, pod, container)
+		}
+	}
+}
+
+// OnStreamEnd passes the end of a stream to every handler implementing
+// StreamLifecycleHandler
+func (m *MultiHandler) OnStreamEnd(namespace, pod, container string, reason error) {
+	for _, h := range m.handlers {
+		if lifecycle, ok := h.(StreamLifecycleHandler); ok {
+			lifecycle.OnStreamEnd(namespace, pod, container, reason)
+		}
+	}
+}
+
+// Start starts every handler implementing HandlerStarter in the order they were added,
+// stopping at the first error
+func (m *MultiHandler) Start(ctx context.Context) error {
+	for _, h := range m.handlers {
+		if starter, ok := h.(HandlerStarter); ok {
+			if err := starter.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes every handler implementing io.Closer in flush order, waiting for each to
+// return before the next. A failing handler doesn't keep the later ones from closing.
+func (m *MultiHandler) Close() error {
+	var errs []error
+	for _, h := range m.order {
+		if closer, ok := h.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
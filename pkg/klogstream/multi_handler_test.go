@@ -0,0 +1,80 @@
+package klogstream
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shutdownRecorder records the order in which handlers end and close
+type shutdownRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *shutdownRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// orderedSink records its shutdown, taking delay to end
+type orderedSink struct {
+	name     string
+	delay    time.Duration
+	closeErr error
+	recorder *shutdownRecorder
+}
+
+func (s *orderedSink) OnLog(msg LogMessage) {}
+func (s *orderedSink) OnError(err error)    {}
+
+func (s *orderedSink) OnEnd() {
+	s.recorder.record(s.name + " end start")
+	time.Sleep(s.delay)
+	s.recorder.record(s.name + " end done")
+}
+
+func (s *orderedSink) Close() error {
+	s.recorder.record(s.name + " close")
+	return s.closeErr
+}
+
+func TestMultiHandler_FlushOrder(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	file := &orderedSink{name: "file", recorder: recorder, closeErr: errors.New("disk full")}
+	buffer := &orderedSink{name: "buffer", delay: 50 * time.Millisecond, recorder: recorder}
+
+	// The buffer writes into the file, so it must be flushed first
+	m := NewMultiHandler(file, buffer).WithFlushOrder(buffer)
+	m.OnEnd()
+	err := m.Close()
+
+	want := []string{
+		"buffer end start", "buffer end done", "file end start", "file end done",
+		"buffer close", "file close",
+	}
+	if got := strings.Join(recorder.events, ", "); got != strings.Join(want, ", ") {
+		t.Errorf("shutdown order = %s, want %s", got, strings.Join(want, ", "))
+	}
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Close() error = %v, want the file sink's error", err)
+	}
+}
+
+func TestMultiHandler_DefaultOrder(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	first := &orderedSink{name: "first", recorder: recorder}
+	second := &orderedSink{name: "second", recorder: recorder}
+
+	// Handlers that aren't part of the MultiHandler are ignored
+	m := NewMultiHandler(first, second).WithFlushOrder(&orderedSink{name: "other", recorder: recorder})
+	m.OnEnd()
+
+	want := "first end start, first end done, second end start, second end done"
+	if got := strings.Join(recorder.events, ", "); got != want {
+		t.Errorf("shutdown order = %s, want %s", got, want)
+	}
+}
@@ -1,7 +1,14 @@
 package klogstream
 
 import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
 	"github.com/archsyscall/klogstream/internal/kube"
+	"github.com/archsyscall/klogstream/internal/stream"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -17,19 +24,160 @@ type StreamConfig struct {
 	Filter *LogFilter
 	// Formatter is the log formatter
 	Formatter LogFormatter
+	// OutputFormat names a built-in formatter installed in place of Formatter, see WithOutputFormat
+	OutputFormat string
+	// Template is the template of the template output format
+	Template string
+	// ColorByPod colors each pod with its own color from ColorPalette in a TextFormatter
+	ColorByPod bool
+	// ColorPalette holds the ANSI color codes of ColorByPod
+	ColorPalette []string
 	// Handler is the log handler
 	Handler LogHandler
+	// ContainerHandlers route the logs of matching containers to their own handlers
+	ContainerHandlers []ContainerRoute
 	// Matcher is the multiline matcher
 	Matcher MultilineMatcher
 	// RetryPolicy configures retry behavior
 	RetryPolicy RetryPolicy
+	// LinePrefix is a template prepended to every log message before it reaches the handler
+	LinePrefix string
+	// PauseBufferSize is the number of messages buffered while the streamer is paused
+	PauseBufferSize int
+	// TrimCarriageReturn strips a trailing carriage return from each log line
+	TrimCarriageReturn bool
+	// SanitizeUTF8 replaces invalid UTF-8 sequences in log messages
+	SanitizeUTF8 bool
+	// StreamLabel is an arbitrary tag stamped on every log message from this streamer
+	StreamLabel string
+	// HandlerTimeout bounds how long a single OnLog call may block the stream
+	HandlerTimeout time.Duration
+	// DryRunMatch lists pods once on Start and reports what the filter matched
+	DryRunMatch bool
+	// PodDeletionGracePeriod caps how long a deleted pod's streams stay open for shutdown logs
+	PodDeletionGracePeriod time.Duration
+	// BackfillRate paces the historical lines read when a stream opens, in lines per second
+	BackfillRate int
+	// RawPassthrough delivers raw bytes to a RawHandler instead of formatted messages
+	RawPassthrough bool
+	// NamespaceListConcurrency bounds how many namespaces are listed in parallel on Start
+	NamespaceListConcurrency int
+	// SinceAfterStart streams each pod's logs from its start time plus this offset
+	SinceAfterStart *time.Duration
+	// Backpressure decides what happens when the handler can't keep up
+	Backpressure BackpressureStrategy
+	// BackpressureBufferSize is the number of lines queued for the handler by the drop strategies
+	BackpressureBufferSize int
+	// ReadBufferSize is the size of the buffer each container log stream is read through
+	ReadBufferSize int
+	// MaxLineBytes is the longest log line a container stream may contain
+	MaxLineBytes int
+	// MaxLineBytesDrop drops log lines longer than this many bytes
+	MaxLineBytesDrop int
+	// LineNumbering stamps each message with its line number within the container's stream
+	LineNumbering bool
+	// StreamClassifier tags each message with the container output it was written to
+	StreamClassifier StreamClassifier
+	// MaxDuration stops the streamer by itself once it has been streaming this long
+	MaxDuration time.Duration
+	// ListPageSize is the number of pods fetched per page when listing a namespace on Start
+	ListPageSize int64
+	// ContainerRestartEvents annotates container restarts inline with the logs
+	ContainerRestartEvents bool
+	// MaxConcurrentStreams bounds how many container streams are open at once
+	MaxConcurrentStreams int
+	// StreamPriority orders containers waiting for a stream under MaxConcurrentStreams
+	StreamPriority func(pod *corev1.Pod) int
+	// OnListComplete is called with each namespace's matched pod count after its initial listing
+	OnListComplete func(namespace string, matched int)
+	// AutoDetectJSON applies JSON multiline merging to the container streams that log JSON
+	AutoDetectJSON bool
+	// QuietGrace closes container streams that produce no output within this period
+	QuietGrace time.Duration
+	// QuietReprobeInterval is how long a silent container waits before its stream is reopened
+	QuietReprobeInterval time.Duration
+	// RetryBudgetMax is the number of retries allowed across all streams per RetryBudgetWindow
+	RetryBudgetMax int
+	// RetryBudgetWindow is the window of the retry budget
+	RetryBudgetWindow time.Duration
+	// PodFilter is a predicate pods must pass, in addition to the filter, to be streamed
+	PodFilter func(pod *corev1.Pod) bool
+	// MergePodContainers serializes the lines of all containers of a pod
+	MergePodContainers bool
+	// CheckpointStore persists where each container stream got to
+	CheckpointStore CheckpointStore
+	// CheckpointInterval is how often checkpoints are saved
+	CheckpointInterval time.Duration
+	// FollowFromNow streams only lines logged after Start
+	FollowFromNow bool
+	// PodRateLimit caps the lines per second delivered from each pod
+	PodRateLimit int
+	// ContainerLogOptions override the log options of matching containers
+	ContainerLogOptions []ContainerLogOptions
+	// HandlerErrorCallback receives the errors of a FallibleHandler's WriteLog
+	HandlerErrorCallback func(err error)
+	// DeadLetterHandler receives the messages a FallibleHandler failed to write
+	DeadLetterHandler LogHandler
+	// SinkHealthCheckInterval is how often a HealthCheckable handler is probed
+	SinkHealthCheckInterval time.Duration
+	// SinkHealthFailures is how many probes in a row must fail before the sink is unhealthy
+	SinkHealthFailures int
+	// OnSinkUnhealthy is called when the sink becomes unhealthy
+	OnSinkUnhealthy func(error)
+	// TimestampSource decides which time populates LogMessage.Timestamp
+	TimestampSource TimestampSource
+	// TimestampExtractor reads the application's timestamp from a line
+	TimestampExtractor func(line string) (time.Time, bool)
+	// PreserveOriginalMessage keeps LogMessage.Message unformatted, enabled by default
+	PreserveOriginalMessage bool
+	// BatchWorkloads reads completed pods' logs to their end before they stop being tracked
+	BatchWorkloads bool
+	// ExitCodeCapture delivers an event message with the exit code of each terminated container
+	ExitCodeCapture bool
+	// MaxLogAge drops lines older than this
+	MaxLogAge time.Duration
+	// InitialBurst marks the messages read soon after a container's stream opened as historical
+	InitialBurst time.Duration
+	// PodMetrics counts the messages delivered per pod and container
+	PodMetrics bool
+	// PodMetricsTTL is how long the metrics of a pod are kept after it stops being streamed
+	PodMetricsTTL time.Duration
+	// SinkLabelKeys are the pod labels copied to LogMessage.SinkLabels
+	SinkLabelKeys []string
+	// MaxReconnects abandons containers whose streams keep ending right after opening
+	MaxReconnects int
+	// ReconnectOverlap is how far before the last line read a reconnected stream resumes
+	ReconnectOverlap time.Duration
+	// OnFirstLog is called when a container's stream delivers its first line
+	OnFirstLog func(ref PodContainerRef, at time.Time)
+	// FirstLogOnReconnect calls OnFirstLog again for the first line after every reconnect
+	FirstLogOnReconnect bool
+	// Logger receives the streamer's internal diagnostics at debug level
+	Logger *slog.Logger
+	// StreamerName names the streamer in its diagnostics
+	StreamerName string
+	// MatchCacheTTL is how long the pod filter decision is cached for each pod
+	MatchCacheTTL time.Duration
+	// ReadDeadline fails log stream reads that block for longer
+	ReadDeadline time.Duration
+	// DrainTimeout bounds how long after Stop buffered multiline messages are still delivered
+	DrainTimeout time.Duration
+	// BatchMaxSize is the number of messages that fills a pod's batch, zero disables batching
+	BatchMaxSize int
+	// BatchMaxWait is how long a batch waits to fill before it is delivered
+	BatchMaxWait time.Duration
+
+	// optionErrors collects invalid option values, NewStreamer returns them
+	optionErrors []error
 }
 
 // NewStreamConfig creates a new StreamConfig with default values
 func NewStreamConfig() *StreamConfig {
 	return &StreamConfig{
-		KubeOptions: []kube.Option{kube.UseDefaultConfig()},
-		RetryPolicy: DefaultRetryPolicy,
+		KubeOptions:             []kube.Option{kube.UseDefaultConfig()},
+		RetryPolicy:             DefaultRetryPolicy,
+		TrimCarriageReturn:      true,
+		PreserveOriginalMessage: true,
 	}
 }
 
@@ -62,6 +210,26 @@ func WithClientset(clientset *kubernetes.Clientset) StreamOption {
 	}
 }
 
+// WithMaxIdleConnsPerHost sets how many idle connections to the API server are kept for reuse.
+// All container log streams share a single transport, so raising this on large fan-outs lets
+// reconnecting streams reuse pooled connections instead of exhausting ephemeral ports.
+// Values less than or equal to zero keep the client-go default.
+func WithMaxIdleConnsPerHost(n int) StreamOption {
+	return func(c *StreamConfig) {
+		c.KubeOptions = append(c.KubeOptions, kube.WithMaxIdleConnsPerHost(n))
+	}
+}
+
+// WithLogStreamCompression asks the API server for gzip-encoded responses and decompresses
+// them in a wrapper that counts the bytes on both sides. Metrics reports whether compression
+// is active and how many compressed and decompressed bytes flowed, so operators of remote
+// clusters can verify compression is actually reducing traffic.
+func WithLogStreamCompression(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.KubeOptions = append(c.KubeOptions, kube.WithCompression(enabled))
+	}
+}
+
 // WithFilter sets the log filter
 func WithFilter(filter *LogFilter) StreamOption {
 	return func(c *StreamConfig) {
@@ -76,6 +244,45 @@ func WithFormatter(formatter LogFormatter) StreamOption {
 	}
 }
 
+// WithOutputFormat installs a built-in formatter with its defaults by name, in place of
+// one set with WithFormatter: "raw" for the message text only, "text", "json", "logfmt",
+// or "template", which requires WithTemplate. An unknown name makes NewStreamer fail
+// with ErrInvalidOutputFormat.
+func WithOutputFormat(format string) StreamOption {
+	return func(c *StreamConfig) {
+		c.OutputFormat = format
+	}
+}
+
+// ansiColorCode matches an ANSI SGR sequence such as "\033[36m" or "\033[38;5;208m"
+var ansiColorCode = regexp.MustCompile(`^\x1b\[[0-9;]+m$`)
+
+// WithColorPalette colors each pod with its own color from palette, picked by a hash of
+// its namespace and name so a pod keeps its color, to match the terminal's theme. It
+// applies to a TextFormatter set with WithFormatter or WithOutputFormat("text") and enables
+// its ColorByPod. An empty palette uses DefaultColorPalette. Codes must be ANSI color
+// sequences, such as "\033[38;5;208m", or NewStreamer fails with ErrInvalidColorPalette.
+func WithColorPalette(palette []string) StreamOption {
+	return func(c *StreamConfig) {
+		for _, code := range palette {
+			if !ansiColorCode.MatchString(code) {
+				c.optionErrors = append(c.optionErrors, fmt.Errorf("%w: %q is not an ANSI color sequence", ErrInvalidColorPalette, code))
+				return
+			}
+		}
+		c.ColorByPod = true
+		c.ColorPalette = palette
+	}
+}
+
+// WithTemplate sets the Go template of the "template" output format, using the fields
+// of the TemplateFormatter. Without WithOutputFormat it selects the template format.
+func WithTemplate(templateStr string) StreamOption {
+	return func(c *StreamConfig) {
+		c.Template = templateStr
+	}
+}
+
 // WithHandler sets the log handler
 func WithHandler(handler LogHandler) StreamOption {
 	return func(c *StreamConfig) {
@@ -83,6 +290,38 @@ func WithHandler(handler LogHandler) StreamOption {
 	}
 }
 
+// WithSynchronizedHandler sets a handler that isn't safe for concurrent use, wrapped in a
+// SynchronizedHandler so its calls are serialized. See SynchronizedHandler for the
+// throughput trade-off.
+func WithSynchronizedHandler(handler LogHandler) StreamOption {
+	return func(c *StreamConfig) {
+		c.Handler = NewSynchronizedHandler(handler)
+	}
+}
+
+// WithContainerHandler routes the logs of containers whose name matches containerRegex
+// to the handler. Routes are tried in the order they were added and each message goes to
+// the first match only. Containers matching no route go to the handler set by WithHandler,
+// or are dropped if there is none. Errors and the end of streaming are sent to all handlers.
+// An invalid regex or a nil handler makes NewStreamer fail.
+func WithContainerHandler(containerRegex string, handler LogHandler) StreamOption {
+	return func(c *StreamConfig) {
+		pattern, err := regexp.Compile(containerRegex)
+		if err != nil {
+			c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidRegex, containerRegex, err))
+			return
+		}
+		if handler == nil {
+			c.optionErrors = append(c.optionErrors, fmt.Errorf("container handler for %q: %w", containerRegex, ErrNoHandler))
+			return
+		}
+		c.ContainerHandlers = append(c.ContainerHandlers, ContainerRoute{
+			Pattern: pattern,
+			Handler: handler,
+		})
+	}
+}
+
 // WithMatcher sets the multiline matcher
 func WithMatcher(matcher MultilineMatcher) StreamOption {
 	return func(c *StreamConfig) {
@@ -96,3 +335,727 @@ func WithRetryPolicy(policy RetryPolicy) StreamOption {
 		c.RetryPolicy = policy
 	}
 }
+
+// DefaultLinePrefix is a stern-like prefix identifying the source of each line
+const DefaultLinePrefix = "{{.Namespace}}/{{.PodName}}/{{.ContainerName}} | "
+
+// WithLinePrefix sets a template that is rendered and prepended to every log message.
+// The template uses the same fields as the TemplateFormatter and is applied after the
+// formatter, so the prefix is present even when no formatter is configured. The prefix
+// is added to LogMessage.Message as well as LogMessage.Formatted, whether or not the
+// original message is preserved.
+func WithLinePrefix(template string) StreamOption {
+	return func(c *StreamConfig) {
+		c.LinePrefix = template
+	}
+}
+
+// WithPauseBufferSize sets how many log messages are buffered while the streamer is paused.
+// Buffered messages are delivered on Resume, and messages beyond the limit, or still buffered
+// when the streamer stops, are dropped and counted in Metrics.LinesPauseDropped. The default
+// of zero drops every message received while paused.
+func WithPauseBufferSize(size int) StreamOption {
+	return func(c *StreamConfig) {
+		if size >= 0 {
+			c.PauseBufferSize = size
+		}
+	}
+}
+
+// WithTrimCarriageReturn controls whether a trailing carriage return is stripped from each
+// log line. This is enabled by default so CRLF line endings don't leak into messages.
+func WithTrimCarriageReturn(trim bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.TrimCarriageReturn = trim
+	}
+}
+
+// WithUTF8Sanitize controls whether invalid UTF-8 sequences in log messages are replaced
+// with the Unicode replacement character before formatting. The Raw bytes are left untouched.
+func WithUTF8Sanitize(sanitize bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.SanitizeUTF8 = sanitize
+	}
+}
+
+// WithStreamLabel sets an arbitrary label that is stamped on every LogMessage produced by
+// this streamer. This makes it possible to tell streamers apart when several of them feed
+// the same handler.
+func WithStreamLabel(label string) StreamOption {
+	return func(c *StreamConfig) {
+		c.StreamLabel = label
+	}
+}
+
+// WithHandlerTimeout bounds how long a single OnLog call may block a container stream.
+// The handler is called from one goroutine, in order. A call that exceeds the timeout is
+// abandoned, counted in Metrics.HandlerTimeouts, and the stream continues reading. Until the
+// handler returns, up to 16 messages are queued for it and the rest dropped, counted in
+// Metrics.LinesDropped. Timeouts and drops are reported through OnError at most every 10
+// seconds, each report counting the ones since the previous. OnEnd waits up to the timeout
+// for the queued messages before it is passed on.
+func WithHandlerTimeout(timeout time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if timeout >= 0 {
+			c.HandlerTimeout = timeout
+		}
+	}
+}
+
+// WithDryRunMatch enables a pre-flight check on Start that lists pods once and counts the
+// pods and containers matched by the filter. The counts are available from MatchReport,
+// and an ErrNoMatches warning is sent to OnError when nothing matched, which catches
+// typos in pod or container regexes that would otherwise silently stream nothing.
+func WithDryRunMatch(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.DryRunMatch = enabled
+	}
+}
+
+// WithGracefulPodDeletion keeps a deleted pod's log streams open for the pod's own
+// terminationGracePeriodSeconds to capture its shutdown logs, capped at maxGrace.
+// Pods without an explicit grace period use the Kubernetes default of 30 seconds.
+func WithGracefulPodDeletion(maxGrace time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if maxGrace >= 0 {
+			c.PodDeletionGracePeriod = maxGrace
+		}
+	}
+}
+
+// WithFollowFromNow gives "tail -f" semantics: only lines logged after Start are
+// delivered. By default, without a Since time, each container's full log history is
+// replayed before following new lines. Pods that start later are streamed from their
+// first line, since all of it is new. If the filter's Since is later than the start
+// time, Since wins.
+func WithFollowFromNow() StreamOption {
+	return func(c *StreamConfig) {
+		c.FollowFromNow = true
+	}
+}
+
+// WithPerPodRateLimit caps the lines per second delivered from each pod, so one noisy pod
+// can't dominate a shared handler. Each pod may burst up to a second worth of lines, after
+// which its excess lines are dropped before reaching the handler while other pods are
+// unaffected. Dropped lines are counted per pod in Metrics.PodLinesThrottled. Container
+// restart events are never dropped. Zero disables the limit.
+func WithPerPodRateLimit(linesPerSec int) StreamOption {
+	return func(c *StreamConfig) {
+		if linesPerSec >= 0 {
+			c.PodRateLimit = linesPerSec
+		}
+	}
+}
+
+// WithHandlerErrorCallback routes handler-side failures, like a failed file write, to fn
+// instead of conflating them with the streaming errors sent to OnError. Handlers that
+// implement FallibleHandler are called through WriteLog and its errors are passed to fn.
+// fn is called from the goroutines delivering logs, so it must be safe for concurrent use.
+func WithHandlerErrorCallback(fn func(err error)) StreamOption {
+	return func(c *StreamConfig) {
+		c.HandlerErrorCallback = fn
+	}
+}
+
+// WithDeadLetterHandler passes the messages the handler failed to process to deadLetter,
+// so they can be persisted and replayed instead of being lost. Handlers that implement
+// FallibleHandler are called through WriteLog, and each message whose WriteLog returns an
+// error is passed to deadLetter's OnLog, after the error went to the callback set by
+// WithHandlerErrorCallback, if any. deadLetter's OnEnd is called after the handler's.
+// It is called from the goroutines delivering logs, so it must be safe for concurrent use.
+func WithDeadLetterHandler(deadLetter LogHandler) StreamOption {
+	return func(c *StreamConfig) {
+		c.DeadLetterHandler = deadLetter
+	}
+}
+
+// DefaultSinkHealthFailures is how many health checks in a row must fail before the sink
+// is reported unhealthy
+const DefaultSinkHealthFailures = stream.DefaultSinkHealthFailures
+
+// WithSinkHealthCheck probes a handler implementing HealthCheckable every interval. When
+// the probe fails several times in a row, see WithOnSinkUnhealthy, the sink is reported
+// unhealthy, by default through OnError with ErrSinkUnhealthy. It is reported again only
+// after a probe succeeded. Zero disables the probe.
+func WithSinkHealthCheck(interval time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if interval >= 0 {
+			c.SinkHealthCheckInterval = interval
+		}
+	}
+}
+
+// WithOnSinkUnhealthy calls fn with the last probe error once the health check set by
+// WithSinkHealthCheck failed failures times in a row, so the sink can be alerted on or
+// swapped. Zero failures keeps DefaultSinkHealthFailures. fn is called from the health
+// check's goroutine and replaces the report through OnError.
+func WithOnSinkUnhealthy(failures int, fn func(err error)) StreamOption {
+	return func(c *StreamConfig) {
+		if failures >= 0 {
+			c.SinkHealthFailures = failures
+		}
+		c.OnSinkUnhealthy = fn
+	}
+}
+
+// WithBatchByPod delivers messages to a BatchHandler in batches that each hold the
+// messages of a single pod, for systems that ingest per source. Every pod has its own
+// buffer, delivered once it holds maxSize messages or its oldest message waited maxWait,
+// when one of the pod's containers ends and when the streamer stops. A zero maxWait only
+// delivers full batches until then. Batches are delivered one at a time. The option is
+// ignored for handlers that don't implement BatchHandler.
+func WithBatchByPod(maxSize int, maxWait time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if maxSize >= 0 {
+			c.BatchMaxSize = maxSize
+		}
+		if maxWait >= 0 {
+			c.BatchMaxWait = maxWait
+		}
+	}
+}
+
+// TimestampSource decides which time populates LogMessage.Timestamp
+type TimestampSource = stream.TimestampSource
+
+const (
+	// TimestampKubelet uses the time the kubelet recorded for each line, falling back to
+	// the ingestion time for lines without one. This is the default.
+	TimestampKubelet = stream.TimestampKubelet
+	// TimestampIngestion uses the time each line was received, kubelet timestamps are
+	// still requested to resume reconnected streams
+	TimestampIngestion = stream.TimestampIngestion
+	// TimestampAppParsed uses the time read from the line by the extractor set with
+	// WithTimestampExtractor, falling back to the kubelet time and then the ingestion time
+	TimestampAppParsed = stream.TimestampAppParsed
+)
+
+// WithTimestampSource picks the time that populates LogMessage.Timestamp. Whatever the
+// source, log streams are requested with the kubelet's timestamps, which are parsed and
+// removed from the message text, so reconnected streams resume after the last line read.
+func WithTimestampSource(source TimestampSource) StreamOption {
+	return func(c *StreamConfig) {
+		c.TimestampSource = source
+	}
+}
+
+// WithTimestampExtractor reads the timestamp the application embedded in its log lines,
+// returning false for lines without one, and selects TimestampAppParsed. For multiline
+// messages it is called with the first line.
+func WithTimestampExtractor(extract func(line string) (time.Time, bool)) StreamOption {
+	return func(c *StreamConfig) {
+		c.TimestampExtractor = extract
+		c.TimestampSource = TimestampAppParsed
+	}
+}
+
+// WithPreserveOriginalMessageOnFormat keeps LogMessage.Message as it was read from the
+// container instead of replacing it with the formatter's output. The formatted text,
+// including any line prefix, is always available in LogMessage.Formatted. A line prefix
+// set with WithLinePrefix is added to Message either way. It is enabled by default, so
+// handlers that format or parse messages themselves never see text that was already
+// formatted once. Disabling it restores the earlier behavior of replacing Message with
+// the formatted text, for handlers that only print Message.
+func WithPreserveOriginalMessageOnFormat(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.PreserveOriginalMessage = enabled
+	}
+}
+
+// WithBatchWorkloadMode tunes the streamer for Jobs and CronJobs, whose pods complete and
+// disappear quickly. A pod that succeeded or failed is kept until its containers' logs
+// have been read to the end, and the end of a completed container's log ends its stream
+// instead of reconnecting.
+func WithBatchWorkloadMode(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.BatchWorkloads = enabled
+	}
+}
+
+// WithExitCodeCapture reports how streamed containers terminate. Once the log of a
+// terminated container has been read, a LogMessage flagged with IsEvent and carrying the
+// exit code and reason in Exit is delivered to the handler, e.g. "Terminated with exit
+// code 1 (Error)". Combine it with WithBatchWorkloadMode to report the outcome of Jobs.
+func WithExitCodeCapture(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.ExitCodeCapture = enabled
+	}
+}
+
+// WithMaxLogAge drops the lines logged more than d ago, by the kubelet's timestamp, even
+// when a stream replays history, such as on backfill. Logs are also requested from no
+// earlier than d ago. Lines without a timestamp are delivered. Zero keeps lines of any age.
+func WithMaxLogAge(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.MaxLogAge = d
+		}
+	}
+}
+
+// WithSuppressInitialBurst marks the messages read within d after a container's stream
+// first opened with LogMessage.Historical. Attaching replays each container's log
+// history, whose error lines are usually already resolved; alerting handlers can skip
+// historical messages and react only to new lines. Messages are still delivered.
+// Zero marks none.
+func WithSuppressInitialBurst(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.InitialBurst = d
+		}
+	}
+}
+
+// WithMetricsPerPod counts the lines and bytes delivered and the time of the last line
+// for every pod and container, returned by Streamer.PodStats, to find the pods flooding
+// a pipeline. The metrics of a pod are kept for ttl after it stops being streamed, so
+// they stay bounded on clusters with churning pods. A zero ttl uses five minutes.
+func WithMetricsPerPod(ttl time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if ttl >= 0 {
+			c.PodMetrics = true
+			c.PodMetricsTTL = ttl
+		}
+	}
+}
+
+// WithSinkLabelsFromPodLabels copies the pod labels named by keys, such as "app",
+// "version" or "team", to LogMessage.SinkLabels, so handlers for label-indexed sinks like
+// Loki or Elasticsearch get the same label set without hardcoding the mapping. Labels the
+// pod doesn't have are left out. Labels are read when the pod's streams start.
+func WithSinkLabelsFromPodLabels(keys ...string) StreamOption {
+	return func(c *StreamConfig) {
+		c.SinkLabelKeys = append(c.SinkLabelKeys, keys...)
+	}
+}
+
+// WithMaxReconnectsPerContainer abandons a container after its log stream connected
+// successfully but ended within seconds, without an error, n times in a row. A container
+// that writes a line and closes its output in a loop is not retried in the error sense, so
+// RetryPolicy.MaxRetries doesn't stop it reconnecting forever. The container is reported
+// to OnError with ErrMaxReconnects. Zero reconnects without limit.
+func WithMaxReconnectsPerContainer(n int) StreamOption {
+	return func(c *StreamConfig) {
+		if n >= 0 {
+			c.MaxReconnects = n
+		}
+	}
+}
+
+// WithReconnectOverlap resumes a reconnected container stream d before the last line read
+// instead of right after it, so lines logged within d of the reconnect are delivered again.
+// By default a reconnected stream skips the lines it already delivered, which loses any
+// line the kubelet hadn't flushed yet when the connection broke. The overlap trades
+// duplicates for an at-least-once guarantee across the reconnect, for sinks that would
+// rather deduplicate than miss lines. Zero keeps the default.
+func WithReconnectOverlap(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.ReconnectOverlap = d
+		}
+	}
+}
+
+// WithOnFirstLog calls fn with the container and the time when a container's stream
+// delivers its first line, after filtering, e.g. to measure how long each container takes
+// to start logging. fn is called once per container stream, or again for the first line
+// after every reconnect when resetOnReconnect is set. Streams run concurrently, so fn may
+// be called concurrently for different containers.
+func WithOnFirstLog(fn func(ref PodContainerRef, at time.Time), resetOnReconnect bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.OnFirstLog = fn
+		c.FirstLogOnReconnect = resetOnReconnect
+	}
+}
+
+// WithLogger writes the streamer's internal diagnostics to logger as debug records: which
+// pods and containers were matched or skipped and why, and when container streams are
+// opened, closed or retried. They help find out why a pod isn't streamed, and are separate
+// from the log data, which only goes to the handler. Nil disables the diagnostics.
+func WithLogger(logger *slog.Logger) StreamOption {
+	return func(c *StreamConfig) {
+		c.Logger = logger
+	}
+}
+
+// WithStreamerName adds name to every diagnostic record written to the WithLogger logger as
+// the "streamer" attribute, to tell apart several streamers sharing a logger
+func WithStreamerName(name string) StreamOption {
+	return func(c *StreamConfig) {
+		c.StreamerName = name
+	}
+}
+
+// WithMatchCacheTTL caches whether each pod matches the pod filters, keyed by its UID, for
+// d. The watch reports every status change of a pod as a modified event, and during large
+// rollouts the same pods are evaluated against the pod name regex, label selectors and
+// WithPodFilterFunc over and over; with the cache only the first event within d does.
+// A pod whose labels changed is evaluated again right away, so relabeled pods still start
+// or stop streaming. A WithPodFilterFunc predicate that depends on other fields, such as
+// the pod's status, only sees the changes once the decision expires. Zero evaluates the
+// filters on every event.
+func WithMatchCacheTTL(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.MatchCacheTTL = d
+		}
+	}
+}
+
+// WithReadDeadline fails a log stream read that doesn't return within d, so a connection
+// that hangs without being closed, such as a half-open TCP connection, is detected and
+// the stream is reopened through the retry policy instead of blocking forever. A read
+// also waits for the container's next line, so d must be longer than the quietest
+// container's longest gap between lines. Zero disables the deadline.
+func WithReadDeadline(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.ReadDeadline = d
+		}
+	}
+}
+
+// WithDrainTimeout makes Stop deliver the multiline messages being assembled, such as a
+// stack trace whose last lines haven't been read yet, instead of dropping them. Each
+// container's stream flushes what the matcher buffered as a partial message when it stops,
+// before OnEnd is called. A stream that stops more than d after Stop was called gives up
+// on its buffer. Zero, the default, drops the buffered lines.
+func WithDrainTimeout(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.DrainTimeout = d
+		}
+	}
+}
+
+// PodLogOptionsOverride replaces parts of the log options used for matching containers
+type PodLogOptionsOverride = stream.PodLogOptionsOverride
+
+// ContainerLogOptions overrides the log options of containers whose name matches ContainerRegex
+type ContainerLogOptions = stream.ContainerLogOptions
+
+// WithContainerLogOptions overrides the log options of containers whose name matches
+// containerRegex, e.g. to only tail a noisy proxy while streaming the app's full history.
+// Fields set in opts replace the defaults computed from the other options, SinceSeconds
+// replacing any since time. When several patterns match a container, the one added first
+// wins. An invalid pattern makes NewStreamer fail with ErrInvalidRegex.
+func WithContainerLogOptions(containerRegex string, opts PodLogOptionsOverride) StreamOption {
+	return func(c *StreamConfig) {
+		regex, err := regexp.Compile(containerRegex)
+		if err != nil {
+			c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidRegex, containerRegex, err))
+			return
+		}
+		c.ContainerLogOptions = append(c.ContainerLogOptions, ContainerLogOptions{
+			ContainerRegex: regex,
+			Override:       opts,
+		})
+	}
+}
+
+// WithSinceAfterStart streams each pod's logs starting at the pod's status.startTime plus
+// the offset, skipping the noisy first seconds of every pod uniformly. Unlike a fixed Since
+// time it is computed per pod, so restarted jobs and new pods are all treated the same.
+// If the filter's Since is later than the computed time, Since wins.
+func WithSinceAfterStart(offset time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if offset >= 0 {
+			c.SinceAfterStart = &offset
+		}
+	}
+}
+
+// WithBackfillRate paces the historical lines sent when a container stream opens at
+// linesPerSec, instead of reading the whole backlog in one burst. Once the stream
+// catches up with the container's live output, lines are delivered as they arrive.
+// This smooths the memory and CPU spike when starting on busy, long-lived pods.
+// Zero disables pacing.
+func WithBackfillRate(linesPerSec int) StreamOption {
+	return func(c *StreamConfig) {
+		if linesPerSec >= 0 {
+			c.BackfillRate = linesPerSec
+		}
+	}
+}
+
+// WithRawPassthrough delivers each log line's bytes exactly as the kubelet sent them,
+// timestamp prefix included, skipping the formatter, UTF-8 sanitizing, carriage return trimming and line prefixes.
+// It only takes effect when the handler implements RawHandler, which then receives
+// OnRawLog calls in place of OnLog. Other handlers keep receiving formatted messages.
+func WithRawPassthrough(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.RawPassthrough = enabled
+	}
+}
+
+// WithParallelNamespaceListing sets how many namespaces are listed in parallel when the
+// streamer starts, so startup doesn't slow down linearly when watching many namespaces.
+// A namespace whose List call fails is reported to OnError without stopping the others.
+// Zero uses the default of 8, one lists the namespaces one at a time.
+func WithParallelNamespaceListing(workers int) StreamOption {
+	return func(c *StreamConfig) {
+		if workers >= 0 {
+			c.NamespaceListConcurrency = workers
+		}
+	}
+}
+
+// WithReadBufferSize sets the size of the buffer each container log stream is read
+// through. Larger buffers mean fewer reads from the network for chatty containers.
+// Zero uses the default of 64KiB.
+func WithReadBufferSize(size int) StreamOption {
+	return func(c *StreamConfig) {
+		if size >= 0 {
+			c.ReadBufferSize = size
+		}
+	}
+}
+
+// WithMaxLineBytes caps how many bytes are read into a single log line, so a container
+// writing a huge line, or output without any newline, can't grow the memory without bound.
+// A longer line is delivered in pieces of n bytes, each with LogMessage.Truncated set but
+// the last one. Zero uses the default of 1MiB.
+func WithMaxLineBytes(n int) StreamOption {
+	return func(c *StreamConfig) {
+		if n >= 0 {
+			c.MaxLineBytes = n
+		}
+	}
+}
+
+// WithMaxLineBytesDrop drops any log line longer than n bytes before it is merged, filtered
+// or delivered, for containers that occasionally log huge dumps that are never worth
+// reading. The dropped lines are counted in Metrics.LinesOversize. Unlike WithMaxLineBytes,
+// which splits a longer line into pieces, no part of the line is delivered. The whole line
+// is measured across its pieces, so n may be above or below the WithMaxLineBytes limit.
+// The pieces of a line above that limit are held back until the line's length is known.
+// Zero keeps lines of any length.
+func WithMaxLineBytesDrop(n int) StreamOption {
+	return func(c *StreamConfig) {
+		if n >= 0 {
+			c.MaxLineBytesDrop = n
+		}
+	}
+}
+
+// WithLogLineNumbering stamps each log message with LineNumber, its position in the
+// container's stream starting from 1. Each container is numbered independently, and the
+// numbering continues when a stream reconnects. The default text formatter shows the
+// number, see TextFormatter.ShowLineNumber.
+func WithLogLineNumbering(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.LineNumbering = enabled
+	}
+}
+
+// WithMaxDuration stops the streamer by itself once it has been streaming for the
+// duration, which suits capture jobs that tail pods for a fixed window. The handler's
+// OnEnd is called as with Stop, EndReason reports EndReasonCompleted, and Run returns nil.
+// Zero streams until stopped.
+func WithMaxDuration(d time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if d >= 0 {
+			c.MaxDuration = d
+		}
+	}
+}
+
+// WithListPageSize pages through the initial pod listing of each namespace n pods at a
+// time, starting log streams as pages arrive. On namespaces with thousands of pods this
+// smooths memory use and API server load on Start. Zero lists all pods at once.
+func WithListPageSize(n int64) StreamOption {
+	return func(c *StreamConfig) {
+		if n >= 0 {
+			c.ListPageSize = n
+		}
+	}
+}
+
+// WithContainerStateChangeLogging annotates container restarts in the log timeline.
+// When the pod watch sees a streamed container's restart count go up, a LogMessage flagged
+// with IsEvent is delivered to the handler, e.g. "Restarted, previous exit code 137".
+func WithContainerStateChangeLogging(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.ContainerRestartEvents = enabled
+	}
+}
+
+// WithMaxConcurrentStreams bounds how many container log streams are open at once.
+// Containers beyond the limit wait until a stream ends, in the order set by
+// WithStreamPriority. Zero leaves the number of streams unbounded.
+func WithMaxConcurrentStreams(n int) StreamOption {
+	return func(c *StreamConfig) {
+		if n >= 0 {
+			c.MaxConcurrentStreams = n
+		}
+	}
+}
+
+// WithStreamPriority orders the containers waiting for a stream when the limit set by
+// WithMaxConcurrentStreams is reached. Containers of pods with a higher priority are
+// streamed first, e.g. ranking pods by restart count focuses a bounded collector on
+// failing pods. Without it waiting containers are streamed first come, first served.
+func WithStreamPriority(priority func(pod *corev1.Pod) int) StreamOption {
+	return func(c *StreamConfig) {
+		c.StreamPriority = priority
+	}
+}
+
+// WithOnListComplete calls fn once per namespace after its initial pod listing on Start,
+// with the number of pods matching the filter, e.g. to print "namespace default: 12 pods
+// matched, streaming". Namespaces are listed in parallel, so fn may be called concurrently.
+func WithOnListComplete(fn func(namespace string, matched int)) StreamOption {
+	return func(c *StreamConfig) {
+		c.OnListComplete = fn
+	}
+}
+
+// WithContainerLogsJSONAutoDetect merges pretty-printed JSON spread over several lines,
+// as the JSONMatcher does, only for the containers that log JSON. Each container stream
+// is checked when it opens: if its first line starts a JSON object it is merged with its
+// own JSONMatcher, otherwise it is delivered line by line. This avoids applying bracket
+// counting to plain text and stack traces. It has no effect when WithMatcher is set.
+func WithContainerLogsJSONAutoDetect(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.AutoDetectJSON = enabled
+	}
+}
+
+// WithStreamOnlyContainersWithLogs closes the log stream of a container that produces no
+// output within quietGrace, reclaiming the connection and goroutine held by sidecars that
+// never log, and opens it again every reprobeInterval to catch later output. A zero
+// reprobeInterval uses the default of one minute. Zero quietGrace keeps silent streams open.
+func WithStreamOnlyContainersWithLogs(quietGrace, reprobeInterval time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if quietGrace >= 0 {
+			c.QuietGrace = quietGrace
+		}
+		if reprobeInterval >= 0 {
+			c.QuietReprobeInterval = reprobeInterval
+		}
+	}
+}
+
+// WithRetryBudget bounds the retries of all log streams and pod watches together to
+// maxPerWindow per window. RetryPolicy.MaxRetries applies to each stream on its own, so
+// during an outage hundreds of streams would otherwise retry at once; with a budget,
+// retries beyond it wait for the budget to refill. Zero for either leaves retries unbounded.
+func WithRetryBudget(maxPerWindow int, window time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		if maxPerWindow >= 0 && window >= 0 {
+			c.RetryBudgetMax = maxPerWindow
+			c.RetryBudgetWindow = window
+		}
+	}
+}
+
+// WithPodFilterFunc streams only pods for which filter returns true, for selections the
+// built-in filters can't express, such as pods with more than one container or with a
+// given environment variable. It is checked after the built-in filters on every listed
+// pod and on every ADDED and MODIFIED watch event, so it must be fast and must not block.
+func WithPodFilterFunc(filter func(pod *corev1.Pod) bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.PodFilter = filter
+	}
+}
+
+// WithMergeContainersIntoPodStream merges all containers of a pod into a single stream.
+// Lines of the same pod are delivered one at a time in arrival order, so the handler
+// sees a coherent per-pod sequence instead of lines from the pod's containers arriving
+// concurrently. Each line still carries its container name. Different pods are still
+// delivered concurrently.
+func WithMergeContainersIntoPodStream(enabled bool) StreamOption {
+	return func(c *StreamConfig) {
+		c.MergePodContainers = enabled
+	}
+}
+
+// WithCheckpointStore records the kubelet time of the last line delivered from every
+// container, whatever the timestamp source, and saves it to store every interval and when
+// the streamer ends. Checkpoints are loaded on Start, and containers seen by an earlier
+// run resume streaming from their checkpoint, so a restarted collector picks up near
+// where it left off. Lines logged within the second of a checkpoint may be delivered
+// again. The checkpoints of a pod are dropped once it is no longer streamed. A zero
+// interval uses DefaultCheckpointInterval.
+func WithCheckpointStore(store CheckpointStore, interval time.Duration) StreamOption {
+	return func(c *StreamConfig) {
+		c.CheckpointStore = store
+		if interval >= 0 {
+			c.CheckpointInterval = interval
+		}
+	}
+}
+
+// EndReason tells why a streamer ended
+type EndReason = stream.EndReason
+
+const (
+	// EndReasonNone means the streamer has not ended
+	EndReasonNone = stream.EndReasonNone
+	// EndReasonStopped means Stop was called
+	EndReasonStopped = stream.EndReasonStopped
+	// EndReasonCompleted means the streamer stopped itself after WithMaxDuration elapsed
+	EndReasonCompleted = stream.EndReasonCompleted
+)
+
+// StreamKind identifies which output of the container a log line was written to
+type StreamKind = stream.StreamKind
+
+const (
+	// StreamUnknown is used when no classifier is set or it couldn't tell the stream
+	StreamUnknown = stream.StreamUnknown
+	// StreamStdout marks lines written to the container's standard output
+	StreamStdout = stream.StreamStdout
+	// StreamStderr marks lines written to the container's standard error
+	StreamStderr = stream.StreamStderr
+)
+
+// StreamClassifier decides which output of the container a log line was written to
+type StreamClassifier = stream.StreamClassifier
+
+// WithStreamClassifier tags each log message with Stream, the container output it was
+// written to. Kubernetes merges stdout and stderr into a single log stream, so the kind
+// can only be recovered from the line itself, e.g. when a container prefixes its stderr
+// lines. Messages are StreamUnknown without a classifier.
+func WithStreamClassifier(classify func(line string) StreamKind) StreamOption {
+	return func(c *StreamConfig) {
+		c.StreamClassifier = classify
+	}
+}
+
+// BackpressureStrategy decides what the streamer does when the handler can't keep up
+type BackpressureStrategy = stream.BackpressureStrategy
+
+const (
+	// BackpressureBlock delivers every line by calling the handler from the stream readers.
+	// Nothing is lost in the streamer, but a slow handler stops the streams from being read,
+	// and the kubelet may drop logs once its own buffers overflow. This is the default.
+	BackpressureBlock = stream.BackpressureBlock
+	// BackpressureDropOldest queues lines for the handler and discards the oldest queued line
+	// when the queue is full, keeping the streams live and favoring the most recent logs
+	BackpressureDropOldest = stream.BackpressureDropOldest
+	// BackpressureDropNewest queues lines for the handler and discards incoming lines when the
+	// queue is full, keeping the streams live and favoring the logs already queued
+	BackpressureDropNewest = stream.BackpressureDropNewest
+)
+
+// WithSourceBackpressure chooses between completeness and liveness when the handler is
+// slower than the containers produce logs. BackpressureBlock never drops a line but lets
+// a slow handler stall reading; the drop strategies keep reading at full speed and discard
+// lines instead, counted in Metrics().LinesDropped. Choose deliberately: there is no
+// strategy that is both lossless and unaffected by a slow handler.
+func WithSourceBackpressure(strategy BackpressureStrategy) StreamOption {
+	return func(c *StreamConfig) {
+		c.Backpressure = strategy
+	}
+}
+
+// WithBackpressureBufferSize sets how many lines the drop strategies queue for the handler
+// before dropping. Zero uses the default of 1024.
+func WithBackpressureBufferSize(size int) StreamOption {
+	return func(c *StreamConfig) {
+		if size >= 0 {
+			c.BackpressureBufferSize = size
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package klogstream
 
 import (
+	"fmt"
 	"regexp"
 	"time"
 
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
@@ -17,6 +19,30 @@ func WithNamespace(namespace string) StreamOption {
 	}
 }
 
+// WithAllNamespaces streams the pods of every namespace the credentials can list, like
+// kubectl logs --all-namespaces, instead of the namespaces added with WithNamespace.
+// Pods are then listed and watched cluster-wide.
+func WithAllNamespaces() StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.AllNamespaces = true
+	}
+}
+
+// WithExcludeNamespace never streams the pods of the namespaces, to leave noisy system
+// namespaces such as kube-system out of WithAllNamespaces. It combines with the label and
+// field selectors, and can be given several times.
+func WithExcludeNamespace(namespaces ...string) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.ExcludeNamespaces = append(c.Filter.ExcludeNamespaces, namespaces...)
+	}
+}
+
 // WithPodRegex adds a pod name regex to the log filter
 func WithPodRegex(pattern string) StreamOption {
 	return func(c *StreamConfig) {
@@ -61,7 +87,8 @@ func WithLabel(key, value string) StreamOption {
 }
 
 // WithLabelSelector adds a label selector string to the log filter
-// The format is the same as kubectl's label selector (e.g., "app=myapp,env=prod")
+// The format is the same as kubectl's label selector (e.g., "app=myapp,env=prod").
+// A selector that can't be parsed makes NewStreamer fail with ErrInvalidLabelSelector.
 func WithLabelSelector(selector string) StreamOption {
 	return func(c *StreamConfig) {
 		if c.Filter == nil {
@@ -69,13 +96,63 @@ func WithLabelSelector(selector string) StreamOption {
 		}
 		if selector != "" {
 			sel, err := labels.Parse(selector)
-			if err == nil {
-				c.Filter.LabelSelector = sel
+			if err != nil {
+				// Don't fall back to no selector, that would match every pod
+				c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidLabelSelector, selector, err))
+				return
+			}
+			c.Filter.LabelSelector = sel
+		}
+	}
+}
+
+// WithFieldSelector streams only the pods whose fields match selector, for what labels
+// can't express, such as the pods on a node or in a phase:
+//
+//	WithFieldSelector("spec.nodeName=worker-1")
+//	WithFieldSelector("status.phase=Running")
+//
+// The selector is applied by the API server when listing and watching pods, so only the
+// fields it supports for pods can be used. A selector that can't be parsed makes
+// NewStreamer fail with ErrInvalidFieldSelector.
+func WithFieldSelector(selector string) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		if selector != "" {
+			sel, err := fields.ParseSelector(selector)
+			if err != nil {
+				c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidFieldSelector, selector, err))
+				return
 			}
+			c.Filter.FieldSelector = sel
 		}
 	}
 }
 
+// WithAdditionalSelector streams the pods matching selector too, for an OR that a single
+// label selector can't express, e.g. "app=web" or "app=api":
+//
+//	WithLabelSelector("app=web"), WithAdditionalSelector("app=api")
+//
+// Every selector is listed and watched on its own and a pod matching several is streamed
+// once. It can be repeated. A selector that can't be parsed makes NewStreamer fail with
+// ErrInvalidLabelSelector.
+func WithAdditionalSelector(selector string) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		sel, err := labels.Parse(selector)
+		if err != nil {
+			c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidLabelSelector, selector, err))
+			return
+		}
+		c.Filter.AdditionalSelectors = append(c.Filter.AdditionalSelectors, sel)
+	}
+}
+
 // WithIncludeRegex adds an include regex to the log filter
 func WithIncludeRegex(pattern string) StreamOption {
 	return func(c *StreamConfig) {
@@ -91,7 +168,98 @@ func WithIncludeRegex(pattern string) StreamOption {
 	}
 }
 
-// WithSince sets the time to stream logs from
+// WithExcludeRegex adds an exclude regex to the log filter. Lines matching it are dropped,
+// even if they also match the include regex, e.g. to leave out health checks. An invalid
+// pattern makes NewStreamer fail with ErrInvalidRegex.
+func WithExcludeRegex(pattern string) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		if pattern != "" {
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidRegex, pattern, err))
+				return
+			}
+			c.Filter.ExcludeRegex = regex
+		}
+	}
+}
+
+// DefaultErrorPattern matches the tokens common to error log lines, case-insensitively
+const DefaultErrorPattern = `(?i)\b(error|fatal|panic|exception|critical)\b`
+
+// ErrorsOnly streams only the log lines matching pattern, DefaultErrorPattern if empty, and
+// calls onError with each of them, for alerting on error lines without writing a handler.
+// It sets the include regex and the handler, replacing any set before. Streaming errors
+// are discarded, use a full LogHandler to observe them. An invalid pattern makes
+// NewStreamer fail with ErrInvalidRegex.
+func ErrorsOnly(pattern string, onError func(LogMessage)) StreamOption {
+	if pattern == "" {
+		pattern = DefaultErrorPattern
+	}
+	return func(c *StreamConfig) {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			c.optionErrors = append(c.optionErrors, fmt.Errorf("%w %q: %v", ErrInvalidRegex, pattern, err))
+			return
+		}
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.IncludeRegex = regex
+		c.Handler = &callbackHandler{onLog: onError}
+	}
+}
+
+// WithImageRegex adds a container image regex to the log filter.
+// A container is streamed only if its image or resolved image ID matches the pattern.
+func WithImageRegex(pattern string) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		if pattern != "" {
+			regex, err := regexp.Compile(pattern)
+			if err == nil {
+				c.Filter.ImageRegex = regex
+			}
+		}
+	}
+}
+
+// WithTimeWindow streams only the logs between start and end, such as the ten minutes of
+// an incident. Logs are requested from start, and each container's log is read once, not
+// followed, until the first line logged after end, using the kubelet's timestamps.
+// Both times must be in the past and start must be before end, otherwise NewStreamer
+// fails with ErrInvalidTimeWindow.
+func WithTimeWindow(start, end time.Time) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.Since = &start
+		c.Filter.Until = &end
+	}
+}
+
+// WithMinRestartCount only streams containers whose restart count is at least count.
+// This is useful for focusing on flapping containers, e.g. during CrashLoopBackOff triage.
+// A container is streamed as soon as its restart count reaches count, also after Start.
+func WithMinRestartCount(count int32) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		if count >= 0 {
+			c.Filter.MinRestartCount = count
+		}
+	}
+}
+
+// WithSince sets the time to stream logs from.
+// Without it, each container's full log history is streamed, see WithFollowFromNow.
 func WithSince(duration time.Duration) StreamOption {
 	return func(c *StreamConfig) {
 		if c.Filter == nil {
@@ -104,6 +272,45 @@ func WithSince(duration time.Duration) StreamOption {
 	}
 }
 
+// WithTailLines starts each container's log with only the given number of its most recent
+// lines, instead of its full history, before following it, like kubectl logs --tail. WithContainerLogOptions
+// overrides it for matching containers. A negative count makes NewStreamer fail with
+// ErrInvalidTailLines.
+func WithTailLines(lines int64) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.TailLines = &lines
+	}
+}
+
+// WithPrevious streams the logs of the previous instance of each container, the one that
+// ran before its last restart, like kubectl logs --previous. That is where the cause of a
+// crash is when the restarted container's log starts empty. The previous log doesn't grow,
+// so it is read once instead of followed, and each stream ends cleanly after reading it.
+// The log requests of containers that never restarted fail, they have no previous log.
+func WithPrevious(previous bool) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.Previous = previous
+	}
+}
+
+// WithSinceTime sets the time to stream logs from to an absolute time, such as the start of
+// an incident. It replaces the time set by WithSince, or by WithSinceTime, applied before
+// it. A time in the future makes NewStreamer fail with ErrInvalidSinceTime.
+func WithSinceTime(t time.Time) StreamOption {
+	return func(c *StreamConfig) {
+		if c.Filter == nil {
+			c.Filter = &LogFilter{}
+		}
+		c.Filter.Since = &t
+	}
+}
+
 // WithContainerState sets the container state filter
 func WithContainerState(state string) StreamOption {
 	return func(c *StreamConfig) {
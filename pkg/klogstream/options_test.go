@@ -1,10 +1,19 @@
 package klogstream
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestStreamOptions(t *testing.T) {
@@ -81,3 +90,419 @@ func TestStreamOptions(t *testing.T) {
 		t.Errorf("Expected 4 KubeOptions, got %d", len(config.KubeOptions))
 	}
 }
+
+func TestWithLinePrefix(t *testing.T) {
+	config := NewStreamConfig()
+	WithLinePrefix(DefaultLinePrefix)(config)
+
+	if config.LinePrefix != DefaultLinePrefix {
+		t.Errorf("WithLinePrefix option was not applied correctly, got %q", config.LinePrefix)
+	}
+}
+
+func TestWithContainerLogOptions(t *testing.T) {
+	tail := int64(100)
+	config := NewStreamConfig()
+	WithContainerLogOptions("^proxy$", PodLogOptionsOverride{TailLines: &tail})(config)
+
+	if len(config.ContainerLogOptions) != 1 || !config.ContainerLogOptions[0].ContainerRegex.MatchString("proxy") {
+		t.Fatalf("ContainerLogOptions = %+v, want the proxy pattern", config.ContainerLogOptions)
+	}
+	if got := config.ContainerLogOptions[0].Override.TailLines; got == nil || *got != tail {
+		t.Errorf("TailLines = %v, want %d", got, tail)
+	}
+
+	_, err := NewStreamer(
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+		WithNamespace("default"),
+		WithHandler(NewConsoleHandler()),
+		WithContainerLogOptions("proxy(", PodLogOptionsOverride{}),
+	)
+	if !errors.Is(err, ErrInvalidRegex) {
+		t.Errorf("NewStreamer() with an invalid pattern error = %v, want %v", err, ErrInvalidRegex)
+	}
+}
+
+func TestWithPreserveOriginalMessageOnFormat(t *testing.T) {
+	config := NewStreamConfig()
+	if !config.PreserveOriginalMessage {
+		t.Fatal("PreserveOriginalMessage is disabled by default, want the original message kept")
+	}
+
+	WithPreserveOriginalMessageOnFormat(false)(config)
+	if config.PreserveOriginalMessage {
+		t.Error("WithPreserveOriginalMessageOnFormat(false) was not applied")
+	}
+}
+
+func TestErrorsOnly(t *testing.T) {
+	var alerts []string
+	config := NewStreamConfig()
+	ErrorsOnly("", func(msg LogMessage) { alerts = append(alerts, msg.Message) })(config)
+
+	// Deliver the lines the include filter lets through, as the streamer does
+	for _, line := range []string{
+		"ERROR: connection refused",
+		"GET /healthz 200",
+		"panic: runtime error",
+		"terror alert level lowered",
+		"Fatal exception in worker",
+	} {
+		if config.Filter.IncludeRegex.MatchString(line) {
+			config.Handler.OnLog(LogMessage{Message: line})
+		}
+	}
+
+	want := []string{"ERROR: connection refused", "panic: runtime error", "Fatal exception in worker"}
+	if len(alerts) != len(want) {
+		t.Fatalf("callback received %q, want %q", alerts, want)
+	}
+	for i := range want {
+		if alerts[i] != want[i] {
+			t.Errorf("alert %d = %q, want %q", i, alerts[i], want[i])
+		}
+	}
+
+	_, err := NewStreamer(
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+		WithNamespace("default"),
+		ErrorsOnly("error(", func(LogMessage) {}),
+	)
+	if !errors.Is(err, ErrInvalidRegex) {
+		t.Errorf("NewStreamer() with an invalid pattern error = %v, want %v", err, ErrInvalidRegex)
+	}
+}
+
+func TestWithTimeWindow(t *testing.T) {
+	end := time.Now().Add(-time.Minute)
+	start := end.Add(-10 * time.Minute)
+
+	config := NewStreamConfig()
+	WithTimeWindow(start, end)(config)
+	if config.Filter.Since == nil || !config.Filter.Since.Equal(start) || config.Filter.Until == nil || !config.Filter.Until.Equal(end) {
+		t.Errorf("filter window = %v to %v, want %v to %v", config.Filter.Since, config.Filter.Until, start, end)
+	}
+
+	for name, window := range map[string][2]time.Time{
+		"end before start": {end, start},
+		"end in future":    {start, time.Now().Add(time.Hour)},
+	} {
+		_, err := NewStreamer(
+			WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+			WithNamespace("default"),
+			WithHandler(NewConsoleHandler()),
+			WithTimeWindow(window[0], window[1]),
+		)
+		if !errors.Is(err, ErrInvalidTimeWindow) {
+			t.Errorf("%s: NewStreamer() error = %v, want %v", name, err, ErrInvalidTimeWindow)
+		}
+	}
+}
+
+func TestWithSinceTime(t *testing.T) {
+	incident := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+
+	config := NewStreamConfig()
+	WithSinceTime(incident)(config)
+	if config.Filter.Since == nil || !config.Filter.Since.Equal(incident) {
+		t.Errorf("Filter.Since = %v, want %v", config.Filter.Since, incident)
+	}
+
+	// The option applied last wins
+	config = NewStreamConfig()
+	WithSince(time.Hour)(config)
+	WithSinceTime(incident)(config)
+	if !config.Filter.Since.Equal(incident) {
+		t.Errorf("Filter.Since = %v after WithSince, WithSinceTime, want %v", config.Filter.Since, incident)
+	}
+
+	config = NewStreamConfig()
+	WithSinceTime(incident)(config)
+	before := time.Now()
+	WithSince(time.Hour)(config)
+	if since := *config.Filter.Since; since.Equal(incident) || since.Before(before.Add(-time.Hour)) {
+		t.Errorf("Filter.Since = %v after WithSinceTime, WithSince, want an hour ago", since)
+	}
+
+	_, err := NewStreamer(
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+		WithNamespace("default"),
+		WithHandler(NewConsoleHandler()),
+		WithSinceTime(time.Now().Add(time.Hour)),
+	)
+	if !errors.Is(err, ErrInvalidSinceTime) {
+		t.Errorf("NewStreamer() with a future since time error = %v, want %v", err, ErrInvalidSinceTime)
+	}
+}
+
+func TestWithTailLines(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	clientset := fake.NewSimpleClientset(pod)
+
+	streamer, err := NewStreamer(
+		withFakeClientset(clientset),
+		WithNamespace("default"),
+		WithHandler(&discardHandler{}),
+		WithTailLines(50),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer streamer.Stop()
+
+	// The option reaches the log request made for the container
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var opts *corev1.PodLogOptions
+		for _, action := range clientset.Actions() {
+			if action.GetSubresource() == "log" {
+				opts = action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+			}
+		}
+		if opts != nil {
+			if opts.TailLines == nil || *opts.TailLines != 50 {
+				t.Errorf("TailLines = %v, want 50", opts.TailLines)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the log request")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = NewStreamer(
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+		WithNamespace("default"),
+		WithHandler(NewConsoleHandler()),
+		WithTailLines(-1),
+	)
+	if !errors.Is(err, ErrInvalidTailLines) {
+		t.Errorf("NewStreamer() with negative tail lines error = %v, want %v", err, ErrInvalidTailLines)
+	}
+}
+
+func TestWithPrevious(t *testing.T) {
+	config := NewStreamConfig()
+	WithPrevious(true)(config)
+	if config.Filter == nil || !config.Filter.Previous {
+		t.Fatalf("filter = %+v, want Previous set", config.Filter)
+	}
+
+	internal, err := convertFilter(&LogFilter{Namespaces: []string{"default"}, Previous: true})
+	if err != nil {
+		t.Fatalf("convertFilter() error = %v", err)
+	}
+	if !internal.Previous {
+		t.Error("converted filter dropped Previous")
+	}
+}
+
+func TestWithOutputFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		template string
+		want     LogFormatter
+	}{
+		{format: "raw", want: RawFormatter{}},
+		{format: "text", want: &TextFormatter{}},
+		{format: "json", want: &JSONFormatter{}},
+		{format: "logfmt", want: &LogfmtFormatter{}},
+		{format: "template", template: "{{.PodName}}: {{.Message}}", want: &TemplateFormatter{}},
+		{template: "{{.Message}}", want: &TemplateFormatter{}},
+	}
+
+	for _, tt := range tests {
+		config := NewStreamConfig()
+		WithOutputFormat(tt.format)(config)
+		WithTemplate(tt.template)(config)
+		if config.OutputFormat != tt.format || config.Template != tt.template {
+			t.Fatalf("config = %q/%q, want %q/%q", config.OutputFormat, config.Template, tt.format, tt.template)
+		}
+
+		format := tt.format
+		if format == "" {
+			format = OutputFormatTemplate
+		}
+		got, err := newOutputFormatter(format, tt.template)
+		if err != nil {
+			t.Fatalf("%s: newOutputFormatter() error = %v", format, err)
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+			t.Errorf("%s: installed %T, want %T", format, got, tt.want)
+		}
+	}
+
+	if got := (RawFormatter{}).Format(LogMessage{PodName: "web-1", Message: "ready"}); got != "ready" {
+		t.Errorf("raw format = %q, want the message only", got)
+	}
+
+	for name, options := range map[string][]StreamOption{
+		"unknown format":       {WithOutputFormat("yaml")},
+		"template without one": {WithOutputFormat("template")},
+		"invalid template":     {WithOutputFormat("template"), WithTemplate("{{.Message")},
+	} {
+		_, err := NewStreamer(append([]StreamOption{
+			WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+			WithNamespace("default"),
+			WithHandler(NewConsoleHandler()),
+		}, options...)...)
+		if !errors.Is(err, ErrInvalidOutputFormat) {
+			t.Errorf("%s: NewStreamer() error = %v, want %v", name, err, ErrInvalidOutputFormat)
+		}
+	}
+}
+
+func TestWithColorPalette(t *testing.T) {
+	palette := []string{"\033[38;5;208m", "\033[38;5;141m"}
+	textFormatter := NewTextFormatter()
+	textFormatter.ShowNamespace = false
+	textFormatter.ShowTimestamp = false
+	textFormatter.ShowContainerName = false
+
+	_, err := NewStreamer(
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+		WithNamespace("default"),
+		WithHandler(NewConsoleHandler()),
+		WithFormatter(textFormatter),
+		WithColorPalette(palette),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if !textFormatter.ColorByPod || !reflect.DeepEqual(textFormatter.Palette, palette) {
+		t.Fatalf("text formatter colors by pod %v with %q, want the palette", textFormatter.ColorByPod, textFormatter.Palette)
+	}
+
+	// Each pod is colored from the palette, the same way every time
+	for _, pod := range []string{"web-1", "web-2", "api-1"} {
+		line := textFormatter.Format(LogMessage{Namespace: "default", PodName: pod, Message: "hello"})
+		color := line[:strings.Index(line, pod)]
+		if !slices.Contains(palette, color) {
+			t.Errorf("pod %s colored %q, want a color of the palette", pod, color)
+		}
+		if again := textFormatter.Format(LogMessage{Namespace: "default", PodName: pod, Message: "hello"}); again != line {
+			t.Errorf("pod %s formatted %q then %q", pod, line, again)
+		}
+	}
+
+	// An empty palette falls back to the default
+	config := NewStreamConfig()
+	WithColorPalette(nil)(config)
+	if !config.ColorByPod || len(config.optionErrors) != 0 {
+		t.Errorf("WithColorPalette(nil) = %v, %v, want color by pod with the default palette", config.ColorByPod, config.optionErrors)
+	}
+
+	for _, code := range []string{"", "red", "\033[", "36m"} {
+		_, err := NewStreamer(
+			WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+			WithNamespace("default"),
+			WithHandler(NewConsoleHandler()),
+			WithColorPalette([]string{code}),
+		)
+		if !errors.Is(err, ErrInvalidColorPalette) {
+			t.Errorf("NewStreamer() with code %q error = %v, want %v", code, err, ErrInvalidColorPalette)
+		}
+	}
+}
+
+func TestWithAdditionalSelector(t *testing.T) {
+	config := NewStreamConfig()
+	WithLabelSelector("app=web")(config)
+	WithAdditionalSelector("app=api")(config)
+	WithAdditionalSelector("tier in (frontend)")(config)
+	if n := len(config.Filter.AdditionalSelectors); n != 2 || config.Filter.LabelSelector.String() != "app=web" {
+		t.Fatalf("filter has selector %v and %d additional ones, want app=web and 2", config.Filter.LabelSelector, n)
+	}
+
+	_, err := NewStreamer(
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}),
+		WithNamespace("default"),
+		WithHandler(NewConsoleHandler()),
+		WithAdditionalSelector("app in (web"),
+	)
+	if !errors.Is(err, ErrInvalidLabelSelector) {
+		t.Errorf("NewStreamer() with an invalid selector error = %v, want %v", err, ErrInvalidLabelSelector)
+	}
+}
+
+func TestWithFieldSelector(t *testing.T) {
+	config := NewStreamConfig()
+	WithFieldSelector("spec.nodeName=worker-1,status.phase=Running")(config)
+	if sel := config.Filter.FieldSelector; sel == nil || sel.String() != "spec.nodeName=worker-1,status.phase=Running" {
+		t.Fatalf("filter field selector = %v, want spec.nodeName=worker-1,status.phase=Running", sel)
+	}
+
+	internal, err := convertFilter(&LogFilter{Namespaces: []string{"default"}, FieldSelector: config.Filter.FieldSelector})
+	if err != nil {
+		t.Fatalf("convertFilter() error = %v", err)
+	}
+	if internal.FieldSelector == nil || internal.FieldSelector.String() != config.Filter.FieldSelector.String() {
+		t.Error("converted filter dropped the field selector")
+	}
+
+	// Parse errors surface when the streamer is built
+	_, err = NewBuilder().
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}).
+		WithNamespace("default").
+		WithFieldSelector("spec.nodeName").
+		WithHandler(NewConsoleHandler()).
+		Build()
+	if !errors.Is(err, ErrInvalidFieldSelector) {
+		t.Errorf("Build() with an invalid selector error = %v, want %v", err, ErrInvalidFieldSelector)
+	}
+}
+
+func TestWithExcludeNamespace(t *testing.T) {
+	config := NewStreamConfig()
+	WithAllNamespaces()(config)
+	WithExcludeNamespace("kube-system")(config)
+	WithExcludeNamespace("kube-public", "monitoring")(config)
+	if got := strings.Join(config.Filter.ExcludeNamespaces, ","); got != "kube-system,kube-public,monitoring" {
+		t.Fatalf("filter excluded namespaces = %s, want kube-system,kube-public,monitoring", got)
+	}
+
+	internal, err := convertFilter(config.Filter)
+	if err != nil {
+		t.Fatalf("convertFilter() error = %v", err)
+	}
+	if got := strings.Join(internal.ExcludeNamespaces, ","); got != "kube-system,kube-public,monitoring" {
+		t.Errorf("converted filter excluded namespaces = %s", got)
+	}
+}
+
+func TestWithExcludeRegex(t *testing.T) {
+	config := NewStreamConfig()
+	WithIncludeRegex("ERROR")(config)
+	WithExcludeRegex("healthz")(config)
+	if config.Filter.ExcludeRegex == nil || config.Filter.ExcludeRegex.String() != "healthz" {
+		t.Errorf("ExcludeRegex = %v, want healthz", config.Filter.ExcludeRegex)
+	}
+	if config.Filter.IncludeRegex == nil {
+		t.Error("WithExcludeRegex replaced the include regex")
+	}
+
+	internal, err := convertFilter(&LogFilter{Namespaces: []string{"default"}, ExcludeRegex: config.Filter.ExcludeRegex})
+	if err != nil {
+		t.Fatalf("convertFilter() error = %v", err)
+	}
+	if internal.ExcludeRegex != config.Filter.ExcludeRegex {
+		t.Error("convertFilter() dropped the exclude regex")
+	}
+
+	_, err = NewBuilder().
+		WithRestConfig(&rest.Config{Host: "https://test-server:8443"}).
+		WithNamespace("default").
+		WithExcludeRegex("healthz(").
+		Build()
+	if !errors.Is(err, ErrInvalidRegex) {
+		t.Errorf("Build() with an invalid pattern error = %v, want %v", err, ErrInvalidRegex)
+	}
+}
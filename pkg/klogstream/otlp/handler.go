@@ -0,0 +1,169 @@
+// Package otlp exports log messages to an OpenTelemetry collector. It is kept apart from
+// the klogstream package so that programs that don't export to OpenTelemetry don't carry
+// it. The handler speaks OTLP/HTTP with the JSON encoding, which every collector's OTLP
+// receiver accepts, so it needs no OpenTelemetry SDK.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/archsyscall/klogstream/pkg/klogstream"
+)
+
+// DefaultBatchSize is the number of records buffered before they are exported
+const DefaultBatchSize = 512
+
+// scopeName names the instrumentation scope of the exported records
+const scopeName = "github.com/archsyscall/klogstream"
+
+// Config configures where a Handler exports log records
+type Config struct {
+	// Endpoint is the collector's OTLP/HTTP logs URL, such as http://collector:4318/v1/logs
+	Endpoint string
+	// Client sends the requests, defaults to http.DefaultClient
+	Client *http.Client
+	// Headers are added to every request, such as an authentication header
+	Headers map[string]string
+	// Cluster is set as the k8s.cluster.name resource attribute if not empty
+	Cluster string
+	// BatchSize is the number of records buffered before they are exported, defaults to
+	// DefaultBatchSize. OnEnd and Flush export the records buffered so far.
+	BatchSize int
+	// ErrOut receives errors, defaults to stderr
+	ErrOut io.Writer
+}
+
+// Handler is a klogstream.LogHandler exporting each log message as an OpenTelemetry
+// LogRecord. The body is the message, the severity is parsed from its level keyword, see
+// ParseSeverity, and the namespace, pod, container and cluster are resource attributes.
+// Records are exported in batches of Config.BatchSize and on OnEnd.
+type Handler struct {
+	config Config
+
+	mu      sync.Mutex
+	pending []klogstream.LogMessage
+}
+
+// NewHandler creates a Handler exporting to config.Endpoint
+func NewHandler(config Config) (*Handler, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("OTLP endpoint is required")
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.ErrOut == nil {
+		config.ErrOut = os.Stderr
+	}
+	return &Handler{config: config}, nil
+}
+
+// OnLog buffers the message, exporting the batch once it is full
+func (h *Handler) OnLog(msg klogstream.LogMessage) {
+	h.mu.Lock()
+	h.pending = append(h.pending, msg)
+	var batch []klogstream.LogMessage
+	if len(h.pending) >= h.config.BatchSize {
+		batch = h.pending
+		h.pending = nil
+	}
+	h.mu.Unlock()
+
+	h.report(h.export(batch))
+}
+
+// OnError writes error messages to the configured error output
+func (h *Handler) OnError(err error) {
+	h.report(err)
+}
+
+// OnEnd exports the buffered records
+func (h *Handler) OnEnd() {
+	h.report(h.Flush())
+}
+
+// Flush exports the buffered records, returning any failure
+func (h *Handler) Flush() error {
+	h.mu.Lock()
+	batch := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+
+	return h.export(batch)
+}
+
+// report writes a failure to the error output
+func (h *Handler) report(err error) {
+	if err == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(h.config.ErrOut, "Error: %v\n", err)
+}
+
+// export sends the messages to the collector in a single request
+func (h *Handler) export(batch []klogstream.LogMessage) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(newExportRequest(batch, h.config.Cluster, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to encode OTLP request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, h.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := h.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export %d log records: %w", len(batch), err)
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export %d log records: collector responded %s", len(batch), resp.Status)
+	}
+	return nil
+}
+
+// newExportRequest groups the messages by container into one resource each, keeping
+// the order of the messages within a container
+func newExportRequest(batch []klogstream.LogMessage, cluster string, observed time.Time) exportRequest {
+	var request exportRequest
+	index := make(map[resourceKey]int)
+	for _, msg := range batch {
+		key := resourceKey{namespace: msg.Namespace, pod: msg.PodName, container: msg.ContainerName}
+		i, ok := index[key]
+		if !ok {
+			i = len(request.ResourceLogs)
+			index[key] = i
+			request.ResourceLogs = append(request.ResourceLogs, resourceLogs{
+				Resource:  resource{Attributes: resourceAttributes(msg, cluster)},
+				ScopeLogs: []scopeLogs{{Scope: scope{Name: scopeName}}},
+			})
+		}
+		logs := &request.ResourceLogs[i].ScopeLogs[0]
+		logs.LogRecords = append(logs.LogRecords, newLogRecord(msg, observed.UnixNano()))
+	}
+	return request
+}
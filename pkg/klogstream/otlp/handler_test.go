@@ -0,0 +1,128 @@
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/klogstream/pkg/klogstream"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantNumber int
+		wantText   string
+	}{
+		{line: "2024-05-01 ERROR connection refused", wantNumber: SeverityError, wantText: "ERROR"},
+		{line: `level=warning msg="disk almost full"`, wantNumber: SeverityWarn, wantText: "WARN"},
+		{line: "[info] listening on :8080", wantNumber: SeverityInfo, wantText: "INFO"},
+		{line: "panic: runtime error: index out of range", wantNumber: SeverityFatal, wantText: "FATAL"},
+		{line: "DEBUG cache miss", wantNumber: SeverityDebug, wantText: "DEBUG"},
+		{line: "terror alert lowered", wantNumber: SeverityUnspecified, wantText: ""},
+		{line: "GET /healthz 200", wantNumber: SeverityUnspecified, wantText: ""},
+	}
+
+	for _, tt := range tests {
+		number, text := ParseSeverity(tt.line)
+		if number != tt.wantNumber || text != tt.wantText {
+			t.Errorf("ParseSeverity(%q) = %d, %q, want %d, %q", tt.line, number, text, tt.wantNumber, tt.wantText)
+		}
+	}
+}
+
+func TestNewExportRequest(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	observed := timestamp.Add(time.Second)
+	batch := []klogstream.LogMessage{
+		{
+			Namespace: "default", PodName: "web-1", ContainerName: "app",
+			Timestamp: timestamp, Message: "ERROR connection refused", Formatted: "[web-1] ERROR connection refused",
+			Stream: klogstream.StreamStderr, LineNumber: 7,
+			SinkLabels: map[string]string{"team": "payments", "app": "web"},
+		},
+		{Namespace: "default", PodName: "web-2", ContainerName: "app", Message: "ready"},
+		{Namespace: "default", PodName: "web-1", ContainerName: "app", Message: "INFO retrying"},
+	}
+
+	data, err := json.Marshal(newExportRequest(batch, "prod-eu", observed))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	want := `{"resourceLogs":[` +
+		`{"resource":{"attributes":[` +
+		`{"key":"k8s.namespace.name","value":{"stringValue":"default"}},` +
+		`{"key":"k8s.pod.name","value":{"stringValue":"web-1"}},` +
+		`{"key":"k8s.container.name","value":{"stringValue":"app"}},` +
+		`{"key":"k8s.cluster.name","value":{"stringValue":"prod-eu"}},` +
+		`{"key":"k8s.pod.label.app","value":{"stringValue":"web"}},` +
+		`{"key":"k8s.pod.label.team","value":{"stringValue":"payments"}}]},` +
+		`"scopeLogs":[{"scope":{"name":"github.com/archsyscall/klogstream"},"logRecords":[` +
+		`{"timeUnixNano":"1714557600000000000","observedTimeUnixNano":"1714557601000000000",` +
+		`"severityNumber":17,"severityText":"ERROR","body":{"stringValue":"ERROR connection refused"},` +
+		`"attributes":[{"key":"log.iostream","value":{"stringValue":"stderr"}},` +
+		`{"key":"klogstream.line_number","value":{"intValue":"7"}}]},` +
+		`{"observedTimeUnixNano":"1714557601000000000","severityNumber":9,"severityText":"INFO",` +
+		`"body":{"stringValue":"INFO retrying"}}]}]},` +
+		`{"resource":{"attributes":[` +
+		`{"key":"k8s.namespace.name","value":{"stringValue":"default"}},` +
+		`{"key":"k8s.pod.name","value":{"stringValue":"web-2"}},` +
+		`{"key":"k8s.container.name","value":{"stringValue":"app"}},` +
+		`{"key":"k8s.cluster.name","value":{"stringValue":"prod-eu"}}]},` +
+		`"scopeLogs":[{"scope":{"name":"github.com/archsyscall/klogstream"},"logRecords":[` +
+		`{"observedTimeUnixNano":"1714557601000000000","body":{"stringValue":"ready"}}]}]}]}`
+	if string(data) != want {
+		t.Errorf("export request =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestHandler_BatchesAndFlushesOnEnd(t *testing.T) {
+	var mu sync.Mutex
+	var requests []exportRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	h, err := NewHandler(Config{Endpoint: server.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	for _, line := range []string{"one", "two", "three"} {
+		h.OnLog(klogstream.LogMessage{Namespace: "default", PodName: "web-1", ContainerName: "app", Message: line})
+	}
+
+	mu.Lock()
+	if len(requests) != 1 {
+		t.Fatalf("exported %d batches before OnEnd, want the full one", len(requests))
+	}
+	mu.Unlock()
+
+	h.OnEnd()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("exported %d batches after OnEnd, want 2", len(requests))
+	}
+	var got []string
+	for _, request := range requests {
+		for _, record := range request.ResourceLogs[0].ScopeLogs[0].LogRecords {
+			got = append(got, *record.Body.StringValue)
+		}
+	}
+	if len(got) != 3 || got[0] != "one" || got[1] != "two" || got[2] != "three" {
+		t.Errorf("exported %q, want every line in order", got)
+	}
+}
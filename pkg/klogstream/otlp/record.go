@@ -0,0 +1,175 @@
+package otlp
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/archsyscall/klogstream/pkg/klogstream"
+)
+
+// Severity numbers of the OpenTelemetry log data model, the first of each range
+const (
+	SeverityUnspecified = 0
+	SeverityTrace       = 1
+	SeverityDebug       = 5
+	SeverityInfo        = 9
+	SeverityWarn        = 13
+	SeverityError       = 17
+	SeverityFatal       = 21
+)
+
+// levelPattern finds the first level keyword in a line, such as "ERROR" or "level=warn"
+var levelPattern = regexp.MustCompile(`(?i)\b(trace|debug|info|warn|warning|error|fatal|panic)\b`)
+
+// ParseSeverity returns the OpenTelemetry severity number and text of a log line from the
+// first level keyword it contains, or SeverityUnspecified and an empty text if there is none
+func ParseSeverity(line string) (number int, text string) {
+	match := levelPattern.FindStringSubmatch(line)
+	if match == nil {
+		return SeverityUnspecified, ""
+	}
+
+	switch strings.ToLower(match[1]) {
+	case "trace":
+		return SeverityTrace, "TRACE"
+	case "debug":
+		return SeverityDebug, "DEBUG"
+	case "info":
+		return SeverityInfo, "INFO"
+	case "warn", "warning":
+		return SeverityWarn, "WARN"
+	case "error":
+		return SeverityError, "ERROR"
+	default:
+		return SeverityFatal, "FATAL"
+	}
+}
+
+// The types below are the parts of the OTLP/HTTP JSON encoding the handler sends, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+// exportRequest is the body of a request to the collector's logs endpoint
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+// logRecord is an OpenTelemetry LogRecord, the 64-bit times are strings as in the
+// protobuf JSON mapping
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber       int        `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+// stringAttr creates a string attribute
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+// intAttr creates an integer attribute
+func intAttr(key string, value int64) keyValue {
+	s := strconv.FormatInt(value, 10)
+	return keyValue{Key: key, Value: anyValue{IntValue: &s}}
+}
+
+// boolAttr creates a boolean attribute
+func boolAttr(key string, value bool) keyValue {
+	return keyValue{Key: key, Value: anyValue{BoolValue: &value}}
+}
+
+// resourceKey identifies the resource a message belongs to
+type resourceKey struct {
+	namespace string
+	pod       string
+	container string
+}
+
+// resourceAttributes returns the resource attributes of a message's container, following
+// the Kubernetes semantic conventions. Sink labels become k8s.pod.label.<key> attributes.
+func resourceAttributes(msg klogstream.LogMessage, cluster string) []keyValue {
+	attrs := []keyValue{
+		stringAttr("k8s.namespace.name", msg.Namespace),
+		stringAttr("k8s.pod.name", msg.PodName),
+		stringAttr("k8s.container.name", msg.ContainerName),
+	}
+	if msg.NodeName != "" {
+		attrs = append(attrs, stringAttr("k8s.node.name", msg.NodeName))
+	}
+	if cluster != "" {
+		attrs = append(attrs, stringAttr("k8s.cluster.name", cluster))
+	}
+
+	// Sort the labels so equal resources encode the same way
+	keys := make([]string, 0, len(msg.SinkLabels))
+	for key := range msg.SinkLabels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		attrs = append(attrs, stringAttr("k8s.pod.label."+key, msg.SinkLabels[key]))
+	}
+	return attrs
+}
+
+// newLogRecord maps a message to a LogRecord. The body is the message as read, not
+// formatted, and the severity is parsed from it.
+func newLogRecord(msg klogstream.LogMessage, observed int64) logRecord {
+	number, text := ParseSeverity(msg.Message)
+	record := logRecord{
+		ObservedTimeUnixNano: strconv.FormatInt(observed, 10),
+		SeverityNumber:       number,
+		SeverityText:         text,
+		Body:                 anyValue{StringValue: &msg.Message},
+	}
+	if !msg.Timestamp.IsZero() {
+		record.TimeUnixNano = strconv.FormatInt(msg.Timestamp.UnixNano(), 10)
+	}
+
+	if label := msg.Stream.Label(); label != "" {
+		record.Attributes = append(record.Attributes, stringAttr("log.iostream", label))
+	}
+	if msg.StreamLabel != "" {
+		record.Attributes = append(record.Attributes, stringAttr("klogstream.stream_label", msg.StreamLabel))
+	}
+	if msg.LineNumber > 0 {
+		record.Attributes = append(record.Attributes, intAttr("klogstream.line_number", msg.LineNumber))
+	}
+	if msg.IsEvent {
+		record.Attributes = append(record.Attributes, boolAttr("klogstream.event", true))
+	}
+	return record
+}
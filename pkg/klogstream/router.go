@@ -0,0 +1,131 @@
+package klogstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ContainerRoute sends the logs of containers whose name matches Pattern to Handler
+type ContainerRoute struct {
+	// Pattern matches container names
+	Pattern *regexp.Regexp
+	// Handler receives the logs of matching containers
+	Handler LogHandler
+}
+
+// ContainerRouter dispatches each log message to the handler of the first route matching
+// its container, falling back to the default handler for unmatched containers
+type ContainerRouter struct {
+	routes   []ContainerRoute
+	fallback LogHandler
+	// handlers lists every distinct handler once, for broadcasting errors and the end signal
+	handlers []LogHandler
+}
+
+// newContainerRouter creates a router over the routes, using fallback for unmatched containers.
+// The fallback may be nil, in which case logs of unmatched containers are dropped.
+func newContainerRouter(routes []ContainerRoute, fallback LogHandler) *ContainerRouter {
+	r := &ContainerRouter{
+		routes:   routes,
+		fallback: fallback,
+	}
+
+	seen := make(map[LogHandler]bool)
+	add := func(h LogHandler) {
+		if h != nil && !seen[h] {
+			seen[h] = true
+			r.handlers = append(r.handlers, h)
+		}
+	}
+	for _, route := range routes {
+		add(route.Handler)
+	}
+	add(fallback)
+
+	return r
+}
+
+// handlerFor returns the handler of the first route matching the container, or the
+// fallback, which may be nil
+func (r *ContainerRouter) handlerFor(container string) LogHandler {
+	for _, route := range r.routes {
+		if route.Pattern.MatchString(container) {
+			return route.Handler
+		}
+	}
+	return r.fallback
+}
+
+// OnLog sends the message to the first handler whose pattern matches the container
+func (r *ContainerRouter) OnLog(msg LogMessage) {
+	if h := r.handlerFor(msg.ContainerName); h != nil {
+		h.OnLog(msg)
+	}
+}
+
+// OnError reports the error to every handler
+func (r *ContainerRouter) OnError(err error) {
+	for _, h := range r.handlers {
+		h.OnError(err)
+	}
+}
+
+// OnEnd signals the end of streaming to every handler
+func (r *ContainerRouter) OnEnd() {
+	for _, h := range r.handlers {
+		h.OnEnd()
+	}
+}
+
+// OnStreamStart passes the start of a stream to the handler the container is routed to, if
+// it implements StreamLifecycleHandler
+func (r *ContainerRouter) OnStreamStart(namespace, pod, container string) {
+	if h := r.handlerFor(container); h != nil && supports[StreamLifecycleHandler](h) {
+		h.(StreamLifecycleHandler).OnStreamStart(namespace, pod, container)
+	}
+}
+
+// OnStreamEnd passes the end of a stream to the handler the container is routed to, if it
+// implements StreamLifecycleHandler
+func (r *ContainerRouter) OnStreamEnd(namespace, pod, container string, reason error) {
+	if h := r.handlerFor(container); h != nil && supports[StreamLifecycleHandler](h) {
+		h.(StreamLifecycleHandler).OnStreamEnd(namespace, pod, container, reason)
+	}
+}
+
+// HealthCheck probes the sink of every handler implementing HealthCheckable, returning
+// their errors joined
+func (r *ContainerRouter) HealthCheck() error {
+	var errs []error
+	for _, h := range r.handlers {
+		if supports[HealthCheckable](h) {
+			errs = append(errs, h.(HealthCheckable).HealthCheck())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start starts every handler implementing HandlerStarter, stopping at the first error
+func (r *ContainerRouter) Start(ctx context.Context) error {
+	for _, h := range r.handlers {
+		if starter, ok := h.(HandlerStarter); ok {
+			if err := starter.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes every handler implementing io.Closer
+func (r *ContainerRouter) Close() error {
+	var errs []error
+	for _, h := range r.handlers {
+		if closer, ok := h.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,208 @@
+package klogstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// containerCountingHandler counts messages per container
+type containerCountingHandler struct {
+	mu     sync.Mutex
+	counts map[string]int
+	errors int
+	ended  int
+}
+
+func newContainerCountingHandler() *containerCountingHandler {
+	return &containerCountingHandler{counts: make(map[string]int)}
+}
+
+func (h *containerCountingHandler) OnLog(msg LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[msg.ContainerName]++
+}
+
+func (h *containerCountingHandler) OnError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errors++
+}
+
+func (h *containerCountingHandler) OnEnd() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ended++
+}
+
+// containers returns the containers the handler received logs from
+func (h *containerCountingHandler) containers() map[string]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	containers := make(map[string]bool)
+	for name := range h.counts {
+		containers[name] = true
+	}
+	return containers
+}
+
+func TestWithContainerHandler(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app"},
+			{Name: "access-log"},
+			{Name: "sidecar"},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	appHandler := newContainerCountingHandler()
+	accessHandler := newContainerCountingHandler()
+	defaultHandler := newContainerCountingHandler()
+
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset(pod)),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithContainerHandler("^app$", appHandler),
+		WithContainerHandler("log$", accessHandler),
+		// Shadowed by the previous route, access-log only goes to the first match
+		WithContainerHandler("^access", appHandler),
+		WithHandler(defaultHandler),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// Wait until every container has produced some logs
+	deadline := time.Now().Add(5 * time.Second)
+	for len(appHandler.containers())+len(accessHandler.containers())+len(defaultHandler.containers()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for logs from every container")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	streamer.Stop()
+
+	want := map[*containerCountingHandler]string{
+		appHandler:     "app",
+		accessHandler:  "access-log",
+		defaultHandler: "sidecar",
+	}
+	for handler, container := range want {
+		got := handler.containers()
+		if len(got) != 1 || !got[container] {
+			t.Errorf("handler for %s received logs from %v", container, got)
+		}
+		if handler.ended != 1 {
+			t.Errorf("handler for %s received OnEnd %d times, want 1", container, handler.ended)
+		}
+	}
+}
+
+func TestContainerRouter_BroadcastsErrors(t *testing.T) {
+	routed := newContainerCountingHandler()
+	fallback := newContainerCountingHandler()
+
+	router := newContainerRouter([]ContainerRoute{
+		{Pattern: regexp.MustCompile("^app$"), Handler: routed},
+		{Pattern: regexp.MustCompile("^web$"), Handler: routed},
+	}, fallback)
+
+	router.OnError(errors.New("stream failed"))
+	router.OnEnd()
+
+	for name, handler := range map[string]*containerCountingHandler{"routed": routed, "fallback": fallback} {
+		if handler.errors != 1 || handler.ended != 1 {
+			t.Errorf("%s handler received %d errors and %d ends, want 1 each", name, handler.errors, handler.ended)
+		}
+	}
+
+	// Without a fallback, unmatched containers are dropped
+	router = newContainerRouter([]ContainerRoute{{Pattern: regexp.MustCompile("^app$"), Handler: routed}}, nil)
+	router.OnLog(LogMessage{ContainerName: "sidecar"})
+	if got := routed.containers(); len(got) != 0 {
+		t.Errorf("routed handler received logs from %v", got)
+	}
+}
+
+func TestWithContainerHandler_InvalidRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  StreamOption
+		wantErr error
+	}{
+		{
+			name:    "invalid regex",
+			option:  WithContainerHandler("app(", newContainerCountingHandler()),
+			wantErr: ErrInvalidRegex,
+		},
+		{
+			name:    "nil handler",
+			option:  WithContainerHandler("^app$", nil),
+			wantErr: ErrNoHandler,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStreamer(
+				withFakeClientset(fake.NewSimpleClientset()),
+				WithNamespace("default"),
+				WithHandler(newContainerCountingHandler()),
+				tt.option,
+			)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewStreamer() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContainerRouter_ForwardsOptionalInterfaces(t *testing.T) {
+	events := &streamEventsHandler{}
+	capable := &capableHandler{}
+	plain := newContainerCountingHandler()
+
+	router := newContainerRouter([]ContainerRoute{
+		{Pattern: regexp.MustCompile("^app$"), Handler: NewSynchronizedHandler(events)},
+		{Pattern: regexp.MustCompile("^db$"), Handler: capable},
+	}, plain)
+
+	// Stream events go to the handler the container is routed to
+	router.OnStreamStart("default", "web-1", "app")
+	router.OnStreamEnd("default", "web-1", "app", nil)
+	router.OnStreamStart("default", "web-1", "sidecar")
+	if got, want := fmt.Sprint(events.events), "[start default/web-1/app end default/web-1/app: <nil>]"; got != want {
+		t.Errorf("stream events = %s, want %s", got, want)
+	}
+
+	if err := router.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	if capable.checks != 1 {
+		t.Errorf("routed handler checked %d times, want 1", capable.checks)
+	}
+
+	// The router supports an optional interface only if a routed handler does
+	if !supports[HealthCheckable](router) || !supports[StreamLifecycleHandler](router) {
+		t.Error("router doesn't support the interfaces of its routed handlers")
+	}
+	router = newContainerRouter([]ContainerRoute{{Pattern: regexp.MustCompile("^app$"), Handler: plain}}, nil)
+	if supports[HealthCheckable](router) || supports[StreamLifecycleHandler](router) {
+		t.Error("router supports interfaces none of its handlers implement")
+	}
+}
@@ -2,7 +2,11 @@ package klogstream
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/archsyscall/klogstream/internal/filter"
 	"github.com/archsyscall/klogstream/internal/kube"
@@ -17,6 +21,29 @@ type Streamer interface {
 	Start(ctx context.Context) error
 	// Stop stops all log streaming activity
 	Stop()
+	// Pause stops delivering logs to the handler while keeping the streams open
+	Pause()
+	// Resume continues delivering logs after a Pause
+	Resume()
+	// MatchReport returns the pod and container counts found by the dry-run match on Start
+	MatchReport() MatchReport
+	// Metrics returns a snapshot of the streamer's runtime counters
+	Metrics() Metrics
+	// Stats returns a snapshot of the active streams and the main counters
+	Stats() StreamerStats
+	// PodStats returns the metrics of each pod keyed by namespace/pod, nil unless
+	// WithMetricsPerPod is set
+	PodStats() map[string]PodMetrics
+	// Snapshot returns the state of the active pods and their container streams
+	Snapshot() Snapshot
+	// Summary returns the totals of the run so far, final once the streamer stopped
+	Summary() Summary
+	// MetricsHTTPHandler returns an http.Handler serving the metrics in Prometheus text format
+	MetricsHTTPHandler() http.Handler
+	// Done returns a channel that is closed when the streamer stops, by Stop or after WithMaxDuration
+	Done() <-chan struct{}
+	// EndReason returns why the streamer ended, or EndReasonNone while it is running
+	EndReason() EndReason
 }
 
 // streamerImpl is the implementation of the Streamer interface
@@ -33,6 +60,27 @@ var NewStreamer = func(options ...StreamOption) (Streamer, error) {
 	for _, option := range options {
 		option(config)
 	}
+	if err := errors.Join(config.optionErrors...); err != nil {
+		return nil, fmt.Errorf("invalid stream options: %w", err)
+	}
+
+	// Install the built-in formatter named by the output format
+	if config.OutputFormat == "" && config.Template != "" {
+		config.OutputFormat = OutputFormatTemplate
+	}
+	if config.OutputFormat != "" {
+		formatter, err := newOutputFormatter(config.OutputFormat, config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stream options: %w", err)
+		}
+		config.Formatter = formatter
+	}
+
+	// Color each pod with its own color
+	if textFormatter, ok := config.Formatter.(*TextFormatter); ok && config.ColorByPod {
+		textFormatter.ColorByPod = true
+		textFormatter.Palette = config.ColorPalette
+	}
 
 	// Convert to internal types
 	internalFilter, err := convertFilter(config.Filter)
@@ -40,6 +88,15 @@ var NewStreamer = func(options ...StreamOption) (Streamer, error) {
 		return nil, err
 	}
 
+	// Dispatch to per-container handlers if any are registered
+	if len(config.ContainerHandlers) > 0 {
+		config.Handler = newContainerRouter(config.ContainerHandlers, config.Handler)
+	}
+
+	if config.Handler == nil {
+		return nil, ErrNoHandler
+	}
+
 	// Create internal client provider
 	clientProvider := kube.NewClientProviderWithOptions(config.KubeOptions...)
 
@@ -53,11 +110,101 @@ var NewStreamer = func(options ...StreamOption) (Streamer, error) {
 			MaxInterval:     config.RetryPolicy.MaxInterval,
 			Multiplier:      config.RetryPolicy.Multiplier,
 		},
+		LinePrefix:               config.LinePrefix,
+		PauseBufferSize:          config.PauseBufferSize,
+		TrimCarriageReturn:       config.TrimCarriageReturn,
+		SanitizeUTF8:             config.SanitizeUTF8,
+		StreamLabel:              config.StreamLabel,
+		HandlerTimeout:           config.HandlerTimeout,
+		DryRunMatch:              config.DryRunMatch,
+		PodDeletionGracePeriod:   config.PodDeletionGracePeriod,
+		BackfillRate:             config.BackfillRate,
+		NamespaceListConcurrency: config.NamespaceListConcurrency,
+		SinceAfterStart:          config.SinceAfterStart,
+		Backpressure:             config.Backpressure,
+		BackpressureBufferSize:   config.BackpressureBufferSize,
+		ReadBufferSize:           config.ReadBufferSize,
+		MaxLineBytes:             config.MaxLineBytes,
+		MaxLineBytesDrop:         config.MaxLineBytesDrop,
+		LineNumbering:            config.LineNumbering,
+		StreamClassifier:         config.StreamClassifier,
+		MaxDuration:              config.MaxDuration,
+		ListPageSize:             config.ListPageSize,
+		ContainerRestartEvents:   config.ContainerRestartEvents,
+		MaxConcurrentStreams:     config.MaxConcurrentStreams,
+		StreamPriority:           config.StreamPriority,
+		OnListComplete:           config.OnListComplete,
+		AutoDetectJSON:           config.AutoDetectJSON,
+		QuietGrace:               config.QuietGrace,
+		QuietReprobeInterval:     config.QuietReprobeInterval,
+		RetryBudgetMax:           config.RetryBudgetMax,
+		RetryBudgetWindow:        config.RetryBudgetWindow,
+		PodFilter:                config.PodFilter,
+		MergePodContainers:       config.MergePodContainers,
+		CheckpointStore:          config.CheckpointStore,
+		CheckpointInterval:       config.CheckpointInterval,
+		FollowFromNow:            config.FollowFromNow,
+		PodRateLimit:             config.PodRateLimit,
+		ContainerLogOptions:      config.ContainerLogOptions,
+		TimestampSource:          config.TimestampSource,
+		TimestampExtractor:       config.TimestampExtractor,
+		ReplaceMessage:           !config.PreserveOriginalMessage,
+		ReadDeadline:             config.ReadDeadline,
+		DrainTimeout:             config.DrainTimeout,
+		MaxReconnects:            config.MaxReconnects,
+		ReconnectOverlap:         config.ReconnectOverlap,
+		SinkLabelKeys:            config.SinkLabelKeys,
+		PodMetrics:               config.PodMetrics,
+		PodMetricsTTL:            config.PodMetricsTTL,
+		InitialBurst:             config.InitialBurst,
+		MaxLogAge:                config.MaxLogAge,
+		BatchWorkloads:           config.BatchWorkloads,
+		ExitCodeCapture:          config.ExitCodeCapture,
+		HealthCheckInterval:      config.SinkHealthCheckInterval,
+		HealthCheckFailures:      config.SinkHealthFailures,
+		OnSinkUnhealthy:          config.OnSinkUnhealthy,
+		FirstLogOnReconnect:      config.FirstLogOnReconnect,
+		Logger:                   config.Logger,
+		Name:                     config.StreamerName,
+		MatchCacheTTL:            config.MatchCacheTTL,
 	}
 
 	// Set handler with adapter
-	if config.Handler != nil {
-		internalConfig.Handler = stream.NewHandlerAdapter(adaptHandler(config.Handler))
+	internalConfig.Handler = stream.NewHandlerAdapter(adaptHandler(config.Handler, config.HandlerErrorCallback, config.DeadLetterHandler))
+
+	// Tie the handler's resources to the streamer's lifetime
+	if starter, ok := config.Handler.(HandlerStarter); ok {
+		internalConfig.HandlerStart = starter.Start
+	}
+	if closer, ok := config.Handler.(io.Closer); ok {
+		internalConfig.HandlerClose = closer.Close
+	}
+
+	// Report when the containers' streams start and end
+	if onFirstLog := config.OnFirstLog; onFirstLog != nil {
+		internalConfig.OnFirstLog = func(ref stream.PodContainerRef, at time.Time) {
+			onFirstLog(PodContainerRef(ref), at)
+		}
+	}
+	if lifecycle, ok := config.Handler.(StreamLifecycleHandler); ok && supports[StreamLifecycleHandler](config.Handler) {
+		internalConfig.LifecycleHandler = lifecycle
+	}
+
+	// Probe the handler's sink if it supports it
+	if checkable, ok := config.Handler.(HealthCheckable); ok && supports[HealthCheckable](config.Handler) {
+		internalConfig.HealthCheck = checkable.HealthCheck
+	}
+
+	// Deliver per-pod batches if the handler supports it
+	if batchHandler, ok := config.Handler.(BatchHandler); ok && supports[BatchHandler](config.Handler) && config.BatchMaxSize > 0 {
+		internalConfig.BatchHandler = &batchHandlerWrapper{handler: batchHandler}
+		internalConfig.BatchMaxSize = config.BatchMaxSize
+		internalConfig.BatchMaxWait = config.BatchMaxWait
+	}
+
+	// Deliver raw bytes if the handler supports it
+	if rawHandler, ok := config.Handler.(RawHandler); ok && supports[RawHandler](config.Handler) && config.RawPassthrough {
+		internalConfig.RawHandler = &rawHandlerWrapper{handler: rawHandler}
 	}
 
 	// Set formatter with adapter if provided
@@ -91,20 +238,139 @@ func (s *streamerImpl) Stop() {
 	s.internal.Stop()
 }
 
+// Pause stops delivering logs to the handler while keeping the streams open
+func (s *streamerImpl) Pause() {
+	s.internal.Pause()
+}
+
+// Resume continues delivering logs after a Pause
+func (s *streamerImpl) Resume() {
+	s.internal.Resume()
+}
+
+// MatchReport returns the pod and container counts found by the dry-run match on Start
+func (s *streamerImpl) MatchReport() MatchReport {
+	report := s.internal.MatchReport()
+	return MatchReport{
+		PodsMatched:       report.PodsMatched,
+		ContainersMatched: report.ContainersMatched,
+	}
+}
+
+// Metrics returns a snapshot of the streamer's runtime counters
+func (s *streamerImpl) Metrics() Metrics {
+	metrics := s.internal.Metrics()
+	return Metrics{
+		ActiveStreams:  metrics.ActiveStreams,
+		LinesDelivered: metrics.LinesDelivered,
+		LinesDropped:   metrics.LinesDropped,
+		LinesThrottled: metrics.LinesThrottled,
+		LinesOversize:  metrics.LinesOversize,
+		BytesRead:      metrics.BytesRead,
+		Retries:        metrics.Retries,
+		Errors:         metrics.Errors,
+
+		LinesPauseDropped: metrics.LinesPauseDropped,
+		HandlerTimeouts:   metrics.HandlerTimeouts,
+
+		CompressionActive: metrics.CompressionActive,
+		BytesCompressed:   metrics.BytesCompressed,
+		BytesDecompressed: metrics.BytesDecompressed,
+
+		PodLinesThrottled: metrics.PodLinesThrottled,
+	}
+}
+
+// Stats returns a snapshot of the active streams and the main counters
+func (s *streamerImpl) Stats() StreamerStats {
+	return StreamerStats(s.internal.Stats())
+}
+
+// PodStats returns the metrics of each pod keyed by namespace/pod
+func (s *streamerImpl) PodStats() map[string]PodMetrics {
+	stats := s.internal.PodStats()
+	if stats == nil {
+		return nil
+	}
+
+	pods := make(map[string]PodMetrics, len(stats))
+	for key, stat := range stats {
+		pod := PodMetrics{
+			ContainerMetrics: ContainerMetrics(stat.ContainerMetrics),
+			Containers:       make(map[string]ContainerMetrics, len(stat.Containers)),
+		}
+		for name, container := range stat.Containers {
+			pod.Containers[name] = ContainerMetrics(container)
+		}
+		pods[key] = pod
+	}
+	return pods
+}
+
+// Snapshot returns the state of the active pods and their container streams. It is cheap
+// enough to take on demand, and each stream's values are consistent on their own.
+func (s *streamerImpl) Snapshot() Snapshot {
+	snapshot := s.internal.Snapshot()
+	streams := make([]StreamSnapshot, len(snapshot.Streams))
+	for i, stream := range snapshot.Streams {
+		streams[i] = StreamSnapshot(stream)
+	}
+	return Snapshot{
+		Taken:      snapshot.Taken,
+		ActivePods: snapshot.ActivePods,
+		Streams:    streams,
+		Goroutines: snapshot.Goroutines,
+	}
+}
+
+// Summary returns the totals of the streamer's run, such as the lines and pods captured.
+// Once the streamer stopped they are final, e.g. for a capture tool to report on exit:
+//
+//	summary := streamer.Summary()
+//	fmt.Printf("captured %d lines from %d pods in %s\n", summary.Lines, summary.Pods, summary.Duration)
+func (s *streamerImpl) Summary() Summary {
+	return Summary(s.internal.Summary())
+}
+
+// MetricsHTTPHandler returns an http.Handler serving the metrics in Prometheus text format
+func (s *streamerImpl) MetricsHTTPHandler() http.Handler {
+	return NewMetricsHTTPHandler(s)
+}
+
+// Done returns a channel that is closed when the streamer stops
+func (s *streamerImpl) Done() <-chan struct{} {
+	return s.internal.Done()
+}
+
+// EndReason returns why the streamer ended, or EndReasonNone while it is running
+func (s *streamerImpl) EndReason() EndReason {
+	return s.internal.EndReason()
+}
+
 // convertFilter converts a public LogFilter to an internal filter
 func convertFilter(logFilter *LogFilter) (*filter.LogFilter, error) {
 	if logFilter == nil {
-		return nil, fmt.Errorf("log filter is required")
+		return nil, ErrNoFilter
 	}
 
 	f := &filter.LogFilter{
-		PodNameRegex:   logFilter.PodNameRegex,
-		ContainerRegex: logFilter.ContainerRegex,
-		LabelSelector:  logFilter.LabelSelector,
-		IncludeRegex:   logFilter.IncludeRegex,
-		Since:          logFilter.Since,
-		ContainerState: logFilter.ContainerState,
-		Namespaces:     logFilter.Namespaces,
+		PodNameRegex:        logFilter.PodNameRegex,
+		ContainerRegex:      logFilter.ContainerRegex,
+		LabelSelector:       logFilter.LabelSelector,
+		AdditionalSelectors: logFilter.AdditionalSelectors,
+		FieldSelector:       logFilter.FieldSelector,
+		IncludeRegex:        logFilter.IncludeRegex,
+		ExcludeRegex:        logFilter.ExcludeRegex,
+		ImageRegex:          logFilter.ImageRegex,
+		MinRestartCount:     logFilter.MinRestartCount,
+		Since:               logFilter.Since,
+		Until:               logFilter.Until,
+		TailLines:           logFilter.TailLines,
+		ContainerState:      logFilter.ContainerState,
+		Previous:            logFilter.Previous,
+		Namespaces:          logFilter.Namespaces,
+		AllNamespaces:       logFilter.AllNamespaces,
+		ExcludeNamespaces:   logFilter.ExcludeNamespaces,
 	}
 
 	// Set default container state if not specified
@@ -114,27 +380,68 @@ func convertFilter(logFilter *LogFilter) (*filter.LogFilter, error) {
 
 	// Validate the filter
 	if err := f.Validate(); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid log filter: %w", err)
 	}
 
 	return f, nil
 }
 
+// fromStreamMessage converts an internal stream message to a public LogMessage
+func fromStreamMessage(logMsg stream.LogMessage) LogMessage {
+	return LogMessage{
+		Namespace:     logMsg.Namespace,
+		PodName:       logMsg.PodName,
+		ContainerName: logMsg.ContainerName,
+		NodeName:      logMsg.NodeName,
+		StreamLabel:   logMsg.StreamLabel,
+		LineNumber:    logMsg.LineNumber,
+		Stream:        logMsg.Stream,
+		IsEvent:       logMsg.IsEvent,
+		Timestamp:     logMsg.Timestamp,
+		Message:       logMsg.Message,
+		Formatted:     logMsg.Formatted,
+		SinkLabels:    logMsg.SinkLabels,
+		PodLabels:     logMsg.PodLabels,
+		Historical:    logMsg.Historical,
+		Truncated:     logMsg.Truncated,
+		Exit:          fromStreamExit(logMsg.Exit),
+		Raw:           logMsg.Raw,
+	}
+}
+
+// fromStreamExit converts an internal container exit to the public type
+func fromStreamExit(exit *stream.ContainerExit) *ContainerExit {
+	if exit == nil {
+		return nil
+	}
+	return &ContainerExit{ExitCode: exit.ExitCode, Reason: exit.Reason}
+}
+
 // handlerWrapper adapts the public LogHandler to the stream.ExternalLogHandler interface
 type handlerWrapper struct {
 	handler LogHandler
+	// fallible is set when handler errors are routed to onWriteError or deadLetter
+	fallible     FallibleHandler
+	onWriteError func(error)
+	// deadLetter receives the messages fallible failed to write
+	deadLetter LogHandler
 }
 
 func (w *handlerWrapper) OnLog(msg interface{}) {
 	if logMsg, ok := msg.(stream.LogMessage); ok {
-		w.handler.OnLog(LogMessage{
-			Namespace:     logMsg.Namespace,
-			PodName:       logMsg.PodName,
-			ContainerName: logMsg.ContainerName,
-			Timestamp:     logMsg.Timestamp,
-			Message:       logMsg.Message,
-			Raw:           logMsg.Raw,
-		})
+		if w.fallible != nil {
+			message := fromStreamMessage(logMsg)
+			if err := w.fallible.WriteLog(message); err != nil {
+				if w.onWriteError != nil {
+					w.onWriteError(err)
+				}
+				if w.deadLetter != nil {
+					w.deadLetter.OnLog(message)
+				}
+			}
+			return
+		}
+		w.handler.OnLog(fromStreamMessage(logMsg))
 	}
 }
 
@@ -144,11 +451,53 @@ func (w *handlerWrapper) OnError(err error) {
 
 func (w *handlerWrapper) OnEnd() {
 	w.handler.OnEnd()
+	if w.deadLetter != nil {
+		w.deadLetter.OnEnd()
+	}
+}
+
+// rawHandlerWrapper adapts the public RawHandler to the stream.RawLogHandler interface
+type rawHandlerWrapper struct {
+	handler RawHandler
+}
+
+func (w *rawHandlerWrapper) OnRawLog(raw []byte, meta stream.LogMessage) {
+	w.handler.OnRawLog(raw, LogMetadata{
+		Namespace:     meta.Namespace,
+		PodName:       meta.PodName,
+		ContainerName: meta.ContainerName,
+		StreamLabel:   meta.StreamLabel,
+		LineNumber:    meta.LineNumber,
+		Stream:        meta.Stream,
+		IsEvent:       meta.IsEvent,
+		Timestamp:     meta.Timestamp,
+	})
 }
 
-// adaptHandler adapts the public LogHandler to the stream.ExternalLogHandler interface
-func adaptHandler(handler LogHandler) stream.ExternalLogHandler {
-	return &handlerWrapper{handler: handler}
+// batchHandlerWrapper adapts the public BatchHandler to the stream.BatchLogHandler interface
+type batchHandlerWrapper struct {
+	handler BatchHandler
+}
+
+func (w *batchHandlerWrapper) OnBatch(batch []stream.LogMessage) {
+	messages := make([]LogMessage, len(batch))
+	for i, msg := range batch {
+		messages[i] = fromStreamMessage(msg)
+	}
+	w.handler.OnBatch(messages)
+}
+
+// adaptHandler adapts the public LogHandler to the stream.ExternalLogHandler interface,
+// passing the write errors of a FallibleHandler to onWriteError and the messages it failed
+// to write to deadLetter, if they are set
+func adaptHandler(handler LogHandler, onWriteError func(error), deadLetter LogHandler) stream.ExternalLogHandler {
+	wrapper := &handlerWrapper{handler: handler}
+	if fallible, ok := handler.(FallibleHandler); ok && supports[FallibleHandler](handler) && (onWriteError != nil || deadLetter != nil) {
+		wrapper.fallible = fallible
+		wrapper.onWriteError = onWriteError
+		wrapper.deadLetter = deadLetter
+	}
+	return wrapper
 }
 
 // formatterWrapper adapts the public LogFormatter to the stream.ExternalLogFormatter interface
@@ -158,14 +507,7 @@ type formatterWrapper struct {
 
 func (w *formatterWrapper) Format(msg interface{}) string {
 	if logMsg, ok := msg.(stream.LogMessage); ok {
-		return w.formatter.Format(LogMessage{
-			Namespace:     logMsg.Namespace,
-			PodName:       logMsg.PodName,
-			ContainerName: logMsg.ContainerName,
-			Timestamp:     logMsg.Timestamp,
-			Message:       logMsg.Message,
-			Raw:           logMsg.Raw,
-		})
+		return w.formatter.Format(fromStreamMessage(logMsg))
 	}
 	return ""
 }
@@ -190,7 +532,7 @@ func adaptMatcher(matcher MultilineMatcher) stream.ExternalMatcher {
 }
 
 // Run is a convenience function that creates a streamer with the given options,
-// starts it, and waits for context completion
+// starts it, and waits for context completion or for WithMaxDuration to elapse
 func Run(ctx context.Context, options ...StreamOption) error {
 	streamer, err := NewStreamer(options...)
 	if err != nil {
@@ -202,8 +544,11 @@ func Run(ctx context.Context, options ...StreamOption) error {
 		return err
 	}
 
-	// Wait for context completion
-	<-ctx.Done()
+	// Wait for context completion or for the streamer to stop itself
+	select {
+	case <-ctx.Done():
+	case <-streamer.Done():
+	}
 
 	// Stop streaming
 	streamer.Stop()
@@ -252,6 +597,18 @@ func (b *StreamBuilder) WithNamespace(namespace string) *StreamBuilder {
 	return b
 }
 
+// WithAllNamespaces streams the pods of every namespace
+func (b *StreamBuilder) WithAllNamespaces() *StreamBuilder {
+	b.options = append(b.options, WithAllNamespaces())
+	return b
+}
+
+// WithExcludeNamespace never streams the pods of the namespaces
+func (b *StreamBuilder) WithExcludeNamespace(namespaces ...string) *StreamBuilder {
+	b.options = append(b.options, WithExcludeNamespace(namespaces...))
+	return b
+}
+
 // WithPodRegex adds a pod name regex to the log filter
 func (b *StreamBuilder) WithPodRegex(pattern string) *StreamBuilder {
 	b.options = append(b.options, WithPodRegex(pattern))
@@ -271,18 +628,87 @@ func (b *StreamBuilder) WithLabel(key, value string) *StreamBuilder {
 }
 
 // WithPodLabelSelector adds a label selector string to the log filter
-// The format is the same as kubectl's label selector (e.g., "app=myapp,env=prod")
+// The format is the same as kubectl's label selector (e.g., "app=myapp,env=prod").
+// A selector that can't be parsed makes Build fail with ErrInvalidLabelSelector.
 func (b *StreamBuilder) WithPodLabelSelector(selector string) *StreamBuilder {
 	b.options = append(b.options, WithLabelSelector(selector))
 	return b
 }
 
+// WithFieldSelector adds a field selector string to the log filter, such as
+// "spec.nodeName=worker-1". A selector that can't be parsed makes Build fail with
+// ErrInvalidFieldSelector.
+func (b *StreamBuilder) WithFieldSelector(selector string) *StreamBuilder {
+	b.options = append(b.options, WithFieldSelector(selector))
+	return b
+}
+
 // WithIncludeRegex adds an include regex to the log filter
 func (b *StreamBuilder) WithIncludeRegex(pattern string) *StreamBuilder {
 	b.options = append(b.options, WithIncludeRegex(pattern))
 	return b
 }
 
+// WithExcludeRegex adds an exclude regex to the log filter
+func (b *StreamBuilder) WithExcludeRegex(pattern string) *StreamBuilder {
+	b.options = append(b.options, WithExcludeRegex(pattern))
+	return b
+}
+
+// WithLinePrefix sets a template prepended to every log message
+func (b *StreamBuilder) WithLinePrefix(template string) *StreamBuilder {
+	b.options = append(b.options, WithLinePrefix(template))
+	return b
+}
+
+// WithStreamLabel sets a label stamped on every log message from this streamer
+func (b *StreamBuilder) WithStreamLabel(label string) *StreamBuilder {
+	b.options = append(b.options, WithStreamLabel(label))
+	return b
+}
+
+// WithImageRegex adds a container image regex to the log filter
+func (b *StreamBuilder) WithImageRegex(pattern string) *StreamBuilder {
+	b.options = append(b.options, WithImageRegex(pattern))
+	return b
+}
+
+// WithMinRestartCount only streams containers that restarted at least count times
+func (b *StreamBuilder) WithMinRestartCount(count int32) *StreamBuilder {
+	b.options = append(b.options, WithMinRestartCount(count))
+	return b
+}
+
+// WithSince sets the time to stream logs from, duration before now
+func (b *StreamBuilder) WithSince(duration time.Duration) *StreamBuilder {
+	b.options = append(b.options, WithSince(duration))
+	return b
+}
+
+// WithSinceTime sets the time to stream logs from to an absolute time
+func (b *StreamBuilder) WithSinceTime(t time.Time) *StreamBuilder {
+	b.options = append(b.options, WithSinceTime(t))
+	return b
+}
+
+// WithContainerState sets the container state filter ("all", "running" or "terminated")
+func (b *StreamBuilder) WithContainerState(state string) *StreamBuilder {
+	b.options = append(b.options, WithContainerState(state))
+	return b
+}
+
+// WithTailLines starts each container's log with only its most recent lines
+func (b *StreamBuilder) WithTailLines(lines int64) *StreamBuilder {
+	b.options = append(b.options, WithTailLines(lines))
+	return b
+}
+
+// WithPrevious streams the logs of the previous container instances, see WithPrevious
+func (b *StreamBuilder) WithPrevious(previous bool) *StreamBuilder {
+	b.options = append(b.options, WithPrevious(previous))
+	return b
+}
+
 // WithFormatter sets the log formatter
 func (b *StreamBuilder) WithFormatter(formatter LogFormatter) *StreamBuilder {
 	b.options = append(b.options, WithFormatter(formatter))
@@ -3,14 +3,35 @@ package klogstream
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"text/template"
 	"time"
 
+	"github.com/archsyscall/klogstream/internal/kube"
+	"github.com/archsyscall/klogstream/internal/stream"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
 )
 
+// withFakeClientset streams from a fake clientset, which WithClientset doesn't accept
+func withFakeClientset(clientset kubernetes.Interface) StreamOption {
+	return func(c *StreamConfig) {
+		c.KubeOptions = append(c.KubeOptions, kube.WithClientset(clientset))
+	}
+}
+
 // MockStreamer is a mock implementation of the Streamer interface for testing
 type MockStreamer struct {
 	StartCalled bool
@@ -32,6 +53,20 @@ func (m *MockStreamer) Stop() {
 	m.StopCalled = true
 }
 
+func (m *MockStreamer) Pause()                          {}
+func (m *MockStreamer) Resume()                         {}
+func (m *MockStreamer) MatchReport() MatchReport        { return MatchReport{} }
+func (m *MockStreamer) Metrics() Metrics                { return Metrics{} }
+func (m *MockStreamer) Stats() StreamerStats            { return StreamerStats{} }
+func (m *MockStreamer) PodStats() map[string]PodMetrics { return nil }
+func (m *MockStreamer) Snapshot() Snapshot              { return Snapshot{} }
+func (m *MockStreamer) Summary() Summary                { return Summary{} }
+func (m *MockStreamer) MetricsHTTPHandler() http.Handler {
+	return NewMetricsHTTPHandler(m)
+}
+func (m *MockStreamer) Done() <-chan struct{} { return nil }
+func (m *MockStreamer) EndReason() EndReason  { return EndReasonNone }
+
 // MockFactory is used to create mock streamers for testing
 type MockFactory struct {
 	CreateFunc func(options ...StreamOption) (Streamer, error)
@@ -79,6 +114,44 @@ func TestNewStreamer_NeedsHandler(t *testing.T) {
 	}
 }
 
+func TestNewStreamer_InvalidLabelSelector(t *testing.T) {
+	restConfig := &rest.Config{
+		Host: "https://test-server:8443",
+	}
+
+	for _, selector := range []string{"app in (web", "=web", "app==web=api"} {
+		_, err := NewStreamer(
+			WithRestConfig(restConfig),
+			WithNamespace("default"),
+			WithLabelSelector(selector),
+			WithHandler(NewConsoleHandler()),
+		)
+		if !errors.Is(err, ErrInvalidLabelSelector) {
+			t.Errorf("NewStreamer() with selector %q error = %v, want %v", selector, err, ErrInvalidLabelSelector)
+		}
+
+		_, err = NewBuilder().
+			WithRestConfig(restConfig).
+			WithNamespace("default").
+			WithPodLabelSelector(selector).
+			WithHandler(NewConsoleHandler()).
+			Build()
+		if !errors.Is(err, ErrInvalidLabelSelector) {
+			t.Errorf("Build() with selector %q error = %v, want %v", selector, err, ErrInvalidLabelSelector)
+		}
+	}
+
+	// A valid selector still builds
+	if _, err := NewStreamer(
+		WithRestConfig(restConfig),
+		WithNamespace("default"),
+		WithLabelSelector("app=web,tier in (frontend)"),
+		WithHandler(NewConsoleHandler()),
+	); err != nil {
+		t.Errorf("NewStreamer() with a valid selector error = %v", err)
+	}
+}
+
 func TestStreamBuilder(t *testing.T) {
 	origNewStreamer := NewStreamer
 	defer func() {
@@ -131,6 +204,44 @@ func TestStreamBuilder(t *testing.T) {
 	}
 }
 
+func TestStreamBuilder_SinceAndContainerState(t *testing.T) {
+	origNewStreamer := NewStreamer
+	defer func() {
+		NewStreamer = origNewStreamer
+	}()
+
+	var config *StreamConfig
+	mockFactory := &MockFactory{
+		CreateFunc: func(options ...StreamOption) (Streamer, error) {
+			config = NewStreamConfig()
+			for _, option := range options {
+				option(config)
+			}
+			return &MockStreamer{}, nil
+		},
+	}
+
+	NewStreamer = mockFactory.NewStreamer
+
+	before := time.Now()
+	_, err := NewBuilder().
+		WithNamespace("default").
+		WithSince(10 * time.Minute).
+		WithContainerState("running").
+		Build()
+	if err != nil {
+		t.Fatalf("Builder.Build() error = %v", err)
+	}
+
+	want := before.Add(-10 * time.Minute)
+	if since := config.Filter.Since; since == nil || since.Before(want) || since.After(time.Now().Add(-10*time.Minute)) {
+		t.Errorf("Filter.Since = %v, want 10 minutes before the build", since)
+	}
+	if config.Filter.ContainerState != "running" {
+		t.Errorf("Filter.ContainerState = %q, want %q", config.Filter.ContainerState, "running")
+	}
+}
+
 func TestRun(t *testing.T) {
 	origNewStreamer := NewStreamer
 	defer func() {
@@ -170,6 +281,312 @@ func TestRun(t *testing.T) {
 	}
 }
 
+// doneStreamer is a MockStreamer that stops by itself
+type doneStreamer struct {
+	MockStreamer
+	done chan struct{}
+}
+
+func (m *doneStreamer) Done() <-chan struct{} { return m.done }
+
+func TestRun_ReturnsWhenStreamerStops(t *testing.T) {
+	origNewStreamer := NewStreamer
+	defer func() {
+		NewStreamer = origNewStreamer
+	}()
+
+	mockStreamer := &doneStreamer{done: make(chan struct{})}
+	NewStreamer = func(options ...StreamOption) (Streamer, error) {
+		return mockStreamer, nil
+	}
+
+	// The streamer ends its capture window on its own
+	close(mockStreamer.done)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(context.Background(), WithMaxDuration(time.Minute))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the streamer stopped")
+	}
+
+	if !mockStreamer.StopCalled {
+		t.Error("Run(): Streamer.Stop() not called")
+	}
+}
+
+// lifecycleHandler records the lifecycle calls it receives
+type lifecycleHandler struct {
+	mu       sync.Mutex
+	calls    []string
+	startErr error
+}
+
+func (h *lifecycleHandler) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func (h *lifecycleHandler) Start(ctx context.Context) error {
+	h.record("start")
+	return h.startErr
+}
+
+func (h *lifecycleHandler) OnLog(msg LogMessage) { h.record("log") }
+func (h *lifecycleHandler) OnError(err error)    {}
+func (h *lifecycleHandler) OnEnd()               { h.record("end") }
+
+func (h *lifecycleHandler) Close() error {
+	h.record("close")
+	return nil
+}
+
+func TestStreamer_HandlerLifecycle(t *testing.T) {
+	handler := &lifecycleHandler{}
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset()),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(handler),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	streamer.Stop()
+
+	if got := fmt.Sprint(handler.calls); got != "[start end close]" {
+		t.Errorf("handler calls = %s, want [start end close]", got)
+	}
+
+	// Routed handlers are started and closed through the router
+	routed := &lifecycleHandler{}
+	streamer, err = NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset()),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithContainerHandler("^app$", routed),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	streamer.Stop()
+
+	if got := fmt.Sprint(routed.calls); got != "[start end close]" {
+		t.Errorf("routed handler calls = %s, want [start end close]", got)
+	}
+}
+
+// streamEventsHandler records the stream lifecycle calls it receives
+type streamEventsHandler struct {
+	discardHandler
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *streamEventsHandler) OnStreamStart(namespace, pod, container string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, "start "+namespace+"/"+pod+"/"+container)
+}
+
+func (h *streamEventsHandler) OnStreamEnd(namespace, pod, container string, reason error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, fmt.Sprintf("end %s/%s/%s: %v", namespace, pod, container, reason))
+}
+
+func TestStreamer_StreamLifecycleHandler(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	// The hooks reach the handler through the wrapping handlers too
+	handler := &streamEventsHandler{}
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset(pod)),
+		WithNamespace("default"),
+		WithHandler(NewSynchronizedHandler(NewMultiHandler(handler))),
+		// The previous log is read once, so the stream ends by itself
+		WithPrevious(true),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer streamer.Stop()
+
+	want := "[start default/web-1/app end default/web-1/app: <nil>]"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		handler.mu.Lock()
+		got := fmt.Sprint(handler.events)
+		handler.mu.Unlock()
+		if got == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream events = %s, want %s", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamer_HandlerStartError(t *testing.T) {
+	startErr := errors.New("connection refused")
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset()),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(&lifecycleHandler{startErr: startErr}),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	if err := streamer.Start(context.Background()); !errors.Is(err, startErr) {
+		t.Errorf("Start() error = %v, want %v", err, startErr)
+	}
+}
+
+// failingCheckpointStore fails to load the checkpoints
+type failingCheckpointStore struct{}
+
+func (failingCheckpointStore) Load() (map[CheckpointKey]time.Time, error) {
+	return nil, errors.New("checkpoint file is corrupt")
+}
+
+func (failingCheckpointStore) Save(map[CheckpointKey]time.Time) error { return nil }
+
+func TestStreamer_StartFailureEndsStartedHandler(t *testing.T) {
+	handler := &lifecycleHandler{}
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset()),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(handler),
+		WithCheckpointStore(failingCheckpointStore{}, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	if err := streamer.Start(context.Background()); err == nil {
+		t.Fatal("Start() error = nil, want the checkpoint load error")
+	}
+
+	// The started handler is ended and closed once, a later Stop doesn't do it again
+	want := "[start end close]"
+	handler.mu.Lock()
+	got := fmt.Sprint(handler.calls)
+	handler.mu.Unlock()
+	if got != want {
+		t.Errorf("handler calls after failed Start = %s, want %s", got, want)
+	}
+
+	streamer.Stop()
+	handler.mu.Lock()
+	got = fmt.Sprint(handler.calls)
+	handler.mu.Unlock()
+	if got != want {
+		t.Errorf("handler calls after Stop = %s, want %s", got, want)
+	}
+}
+
+// labelHandler counts messages per stream label and is safe for concurrent use
+type labelHandler struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (h *labelHandler) OnLog(message LogMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.counts == nil {
+		h.counts = make(map[string]int)
+	}
+	h.counts[message.StreamLabel]++
+}
+
+func (h *labelHandler) OnError(err error) {}
+
+func (h *labelHandler) OnEnd() {}
+
+// labels returns the stream labels seen so far
+func (h *labelHandler) labels() map[string]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	labels := make(map[string]bool)
+	for label := range h.counts {
+		labels[label] = true
+	}
+	return labels
+}
+
+func TestStreamLabel_MultipleStreamersOneHandler(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app:latest"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	clientset := fake.NewSimpleClientset(pod)
+	handler := &labelHandler{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var streamers []Streamer
+	for _, label := range []string{"errors-only", "all"} {
+		streamer, err := NewStreamer(
+			withFakeClientset(clientset),
+			WithNamespace("default"),
+			WithStreamLabel(label),
+			WithHandler(handler),
+		)
+		if err != nil {
+			t.Fatalf("NewStreamer() error = %v", err)
+		}
+		if err := streamer.Start(ctx); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		streamers = append(streamers, streamer)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		labels := handler.labels()
+		if labels["errors-only"] && labels["all"] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream labels = %v, want errors-only and all", labels)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, streamer := range streamers {
+		streamer.Stop()
+	}
+
+	if labels := handler.labels(); len(labels) != 2 {
+		t.Errorf("expected messages labelled by exactly two streamers, got %v", labels)
+	}
+}
+
 func TestBuilderRun(t *testing.T) {
 	origNewStreamer := NewStreamer
 	defer func() {
@@ -370,3 +787,386 @@ func TestClientCreation(t *testing.T) {
 		t.Errorf("WithRestConfig() did not add kube option")
 	}
 }
+
+// failingHandler is a FallibleHandler whose writes fail
+type failingHandler struct {
+	err    error
+	logged int
+	errors []error
+}
+
+func (h *failingHandler) OnLog(LogMessage) { h.logged++ }
+
+func (h *failingHandler) WriteLog(LogMessage) error { return h.err }
+
+func (h *failingHandler) OnError(err error) { h.errors = append(h.errors, err) }
+
+func (h *failingHandler) OnEnd() {}
+
+func TestHandlerErrorCallback(t *testing.T) {
+	writeErr := errors.New("disk full")
+	msg := stream.LogMessage{PodName: "web-1", Message: "hello"}
+
+	// Write errors go to the callback, not to OnError
+	handler := &failingHandler{err: writeErr}
+	var callbackErrs []error
+	adaptHandler(handler, func(err error) { callbackErrs = append(callbackErrs, err) }, nil).OnLog(msg)
+
+	if len(callbackErrs) != 1 || !errors.Is(callbackErrs[0], writeErr) {
+		t.Errorf("callback received %v, want %v", callbackErrs, writeErr)
+	}
+	if len(handler.errors) != 0 || handler.logged != 0 {
+		t.Errorf("handler got OnError %v and %d OnLog calls, want only WriteLog", handler.errors, handler.logged)
+	}
+
+	// Without a callback the handler is called through OnLog as before
+	handler = &failingHandler{err: writeErr}
+	adaptHandler(handler, nil, nil).OnLog(msg)
+	if handler.logged != 1 {
+		t.Errorf("handler got %d OnLog calls without a callback, want 1", handler.logged)
+	}
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	msg := stream.LogMessage{PodName: "web-1", Message: "hello"}
+
+	// A message the handler rejects goes to the dead-letter handler
+	deadLetter := NewChannelHandler(1)
+	handler := &failingHandler{err: errors.New("sink unavailable")}
+	wrapper := adaptHandler(handler, nil, deadLetter)
+	wrapper.OnLog(msg)
+
+	select {
+	case got := <-deadLetter.Messages():
+		if got.PodName != "web-1" || got.Message != "hello" {
+			t.Errorf("dead-letter handler received %+v, want the rejected message", got)
+		}
+	default:
+		t.Fatal("rejected message was not passed to the dead-letter handler")
+	}
+
+	// Messages the handler accepts don't
+	handler.err = nil
+	wrapper.OnLog(msg)
+	select {
+	case got := <-deadLetter.Messages():
+		t.Errorf("dead-letter handler received accepted message %+v", got)
+	default:
+	}
+
+	// The dead-letter handler ends with the handler
+	wrapper.OnEnd()
+	if _, ok := <-deadLetter.Messages(); ok {
+		t.Error("dead-letter handler was not ended")
+	}
+}
+
+// templateHandler formats messages itself, like the handler of examples/custom
+type templateHandler struct {
+	tmpl *template.Template
+	out  bytes.Buffer
+}
+
+func (h *templateHandler) OnLog(msg LogMessage) { _ = h.tmpl.Execute(&h.out, msg) }
+func (h *templateHandler) OnError(err error)    {}
+func (h *templateHandler) OnEnd()               {}
+
+func TestFormattingHandler_SeesOriginalMessage(t *testing.T) {
+	handler := &templateHandler{tmpl: template.Must(template.New("line").Parse("{{.PodName}}: {{.Message}} | {{.Formatted}}"))}
+
+	// The streamer sets Formatted and leaves Message as read from the container
+	msg := stream.LogMessage{PodName: "web-1", Message: "hello", Formatted: `{"message":"hello"}`}
+	adaptHandler(handler, nil, nil).OnLog(msg)
+
+	want := `web-1: hello | {"message":"hello"}`
+	if got := handler.out.String(); got != want {
+		t.Errorf("handler wrote %q, want %q", got, want)
+	}
+}
+
+func TestArchiveHandler_WriteLogError(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewArchiveHandler(ArchiveConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewArchiveHandler() error = %v", err)
+	}
+
+	// The archive can't be created once its directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var _ FallibleHandler = h
+	if err := h.WriteLog(LogMessage{Message: "lost"}); err == nil {
+		t.Error("WriteLog() succeeded without an archive directory")
+	}
+}
+
+func TestFileHandler_ReopenOnSignal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h, err := NewFileHandler(path, FileHandlerOptions{})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error = %v", err)
+	}
+	var _ FallibleHandler = h
+	stop := h.ReopenOnSignal(syscall.SIGHUP)
+	defer stop()
+
+	h.OnLog(LogMessage{Message: "before"})
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is recreated once the signal was handled
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("file was not reopened after SIGHUP")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	h.OnLog(LogMessage{Message: "after"})
+	h.OnEnd()
+	for file, want := range map[string]string{path + ".1": "before\n", path: "after\n"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, want)
+		}
+	}
+}
+
+// overlapDetectingHandler counts the calls that entered while another call was running
+type overlapDetectingHandler struct {
+	inside   atomic.Int32
+	overlaps atomic.Int32
+	calls    int
+}
+
+func (h *overlapDetectingHandler) enter() {
+	if h.inside.Add(1) > 1 {
+		h.overlaps.Add(1)
+	}
+	h.calls++
+	time.Sleep(10 * time.Microsecond)
+	h.inside.Add(-1)
+}
+
+func (h *overlapDetectingHandler) OnLog(LogMessage) { h.enter() }
+func (h *overlapDetectingHandler) OnError(error)    { h.enter() }
+func (h *overlapDetectingHandler) OnEnd()           { h.enter() }
+
+func TestSynchronizedHandler(t *testing.T) {
+	handler := &overlapDetectingHandler{}
+	config := NewStreamConfig()
+	WithSynchronizedHandler(handler)(config)
+
+	// Call the handler from many goroutines, as the container streams do
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				config.Handler.OnLog(LogMessage{Message: "hello"})
+				config.Handler.OnError(errors.New("stream failed"))
+			}
+		}()
+	}
+	wg.Wait()
+	config.Handler.OnEnd()
+
+	if n := handler.overlaps.Load(); n != 0 {
+		t.Errorf("%d calls overlapped", n)
+	}
+	if handler.calls != 8*50*2+1 {
+		t.Errorf("handler got %d calls, want %d", handler.calls, 8*50*2+1)
+	}
+}
+
+// capableHandler implements every optional handler interface, counting the calls
+type capableHandler struct {
+	overlapDetectingHandler
+	writes, batches, raws, checks int
+}
+
+func (h *capableHandler) WriteLog(LogMessage) error    { h.writes++; return errors.New("sink down") }
+func (h *capableHandler) OnBatch([]LogMessage)         { h.batches++ }
+func (h *capableHandler) OnRawLog([]byte, LogMetadata) { h.raws++ }
+func (h *capableHandler) HealthCheck() error           { h.checks++; return nil }
+
+func TestSynchronizedHandler_ForwardsOptionalInterfaces(t *testing.T) {
+	capable := &capableHandler{}
+	var handler LogHandler = NewSynchronizedHandler(capable)
+
+	if err := handler.(FallibleHandler).WriteLog(LogMessage{}); err == nil {
+		t.Error("WriteLog() error = nil, want the wrapped handler's error")
+	}
+	handler.(BatchHandler).OnBatch([]LogMessage{{}})
+	handler.(RawHandler).OnRawLog([]byte("raw"), LogMetadata{})
+	_ = handler.(HealthCheckable).HealthCheck()
+	if capable.writes != 1 || capable.batches != 1 || capable.raws != 1 || capable.checks != 1 {
+		t.Errorf("wrapped handler got %+v, want one call of each", capable)
+	}
+	if !supports[FallibleHandler](handler) || !supports[BatchHandler](handler) ||
+		!supports[RawHandler](handler) || !supports[HealthCheckable](handler) {
+		t.Error("supports() = false for an interface of the wrapped handler")
+	}
+
+	// Write errors reach the callback through the wrapper
+	var writeErrors int
+	adaptHandler(handler, func(error) { writeErrors++ }, nil).OnLog(stream.LogMessage{})
+	if writeErrors != 1 {
+		t.Errorf("write error callback called %d times, want 1", writeErrors)
+	}
+
+	// The interfaces a plain handler doesn't implement are not used by the streamer, and
+	// fall back to OnLog when called anyway
+	plain := &overlapDetectingHandler{}
+	handler = NewSynchronizedHandler(plain)
+	if supports[FallibleHandler](handler) || supports[BatchHandler](handler) ||
+		supports[RawHandler](handler) || supports[HealthCheckable](handler) {
+		t.Error("supports() = true for an interface the wrapped handler lacks")
+	}
+	handler.(BatchHandler).OnBatch([]LogMessage{{}, {}})
+	if err := handler.(FallibleHandler).WriteLog(LogMessage{}); err != nil {
+		t.Errorf("WriteLog() error = %v for a handler that can't fail", err)
+	}
+	if plain.calls != 3 {
+		t.Errorf("wrapped handler got %d OnLog calls, want 3", plain.calls)
+	}
+}
+
+func TestChannelHandler(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	handler := NewChannelHandler(10)
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset(pod)),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(handler),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	select {
+	case msg := <-handler.Messages():
+		if msg.PodName != "web-1" || msg.Message != "fake logs" {
+			t.Errorf("received %+v, want the pod's log line", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message arrived on the channel")
+	}
+
+	// Stop ends the stream, the channel is read until it is closed
+	go streamer.Stop()
+	closed := make(chan struct{})
+	go func() {
+		for range handler.Messages() {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("messages channel was not closed on stop")
+	}
+	// The errors channel is closed too, after any buffered errors
+	for range handler.Errors() {
+	}
+
+	// Calls after the end are ignored
+	handler.OnLog(LogMessage{Message: "late"})
+	handler.OnError(errors.New("late"))
+}
+
+func TestChannelHandler_EndWithStalledConsumer(t *testing.T) {
+	handler := NewChannelHandler(1)
+	handler.OnLog(LogMessage{Message: "buffered"})
+
+	// Nobody reads, the next message waits for room
+	sent := make(chan struct{})
+	go func() {
+		handler.OnLog(LogMessage{Message: "waiting"})
+		close(sent)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// OnEnd doesn't wait for the consumer and releases the waiting call
+	ended := make(chan struct{})
+	go func() {
+		handler.OnEnd()
+		close(ended)
+	}()
+	for _, done := range []chan struct{}{ended, sent} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnEnd blocked behind a stalled consumer")
+		}
+	}
+
+	var got []string
+	for msg := range handler.Messages() {
+		got = append(got, msg.Message)
+	}
+	if len(got) != 1 || got[0] != "buffered" {
+		t.Errorf("received %q, want only the buffered message", got)
+	}
+}
+
+// unhealthyHandler is a handler whose sink is down
+type unhealthyHandler struct {
+	lifecycleHandler
+}
+
+func (h *unhealthyHandler) HealthCheck() error { return errors.New("connection refused") }
+
+func TestSinkHealthCheck(t *testing.T) {
+	unhealthy := make(chan error, 1)
+	streamer, err := NewStreamer(
+		withFakeClientset(fake.NewSimpleClientset()),
+		WithFilter(&LogFilter{Namespaces: []string{"default"}}),
+		WithHandler(&unhealthyHandler{}),
+		WithSinkHealthCheck(5*time.Millisecond),
+		WithOnSinkUnhealthy(2, func(err error) {
+			select {
+			case unhealthy <- err:
+			default:
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+	if err := streamer.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer streamer.Stop()
+
+	select {
+	case err := <-unhealthy:
+		if err == nil || err.Error() != "connection refused" {
+			t.Errorf("callback error = %v, want the health check's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unhealthy sink was not reported")
+	}
+}